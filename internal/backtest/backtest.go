@@ -0,0 +1,165 @@
+// Package backtest replays historical candles through a strategy, the same one that
+// runs live, and trades its signals
+package backtest
+
+import (
+	"errors"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/strategy"
+)
+
+var ErrNoSignals = errors.New("strategy doesn't raise signals")
+
+// Options for a run
+type Options struct {
+	Token    uint32 // stamped on the replayed ticks
+	Quantity int    // units per trade, 1 when 0
+	Short    bool   // SELL signals open shorts when flat
+
+	// Charges of one fill, side is BUY or SELL, nil trades free
+	Charges func(side string, quantity int, price float64) float64
+}
+
+// Trade is one round trip
+type Trade struct {
+	Side       string    `json:"side"` // BUY for a long, SELL for a short
+	Quantity   int       `json:"quantity"`
+	EntryTime  time.Time `json:"entry_time"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitTime   time.Time `json:"exit_time"`
+	ExitPrice  float64   `json:"exit_price"`
+	Charges    float64   `json:"charges"`
+	PnL        float64   `json:"pnl"` // net of charges
+}
+
+// Result of a run, a position still open at the end is closed at the last close
+type Result struct {
+	Candles     int               `json:"candles"`
+	Signals     []strategy.Signal `json:"signals"`
+	Trades      []Trade           `json:"trades"`
+	Charges     float64           `json:"charges"`
+	NetPnL      float64           `json:"net_pnl"`
+	WinRate     float64           `json:"win_rate"`     // fraction of trades with a profit
+	MaxDrawdown float64           `json:"max_drawdown"` // deepest fall of closed trade equity from its peak, in rupees
+}
+
+// Tick is the tick a strategy sees for a candle. Strategies running tick by tick
+// read the candle from OHLC, the same way they read a live tick, and Bars of a
+// longer timeframe fold the whole candle in.
+func Tick(token uint32, c strategy.Candle) kitemodels.Tick {
+	tick := kitemodels.Tick{
+		Mode:            strategy.ModeCandle,
+		InstrumentToken: token,
+		LastPrice:       c.Close,
+		OHLC:            kitemodels.OHLC{Open: c.Open, High: c.High, Low: c.Low, Close: c.Close},
+	}
+	tick.Timestamp.Time = c.Timestamp
+	return tick
+}
+
+// Run feeds st one tick per candle and fills every signal at its price
+func Run(st strategy.Strategy, candles []strategy.Candle, opts Options) (*Result, error) {
+	signaller, ok := st.(strategy.Signaller)
+	if !ok {
+		return nil, ErrNoSignals
+	}
+	if opts.Quantity <= 0 {
+		opts.Quantity = 1
+	}
+
+	b := &book{opts: opts}
+	res := &Result{Candles: len(candles), Signals: []strategy.Signal{}}
+	for _, c := range candles {
+		tick := Tick(opts.Token, c)
+		st.OnTick(tick)
+		for _, sig := range signaller.Signals() {
+			if sig.Token == 0 {
+				sig.Token = tick.InstrumentToken
+			}
+			if sig.Timestamp.IsZero() {
+				sig.Timestamp = c.Timestamp
+			}
+			res.Signals = append(res.Signals, sig)
+			b.signal(sig)
+		}
+	}
+	if len(candles) > 0 {
+		last := candles[len(candles)-1]
+		b.close(last.Timestamp, last.Close)
+	}
+
+	res.Trades = b.trades
+	if res.Trades == nil {
+		res.Trades = []Trade{}
+	}
+	for _, t := range res.Trades {
+		res.Charges += t.Charges
+	}
+	res.NetPnL, res.WinRate, res.MaxDrawdown = Summarise(res.Trades)
+	return res, nil
+}
+
+// Summarise works out net P&L, win rate and max drawdown of trades in order
+func Summarise(trades []Trade) (net, winRate, maxDrawdown float64) {
+	wins, peak := 0, 0.0
+	for _, t := range trades {
+		net += t.PnL
+		if t.PnL > 0 {
+			wins++
+		}
+		peak = max(peak, net)
+		maxDrawdown = max(maxDrawdown, peak-net)
+	}
+	if len(trades) > 0 {
+		winRate = float64(wins) / float64(len(trades))
+	}
+	return net, winRate, maxDrawdown
+}
+
+// book holds at most one position, opposite signals close it
+type book struct {
+	opts   Options
+	open   *Trade
+	trades []Trade
+}
+
+func (b *book) signal(sig strategy.Signal) {
+	if b.open != nil {
+		if b.open.Side == sig.Side {
+			return
+		}
+		b.close(sig.Timestamp, sig.Price)
+		if !b.opts.Short {
+			return
+		}
+	}
+	if sig.Side == "SELL" && !b.opts.Short {
+		return
+	}
+	b.open = &Trade{Side: sig.Side, Quantity: b.opts.Quantity, EntryTime: sig.Timestamp, EntryPrice: sig.Price}
+}
+
+func (b *book) close(at time.Time, price float64) {
+	if b.open == nil {
+		return
+	}
+	t := *b.open
+	t.ExitTime, t.ExitPrice = at, price
+	t.PnL = (price - t.EntryPrice) * float64(t.Quantity)
+	if t.Side == "SELL" {
+		t.PnL = -t.PnL
+	}
+	if b.opts.Charges != nil {
+		exit := "SELL"
+		if t.Side == "SELL" {
+			exit = "BUY"
+		}
+		t.Charges = b.opts.Charges(t.Side, t.Quantity, t.EntryPrice) + b.opts.Charges(exit, t.Quantity, price)
+		t.PnL -= t.Charges
+	}
+	b.trades = append(b.trades, t)
+	b.open = nil
+}
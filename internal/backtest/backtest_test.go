@@ -0,0 +1,222 @@
+package backtest_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/backtest"
+	"friction-trading/internal/strategy"
+)
+
+// scripted signals on fixed candles, by index
+type scripted struct {
+	n     int
+	sides map[int]string
+	out   []strategy.Signal
+}
+
+func (s *scripted) OnTick(tick kitemodels.Tick) {
+	if side, ok := s.sides[s.n]; ok {
+		s.out = append(s.out, strategy.Signal{Side: side, Price: tick.LastPrice})
+	}
+	s.n++
+}
+
+func (s *scripted) Signals() []strategy.Signal {
+	out := s.out
+	s.out = nil
+	return out
+}
+
+type silent struct{}
+
+func (silent) OnTick(kitemodels.Tick) {}
+
+func candles(closes ...float64) []strategy.Candle {
+	start := time.Date(2025, 10, 20, 9, 15, 0, 0, time.UTC)
+	out := make([]strategy.Candle, len(closes))
+	for i, c := range closes {
+		out[i] = strategy.Candle{Timestamp: start.Add(time.Duration(i) * 5 * time.Minute), Open: c, High: c, Low: c, Close: c, LastPrice: c}
+	}
+	return out
+}
+
+func TestRun(t *testing.T) {
+	prices := candles(100, 110, 105, 95, 100, 90)
+	sides := map[int]string{0: "BUY", 1: "SELL", 2: "BUY", 3: "SELL", 4: "SELL", 5: "BUY"}
+
+	long, err := backtest.Run(&scripted{sides: sides}, prices, backtest.Options{Token: 7, Quantity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// +100 then -100, the SELLs when flat and the last BUY's open long at 90 add nothing
+	if len(long.Trades) != 3 || long.NetPnL != 0 || long.WinRate != 1.0/3 || long.MaxDrawdown != 100 {
+		t.Errorf("unexpected long only result %+v", long)
+	}
+	if long.Signals[0].Token != 7 || !long.Signals[0].Timestamp.Equal(prices[0].Timestamp) {
+		t.Errorf("want signals stamped with the token and candle time, got %+v", long.Signals[0])
+	}
+
+	both, _ := backtest.Run(&scripted{sides: sides}, prices, backtest.Options{Quantity: 10, Short: true})
+	// Long 100-110, short 110-105, long 105-95, short 95-90, the last BUY flips long at 90
+	if len(both.Trades) != 5 || both.NetPnL != 100+50-100+50 || both.Trades[1].Side != "SELL" {
+		t.Errorf("unexpected long and short result %+v", both.Trades)
+	}
+
+	// A rupee a fill, two fills a trade
+	perFill := func(side string, quantity int, price float64) float64 { return 1 }
+	charged, _ := backtest.Run(&scripted{sides: sides}, prices, backtest.Options{Quantity: 10, Charges: perFill})
+	if charged.Charges != 6 || charged.NetPnL != -6 || charged.Trades[0].Charges != 2 || charged.Trades[0].PnL != 98 {
+		t.Errorf("want 2 charged on each of 3 trades, got %+v", charged)
+	}
+
+	if _, err := backtest.Run(silent{}, prices, backtest.Options{}); !errors.Is(err, backtest.ErrNoSignals) {
+		t.Errorf("want ErrNoSignals, got %v", err)
+	}
+}
+
+// band buys at or below lo and sells at or above hi
+type band struct {
+	lo, hi float64
+	out    []strategy.Signal
+}
+
+func newBand(p strategy.Params) (strategy.Strategy, error) {
+	if p["lo"] >= p["hi"] {
+		return nil, errors.New("lo must be below hi")
+	}
+	return &band{lo: p["lo"], hi: p["hi"]}, nil
+}
+
+func (b *band) OnTick(tick kitemodels.Tick) {
+	switch {
+	case tick.LastPrice <= b.lo:
+		b.out = append(b.out, strategy.Signal{Side: "BUY", Price: tick.LastPrice})
+	case tick.LastPrice >= b.hi:
+		b.out = append(b.out, strategy.Signal{Side: "SELL", Price: tick.LastPrice})
+	}
+}
+
+func (b *band) Signals() []strategy.Signal {
+	out := b.out
+	b.out = nil
+	return out
+}
+
+func TestOptimise(t *testing.T) {
+	var closes []float64
+	for range 10 {
+		closes = append(closes, 90, 100, 110, 100)
+	}
+	prices := candles(closes...)
+	space := backtest.Space{"lo": {Min: 90, Max: 100, Step: 10}, "hi": {Min: 100, Max: 110, Step: 10}}
+
+	opt, err := backtest.Optimise(newBand, prices, space, backtest.Search{Windows: 3, Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opt.Results) != 4 || len(opt.Windows) != 3 {
+		t.Fatalf("want 4 sets over 3 windows, got %d and %d", len(opt.Results), len(opt.Windows))
+	}
+	// Buying 90 and selling 110 wins, lo 100 buys the same candles but comes later
+	best := opt.Results[0]
+	if best.Rank != 1 || best.Params["hi"] != 110 || best.Params["lo"] != 90 || best.OutOfSample.NetPnL <= 0 || best.OutOfSample.Score != best.OutOfSample.NetPnL {
+		t.Errorf("want lo 90 hi 110 ranked first, got %+v", best)
+	}
+	if last := opt.Results[3]; last.Error == "" || last.Params["lo"] != 100 || last.Params["hi"] != 100 {
+		t.Errorf("want the rejected set ranked last, got %+v", last)
+	}
+	for i, w := range opt.Windows {
+		if w.Best["hi"] != 110 || w.OutOfSample.Trades == 0 || !w.OutFrom.After(w.InFrom) {
+			t.Errorf("window %d: unexpected %+v", i, w)
+		}
+		// Out-of-sample parts follow each other
+		if i > 0 && !w.OutFrom.After(opt.Windows[i-1].OutTo) {
+			t.Errorf("window %d starts out of sample at %s, before %s", i, w.OutFrom, opt.Windows[i-1].OutTo)
+		}
+	}
+	if opt.WalkForward.NetPnL <= 0 || opt.Efficiency <= 0 || !opt.Windows[2].OutTo.Equal(prices[len(prices)-1].Timestamp) {
+		t.Errorf("unexpected walk forward %+v, efficiency %.2f", opt.WalkForward, opt.Efficiency)
+	}
+
+	// Random search is repeatable from its seed
+	random := backtest.Search{Method: backtest.MethodRandom, Samples: 5, Seed: 42, Metric: backtest.MetricRecovery}
+	a, err := backtest.Optimise(newBand, prices, space, random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := backtest.Optimise(newBand, prices, space, random)
+	if len(a.Results) != 5 || a.Seed != 42 || !reflect.DeepEqual(a.Results, b.Results) {
+		t.Errorf("want the same 5 sets from seed 42, got %+v and %+v", a.Results, b.Results)
+	}
+
+	for name, tc := range map[string]struct {
+		space  backtest.Space
+		search backtest.Search
+	}{
+		"no step":     {backtest.Space{"lo": {Min: 90, Max: 100}}, backtest.Search{}},
+		"no params":   {nil, backtest.Search{}},
+		"metric":      {space, backtest.Search{Metric: "sharpe"}},
+		"in sample":   {space, backtest.Search{InSample: 1.5}},
+		"few candles": {space, backtest.Search{Windows: 40}},
+		"too many":    {backtest.Space{"lo": {Min: 0, Max: 1000, Step: 1}, "hi": {Min: 0, Max: 1000, Step: 1}}, backtest.Search{}},
+	} {
+		if _, err := backtest.Optimise(newBand, prices, tc.space, tc.search); !errors.Is(err, backtest.ErrSearch) {
+			t.Errorf("%s: want ErrSearch, got %v", name, err)
+		}
+	}
+	silentFactory := func(strategy.Params) (strategy.Strategy, error) { return silent{}, nil }
+	if _, err := backtest.Optimise(silentFactory, prices, space, backtest.Search{}); !errors.Is(err, backtest.ErrNoSignals) {
+		t.Errorf("want ErrNoSignals, got %v", err)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	var trades []backtest.Trade
+	for _, pnl := range []float64{100, -50, 100, -50} {
+		trades = append(trades, backtest.Trade{Side: "BUY", Quantity: 1, PnL: pnl})
+	}
+
+	// Shuffling keeps the final equity, only the path and its drawdown change
+	shuffled, err := backtest.Simulate(trades, backtest.MonteCarlo{Method: backtest.MethodShuffle, Simulations: 200, Seed: 1, Capital: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shuffled.Equity != 1100 || shuffled.Drawdown != 50 || shuffled.FinalEquity.Min != 1100 || shuffled.FinalEquity.Max != 1100 || shuffled.Percentile != 0 {
+		t.Errorf("want every shuffle to end at 1100, got %+v", shuffled)
+	}
+	if shuffled.MaxDrawdown.Min != 50 || shuffled.MaxDrawdown.Max != 100 || shuffled.RiskOfRuin != 0 {
+		t.Errorf("want drawdowns from 50 to 100 and no ruin, got %+v", shuffled.MaxDrawdown)
+	}
+
+	// Slippage always costs
+	slipped, _ := backtest.Simulate(trades, backtest.MonteCarlo{Method: backtest.MethodShuffle, Simulations: 200, Seed: 1, Capital: 1000, Slippage: 5})
+	if slipped.FinalEquity.Max >= 1100 || slipped.Percentile != 1 {
+		t.Errorf("want every path below 1100 with slippage, got %+v", slipped.FinalEquity)
+	}
+
+	// Two losses first lose 10%, a quarter of bootstrap paths at least
+	mc := backtest.MonteCarlo{Simulations: 1000, Seed: 7, Capital: 1000, Ruin: 0.1}
+	boot, _ := backtest.Simulate(trades, mc)
+	again, _ := backtest.Simulate(trades, mc)
+	if boot.Method != backtest.MethodBootstrap || boot.RiskOfRuin < 0.25 || boot.RiskOfRuin >= 1 || boot.LossChance == 0 {
+		t.Errorf("unexpected bootstrap %+v", boot)
+	}
+	d := boot.FinalEquity
+	if d.Min < 800 || d.Max > 1400 || d.P5 > d.P25 || d.P25 > d.Median || d.Median > d.P75 || d.P75 > d.P95 || *again != *boot {
+		t.Errorf("unexpected final equity %+v", d)
+	}
+
+	if _, err := backtest.Simulate(nil, mc); !errors.Is(err, backtest.ErrNoTrades) {
+		t.Errorf("want ErrNoTrades, got %v", err)
+	}
+	for _, bad := range []backtest.MonteCarlo{{}, {Capital: 1000, Method: "jackknife"}, {Capital: 1000, Ruin: 2}, {Capital: 1000, Slippage: -1}} {
+		if _, err := backtest.Simulate(trades, bad); !errors.Is(err, backtest.ErrMonteCarlo) {
+			t.Errorf("%+v: want ErrMonteCarlo, got %v", bad, err)
+		}
+	}
+}
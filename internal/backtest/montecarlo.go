@@ -0,0 +1,182 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+var (
+	ErrMonteCarlo = errors.New("invalid monte carlo")
+	ErrNoTrades   = errors.New("no trades to resample")
+)
+
+const (
+	MethodShuffle   = "shuffle"   // the same trades in a random order
+	MethodBootstrap = "bootstrap" // as many trades drawn with replacement
+
+	defaultSimulations = 1000
+	maxSimulations     = 100000
+	defaultRuin        = 0.5
+)
+
+// MonteCarlo settings, zero values pick the defaults
+type MonteCarlo struct {
+	Method      string  `json:"method"`      // bootstrap or shuffle, bootstrap by default
+	Simulations int     `json:"simulations"` // 1000 by default
+	Seed        uint64  `json:"seed"`        // 0 picks one
+	Capital     float64 `json:"capital"`     // starting equity, in rupees
+	Slippage    float64 `json:"slippage"`    // spread of the slippage per unit on each fill, in rupees
+	Ruin        float64 `json:"ruin"`        // fraction of capital lost that counts as ruin, 0.5 by default
+}
+
+// Distribution of one outcome over the simulations
+type Distribution struct {
+	Mean   float64 `json:"mean"`
+	Min    float64 `json:"min"`
+	P5     float64 `json:"p5"`
+	P25    float64 `json:"p25"`
+	Median float64 `json:"median"`
+	P75    float64 `json:"p75"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+}
+
+// MonteCarloResult compares the backtest's own path with the simulated ones
+type MonteCarloResult struct {
+	Method      string       `json:"method"`
+	Simulations int          `json:"simulations"`
+	Seed        uint64       `json:"seed"`
+	Trades      int          `json:"trades"`
+	Capital     float64      `json:"capital"`
+	Equity      float64      `json:"equity"`       // the backtest's final equity
+	Drawdown    float64      `json:"drawdown"`     // and its max drawdown
+	FinalEquity Distribution `json:"final_equity"` // in rupees
+	MaxDrawdown Distribution `json:"max_drawdown"` // in rupees, from the running peak
+	RiskOfRuin  float64      `json:"risk_of_ruin"` // fraction of paths that lost Ruin of capital at some point
+	LossChance  float64      `json:"loss_chance"`  // fraction of paths ending below capital
+	Percentile  float64      `json:"percentile"`   // fraction of paths ending below the backtest, near 1 means it was lucky
+}
+
+// Simulate replays trades along random paths. Slippage is half normal and always costs,
+// on the entry and the exit of each trade.
+func Simulate(trades []Trade, mc MonteCarlo) (*MonteCarloResult, error) {
+	if len(trades) == 0 {
+		return nil, ErrNoTrades
+	}
+	if err := mc.defaults(); err != nil {
+		return nil, err
+	}
+
+	_, _, drawdown := Summarise(trades)
+	res := &MonteCarloResult{
+		Method:      mc.Method,
+		Simulations: mc.Simulations,
+		Seed:        mc.Seed,
+		Trades:      len(trades),
+		Capital:     mc.Capital,
+		Drawdown:    drawdown,
+	}
+	res.Equity = mc.Capital
+	for _, t := range trades {
+		res.Equity += t.PnL
+	}
+
+	r := rand.New(rand.NewPCG(mc.Seed, mc.Seed))
+	floor := mc.Capital * (1 - mc.Ruin)
+	equities := make([]float64, mc.Simulations)
+	drawdowns := make([]float64, mc.Simulations)
+	order := make([]int, len(trades))
+	ruined, losses, below := 0, 0, 0
+	for sim := range mc.Simulations {
+		for i := range order {
+			order[i] = i
+		}
+		if mc.Method == MethodShuffle {
+			r.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		} else {
+			for i := range order {
+				order[i] = r.IntN(len(trades))
+			}
+		}
+
+		equity, peak, deepest, ruin := mc.Capital, mc.Capital, 0.0, false
+		for _, i := range order {
+			t := trades[i]
+			equity += t.PnL - 2*math.Abs(r.NormFloat64())*mc.Slippage*float64(t.Quantity)
+			peak = max(peak, equity)
+			deepest = max(deepest, peak-equity)
+			ruin = ruin || equity <= floor
+		}
+		equities[sim], drawdowns[sim] = equity, deepest
+		if ruin {
+			ruined++
+		}
+		if equity < mc.Capital {
+			losses++
+		}
+		if equity < res.Equity {
+			below++
+		}
+	}
+
+	n := float64(mc.Simulations)
+	res.FinalEquity, res.MaxDrawdown = distribution(equities), distribution(drawdowns)
+	res.RiskOfRuin, res.LossChance, res.Percentile = float64(ruined)/n, float64(losses)/n, float64(below)/n
+	return res, nil
+}
+
+func (mc *MonteCarlo) defaults() error {
+	if mc.Method == "" {
+		mc.Method = MethodBootstrap
+	}
+	if mc.Method != MethodBootstrap && mc.Method != MethodShuffle {
+		return fmt.Errorf("%w: method %q, want bootstrap or shuffle", ErrMonteCarlo, mc.Method)
+	}
+	if mc.Simulations <= 0 {
+		mc.Simulations = defaultSimulations
+	}
+	if mc.Simulations > maxSimulations {
+		return fmt.Errorf("%w: more than %d simulations", ErrMonteCarlo, maxSimulations)
+	}
+	if mc.Capital <= 0 {
+		return fmt.Errorf("%w: capital is required", ErrMonteCarlo)
+	}
+	if mc.Slippage < 0 {
+		return fmt.Errorf("%w: negative slippage", ErrMonteCarlo)
+	}
+	if mc.Ruin == 0 {
+		mc.Ruin = defaultRuin
+	}
+	if mc.Ruin <= 0 || mc.Ruin > 1 {
+		return fmt.Errorf("%w: ruin %.2f, want a fraction of capital up to 1", ErrMonteCarlo, mc.Ruin)
+	}
+	if mc.Seed == 0 {
+		mc.Seed = rand.Uint64()
+	}
+	return nil
+}
+
+// distribution sorts values in place and reads its percentiles, nearest rank
+func distribution(values []float64) Distribution {
+	slices.Sort(values)
+	at := func(p float64) float64 {
+		return values[int(math.Round(p*float64(len(values)-1)))]
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return Distribution{
+		Mean:   sum / float64(len(values)),
+		Min:    values[0],
+		P5:     at(0.05),
+		P25:    at(0.25),
+		Median: at(0.5),
+		P75:    at(0.75),
+		P95:    at(0.95),
+		Max:    values[len(values)-1],
+	}
+}
@@ -0,0 +1,388 @@
+package backtest
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"friction-trading/internal/strategy"
+)
+
+var ErrSearch = errors.New("invalid search")
+
+const (
+	MethodGrid   = "grid"
+	MethodRandom = "random"
+
+	MetricNetPnL   = "net_pnl"
+	MetricWinRate  = "win_rate"
+	MetricRecovery = "recovery" // net P&L over max drawdown
+
+	// Parameter sets one search tries at most
+	maxSets = 10000
+
+	defaultSamples  = 100
+	defaultInSample = 0.7
+)
+
+// metrics score stats, higher is better
+var metrics = map[string]func(Stats) float64{
+	MetricNetPnL:  func(s Stats) float64 { return s.NetPnL },
+	MetricWinRate: func(s Stats) float64 { return s.WinRate },
+	// Drawdown floored at a rupee so sets that never lost don't score infinity
+	MetricRecovery: func(s Stats) float64 { return s.NetPnL / max(s.MaxDrawdown, 1) },
+}
+
+// Range of values a parameter takes. Grid search steps from Min to Max, random
+// search draws between them, on the steps when Step is set.
+type Range struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Step float64 `json:"step"`
+}
+
+// Space maps parameter names to their ranges
+type Space map[string]Range
+
+// Search settings, zero values pick the defaults
+type Search struct {
+	Method   string  `json:"method"`    // grid or random, grid by default
+	Samples  int     `json:"samples"`   // random draws, 100 by default
+	Seed     uint64  `json:"seed"`      // random search seed, 0 picks one
+	Metric   string  `json:"metric"`    // net_pnl, win_rate or recovery, net_pnl by default
+	Windows  int     `json:"windows"`   // walk-forward windows, 1 by default
+	InSample float64 `json:"in_sample"` // fraction of each window the params are picked on, 0.7 by default
+
+	Workers int             `json:"-"` // parallel runs, one per CPU by default
+	Base    strategy.Params `json:"-"` // fixed params every set starts from, e.g. timeframe
+	Options Options         `json:"-"`
+}
+
+// Stats of one parameter set over a sample
+type Stats struct {
+	Trades      int     `json:"trades"`
+	NetPnL      float64 `json:"net_pnl"`
+	WinRate     float64 `json:"win_rate"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+	Score       float64 `json:"score"` // the search metric
+}
+
+// Row is one parameter set, its trades summed over every window
+type Row struct {
+	Rank        int             `json:"rank"`
+	Params      strategy.Params `json:"params"`
+	InSample    Stats           `json:"in_sample"`
+	OutOfSample Stats           `json:"out_of_sample"`
+	Error       string          `json:"error,omitempty"` // params the strategy rejects
+}
+
+// Window is one walk-forward step, the set that did best in sample traded out of sample
+type Window struct {
+	InFrom      time.Time       `json:"in_from"`
+	OutFrom     time.Time       `json:"out_from"`
+	OutTo       time.Time       `json:"out_to"`
+	Best        strategy.Params `json:"best"`
+	InSample    Stats           `json:"in_sample"`
+	OutOfSample Stats           `json:"out_of_sample"`
+}
+
+// Optimisation is the result of a search, Results are ranked best out of sample first
+type Optimisation struct {
+	Method      string   `json:"method"`
+	Metric      string   `json:"metric"`
+	Seed        uint64   `json:"seed,omitempty"`
+	Candles     int      `json:"candles"`
+	Windows     []Window `json:"windows"`
+	WalkForward Stats    `json:"walk_forward"` // each window's best set out of sample, one after the other
+	Efficiency  float64  `json:"efficiency"`   // walk-forward net P&L over the in-sample net P&L that picked the sets
+	Results     []Row    `json:"results"`
+}
+
+// Optimise runs every parameter set of the search on every walk-forward window, in parallel.
+//
+// Windows slide by their out-of-sample length so the out-of-sample parts follow each other
+// to the last candle. Each run covers a whole window, trades entered before the window's
+// out-of-sample part count in sample, so indicators are warm when it starts.
+func Optimise(factory strategy.Factory, candles []strategy.Candle, space Space, s Search) (*Optimisation, error) {
+	if err := s.defaults(); err != nil {
+		return nil, err
+	}
+	score := metrics[s.Metric]
+	sets, err := s.sets(space)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := split(len(candles), s.Windows, s.InSample)
+	if err != nil {
+		return nil, err
+	}
+
+	// Trades of every set on every window, runs own their slot so workers don't share
+	type outcome struct {
+		in, out []Trade
+		err     error
+	}
+	outcomes := make([][]outcome, len(sets))
+	for i := range outcomes {
+		outcomes[i] = make([]outcome, len(windows))
+	}
+	type job struct{ set, window int }
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for range min(s.Workers, len(sets)*len(windows)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				o := &outcomes[j.set][j.window]
+				st, err := factory(sets[j.set])
+				if err != nil {
+					o.err = err
+					continue
+				}
+				w := windows[j.window]
+				res, err := Run(st, candles[w.start:w.end], s.Options)
+				if err != nil {
+					o.err = err
+					continue
+				}
+				boundary := candles[w.out].Timestamp
+				for _, t := range res.Trades {
+					if t.EntryTime.Before(boundary) {
+						o.in = append(o.in, t)
+					} else {
+						o.out = append(o.out, t)
+					}
+				}
+			}
+		}()
+	}
+	for set := range sets {
+		for window := range windows {
+			jobs <- job{set, window}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// A set fails when any of its runs does
+	errs := make([]error, len(sets))
+	for i := range outcomes {
+		for _, o := range outcomes[i] {
+			if errors.Is(o.err, ErrNoSignals) {
+				return nil, o.err
+			}
+			errs[i] = cmp.Or(errs[i], o.err)
+		}
+	}
+
+	opt := &Optimisation{Method: s.Method, Metric: s.Metric, Seed: s.Seed, Candles: len(candles), Results: make([]Row, len(sets))}
+	for i, params := range sets {
+		row := Row{Params: params}
+		if errs[i] != nil {
+			row.Error = errs[i].Error()
+		} else {
+			var in, out []Trade
+			for _, o := range outcomes[i] {
+				in = append(in, o.in...)
+				out = append(out, o.out...)
+			}
+			row.InSample, row.OutOfSample = stats(in, score), stats(out, score)
+		}
+		opt.Results[i] = row
+	}
+
+	// Walk forward, picking on each window's in-sample part only
+	var walked []Trade
+	var picked float64
+	for wi, w := range windows {
+		best := -1
+		var bestIn Stats
+		for i := range sets {
+			if errs[i] != nil {
+				continue
+			}
+			if in := stats(outcomes[i][wi].in, score); best < 0 || in.Score > bestIn.Score {
+				best, bestIn = i, in
+			}
+		}
+		win := Window{InFrom: candles[w.start].Timestamp, OutFrom: candles[w.out].Timestamp, OutTo: candles[w.end-1].Timestamp}
+		if best >= 0 {
+			win.Best, win.InSample = sets[best], bestIn
+			win.OutOfSample = stats(outcomes[best][wi].out, score)
+			walked = append(walked, outcomes[best][wi].out...)
+			picked += bestIn.NetPnL
+		}
+		opt.Windows = append(opt.Windows, win)
+	}
+	opt.WalkForward = stats(walked, score)
+	if picked > 0 {
+		opt.Efficiency = opt.WalkForward.NetPnL / picked
+	}
+
+	slices.SortStableFunc(opt.Results, func(a, b Row) int {
+		if (a.Error == "") != (b.Error == "") {
+			if a.Error == "" {
+				return -1
+			}
+			return 1
+		}
+		if c := cmp.Compare(b.OutOfSample.Score, a.OutOfSample.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.InSample.Score, a.InSample.Score)
+	})
+	for i := range opt.Results {
+		opt.Results[i].Rank = i + 1
+	}
+	return opt, nil
+}
+
+func stats(trades []Trade, score func(Stats) float64) Stats {
+	s := Stats{Trades: len(trades)}
+	s.NetPnL, s.WinRate, s.MaxDrawdown = Summarise(trades)
+	s.Score = score(s)
+	return s
+}
+
+func (s *Search) defaults() error {
+	if s.Method == "" {
+		s.Method = MethodGrid
+	}
+	if s.Method != MethodGrid && s.Method != MethodRandom {
+		return fmt.Errorf("%w: method %q, want grid or random", ErrSearch, s.Method)
+	}
+	if s.Metric == "" {
+		s.Metric = MetricNetPnL
+	}
+	if _, ok := metrics[s.Metric]; !ok {
+		return fmt.Errorf("%w: metric %q, want net_pnl, win_rate or recovery", ErrSearch, s.Metric)
+	}
+	if s.Samples <= 0 {
+		s.Samples = defaultSamples
+	}
+	if s.Windows <= 0 {
+		s.Windows = 1
+	}
+	if s.InSample == 0 {
+		s.InSample = defaultInSample
+	}
+	if s.InSample <= 0 || s.InSample >= 1 {
+		return fmt.Errorf("%w: in_sample %.2f, want a fraction between 0 and 1", ErrSearch, s.InSample)
+	}
+	if s.Workers <= 0 {
+		s.Workers = runtime.GOMAXPROCS(0)
+	}
+	if s.Method == MethodRandom && s.Seed == 0 {
+		s.Seed = rand.Uint64()
+	}
+	return nil
+}
+
+// sets lists the parameter sets to try, each on top of the base params
+func (s *Search) sets(space Space) ([]strategy.Params, error) {
+	if len(space) == 0 {
+		return nil, fmt.Errorf("%w: no params to search", ErrSearch)
+	}
+	names := slices.Sorted(maps.Keys(space))
+	total := 1
+	for _, name := range names {
+		r := space[name]
+		if r.Min > r.Max || r.Step < 0 {
+			return nil, fmt.Errorf("%w: %s range %v to %v by %v", ErrSearch, name, r.Min, r.Max, r.Step)
+		}
+		if s.Method == MethodGrid {
+			if r.Step == 0 && r.Min != r.Max {
+				return nil, fmt.Errorf("%w: grid search needs a step for %s", ErrSearch, name)
+			}
+			total *= r.steps() + 1
+			if total > maxSets {
+				return nil, fmt.Errorf("%w: more than %d parameter sets", ErrSearch, maxSets)
+			}
+		}
+	}
+
+	set := func() strategy.Params {
+		p := maps.Clone(s.Base)
+		if p == nil {
+			p = strategy.Params{}
+		}
+		return p
+	}
+	if s.Method == MethodRandom {
+		if s.Samples > maxSets {
+			return nil, fmt.Errorf("%w: more than %d samples", ErrSearch, maxSets)
+		}
+		r := rand.New(rand.NewPCG(s.Seed, s.Seed))
+		sets := make([]strategy.Params, s.Samples)
+		for i := range sets {
+			sets[i] = set()
+			for _, name := range names {
+				sets[i][name] = space[name].draw(r)
+			}
+		}
+		return sets, nil
+	}
+
+	sets := []strategy.Params{set()}
+	for _, name := range names {
+		rg := space[name]
+		next := make([]strategy.Params, 0, len(sets)*(rg.steps()+1))
+		for _, p := range sets {
+			for i := range rg.steps() + 1 {
+				p := maps.Clone(p)
+				p[name] = rg.at(i)
+				next = append(next, p)
+			}
+		}
+		sets = next
+	}
+	return sets, nil
+}
+
+// steps between Min and Max
+func (r Range) steps() int {
+	if r.Step == 0 {
+		return 0
+	}
+	return int((r.Max-r.Min)/r.Step + 1e-9)
+}
+
+// at is the i'th step, rounded so 0.1 steps don't drift
+func (r Range) at(i int) float64 {
+	return math.Round((r.Min+float64(i)*r.Step)*1e9) / 1e9
+}
+
+func (r Range) draw(rnd *rand.Rand) float64 {
+	if r.Step > 0 {
+		return r.at(rnd.IntN(r.steps() + 1))
+	}
+	return r.Min + rnd.Float64()*(r.Max-r.Min)
+}
+
+// window of candle indexes, in sample from start and out of sample from out to end
+type window struct{ start, out, end int }
+
+// split n candles into windows sliding by their out-of-sample length
+func split(n, windows int, inSample float64) ([]window, error) {
+	length := int(float64(n) / (1 + float64(windows-1)*(1-inSample)))
+	out := int(float64(length) * (1 - inSample))
+	in := length - out
+	if in < 1 || out < 1 {
+		return nil, fmt.Errorf("%w: %d candles are too few for %d windows", ErrSearch, n, windows)
+	}
+	ws := make([]window, windows)
+	for i := range ws {
+		start := i * out
+		ws[i] = window{start: start, out: start + in, end: start + in + out}
+	}
+	ws[windows-1].end = n
+	return ws, nil
+}
@@ -0,0 +1,188 @@
+// Package basket builds multi-leg option orders (straddles, strangles, condors, spreads)
+// off the option chain and places them hedges first.
+package basket
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/database"
+	"friction-trading/internal/strategy"
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown option strategy")
+	ErrNoLegs          = errors.New("basket has no legs")
+	ErrNoChain         = errors.New("no options listed for underlying and expiry")
+	ErrNoStrike        = errors.New("strike is outside the option chain")
+	ErrNoExpiry        = errors.New("expiry is not listed")
+)
+
+// Option types
+const (
+	Call = "CE"
+	Put  = "PE"
+)
+
+// Leg is one option of a combination, placed relative to the ATM strike
+type Leg struct {
+	Type   string `json:"type"`   // CE or PE
+	Side   string `json:"side"`   // BUY or SELL
+	Offset int    `json:"offset"` // strikes away from ATM, positive is higher
+	Lots   int    `json:"lots"`
+}
+
+func leg(typ, side string, offset, lots int) Leg {
+	return Leg{Type: typ, Side: side, Offset: offset, Lots: lots}
+}
+
+// Legs builds a named combination. Params are width, strikes from ATM of the
+// main legs, wing, strikes further out of the hedges, and lots.
+func Legs(name string, params strategy.Params) ([]Leg, error) {
+	width := int(params.Get("width", 2))
+	wing := int(params.Get("wing", 2))
+	lots := int(params.Get("lots", 1))
+	if width < 0 || wing < 1 || lots < 1 {
+		return nil, errors.New("width can't be negative, wing and lots must be at least 1")
+	}
+
+	buy, sell := kiteconnect.TransactionTypeBuy, kiteconnect.TransactionTypeSell
+	switch name {
+	case "short_straddle":
+		return []Leg{leg(Call, sell, 0, lots), leg(Put, sell, 0, lots)}, nil
+	case "long_straddle":
+		return []Leg{leg(Call, buy, 0, lots), leg(Put, buy, 0, lots)}, nil
+	case "short_strangle":
+		return []Leg{leg(Call, sell, width, lots), leg(Put, sell, -width, lots)}, nil
+	case "long_strangle":
+		return []Leg{leg(Call, buy, width, lots), leg(Put, buy, -width, lots)}, nil
+	case "iron_condor":
+		return []Leg{
+			leg(Call, sell, width, lots), leg(Put, sell, -width, lots),
+			leg(Call, buy, width+wing, lots), leg(Put, buy, -width-wing, lots),
+		}, nil
+	case "iron_butterfly":
+		return []Leg{
+			leg(Call, sell, 0, lots), leg(Put, sell, 0, lots),
+			leg(Call, buy, wing, lots), leg(Put, buy, -wing, lots),
+		}, nil
+	}
+
+	// Spreads are ATM against width strikes away
+	if width < 1 {
+		return nil, errors.New("spread width must be at least 1")
+	}
+	switch name {
+	case "bull_call_spread":
+		return []Leg{leg(Call, buy, 0, lots), leg(Call, sell, width, lots)}, nil
+	case "bear_call_spread":
+		return []Leg{leg(Call, sell, 0, lots), leg(Call, buy, width, lots)}, nil
+	case "bull_put_spread":
+		return []Leg{leg(Put, sell, 0, lots), leg(Put, buy, -width, lots)}, nil
+	case "bear_put_spread":
+		return []Leg{leg(Put, buy, 0, lots), leg(Put, sell, -width, lots)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, name)
+	}
+}
+
+// PickExpiry chooses from listed expiries, ascending: "weekly" (or empty) is the
+// nearest, "monthly" the last one in the nearest month, otherwise a YYYY-MM-DD date.
+func PickExpiry(expiries []time.Time, want string) (time.Time, error) {
+	if len(expiries) == 0 {
+		return time.Time{}, ErrNoChain
+	}
+
+	switch want {
+	case "", "weekly":
+		return expiries[0], nil
+	case "monthly":
+		monthly := expiries[0]
+		for _, e := range expiries[1:] {
+			if e.Year() != monthly.Year() || e.Month() != monthly.Month() {
+				break
+			}
+			monthly = e
+		}
+		return monthly, nil
+	}
+
+	day, err := time.Parse(time.DateOnly, want)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expiry %q, want weekly, monthly or YYYY-MM-DD", want)
+	}
+	for _, e := range expiries {
+		if e.Format(time.DateOnly) == day.Format(time.DateOnly) {
+			return e, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %s", ErrNoExpiry, want)
+}
+
+// Resolve turns legs into market orders on chain, the options of one expiry,
+// with ATM the listed strike nearest to spot.
+func Resolve(chain []*database.Instrument, spot float64, legs []Leg, product string) ([]kiteconnect.OrderParams, error) {
+	if len(legs) == 0 {
+		return nil, ErrNoLegs
+	}
+	if len(chain) == 0 {
+		return nil, ErrNoChain
+	}
+
+	var strikes []float64
+	options := map[string]*database.Instrument{}
+	for _, in := range chain {
+		if !slices.Contains(strikes, in.Strike) {
+			strikes = append(strikes, in.Strike)
+		}
+		options[optionKey(in.InstrumentType, in.Strike)] = in
+	}
+	sort.Float64s(strikes)
+
+	atm := 0
+	for i, k := range strikes {
+		if math.Abs(k-spot) < math.Abs(strikes[atm]-spot) {
+			atm = i
+		}
+	}
+
+	orders := make([]kiteconnect.OrderParams, 0, len(legs))
+	for _, l := range legs {
+		if l.Lots < 1 {
+			return nil, errors.New("every leg needs at least 1 lot")
+		}
+		if l.Side != kiteconnect.TransactionTypeBuy && l.Side != kiteconnect.TransactionTypeSell {
+			return nil, fmt.Errorf("leg side %q, want BUY or SELL", l.Side)
+		}
+
+		i := atm + l.Offset
+		if i < 0 || i >= len(strikes) {
+			return nil, fmt.Errorf("%w: %s %+d from ATM %.2f", ErrNoStrike, l.Type, l.Offset, strikes[atm])
+		}
+		in, ok := options[optionKey(l.Type, strikes[i])]
+		if !ok {
+			return nil, fmt.Errorf("%w: no %s at %.2f", ErrNoStrike, l.Type, strikes[i])
+		}
+
+		orders = append(orders, kiteconnect.OrderParams{
+			Exchange:        in.Exchange,
+			Tradingsymbol:   in.Tradingsymbol,
+			TransactionType: l.Side,
+			OrderType:       kiteconnect.OrderTypeMarket,
+			Product:         product,
+			Validity:        kiteconnect.ValidityDay,
+			Quantity:        l.Lots * int(in.LotSize),
+		})
+	}
+	return orders, nil
+}
+
+func optionKey(typ string, strike float64) string {
+	return fmt.Sprintf("%s:%.2f", typ, strike)
+}
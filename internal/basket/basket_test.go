@@ -0,0 +1,152 @@
+package basket_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/basket"
+	"friction-trading/internal/database"
+	"friction-trading/internal/strategy"
+)
+
+func chain(strikes ...float64) []*database.Instrument {
+	var out []*database.Instrument
+	for _, k := range strikes {
+		for _, typ := range []string{basket.Call, basket.Put} {
+			out = append(out, &database.Instrument{
+				Exchange: "NFO", Tradingsymbol: fmt.Sprintf("NIFTY%.0f%s", k, typ), Strike: k, LotSize: 75, InstrumentType: typ,
+			})
+		}
+	}
+	return out
+}
+
+func TestResolve(t *testing.T) {
+	legs, err := basket.Legs("bull_put_spread", strategy.Params{"width": 1, "lots": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 24990 is nearest 25000
+	orders, err := basket.Resolve(chain(24900, 24950, 25000, 25050), 24990, legs, kiteconnect.ProductNRML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[0].Tradingsymbol != "NIFTY25000PE" || orders[0].TransactionType != kiteconnect.TransactionTypeSell ||
+		orders[1].Tradingsymbol != "NIFTY24950PE" || orders[1].Quantity != 150 {
+		t.Errorf("want sell 25000 PE, buy 24950 PE, 2 lots, got %+v", orders)
+	}
+
+	wide, _ := basket.Legs("iron_condor", strategy.Params{"width": 2, "wing": 2})
+	if _, err := basket.Resolve(chain(24900, 24950, 25000, 25050), 24990, wide, kiteconnect.ProductNRML); !errors.Is(err, basket.ErrNoStrike) {
+		t.Errorf("want ErrNoStrike past the chain, got %v", err)
+	}
+	if _, err := basket.Legs("jade_lizard", nil); !errors.Is(err, basket.ErrUnknownStrategy) {
+		t.Errorf("want ErrUnknownStrategy, got %v", err)
+	}
+}
+
+func TestPickExpiry(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 0, 0, 0, 0, time.UTC) }
+	expiries := []time.Time{day(10, 23), day(10, 28), day(11, 4), day(11, 25)}
+
+	for want, expiry := range map[string]time.Time{
+		"":           day(10, 23),
+		"weekly":     day(10, 23),
+		"monthly":    day(10, 28),
+		"2025-11-04": day(11, 4),
+	} {
+		if got, err := basket.PickExpiry(expiries, want); err != nil || !got.Equal(expiry) {
+			t.Errorf("%q: want %v, got %v, %v", want, expiry, got, err)
+		}
+	}
+	if _, err := basket.PickExpiry(expiries, "2025-11-05"); !errors.Is(err, basket.ErrNoExpiry) {
+		t.Errorf("want ErrNoExpiry, got %v", err)
+	}
+}
+
+// stubBroker fills every order unless its symbol is rejected
+type stubBroker struct {
+	reject    string
+	orders    kiteconnect.Orders
+	margin    float64
+	cancelled []string
+}
+
+func (b *stubBroker) PlaceOrder(variety string, p kiteconnect.OrderParams) (string, error) {
+	id := p.Tradingsymbol + ":" + p.TransactionType
+	o := kiteconnect.Order{
+		OrderID: id, Exchange: p.Exchange, TradingSymbol: p.Tradingsymbol, TransactionType: p.TransactionType,
+		Product: p.Product, Quantity: float64(p.Quantity), FilledQuantity: float64(p.Quantity), Status: kiteconnect.OrderStatusComplete,
+	}
+	if p.Tradingsymbol == b.reject {
+		o.FilledQuantity, o.Status, o.StatusMessage = 0, kiteconnect.OrderStatusRejected, "RMS: margin exceeds"
+	}
+	b.orders = append(b.orders, o)
+	return id, nil
+}
+
+func (b *stubBroker) CancelOrder(variety, orderID string) error {
+	b.cancelled = append(b.cancelled, orderID)
+	return nil
+}
+
+func (b *stubBroker) Orders() (kiteconnect.Orders, error)       { return b.orders, nil }
+func (b *stubBroker) Positions() (kiteconnect.Positions, error) { return kiteconnect.Positions{}, nil }
+func (b *stubBroker) Margins() (kiteconnect.AllMargins, error) {
+	return kiteconnect.AllMargins{Equity: kiteconnect.Margins{Net: 100000}}, nil
+}
+func (b *stubBroker) OrderMargin(kiteconnect.OrderParams) (float64, error) {
+	return 0, nil
+}
+
+func (b *stubBroker) BasketMargin([]kiteconnect.OrderParams) (float64, error) {
+	return b.margin, nil
+}
+
+func hedgedStraddle() []kiteconnect.OrderParams {
+	return []kiteconnect.OrderParams{
+		{Exchange: "NFO", Tradingsymbol: "CE", TransactionType: kiteconnect.TransactionTypeSell, Quantity: 75},
+		{Exchange: "NFO", Tradingsymbol: "PE", TransactionType: kiteconnect.TransactionTypeSell, Quantity: 75},
+		{Exchange: "NFO", Tradingsymbol: "CEWING", TransactionType: kiteconnect.TransactionTypeBuy, Quantity: 75},
+	}
+}
+
+func TestExecuteRollsBack(t *testing.T) {
+	b := &stubBroker{reject: "PE", margin: 50000}
+	e := basket.NewExecutor(b, b.PlaceOrder)
+	e.PollInterval = time.Millisecond
+
+	report, err := e.Execute(hedgedStraddle())
+	if !errors.Is(err, basket.ErrLegFailed) {
+		t.Fatalf("want ErrLegFailed, got %v", err)
+	}
+
+	// Hedge first, then the shorts, then the filled legs are bought and sold back
+	var placed []string
+	for _, o := range b.orders {
+		placed = append(placed, o.OrderID)
+	}
+	want := []string{"CEWING:BUY", "CE:SELL", "PE:SELL", "CEWING:SELL", "CE:BUY"}
+	if len(placed) != len(want) {
+		t.Fatalf("want %v, got %v", want, placed)
+	}
+	for i := range want {
+		if placed[i] != want[i] {
+			t.Fatalf("want %v, got %v", want, placed)
+		}
+	}
+	if len(report.RolledBack) != 2 || report.Error == "" {
+		t.Errorf("want two legs rolled back, got %+v", report)
+	}
+
+	// Margin short of the basket places nothing
+	b = &stubBroker{margin: 200000}
+	if _, err := basket.NewExecutor(b, b.PlaceOrder).Execute(hedgedStraddle()); !errors.Is(err, basket.ErrInsufficientMargin) || len(b.orders) != 0 {
+		t.Errorf("want ErrInsufficientMargin and no orders, got %v, %d orders", err, len(b.orders))
+	}
+}
@@ -0,0 +1,230 @@
+package basket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/broker"
+)
+
+var (
+	ErrInsufficientMargin = errors.New("insufficient margin for basket")
+	ErrLegFailed          = errors.New("basket leg failed")
+)
+
+// Place sends one order, the server routes it through risk
+type Place func(variety string, params kiteconnect.OrderParams) (string, error)
+
+// LegResult is how one leg went
+type LegResult struct {
+	Exchange        string `json:"exchange"`
+	Tradingsymbol   string `json:"tradingsymbol"`
+	TransactionType string `json:"transaction_type"`
+	Quantity        int    `json:"quantity"`
+	OrderID         string `json:"order_id,omitempty"`
+	Status          string `json:"status,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// Report is what a basket order did
+type Report struct {
+	Margin     float64     `json:"margin"`
+	Available  float64     `json:"available"`
+	Legs       []LegResult `json:"legs"`
+	RolledBack []string    `json:"rolled_back,omitempty"` // orders cancelled or exits placed
+	Error      string      `json:"error,omitempty"`
+}
+
+// Executor places baskets leg by leg
+type Executor struct {
+	broker broker.Broker
+	place  Place
+
+	// How long legs get to fill before the basket is rolled back
+	FillTimeout  time.Duration
+	PollInterval time.Duration
+}
+
+func NewExecutor(b broker.Broker, place Place) *Executor {
+	return &Executor{broker: b, place: place, FillTimeout: 10 * time.Second, PollInterval: 250 * time.Millisecond}
+}
+
+// Margin is what the basket blocks together, against equity margin available
+func (e *Executor) Margin(orders []kiteconnect.OrderParams) (required, available float64, err error) {
+	if required, err = e.broker.BasketMargin(orders); err != nil {
+		return 0, 0, fmt.Errorf("basket margin: %w", err)
+	}
+	margins, err := e.broker.Margins()
+	if err != nil {
+		return 0, 0, fmt.Errorf("account margins: %w", err)
+	}
+	return required, margins.Equity.Net, nil
+}
+
+// Execute checks margin, places the buys (hedges) and waits for them to fill,
+// then places the sells. When a leg fails the legs already placed are undone.
+func (e *Executor) Execute(orders []kiteconnect.OrderParams) (Report, error) {
+	var report Report
+	if len(orders) == 0 {
+		return report, ErrNoLegs
+	}
+
+	var err error
+	report.Margin, report.Available, err = e.Margin(orders)
+	if err != nil {
+		return report, err
+	}
+	if report.Margin > report.Available {
+		return report, fmt.Errorf("%w: needs %.2f, %.2f available", ErrInsufficientMargin, report.Margin, report.Available)
+	}
+
+	var hedges, shorts []kiteconnect.OrderParams
+	for _, o := range orders {
+		if o.TransactionType == kiteconnect.TransactionTypeBuy {
+			hedges = append(hedges, o)
+		} else {
+			shorts = append(shorts, o)
+		}
+	}
+
+	// Shorts only go once the hedges are in, for the margin benefit
+	for _, group := range [][]kiteconnect.OrderParams{hedges, shorts} {
+		if err = e.placeAll(&report, group); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		return report, nil
+	}
+
+	report.Error = err.Error()
+	log.Printf("Basket failed, rolling back :- %v\n", err)
+	if rbErr := e.rollback(&report); rbErr != nil {
+		// Legs are left open, someone has to look at the account
+		err = errors.Join(err, fmt.Errorf("rollback: %w", rbErr))
+		report.Error = err.Error()
+		log.Printf("ALERT basket rollback incomplete, check open legs :- %v\n", rbErr)
+	}
+	return report, err
+}
+
+// placeAll places a group of legs and waits for all of them to fill
+func (e *Executor) placeAll(report *Report, group []kiteconnect.OrderParams) error {
+	if len(group) == 0 {
+		return nil
+	}
+
+	first := len(report.Legs)
+	for _, o := range group {
+		o.Tag = "basket"
+		res := LegResult{
+			Exchange:        o.Exchange,
+			Tradingsymbol:   o.Tradingsymbol,
+			TransactionType: o.TransactionType,
+			Quantity:        o.Quantity,
+		}
+		id, err := e.place(kiteconnect.VarietyRegular, o)
+		if err != nil {
+			res.Error = err.Error()
+			report.Legs = append(report.Legs, res)
+			return fmt.Errorf("%w: %s %s: %v", ErrLegFailed, o.TransactionType, o.Tradingsymbol, err)
+		}
+		res.OrderID = id
+		report.Legs = append(report.Legs, res)
+	}
+	return e.waitFilled(report.Legs[first:])
+}
+
+// waitFilled polls the order book until legs complete, fail or time out
+func (e *Executor) waitFilled(legs []LegResult) error {
+	deadline := time.Now().Add(e.FillTimeout)
+	for {
+		orders, err := e.broker.Orders()
+		if err != nil {
+			return fmt.Errorf("fetch orders: %w", err)
+		}
+		status := latestStatus(orders)
+
+		pending := 0
+		for i := range legs {
+			l := &legs[i]
+			l.Status = status[l.OrderID].Status
+			switch l.Status {
+			case kiteconnect.OrderStatusComplete:
+			case kiteconnect.OrderStatusRejected, kiteconnect.OrderStatusCancelled:
+				l.Error = status[l.OrderID].StatusMessage
+				return fmt.Errorf("%w: %s %s %s", ErrLegFailed, l.TransactionType, l.Tradingsymbol, l.Status)
+			default:
+				pending++
+			}
+		}
+		if pending == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: %d legs unfilled after %s", ErrLegFailed, pending, e.FillTimeout)
+		}
+		time.Sleep(e.PollInterval)
+	}
+}
+
+// rollback cancels legs still working and exits what filled
+func (e *Executor) rollback(report *Report) error {
+	orders, err := e.broker.Orders()
+	if err != nil {
+		return fmt.Errorf("fetch orders: %w", err)
+	}
+	status := latestStatus(orders)
+
+	var errs []error
+	for _, l := range report.Legs {
+		if l.OrderID == "" {
+			continue
+		}
+		o := status[l.OrderID]
+		if broker.IsOpen(o) {
+			if err := e.broker.CancelOrder(o.Variety, o.OrderID); err != nil {
+				errs = append(errs, fmt.Errorf("cancel %s: %w", o.OrderID, err))
+				continue
+			}
+			report.RolledBack = append(report.RolledBack, o.OrderID)
+		}
+
+		// Undo whatever part of the leg filled
+		if o.FilledQuantity <= 0 {
+			continue
+		}
+		qty := int(o.FilledQuantity)
+		if o.TransactionType == kiteconnect.TransactionTypeSell {
+			qty = -qty
+		}
+		exit := broker.ExitParams(kiteconnect.Position{
+			Exchange:      o.Exchange,
+			Tradingsymbol: o.TradingSymbol,
+			Product:       o.Product,
+			Quantity:      qty,
+		})
+		exit.Tag = "basket"
+		id, err := e.place(kiteconnect.VarietyRegular, exit)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("exit %s: %w", o.TradingSymbol, err))
+			continue
+		}
+		log.Printf("Basket rollback :- %s %d %s, order %s\n", exit.TransactionType, exit.Quantity, exit.Tradingsymbol, id)
+		report.RolledBack = append(report.RolledBack, id)
+	}
+	return errors.Join(errs...)
+}
+
+// latestStatus indexes orders by id
+func latestStatus(orders kiteconnect.Orders) map[string]kiteconnect.Order {
+	status := make(map[string]kiteconnect.Order, len(orders))
+	for _, o := range orders {
+		status[o.OrderID] = o
+	}
+	return status
+}
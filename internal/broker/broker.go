@@ -0,0 +1,206 @@
+// Package broker is what the server trades through, Kite in production
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+// Broker places and manages orders on an account
+type Broker interface {
+	PlaceOrder(variety string, params kiteconnect.OrderParams) (string, error)
+	CancelOrder(variety, orderID string) error
+	Orders() (kiteconnect.Orders, error)
+	Positions() (kiteconnect.Positions, error)
+	Margins() (kiteconnect.AllMargins, error)
+	// OrderMargin is the margin the order would block
+	OrderMargin(params kiteconnect.OrderParams) (float64, error)
+	// BasketMargin is the margin the orders would block together, after hedge benefit
+	BasketMargin(params []kiteconnect.OrderParams) (float64, error)
+}
+
+// Kite trades through a Kite Connect client
+type Kite struct {
+	Client *kiteconnect.Client
+}
+
+// NewKite wraps a Kite client, the client may log in later
+func NewKite(kc *kiteconnect.Client) *Kite {
+	return &Kite{Client: kc}
+}
+
+func (k *Kite) PlaceOrder(variety string, params kiteconnect.OrderParams) (string, error) {
+	resp, err := k.Client.PlaceOrder(variety, params)
+	if err != nil {
+		return "", err
+	}
+	return resp.OrderID, nil
+}
+
+func (k *Kite) CancelOrder(variety, orderID string) error {
+	_, err := k.Client.CancelOrder(variety, orderID, nil)
+	return err
+}
+
+func (k *Kite) Orders() (kiteconnect.Orders, error) {
+	return k.Client.GetOrders()
+}
+
+func (k *Kite) Positions() (kiteconnect.Positions, error) {
+	return k.Client.GetPositions()
+}
+
+func (k *Kite) Margins() (kiteconnect.AllMargins, error) {
+	return k.Client.GetUserMargins()
+}
+
+func (k *Kite) OrderMargin(params kiteconnect.OrderParams) (float64, error) {
+	margins, err := k.Client.GetOrderMargins(kiteconnect.GetMarginParams{
+		OrderParams: []kiteconnect.OrderMarginParam{marginParam(params)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(margins) == 0 {
+		return 0, errors.New("no margin returned for order")
+	}
+	return margins[0].Total, nil
+}
+
+func (k *Kite) BasketMargin(params []kiteconnect.OrderParams) (float64, error) {
+	basket := make([]kiteconnect.OrderMarginParam, len(params))
+	for i, p := range params {
+		basket[i] = marginParam(p)
+	}
+	margins, err := k.Client.GetBasketMargins(kiteconnect.GetBasketParams{
+		OrderParams:       basket,
+		ConsiderPositions: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return margins.Final.Total, nil
+}
+
+func marginParam(params kiteconnect.OrderParams) kiteconnect.OrderMarginParam {
+	return kiteconnect.OrderMarginParam{
+		Exchange:        params.Exchange,
+		Tradingsymbol:   params.Tradingsymbol,
+		TransactionType: params.TransactionType,
+		Variety:         kiteconnect.VarietyRegular,
+		Product:         params.Product,
+		OrderType:       params.OrderType,
+		Quantity:        float64(params.Quantity),
+		Price:           params.Price,
+		TriggerPrice:    params.TriggerPrice,
+	}
+}
+
+// TagPrefix marks orders placed by this server, Kite tags are at most 20 chars
+const (
+	TagPrefix = "ft"
+	maxTagLen = 20
+)
+
+// OwnTag prefixes an order tag so our orders can be told apart from manual ones
+func OwnTag(tag string) string {
+	if strings.HasPrefix(tag, TagPrefix) {
+		return tag
+	}
+	tag = TagPrefix + tag
+	if len(tag) > maxTagLen {
+		tag = tag[:maxTagLen]
+	}
+	return tag
+}
+
+// IsOwn reports whether the order was placed by this server
+func IsOwn(o kiteconnect.Order) bool {
+	return strings.HasPrefix(o.Tag, TagPrefix)
+}
+
+// Open order statuses which can still be cancelled
+var openStatuses = map[string]bool{
+	"OPEN":                      true,
+	"TRIGGER PENDING":           true,
+	"AMO REQ RECEIVED":          true,
+	"MODIFY VALIDATION PENDING": true,
+	"VALIDATION PENDING":        true,
+	"PUT ORDER REQ RECEIVED":    true,
+}
+
+// IsOpen reports whether an order is still working at the exchange
+func IsOpen(o kiteconnect.Order) bool {
+	return openStatuses[o.Status]
+}
+
+// CancelOpenOrders cancels every open order, returning the ids cancelled
+func CancelOpenOrders(b Broker) ([]string, error) {
+	orders, err := b.Orders()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cancelled []string
+		errs      []error
+	)
+	for _, o := range orders {
+		if !IsOpen(o) {
+			continue
+		}
+		if err := b.CancelOrder(o.Variety, o.OrderID); err != nil {
+			errs = append(errs, fmt.Errorf("cancel %s: %w", o.OrderID, err))
+			continue
+		}
+		cancelled = append(cancelled, o.OrderID)
+	}
+	return cancelled, errors.Join(errs...)
+}
+
+// SquareOff closes open net positions of product at market, returning the order ids.
+// An empty product squares off every product.
+func SquareOff(b Broker, product string) ([]string, error) {
+	positions, err := b.Positions()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		placed []string
+		errs   []error
+	)
+	for _, p := range positions.Net {
+		if p.Quantity == 0 || (product != "" && p.Product != product) {
+			continue
+		}
+		id, err := b.PlaceOrder(kiteconnect.VarietyRegular, ExitParams(p))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("square off %s: %w", p.Tradingsymbol, err))
+			continue
+		}
+		placed = append(placed, id)
+	}
+	return placed, errors.Join(errs...)
+}
+
+// ExitParams is the market order which flattens a position
+func ExitParams(p kiteconnect.Position) kiteconnect.OrderParams {
+	params := kiteconnect.OrderParams{
+		Exchange:        p.Exchange,
+		Tradingsymbol:   p.Tradingsymbol,
+		TransactionType: kiteconnect.TransactionTypeSell,
+		OrderType:       kiteconnect.OrderTypeMarket,
+		Product:         p.Product,
+		Validity:        kiteconnect.ValidityDay,
+		Quantity:        p.Quantity,
+	}
+	if p.Quantity < 0 {
+		params.TransactionType = kiteconnect.TransactionTypeBuy
+		params.Quantity = -p.Quantity
+	}
+	return params
+}
@@ -0,0 +1,408 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/charges"
+)
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// Quote prices an instrument for paper fills
+type Quote func(exchange, tradingsymbol string) (float64, error)
+
+// Paper fills orders in memory against live prices, so signals can trade without money at risk.
+// Market and marketable limit orders fill at the quote. Limit and stop orders rest until Match
+// sees the quote reach them, or until cancelled.
+// Margin is the full notional, with no hedge benefit. Charges come out of cash, positions
+// show gross P&L like Kite's.
+type Paper struct {
+	quote   Quote
+	capital float64
+	now     func() time.Time
+
+	mu        sync.Mutex
+	seq       int
+	orders    []kiteconnect.Order
+	positions []*kiteconnect.Position
+	onUpdate  func(kiteconnect.Order)
+	rates     *charges.Table
+	charges   charges.Breakdown
+}
+
+// NewPaper returns a paper account holding capital
+func NewPaper(capital float64, quote Quote) *Paper {
+	return &Paper{quote: quote, capital: capital, now: time.Now}
+}
+
+// SetClock replaces the clock used to stamp orders
+func (p *Paper) SetClock(now func() time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.now = now
+}
+
+// SetCharges charges every fill at rates, fills are free until it's called
+func (p *Paper) SetCharges(rates *charges.Table) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates = rates
+}
+
+// Charges paid on fills so far
+func (p *Paper) Charges() charges.Breakdown {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.charges
+}
+
+// OnUpdate is called with every order as it's placed, filled or cancelled
+func (p *Paper) OnUpdate(fn func(kiteconnect.Order)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onUpdate = fn
+}
+
+func (p *Paper) PlaceOrder(variety string, params kiteconnect.OrderParams) (string, error) {
+	if params.Quantity <= 0 {
+		return "", errors.New("paper: quantity must be positive")
+	}
+	if params.TransactionType != kiteconnect.TransactionTypeBuy && params.TransactionType != kiteconnect.TransactionTypeSell {
+		return "", fmt.Errorf("paper: unknown transaction type %q", params.TransactionType)
+	}
+	switch params.OrderType {
+	case kiteconnect.OrderTypeLimit, kiteconnect.OrderTypeSL:
+		if params.Price <= 0 {
+			return "", fmt.Errorf("paper: %s orders need a price", params.OrderType)
+		}
+	}
+	switch params.OrderType {
+	case kiteconnect.OrderTypeSL, kiteconnect.OrderTypeSLM:
+		if params.TriggerPrice <= 0 {
+			return "", fmt.Errorf("paper: %s orders need a trigger price", params.OrderType)
+		}
+	}
+
+	// Price before taking the lock, quotes may go over the network
+	ltp, err := p.quote(params.Exchange, params.Tradingsymbol)
+	if err != nil {
+		return "", fmt.Errorf("paper: quote %s:%s: %w", params.Exchange, params.Tradingsymbol, err)
+	}
+
+	p.mu.Lock()
+	p.seq++
+	order := kiteconnect.Order{
+		OrderID:         fmt.Sprintf("paper-%d", p.seq),
+		Status:          "OPEN",
+		OrderTimestamp:  models.Time{Time: p.now()},
+		Variety:         variety,
+		Exchange:        params.Exchange,
+		TradingSymbol:   params.Tradingsymbol,
+		OrderType:       params.OrderType,
+		TransactionType: params.TransactionType,
+		Validity:        params.Validity,
+		Product:         params.Product,
+		Quantity:        float64(params.Quantity),
+		Price:           params.Price,
+		TriggerPrice:    params.TriggerPrice,
+		PendingQuantity: float64(params.Quantity),
+		Tag:             params.Tag,
+	}
+	if marketable(params, ltp) {
+		p.complete(&order, ltp)
+	} else if params.OrderType == kiteconnect.OrderTypeSL || params.OrderType == kiteconnect.OrderTypeSLM {
+		order.Status = "TRIGGER PENDING"
+	}
+	p.orders = append(p.orders, order)
+	onUpdate := p.onUpdate
+	p.mu.Unlock()
+
+	if onUpdate != nil {
+		onUpdate(order)
+	}
+	return order.OrderID, nil
+}
+
+// marketable reports whether an order fills at once at ltp
+func marketable(params kiteconnect.OrderParams, ltp float64) bool {
+	switch params.OrderType {
+	case kiteconnect.OrderTypeMarket, "":
+		return true
+	case kiteconnect.OrderTypeLimit:
+		return reached(params.TransactionType, params.Price, ltp)
+	}
+	return false
+}
+
+// reached reports whether ltp is at or better than a buy or sell limit
+func reached(side string, limit, ltp float64) bool {
+	if side == kiteconnect.TransactionTypeBuy {
+		return limit >= ltp
+	}
+	return limit <= ltp
+}
+
+// complete fills the whole order at price, callers hold p.mu
+func (p *Paper) complete(order *kiteconnect.Order, price float64) {
+	order.Status = "COMPLETE"
+	order.AveragePrice = price
+	order.FilledQuantity = order.Quantity
+	order.PendingQuantity = 0
+	p.fill(kiteconnect.OrderParams{
+		Exchange:        order.Exchange,
+		Tradingsymbol:   order.TradingSymbol,
+		Product:         order.Product,
+		TransactionType: order.TransactionType,
+		Quantity:        int(order.Quantity),
+	}, price)
+	if p.rates != nil {
+		p.charges = p.charges.Add(p.rates.Order(order.Exchange, order.TradingSymbol, order.Product, order.TransactionType, order.Quantity, price))
+	}
+}
+
+// Match re-quotes instruments with resting orders and fills those the quote has reached.
+// A stop triggers when the quote crosses its trigger price, SL-M then fills at the quote
+// and SL rests as a limit at its price.
+func (p *Paper) Match() {
+	p.mu.Lock()
+	var resting [][2]string
+	for _, o := range p.orders {
+		key := [2]string{o.Exchange, o.TradingSymbol}
+		if IsOpen(o) && !slices.Contains(resting, key) {
+			resting = append(resting, key)
+		}
+	}
+	p.mu.Unlock()
+	if len(resting) == 0 {
+		return
+	}
+
+	// Quote outside the lock, an instrument that fails to quote waits for the next round
+	quotes := map[[2]string]float64{}
+	for _, key := range resting {
+		if ltp, err := p.quote(key[0], key[1]); err == nil {
+			quotes[key] = ltp
+		}
+	}
+
+	p.mu.Lock()
+	var updates []kiteconnect.Order
+	for i := range p.orders {
+		o := &p.orders[i]
+		ltp, ok := quotes[[2]string{o.Exchange, o.TradingSymbol}]
+		if !ok || !IsOpen(*o) {
+			continue
+		}
+		if o.Status == "TRIGGER PENDING" {
+			// A buy stop triggers on a rise to its trigger, a sell stop on a fall
+			triggered := ltp >= o.TriggerPrice
+			if o.TransactionType == kiteconnect.TransactionTypeSell {
+				triggered = ltp <= o.TriggerPrice
+			}
+			if !triggered {
+				continue
+			}
+			if o.OrderType == kiteconnect.OrderTypeSLM {
+				p.complete(o, ltp)
+				updates = append(updates, *o)
+				continue
+			}
+			o.Status = "OPEN"
+			if !reached(o.TransactionType, o.Price, ltp) {
+				updates = append(updates, *o)
+				continue
+			}
+		} else if !reached(o.TransactionType, o.Price, ltp) {
+			continue
+		}
+		p.complete(o, ltp)
+		updates = append(updates, *o)
+	}
+	onUpdate := p.onUpdate
+	p.mu.Unlock()
+
+	if onUpdate != nil {
+		for _, o := range updates {
+			onUpdate(o)
+		}
+	}
+}
+
+// Run matches resting orders every interval until ctx is done
+func (p *Paper) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Match()
+		}
+	}
+}
+
+// fill books a trade into its position, callers hold p.mu
+func (p *Paper) fill(params kiteconnect.OrderParams, price float64) {
+	var pos *kiteconnect.Position
+	for _, existing := range p.positions {
+		if existing.Exchange == params.Exchange && existing.Tradingsymbol == params.Tradingsymbol && existing.Product == params.Product {
+			pos = existing
+			break
+		}
+	}
+	if pos == nil {
+		pos = &kiteconnect.Position{Exchange: params.Exchange, Tradingsymbol: params.Tradingsymbol, Product: params.Product, Multiplier: 1}
+		p.positions = append(p.positions, pos)
+	}
+
+	qty := params.Quantity
+	value := float64(qty) * price
+	if params.TransactionType == kiteconnect.TransactionTypeBuy {
+		pos.BuyQuantity += qty
+		pos.BuyValue += value
+		pos.BuyPrice = pos.BuyValue / float64(pos.BuyQuantity)
+	} else {
+		pos.SellQuantity += qty
+		pos.SellValue += value
+		pos.SellPrice = pos.SellValue / float64(pos.SellQuantity)
+		qty = -qty
+	}
+
+	// Adding to the position averages in, reducing it books the difference
+	switch {
+	case pos.Quantity == 0 || (pos.Quantity > 0) == (qty > 0):
+		open := math.Abs(float64(pos.Quantity))
+		pos.AveragePrice = (pos.AveragePrice*open + price*math.Abs(float64(qty))) / (open + math.Abs(float64(qty)))
+	default:
+		closed := min(abs(qty), abs(pos.Quantity))
+		if pos.Quantity > 0 {
+			pos.Realised += (price - pos.AveragePrice) * float64(closed)
+		} else {
+			pos.Realised += (pos.AveragePrice - price) * float64(closed)
+		}
+		if abs(qty) > abs(pos.Quantity) {
+			pos.AveragePrice = price
+		}
+	}
+	pos.Quantity += qty
+	if pos.Quantity == 0 {
+		pos.AveragePrice = 0
+	}
+	pos.LastPrice = price
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func (p *Paper) CancelOrder(variety, orderID string) error {
+	p.mu.Lock()
+	var cancelled *kiteconnect.Order
+	for i := range p.orders {
+		if p.orders[i].OrderID != orderID {
+			continue
+		}
+		if !IsOpen(p.orders[i]) {
+			p.mu.Unlock()
+			return fmt.Errorf("paper: order %s is %s", orderID, p.orders[i].Status)
+		}
+		p.orders[i].Status = "CANCELLED"
+		p.orders[i].CancelledQuantity = p.orders[i].PendingQuantity
+		p.orders[i].PendingQuantity = 0
+		cancelled = &p.orders[i]
+		break
+	}
+	if cancelled == nil {
+		p.mu.Unlock()
+		return fmt.Errorf("paper: %w: %s", ErrOrderNotFound, orderID)
+	}
+	order := *cancelled
+	onUpdate := p.onUpdate
+	p.mu.Unlock()
+
+	if onUpdate != nil {
+		onUpdate(order)
+	}
+	return nil
+}
+
+func (p *Paper) Orders() (kiteconnect.Orders, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append(kiteconnect.Orders{}, p.orders...), nil
+}
+
+// Positions are marked to the latest quote, or the last fill when quoting fails
+func (p *Paper) Positions() (kiteconnect.Positions, error) {
+	p.mu.Lock()
+	positions := make([]kiteconnect.Position, len(p.positions))
+	for i, pos := range p.positions {
+		positions[i] = *pos
+	}
+	p.mu.Unlock()
+
+	for i := range positions {
+		pos := &positions[i]
+		if ltp, err := p.quote(pos.Exchange, pos.Tradingsymbol); err == nil {
+			pos.LastPrice = ltp
+		}
+		pos.Value = pos.SellValue - pos.BuyValue
+		pos.Unrealised = (pos.LastPrice - pos.AveragePrice) * float64(pos.Quantity)
+		pos.PnL = pos.Realised + pos.Unrealised
+		pos.M2M = pos.PnL
+	}
+	return kiteconnect.Positions{Net: positions, Day: slices.Clone(positions)}, nil
+}
+
+// Margins treat capital plus realised P&L less charges as cash and open notional as used
+func (p *Paper) Margins() (kiteconnect.AllMargins, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var used, realised float64
+	for _, pos := range p.positions {
+		used += math.Abs(float64(pos.Quantity)) * pos.AveragePrice
+		realised += pos.Realised
+	}
+	return kiteconnect.AllMargins{Equity: kiteconnect.Margins{
+		Enabled:   true,
+		Net:       p.capital + realised - p.charges.Total - used,
+		Available: kiteconnect.AvailableMargins{Cash: p.capital, OpeningBalance: p.capital},
+		Used:      kiteconnect.UsedMargins{Debits: used, M2MRealised: realised},
+	}}, nil
+}
+
+func (p *Paper) OrderMargin(params kiteconnect.OrderParams) (float64, error) {
+	price := params.Price
+	if params.OrderType == kiteconnect.OrderTypeMarket || price == 0 {
+		var err error
+		if price, err = p.quote(params.Exchange, params.Tradingsymbol); err != nil {
+			return 0, fmt.Errorf("paper: quote %s:%s: %w", params.Exchange, params.Tradingsymbol, err)
+		}
+	}
+	return float64(params.Quantity) * price, nil
+}
+
+func (p *Paper) BasketMargin(params []kiteconnect.OrderParams) (float64, error) {
+	total := 0.0
+	for _, leg := range params {
+		margin, err := p.OrderMargin(leg)
+		if err != nil {
+			return 0, err
+		}
+		total += margin
+	}
+	return total, nil
+}
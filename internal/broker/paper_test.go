@@ -0,0 +1,189 @@
+package broker_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/broker"
+	"friction-trading/internal/charges"
+)
+
+// Interface check, the paper account stands in for Kite
+var _ broker.Broker = (*broker.Paper)(nil)
+
+func TestPaperFills(t *testing.T) {
+	ltp := 100.0
+	p := broker.NewPaper(100000, func(exchange, symbol string) (float64, error) { return ltp, nil })
+	var updates []kiteconnect.Order
+	p.OnUpdate(func(o kiteconnect.Order) { updates = append(updates, o) })
+
+	order := func(side, typ string, qty int, price float64) (string, error) {
+		return p.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+			Exchange: "NSE", Tradingsymbol: "SBIN", Product: kiteconnect.ProductMIS,
+			TransactionType: side, OrderType: typ, Quantity: qty, Price: price,
+		})
+	}
+
+	if _, err := order(kiteconnect.TransactionTypeBuy, kiteconnect.OrderTypeMarket, 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	ltp = 110
+	if _, err := order(kiteconnect.TransactionTypeBuy, kiteconnect.OrderTypeLimit, 10, 112); err != nil {
+		t.Fatal(err)
+	}
+
+	// Below the market, so it rests
+	resting, err := order(kiteconnect.TransactionTypeBuy, kiteconnect.OrderTypeLimit, 10, 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sell 15 of the 20 at 120, booking 15 x (120 - 105)
+	ltp = 120
+	if _, err := order(kiteconnect.TransactionTypeSell, kiteconnect.OrderTypeMarket, 15, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	positions, _ := p.Positions()
+	pos := positions.Net[0]
+	if pos.Quantity != 5 || pos.AveragePrice != 105 || pos.Realised != 225 || pos.Unrealised != 75 || pos.PnL != 300 {
+		t.Errorf("unexpected position %+v", pos)
+	}
+
+	margins, _ := p.Margins()
+	if margins.Equity.Used.Debits != 525 || math.Abs(margins.Equity.Net-(100000+225-525)) > 1e-9 {
+		t.Errorf("unexpected margins %+v", margins.Equity)
+	}
+
+	if err := p.CancelOrder(kiteconnect.VarietyRegular, resting); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CancelOrder(kiteconnect.VarietyRegular, resting); err == nil {
+		t.Error("want cancelling twice to fail")
+	}
+	if err := p.CancelOrder(kiteconnect.VarietyRegular, "nope"); !errors.Is(err, broker.ErrOrderNotFound) {
+		t.Errorf("want ErrOrderNotFound, got %v", err)
+	}
+
+	orders, _ := p.Orders()
+	statuses := []string{}
+	for _, o := range orders {
+		statuses = append(statuses, o.Status)
+	}
+	if len(updates) != 5 || statuses[0] != "COMPLETE" || statuses[2] != "CANCELLED" || statuses[3] != "COMPLETE" {
+		t.Errorf("unexpected orders %v after %d updates", statuses, len(updates))
+	}
+}
+
+func TestPaperRestingOrders(t *testing.T) {
+	ltp := 100.0
+	p := broker.NewPaper(100000, func(exchange, symbol string) (float64, error) { return ltp, nil })
+	var updates []kiteconnect.Order
+	p.OnUpdate(func(o kiteconnect.Order) { updates = append(updates, o) })
+
+	order := func(side, typ string, price, trigger float64) (string, error) {
+		return p.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+			Exchange: "NSE", Tradingsymbol: "SBIN", Product: kiteconnect.ProductMIS,
+			TransactionType: side, OrderType: typ, Quantity: 10, Price: price, TriggerPrice: trigger,
+		})
+	}
+	if _, err := order(kiteconnect.TransactionTypeSell, kiteconnect.OrderTypeLimit, 0, 0); err == nil {
+		t.Error("want a LIMIT without a price rejected")
+	}
+	if _, err := order(kiteconnect.TransactionTypeSell, kiteconnect.OrderTypeSLM, 0, 0); err == nil {
+		t.Error("want an SL-M without a trigger rejected")
+	}
+
+	for _, o := range []struct {
+		side, typ      string
+		price, trigger float64
+	}{
+		{kiteconnect.TransactionTypeBuy, kiteconnect.OrderTypeLimit, 95, 0},
+		{kiteconnect.TransactionTypeSell, kiteconnect.OrderTypeSLM, 0, 90},
+		{kiteconnect.TransactionTypeBuy, kiteconnect.OrderTypeSL, 106, 105},
+	} {
+		if _, err := order(o.side, o.typ, o.price, o.trigger); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status := func() []string {
+		orders, _ := p.Orders()
+		statuses := []string{}
+		for _, o := range orders {
+			statuses = append(statuses, o.Status)
+		}
+		return statuses
+	}
+
+	placed := len(updates)
+	p.Match()
+	if len(updates) != placed {
+		t.Errorf("want nothing reached at 100, got %+v", updates[placed:])
+	}
+
+	// The limit buy fills on the dip, the sell stop on the fall through 90
+	ltp = 94
+	p.Match()
+	ltp = 89
+	p.Match()
+	orders, _ := p.Orders()
+	if orders[0].Status != "COMPLETE" || orders[0].AveragePrice != 94 || orders[1].Status != "COMPLETE" || orders[1].AveragePrice != 89 {
+		t.Errorf("want the limit at 94 and the stop at 89, got %v", status())
+	}
+
+	// The buy stop triggers above its limit and rests until the price comes back
+	ltp = 107
+	p.Match()
+	if got := status(); got[2] != "OPEN" {
+		t.Errorf("want the triggered SL open at its limit, got %v", got)
+	}
+	ltp = 105.5
+	p.Match()
+	orders, _ = p.Orders()
+	if orders[2].Status != "COMPLETE" || orders[2].AveragePrice != 105.5 {
+		t.Errorf("want the SL filled at 105.5, got %+v", orders[2])
+	}
+	if len(updates) != placed+4 {
+		t.Errorf("want an update per fill and one for the trigger, got %d", len(updates)-placed)
+	}
+
+	positions, _ := p.Positions()
+	if pos := positions.Net[0]; pos.Quantity != 10 || pos.Realised != -50 {
+		t.Errorf("want long 10 after losing 5 a unit on the stop, got %+v", pos)
+	}
+}
+
+func TestPaperCharges(t *testing.T) {
+	ltp := 100.0
+	p := broker.NewPaper(100000, func(exchange, symbol string) (float64, error) { return ltp, nil })
+	p.SetCharges(charges.Zerodha())
+	order := func(side, typ string, price float64) {
+		if _, err := p.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+			Exchange: "NSE", Tradingsymbol: "SBIN", Product: kiteconnect.ProductMIS,
+			TransactionType: side, OrderType: typ, Quantity: 10, Price: price,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	order(kiteconnect.TransactionTypeBuy, kiteconnect.OrderTypeMarket, 0)
+	order(kiteconnect.TransactionTypeBuy, kiteconnect.OrderTypeLimit, 90) // rests, so it costs nothing
+	ltp = 110
+	order(kiteconnect.TransactionTypeSell, kiteconnect.OrderTypeMarket, 0)
+
+	z := charges.Zerodha()
+	want := z.Order("NSE", "SBIN", "MIS", "BUY", 10, 100).Add(z.Order("NSE", "SBIN", "MIS", "SELL", 10, 110))
+	if got := p.Charges(); math.Abs(got.Total-want.Total) > 1e-9 || got.STT == 0 {
+		t.Errorf("want charges %+v, got %+v", want, got)
+	}
+
+	// Positions stay gross, cash pays the charges
+	positions, _ := p.Positions()
+	margins, _ := p.Margins()
+	if positions.Net[0].Realised != 100 || math.Abs(margins.Equity.Net-(100000+100-want.Total)) > 1e-9 {
+		t.Errorf("unexpected realised %.2f and net cash %.2f", positions.Net[0].Realised, margins.Equity.Net)
+	}
+}
@@ -0,0 +1,250 @@
+// Package bus is a typed in-process pub/sub, each subscriber drains its own
+// bounded queue on its own goroutine so slow consumers don't hold up the rest
+package bus
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Default queue length per subscriber
+const DefaultQueue = 256
+
+// Policy decides what a publish does when a subscriber's queue is full
+type Policy int
+
+const (
+	// Drop the event for that subscriber and count it
+	Drop Policy = iota
+	// Block the publisher until there's room, for consumers that can't miss events
+	Block
+)
+
+func (p Policy) String() string {
+	if p == Block {
+		return "block"
+	}
+	return "drop"
+}
+
+func (p Policy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Policy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "drop":
+		*p = Drop
+	case "block":
+		*p = Block
+	default:
+		return fmt.Errorf("unknown policy %q, want drop or block", text)
+	}
+	return nil
+}
+
+// Key is what subscribers filter an event on
+type Key struct {
+	Token    uint32 // instrument, 0 when none
+	Strategy string // strategy instance or order tag, empty when none
+}
+
+// Options for a subscription, empty filters match everything
+type Options struct {
+	Name       string
+	Queue      int // DefaultQueue when 0
+	Policy     Policy
+	Tokens     []uint32
+	Strategies []string
+}
+
+// SubStats describes one subscriber
+type SubStats struct {
+	Name      string `json:"name"`
+	Policy    Policy `json:"policy"`
+	Queued    int    `json:"queued"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// Stats describes a bus and its subscribers
+type Stats struct {
+	Published   uint64     `json:"published"`
+	Subscribers []SubStats `json:"subscribers"`
+}
+
+// Bus carries events of one type
+type Bus[T any] struct {
+	key func(T) Key
+
+	mu   sync.RWMutex
+	subs []*Subscription[T]
+
+	published atomic.Uint64
+}
+
+// New returns a bus which filters events on key(event)
+func New[T any](key func(T) Key) *Bus[T] {
+	return &Bus[T]{key: key}
+}
+
+// Subscription is one consumer of a bus
+type Subscription[T any] struct {
+	bus        *Bus[T]
+	opts       Options
+	tokens     map[uint32]struct{}
+	strategies map[string]struct{}
+	handle     func(T)
+
+	queue chan T
+	once  sync.Once
+	done  chan struct{}
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// Subscribe calls handle with every matching event, in publish order, on a
+// goroutine of its own until the subscription is closed
+func (b *Bus[T]) Subscribe(opts Options, handle func(T)) *Subscription[T] {
+	if opts.Queue <= 0 {
+		opts.Queue = DefaultQueue
+	}
+	sub := &Subscription[T]{
+		bus:    b,
+		opts:   opts,
+		handle: handle,
+		queue:  make(chan T, opts.Queue),
+		done:   make(chan struct{}),
+	}
+	if len(opts.Tokens) > 0 {
+		sub.tokens = map[uint32]struct{}{}
+		for _, t := range opts.Tokens {
+			sub.tokens[t] = struct{}{}
+		}
+	}
+	if len(opts.Strategies) > 0 {
+		sub.strategies = map[string]struct{}{}
+		for _, st := range opts.Strategies {
+			sub.strategies[st] = struct{}{}
+		}
+	}
+
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	go sub.run()
+	return sub
+}
+
+func (sub *Subscription[T]) run() {
+	for {
+		select {
+		case <-sub.done:
+			return
+		case v := <-sub.queue:
+			sub.handle(v)
+			sub.delivered.Add(1)
+		}
+	}
+}
+
+func (sub *Subscription[T]) matches(k Key) bool {
+	if sub.tokens != nil {
+		if _, ok := sub.tokens[k.Token]; !ok {
+			return false
+		}
+	}
+	if sub.strategies != nil {
+		if _, ok := sub.strategies[k.Strategy]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// offer queues v under the subscription's policy
+func (sub *Subscription[T]) offer(v T) {
+	if sub.opts.Policy == Block {
+		select {
+		case sub.queue <- v:
+		case <-sub.done:
+		}
+		return
+	}
+	select {
+	case sub.queue <- v:
+	default:
+		sub.dropped.Add(1)
+	}
+}
+
+// Done is closed once the subscription is closed
+func (sub *Subscription[T]) Done() <-chan struct{} {
+	return sub.done
+}
+
+// Close stops delivery, queued events are discarded. A handler already running finishes.
+func (sub *Subscription[T]) Close() {
+	sub.once.Do(func() {
+		b := sub.bus
+		b.mu.Lock()
+		b.subs = slices.DeleteFunc(b.subs, func(s *Subscription[T]) bool { return s == sub })
+		b.mu.Unlock()
+		close(sub.done)
+	})
+}
+
+func (sub *Subscription[T]) stats() SubStats {
+	return SubStats{
+		Name:      sub.opts.Name,
+		Policy:    sub.opts.Policy,
+		Queued:    len(sub.queue),
+		Delivered: sub.delivered.Load(),
+		Dropped:   sub.dropped.Load(),
+	}
+}
+
+// Publish hands v to every matching subscriber. It only waits on subscribers
+// with the Block policy whose queues are full.
+func (b *Bus[T]) Publish(v T) {
+	b.published.Add(1)
+	k := b.key(v)
+
+	b.mu.RLock()
+	subs := make([]*Subscription[T], 0, len(b.subs))
+	for _, sub := range b.subs {
+		if sub.matches(k) {
+			subs = append(subs, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		sub.offer(v)
+	}
+}
+
+// Stats of the bus and every subscriber
+func (b *Bus[T]) Stats() Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	stats := Stats{Published: b.published.Load(), Subscribers: []SubStats{}}
+	for _, sub := range b.subs {
+		stats.Subscribers = append(stats.Subscribers, sub.stats())
+	}
+	return stats
+}
+
+// Close ends every subscription
+func (b *Bus[T]) Close() {
+	b.mu.RLock()
+	subs := slices.Clone(b.subs)
+	b.mu.RUnlock()
+	for _, sub := range subs {
+		sub.Close()
+	}
+}
@@ -0,0 +1,122 @@
+package bus_test
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"friction-trading/internal/bus"
+)
+
+type event struct {
+	token    uint32
+	strategy string
+	n        int
+}
+
+func newBus() *bus.Bus[event] {
+	return bus.New(func(e event) bus.Key { return bus.Key{Token: e.token, Strategy: e.strategy} })
+}
+
+// recorder collects what a subscriber was handed
+type recorder struct {
+	mu  sync.Mutex
+	got []int
+}
+
+func (r *recorder) handle(e event) {
+	r.mu.Lock()
+	r.got = append(r.got, e.n)
+	r.mu.Unlock()
+}
+
+func (r *recorder) wait(t *testing.T, n int) []int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		if len(r.got) >= n {
+			got := slices.Clone(r.got)
+			r.mu.Unlock()
+			return got
+		}
+		r.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("want %d events, got %v", n, r.got)
+	return nil
+}
+
+func TestFilters(t *testing.T) {
+	b := newBus()
+	defer b.Close()
+
+	var all, byToken, byStrategy recorder
+	b.Subscribe(bus.Options{Name: "all"}, all.handle)
+	b.Subscribe(bus.Options{Name: "token", Tokens: []uint32{1}}, byToken.handle)
+	b.Subscribe(bus.Options{Name: "strategy", Strategies: []string{"sma:1"}}, byStrategy.handle)
+
+	b.Publish(event{token: 1, n: 1})
+	b.Publish(event{token: 2, strategy: "sma:1", n: 2})
+	b.Publish(event{token: 1, strategy: "sma:1", n: 3})
+
+	if got := all.wait(t, 3); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("want every event in order, got %v", got)
+	}
+	if got := byToken.wait(t, 2); !slices.Equal(got, []int{1, 3}) {
+		t.Errorf("want token 1 events, got %v", got)
+	}
+	if got := byStrategy.wait(t, 2); !slices.Equal(got, []int{2, 3}) {
+		t.Errorf("want sma:1 events, got %v", got)
+	}
+	if stats := b.Stats(); stats.Published != 3 || len(stats.Subscribers) != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	b := newBus()
+	defer b.Close()
+
+	// Both subscribers are stuck on their first event
+	release := make(chan struct{})
+	var dropped, blocked recorder
+	b.Subscribe(bus.Options{Name: "drop", Queue: 1}, func(e event) { <-release; dropped.handle(e) })
+	blocking := b.Subscribe(bus.Options{Name: "block", Queue: 1, Policy: bus.Block}, func(e event) { <-release; blocked.handle(e) })
+
+	published := make(chan struct{})
+	go func() {
+		for n := 1; n <= 4; n++ {
+			b.Publish(event{n: n})
+		}
+		close(published)
+	}()
+
+	// The blocking subscriber holds the publisher back
+	select {
+	case <-published:
+		t.Fatal("publisher ran past a full blocking queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-published
+
+	if got := blocked.wait(t, 4); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("want every event on the blocking subscriber, got %v", got)
+	}
+	got := dropped.wait(t, 2)
+	stats := b.Stats()
+	if stats.Subscribers[0].Dropped == 0 || int(stats.Subscribers[0].Dropped)+len(got) != 4 {
+		t.Errorf("want the dropping subscriber to count what it missed, got %v and %+v", got, stats.Subscribers[0])
+	}
+
+	// Closing a blocking subscriber frees the publisher
+	blocking.Close()
+	for n := 5; n < 10; n++ {
+		b.Publish(event{n: n})
+	}
+	if len(b.Stats().Subscribers) != 1 {
+		t.Error("want the closed subscriber gone")
+	}
+}
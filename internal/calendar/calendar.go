@@ -0,0 +1,295 @@
+// Exchange trading calendar :- sessions, holidays and expiries
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// IST is fixed at +05:30, India doesn't observe DST
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// Exchanges
+const (
+	NSE = "NSE"
+	BSE = "BSE"
+	NFO = "NFO"
+	BFO = "BFO"
+	CDS = "CDS"
+	BCD = "BCD"
+	MCX = "MCX"
+)
+
+var ErrUnknownExchange = errors.New("unknown exchange")
+
+// SessionKind names a part of the trading day
+type SessionKind string
+
+const (
+	PreOpen SessionKind = "pre-open"
+	Normal  SessionKind = "normal"
+	Closing SessionKind = "closing"
+	Evening SessionKind = "evening"
+	Muhurat SessionKind = "muhurat"
+)
+
+// Session is one window of the trading day
+type Session struct {
+	Exchange string      `json:"exchange"`
+	Kind     SessionKind `json:"kind"`
+	Start    time.Time   `json:"start"`
+	End      time.Time   `json:"end"`
+}
+
+// Contains reports whether t falls in [Start, End)
+func (s Session) Contains(t time.Time) bool {
+	return !t.Before(s.Start) && t.Before(s.End)
+}
+
+// Trading reports whether orders match continuously in this session
+func (s Session) Trading() bool {
+	return s.Kind == Normal || s.Kind == Evening || s.Kind == Muhurat
+}
+
+// clock is minutes from midnight IST
+type clock int
+
+func hm(h, m int) clock { return clock(h*60 + m) }
+
+type window struct {
+	kind       SessionKind
+	start, end clock
+}
+
+// Regular sessions by exchange
+var regularSessions = map[string][]window{
+	NSE: {{PreOpen, hm(9, 0), hm(9, 15)}, {Normal, hm(9, 15), hm(15, 30)}, {Closing, hm(15, 40), hm(16, 0)}},
+	BSE: {{PreOpen, hm(9, 0), hm(9, 15)}, {Normal, hm(9, 15), hm(15, 30)}, {Closing, hm(15, 40), hm(16, 0)}},
+	NFO: {{Normal, hm(9, 15), hm(15, 30)}},
+	BFO: {{Normal, hm(9, 15), hm(15, 30)}},
+	CDS: {{Normal, hm(9, 0), hm(17, 0)}},
+	BCD: {{Normal, hm(9, 0), hm(17, 0)}},
+	MCX: {{Normal, hm(9, 0), hm(17, 0)}, {Evening, hm(17, 0), hm(23, 30)}},
+}
+
+// Holiday closes an exchange for the whole day, or only the listed sessions
+type Holiday struct {
+	Date      string   `huml:"date" json:"date"`
+	Name      string   `huml:"name" json:"name"`
+	Exchanges []string `huml:"exchanges" json:"exchanges"`
+	Sessions  []string `huml:"sessions" json:"sessions"`
+}
+
+// SpecialSession is a one-off session, e.g. Diwali muhurat trading
+type SpecialSession struct {
+	Date      string   `huml:"date" json:"date"`
+	Name      string   `huml:"name" json:"name"`
+	Exchanges []string `huml:"exchanges" json:"exchanges"`
+	Start     string   `huml:"start" json:"start"`
+	End       string   `huml:"end" json:"end"`
+}
+
+// appliesTo reports whether an exchange list covers exchange, empty means all
+func appliesTo(exchanges []string, exchange string) bool {
+	if len(exchanges) == 0 {
+		return true
+	}
+	for _, e := range exchanges {
+		if strings.EqualFold(e, exchange) {
+			return true
+		}
+	}
+	return false
+}
+
+// Calendar knows exchange sessions, holidays and expiry days
+type Calendar struct {
+	holidays map[string][]Holiday
+	specials map[string][]SpecialSession
+
+	// expiry weekday per derivatives exchange
+	expiryDays map[string]time.Weekday
+	// underlyings with weekly contracts and their exchange
+	weekly map[string]string
+}
+
+// New returns a calendar with weekends as the only holidays
+func New() *Calendar {
+	return &Calendar{
+		holidays:   map[string][]Holiday{},
+		specials:   map[string][]SpecialSession{},
+		expiryDays: map[string]time.Weekday{NFO: time.Tuesday, BFO: time.Thursday},
+		weekly:     map[string]string{"NIFTY": NFO, "SENSEX": BFO},
+	}
+}
+
+// AddHoliday registers a holiday
+func (c *Calendar) AddHoliday(h Holiday) error {
+	if _, err := time.ParseInLocation(time.DateOnly, h.Date, IST); err != nil {
+		return err
+	}
+	c.holidays[h.Date] = append(c.holidays[h.Date], h)
+	return nil
+}
+
+// AddSpecialSession registers a special session, it replaces regular sessions that day
+func (c *Calendar) AddSpecialSession(sp SpecialSession) error {
+	if _, err := time.ParseInLocation(time.DateOnly, sp.Date, IST); err != nil {
+		return err
+	}
+	if _, err := parseClock(sp.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(sp.End); err != nil {
+		return err
+	}
+	c.specials[sp.Date] = append(c.specials[sp.Date], sp)
+	return nil
+}
+
+// Holidays lists all registered holidays
+func (c *Calendar) Holidays() []Holiday {
+	var out []Holiday
+	for _, hs := range c.holidays {
+		out = append(out, hs...)
+	}
+	return out
+}
+
+// Sessions returns the sessions of exchange on the IST day of t, in order
+func (c *Calendar) Sessions(exchange string, t time.Time) ([]Session, error) {
+	exchange = strings.ToUpper(exchange)
+	windows, ok := regularSessions[exchange]
+	if !ok {
+		return nil, ErrUnknownExchange
+	}
+
+	t = t.In(IST)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, IST)
+	date := day.Format(time.DateOnly)
+
+	// Special sessions replace the regular day
+	var sessions []Session
+	for _, sp := range c.specials[date] {
+		if !appliesTo(sp.Exchanges, exchange) {
+			continue
+		}
+		start, _ := parseClock(sp.Start)
+		end, _ := parseClock(sp.End)
+		sessions = append(sessions, Session{exchange, Muhurat, at(day, start), at(day, end)})
+	}
+	if len(sessions) > 0 {
+		return sessions, nil
+	}
+
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return nil, nil
+	}
+
+	closed := map[SessionKind]bool{}
+	for _, h := range c.holidays[date] {
+		if !appliesTo(h.Exchanges, exchange) {
+			continue
+		}
+		// Whole day holiday
+		if len(h.Sessions) == 0 {
+			return nil, nil
+		}
+		for _, k := range h.Sessions {
+			closed[SessionKind(strings.ToLower(k))] = true
+		}
+	}
+
+	for _, w := range windows {
+		if closed[w.kind] {
+			continue
+		}
+		end := w.end
+		if exchange == MCX && w.kind == Evening && usDST(day) {
+			end = hm(23, 55)
+		}
+		sessions = append(sessions, Session{exchange, w.kind, at(day, w.start), at(day, end)})
+	}
+	return sessions, nil
+}
+
+// IsTradingDay reports whether exchange has any trading session on the day of t
+func (c *Calendar) IsTradingDay(exchange string, t time.Time) bool {
+	sessions, _ := c.Sessions(exchange, t)
+	for _, s := range sessions {
+		if s.Trading() {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionAt returns the session in progress at t
+func (c *Calendar) SessionAt(exchange string, t time.Time) (Session, bool) {
+	sessions, _ := c.Sessions(exchange, t)
+	for _, s := range sessions {
+		if s.Contains(t) {
+			return s, true
+		}
+	}
+	return Session{}, false
+}
+
+// IsOpen reports whether exchange is in a trading session at t
+func (c *Calendar) IsOpen(exchange string, t time.Time) bool {
+	s, ok := c.SessionAt(exchange, t)
+	return ok && s.Trading()
+}
+
+// NextOpen returns t if exchange is open, otherwise the start of its next trading session.
+// Zero time if nothing opens within a month.
+func (c *Calendar) NextOpen(exchange string, t time.Time) time.Time {
+	if c.IsOpen(exchange, t) {
+		return t
+	}
+	for i := 0; i <= 31; i++ {
+		sessions, err := c.Sessions(exchange, t.AddDate(0, 0, i))
+		if err != nil {
+			return time.Time{}
+		}
+		for _, s := range sessions {
+			if s.Trading() && s.Start.After(t) {
+				return s.Start
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// Close returns the end of the last trading session on the day of t, zero on a holiday
+func (c *Calendar) Close(exchange string, t time.Time) time.Time {
+	sessions, _ := c.Sessions(exchange, t)
+	var end time.Time
+	for _, s := range sessions {
+		if s.Trading() && s.End.After(end) {
+			end = s.End
+		}
+	}
+	return end
+}
+
+func at(day time.Time, c clock) time.Time {
+	return day.Add(time.Duration(c) * time.Minute)
+}
+
+// usDST reports whether US daylight saving is on, which stretches the MCX evening session.
+// Second Sunday of March to first Sunday of November.
+func usDST(day time.Time) bool {
+	start := nthSunday(day.Year(), time.March, 2)
+	end := nthSunday(day.Year(), time.November, 1)
+	return !day.Before(start) && day.Before(end)
+}
+
+func nthSunday(year int, month time.Month, n int) time.Time {
+	d := time.Date(year, month, 1, 0, 0, 0, 0, IST)
+	for d.Weekday() != time.Sunday {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d.AddDate(0, 0, 7*(n-1))
+}
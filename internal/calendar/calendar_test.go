@@ -0,0 +1,141 @@
+package calendar_test
+
+import (
+	"testing"
+	"time"
+
+	"friction-trading/internal/calendar"
+)
+
+func ist(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, calendar.IST)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestLoad(t *testing.T) {
+	for _, file := range []string{"testdata/holidays.huml", "testdata/holidays.csv"} {
+		t.Run(file, func(t *testing.T) {
+			c, err := calendar.Load(file)
+			if err != nil {
+				t.Fatal("Load Calendar Error :- ", err)
+			}
+			if got := len(c.Holidays()); got != 4 {
+				t.Errorf("want 4 holidays, got %d", got)
+			}
+			if !c.IsOpen(calendar.NSE, ist("2026-11-08 18:30")) {
+				t.Error("want NSE open for muhurat trading")
+			}
+		})
+	}
+}
+
+func TestIsOpen(t *testing.T) {
+	c, err := calendar.Load("testdata/holidays.huml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		exchange string
+		at       string
+		want     bool
+	}{
+		{calendar.NSE, "2026-01-27 10:00", true},
+		{calendar.NSE, "2026-01-27 09:05", false}, // pre-open
+		{calendar.NSE, "2026-01-27 15:45", false}, // closing session
+		{calendar.NSE, "2026-01-27 00:00", false},
+		{calendar.NFO, "2026-01-26 10:00", false}, // Republic Day
+		{calendar.NSE, "2026-01-31 10:00", false}, // Saturday
+		{calendar.MCX, "2026-01-27 22:00", true},  // evening session
+		{calendar.MCX, "2026-01-27 23:40", false},
+		{calendar.MCX, "2026-07-07 23:40", true}, // US DST extends the evening session
+		{calendar.MCX, "2026-12-25 10:00", false},
+		{calendar.MCX, "2026-12-25 18:00", true},
+		{calendar.NSE, "2026-11-08 10:00", false}, // Sunday, only muhurat
+		{calendar.NSE, "2026-11-08 18:30", true},
+	}
+
+	for _, tt := range tests {
+		if got := c.IsOpen(tt.exchange, ist(tt.at)); got != tt.want {
+			t.Errorf("IsOpen(%s, %s) = %v, want %v", tt.exchange, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestSessionAt(t *testing.T) {
+	c := calendar.New()
+	s, ok := c.SessionAt(calendar.NSE, ist("2026-01-27 09:05"))
+	if !ok || s.Kind != calendar.PreOpen {
+		t.Errorf("want pre-open session, got %v %v", s.Kind, ok)
+	}
+	if _, err := c.Sessions("XYZ", time.Now()); err != calendar.ErrUnknownExchange {
+		t.Errorf("want ErrUnknownExchange, got %v", err)
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+	c, err := calendar.Load("testdata/holidays.huml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		exchange string
+		at       string
+		want     string
+	}{
+		{calendar.NSE, "2026-01-27 10:00", "2026-01-27 10:00"},
+		{calendar.NSE, "2026-01-27 16:00", "2026-01-28 09:15"},
+		{calendar.NFO, "2026-01-23 16:00", "2026-01-27 09:15"}, // over weekend and Republic Day
+		{calendar.MCX, "2026-12-25 08:00", "2026-12-25 17:00"},
+	}
+
+	for _, tt := range tests {
+		if got := c.NextOpen(tt.exchange, ist(tt.at)); !got.Equal(ist(tt.want)) {
+			t.Errorf("NextOpen(%s, %s) = %v, want %v", tt.exchange, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c, err := calendar.Load("testdata/holidays.huml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		underlying string
+		at         string
+		want       string
+	}{
+		{"NIFTY", "2026-01-21 10:00", "2026-01-27 00:00"},
+		{"NIFTY", "2026-01-27 15:00", "2026-01-27 00:00"}, // expiry day itself
+		{"NIFTY", "2026-11-05 10:00", "2026-11-09 00:00"}, // Tuesday holiday moves to Monday
+		{"NIFTY", "2026-11-10 10:00", "2026-11-17 00:00"},
+		{"SENSEX", "2026-01-21 10:00", "2026-01-22 00:00"},
+	}
+
+	for _, tt := range tests {
+		got, err := c.WeeklyExpiry(tt.underlying, ist(tt.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(ist(tt.want)) {
+			t.Errorf("WeeklyExpiry(%s, %s) = %v, want %v", tt.underlying, tt.at, got, tt.want)
+		}
+	}
+
+	if _, err := c.WeeklyExpiry("BANKNIFTY", time.Now()); err != calendar.ErrNoWeeklyExpiry {
+		t.Errorf("want ErrNoWeeklyExpiry, got %v", err)
+	}
+
+	if got := c.MonthlyExpiry("BANKNIFTY", ist("2026-01-28 10:00")); !got.Equal(ist("2026-02-24 00:00")) {
+		t.Errorf("MonthlyExpiry = %v, want 2026-02-24", got)
+	}
+	if !c.IsExpiryDay("NIFTY", ist("2026-01-27 11:00")) {
+		t.Error("want 2026-01-27 to be a NIFTY expiry day")
+	}
+}
@@ -0,0 +1,99 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrNoWeeklyExpiry = errors.New("underlying has no weekly expiry")
+
+// SetExpiryDay changes the expiry weekday of a derivatives exchange
+func (c *Calendar) SetExpiryDay(exchange string, day time.Weekday) {
+	c.expiryDays[strings.ToUpper(exchange)] = day
+}
+
+// SetWeekly marks an underlying as having weekly contracts on exchange
+func (c *Calendar) SetWeekly(underlying, exchange string) {
+	c.weekly[strings.ToUpper(underlying)] = strings.ToUpper(exchange)
+}
+
+// underlyingExchange guesses the derivatives exchange of an underlying
+func (c *Calendar) underlyingExchange(underlying string) string {
+	if ex, ok := c.weekly[underlying]; ok {
+		return ex
+	}
+	switch underlying {
+	case "SENSEX", "BANKEX", "SENSEX50":
+		return BFO
+	}
+	return NFO
+}
+
+// WeeklyExpiry returns the IST date of the weekly expiry on or after the day of t.
+// An expiry falling on a holiday moves to the previous trading day.
+func (c *Calendar) WeeklyExpiry(underlying string, t time.Time) (time.Time, error) {
+	underlying = strings.ToUpper(underlying)
+	exchange, ok := c.weekly[underlying]
+	if !ok {
+		return time.Time{}, ErrNoWeeklyExpiry
+	}
+	weekday := c.expiryDays[exchange]
+
+	today := dayOf(t)
+	d := today
+	for d.Weekday() != weekday {
+		d = d.AddDate(0, 0, 1)
+	}
+	for {
+		expiry := c.previousTradingDay(exchange, d)
+		if !expiry.Before(today) {
+			return expiry, nil
+		}
+		d = d.AddDate(0, 0, 7)
+	}
+}
+
+// MonthlyExpiry returns the IST date of the monthly expiry on or after the day of t,
+// the last expiry weekday of the month moved back over holidays.
+func (c *Calendar) MonthlyExpiry(underlying string, t time.Time) time.Time {
+	exchange := c.underlyingExchange(strings.ToUpper(underlying))
+	weekday := c.expiryDays[exchange]
+
+	today := dayOf(t)
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, IST)
+	for {
+		d := month.AddDate(0, 1, -1)
+		for d.Weekday() != weekday {
+			d = d.AddDate(0, 0, -1)
+		}
+		expiry := c.previousTradingDay(exchange, d)
+		if !expiry.Before(today) {
+			return expiry
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+}
+
+// IsExpiryDay reports whether the day of t is the weekly, or else monthly, expiry of underlying
+func (c *Calendar) IsExpiryDay(underlying string, t time.Time) bool {
+	expiry, err := c.WeeklyExpiry(underlying, t)
+	if err != nil {
+		expiry = c.MonthlyExpiry(underlying, t)
+	}
+	return expiry.Equal(dayOf(t))
+}
+
+// previousTradingDay walks back from d until exchange trades
+func (c *Calendar) previousTradingDay(exchange string, d time.Time) time.Time {
+	for i := 0; i < 10 && !c.IsTradingDay(exchange, d); i++ {
+		d = d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+// dayOf truncates t to midnight IST
+func dayOf(t time.Time) time.Time {
+	t = t.In(IST)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, IST)
+}
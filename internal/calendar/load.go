@@ -0,0 +1,132 @@
+package calendar
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/huml-lang/go-huml"
+)
+
+// calendarFile is the HUML holiday file layout
+type calendarFile struct {
+	Holidays []Holiday        `huml:"holidays"`
+	Muhurat  []SpecialSession `huml:"muhurat"`
+}
+
+// Load builds a calendar from a .huml or .csv holiday file
+func Load(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error :- failed to open calendar file :- %v", err)
+	}
+	defer f.Close()
+
+	c := New()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".huml":
+		err = c.loadHUML(f)
+	case ".csv":
+		err = c.loadCSV(f)
+	default:
+		err = fmt.Errorf("unsupported calendar file %q", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error :- failed to load calendar :- %v", err)
+	}
+
+	return c, nil
+}
+
+func (c *Calendar) loadHUML(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var file calendarFile
+	if err := huml.Unmarshal(b, &file); err != nil {
+		return err
+	}
+
+	for _, h := range file.Holidays {
+		if err := c.AddHoliday(h); err != nil {
+			return fmt.Errorf("holiday %q: %v", h.Date, err)
+		}
+	}
+	for _, sp := range file.Muhurat {
+		if err := c.AddSpecialSession(sp); err != nil {
+			return fmt.Errorf("special session %q: %v", sp.Date, err)
+		}
+	}
+	return nil
+}
+
+// loadCSV reads a header row naming date, name, exchanges, sessions, start and end.
+// Rows with start and end are special sessions; list columns are "|" separated.
+func (c *Calendar) loadCSV(r io.Reader) error {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	cols := map[string]int{}
+	for i, name := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols["date"]; !ok {
+		return fmt.Errorf("missing date column")
+	}
+
+	get := func(row []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	for _, row := range records[1:] {
+		date := get(row, "date")
+		exchanges := splitList(get(row, "exchanges"))
+		start, end := get(row, "start"), get(row, "end")
+
+		if start != "" && end != "" {
+			sp := SpecialSession{Date: date, Name: get(row, "name"), Exchanges: exchanges, Start: start, End: end}
+			if err := c.AddSpecialSession(sp); err != nil {
+				return fmt.Errorf("special session %q: %v", date, err)
+			}
+			continue
+		}
+
+		h := Holiday{Date: date, Name: get(row, "name"), Exchanges: exchanges, Sessions: splitList(get(row, "sessions"))}
+		if err := c.AddHoliday(h); err != nil {
+			return fmt.Errorf("holiday %q: %v", date, err)
+		}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseClock parses "HH:MM" into minutes from midnight
+func parseClock(s string) (clock, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return hm(h, m), nil
+}
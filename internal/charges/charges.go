@@ -0,0 +1,191 @@
+// Package charges works out what an order costs on top of its price: brokerage, STT or CTT,
+// exchange transaction charges, SEBI fees, stamp duty and GST, by segment.
+package charges
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/huml-lang/go-huml"
+)
+
+// Segment picks the rates an order pays
+type Segment string
+
+const (
+	EquityDelivery   Segment = "equity_delivery"
+	EquityIntraday   Segment = "equity_intraday"
+	Futures          Segment = "futures"
+	Options          Segment = "options"
+	CurrencyFutures  Segment = "currency_futures"
+	CurrencyOptions  Segment = "currency_options"
+	CommodityFutures Segment = "commodity_futures"
+	CommodityOptions Segment = "commodity_options"
+)
+
+// Rates of one segment, fractions of the order's turnover unless noted. Option
+// turnover is the premium.
+type Rates struct {
+	Brokerage    float64 `huml:"brokerage" json:"brokerage"`         // 0 charges the cap flat
+	BrokerageCap float64 `huml:"brokerage_cap" json:"brokerage_cap"` // rupees per executed order, 0 is uncapped
+	STTBuy       float64 `huml:"stt_buy" json:"stt_buy"`             // STT, or CTT on commodities
+	STTSell      float64 `huml:"stt_sell" json:"stt_sell"`
+	Exchange     float64 `huml:"exchange" json:"exchange"` // transaction charges
+	SEBI         float64 `huml:"sebi" json:"sebi"`
+	StampBuy     float64 `huml:"stamp_buy" json:"stamp_buy"`
+	GST          float64 `huml:"gst" json:"gst"` // on brokerage, transaction charges and SEBI fees
+}
+
+// Table holds the rates of every segment
+type Table struct {
+	EquityDelivery   Rates `huml:"equity_delivery" json:"equity_delivery"`
+	EquityIntraday   Rates `huml:"equity_intraday" json:"equity_intraday"`
+	Futures          Rates `huml:"futures" json:"futures"`
+	Options          Rates `huml:"options" json:"options"`
+	CurrencyFutures  Rates `huml:"currency_futures" json:"currency_futures"`
+	CurrencyOptions  Rates `huml:"currency_options" json:"currency_options"`
+	CommodityFutures Rates `huml:"commodity_futures" json:"commodity_futures"`
+	CommodityOptions Rates `huml:"commodity_options" json:"commodity_options"`
+}
+
+// SEBI turnover fees are ₹10 a crore, GST is 18%, on every segment
+const (
+	sebi = 10.0 / 1e7
+	gst  = 0.18
+)
+
+// Zerodha's published rates, NSE transaction charges for equity and F&O
+func Zerodha() *Table {
+	return &Table{
+		EquityDelivery:   Rates{STTBuy: 0.001, STTSell: 0.001, Exchange: 0.0000297, SEBI: sebi, StampBuy: 0.00015, GST: gst},
+		EquityIntraday:   Rates{Brokerage: 0.0003, BrokerageCap: 20, STTSell: 0.00025, Exchange: 0.0000297, SEBI: sebi, StampBuy: 0.00003, GST: gst},
+		Futures:          Rates{Brokerage: 0.0003, BrokerageCap: 20, STTSell: 0.0002, Exchange: 0.0000173, SEBI: sebi, StampBuy: 0.00002, GST: gst},
+		Options:          Rates{BrokerageCap: 20, STTSell: 0.001, Exchange: 0.0003503, SEBI: sebi, StampBuy: 0.00003, GST: gst},
+		CurrencyFutures:  Rates{Brokerage: 0.0003, BrokerageCap: 20, Exchange: 0.0000035, SEBI: sebi, StampBuy: 0.000001, GST: gst},
+		CurrencyOptions:  Rates{BrokerageCap: 20, Exchange: 0.000311, SEBI: sebi, StampBuy: 0.000001, GST: gst},
+		CommodityFutures: Rates{Brokerage: 0.0003, BrokerageCap: 20, STTSell: 0.0001, Exchange: 0.000021, SEBI: sebi, StampBuy: 0.00002, GST: gst},
+		CommodityOptions: Rates{BrokerageCap: 20, STTSell: 0.0005, Exchange: 0.000418, SEBI: sebi, StampBuy: 0.00003, GST: gst},
+	}
+}
+
+// Load reads a .huml rate table over Zerodha's rates, segments or rates it leaves out keep theirs
+func Load(path string) (*Table, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error :- failed to open charges file :- %v", err)
+	}
+	t := Zerodha()
+	if err := huml.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("Error :- failed to load charges :- %v", err)
+	}
+	return t, nil
+}
+
+// Rates of a segment
+func (t *Table) Rates(seg Segment) (Rates, error) {
+	switch seg {
+	case EquityDelivery:
+		return t.EquityDelivery, nil
+	case EquityIntraday:
+		return t.EquityIntraday, nil
+	case Futures:
+		return t.Futures, nil
+	case Options:
+		return t.Options, nil
+	case CurrencyFutures:
+		return t.CurrencyFutures, nil
+	case CurrencyOptions:
+		return t.CurrencyOptions, nil
+	case CommodityFutures:
+		return t.CommodityFutures, nil
+	case CommodityOptions:
+		return t.CommodityOptions, nil
+	}
+	return Rates{}, fmt.Errorf("unknown segment %q", seg)
+}
+
+// SegmentOf reads the segment from a Kite exchange, tradingsymbol and product.
+// Equity is intraday under MIS and delivery otherwise.
+func SegmentOf(exchange, tradingsymbol, product string) Segment {
+	option := strings.HasSuffix(tradingsymbol, "CE") || strings.HasSuffix(tradingsymbol, "PE")
+	switch exchange {
+	case "NFO", "BFO":
+		if option {
+			return Options
+		}
+		return Futures
+	case "CDS", "BCD":
+		if option {
+			return CurrencyOptions
+		}
+		return CurrencyFutures
+	case "MCX":
+		if option {
+			return CommodityOptions
+		}
+		return CommodityFutures
+	}
+	if product == "MIS" {
+		return EquityIntraday
+	}
+	return EquityDelivery
+}
+
+// Breakdown of an order's charges, in rupees
+type Breakdown struct {
+	Brokerage float64 `json:"brokerage"`
+	STT       float64 `json:"stt"`
+	Exchange  float64 `json:"exchange"`
+	SEBI      float64 `json:"sebi"`
+	Stamp     float64 `json:"stamp"`
+	GST       float64 `json:"gst"`
+	Total     float64 `json:"total"`
+}
+
+// Add sums two breakdowns
+func (b Breakdown) Add(o Breakdown) Breakdown {
+	return Breakdown{
+		Brokerage: b.Brokerage + o.Brokerage,
+		STT:       b.STT + o.STT,
+		Exchange:  b.Exchange + o.Exchange,
+		SEBI:      b.SEBI + o.SEBI,
+		Stamp:     b.Stamp + o.Stamp,
+		GST:       b.GST + o.GST,
+		Total:     b.Total + o.Total,
+	}
+}
+
+// Order works out the charges of one executed order, side is BUY or SELL
+func (r Rates) Order(side string, quantity float64, price float64) Breakdown {
+	turnover := quantity * price
+	if turnover <= 0 {
+		return Breakdown{}
+	}
+	b := Breakdown{
+		Brokerage: r.BrokerageCap,
+		Exchange:  turnover * r.Exchange,
+		SEBI:      turnover * r.SEBI,
+	}
+	if r.Brokerage > 0 {
+		b.Brokerage = turnover * r.Brokerage
+		if r.BrokerageCap > 0 {
+			b.Brokerage = min(b.Brokerage, r.BrokerageCap)
+		}
+	}
+	if side == "BUY" {
+		b.STT = turnover * r.STTBuy
+		b.Stamp = turnover * r.StampBuy
+	} else {
+		b.STT = turnover * r.STTSell
+	}
+	b.GST = (b.Brokerage + b.Exchange + b.SEBI) * r.GST
+	b.Total = b.Brokerage + b.STT + b.Exchange + b.SEBI + b.Stamp + b.GST
+	return b
+}
+
+// Order works out the charges of an executed order on an instrument
+func (t *Table) Order(exchange, tradingsymbol, product, side string, quantity float64, price float64) Breakdown {
+	r, _ := t.Rates(SegmentOf(exchange, tradingsymbol, product))
+	return r.Order(side, quantity, price)
+}
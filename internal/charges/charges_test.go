@@ -0,0 +1,78 @@
+package charges_test
+
+import (
+	"math"
+	"testing"
+
+	"friction-trading/internal/charges"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestSegmentOf(t *testing.T) {
+	for _, tc := range []struct {
+		exchange, symbol, product string
+		want                      charges.Segment
+	}{
+		{"NSE", "SBIN", "CNC", charges.EquityDelivery},
+		{"BSE", "SBIN", "MIS", charges.EquityIntraday},
+		{"NFO", "NIFTY25O2325050CE", "NRML", charges.Options},
+		{"NFO", "NIFTY25OCTFUT", "MIS", charges.Futures},
+		{"BFO", "SENSEX25O2382000PE", "MIS", charges.Options},
+		{"CDS", "USDINR25OCTFUT", "NRML", charges.CurrencyFutures},
+		{"MCX", "CRUDEOIL25NOV5500CE", "NRML", charges.CommodityOptions},
+		{"MCX", "GOLDM25NOVFUT", "NRML", charges.CommodityFutures},
+	} {
+		if got := charges.SegmentOf(tc.exchange, tc.symbol, tc.product); got != tc.want {
+			t.Errorf("%s:%s %s: want %s, got %s", tc.exchange, tc.symbol, tc.product, tc.want, got)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	z := charges.Zerodha()
+
+	// A NIFTY lot bought at 100 and sold at 110: flat brokerage, STT on the sell
+	buy := z.Order("NFO", "NIFTY25O2325050CE", "NRML", "BUY", 75, 100)
+	sell := z.Order("NFO", "NIFTY25O2325050CE", "NRML", "SELL", 75, 110)
+	if buy.Brokerage != 20 || buy.STT != 0 || !near(buy.Stamp, 0.225) || sell.Stamp != 0 || !near(sell.STT, 8.25) {
+		t.Errorf("unexpected option charges, buy %+v, sell %+v", buy, sell)
+	}
+	if trip := buy.Add(sell); !near(trip.Total, 62.2039105) || !near(trip.GST, 0.18*(trip.Brokerage+trip.Exchange+trip.SEBI)) {
+		t.Errorf("want a 62.20 round trip, got %+v", trip)
+	}
+
+	// Intraday brokerage is 0.03% up to 20 an order, delivery pays none but STT both ways
+	if small := z.Order("NSE", "SBIN", "MIS", "BUY", 10, 800); !near(small.Brokerage, 2.4) {
+		t.Errorf("want 0.03%% brokerage on 8000, got %+v", small)
+	}
+	if big := z.Order("NSE", "SBIN", "MIS", "BUY", 100, 1000); big.Brokerage != 20 || !near(big.Total, 30.2226) {
+		t.Errorf("want brokerage capped at 20, got %+v", big)
+	}
+	if delivery := z.Order("NSE", "SBIN", "CNC", "SELL", 100, 1000); delivery.Brokerage != 0 || !near(delivery.Total, 103.6226) {
+		t.Errorf("unexpected delivery charges %+v", delivery)
+	}
+	if none := z.Order("NSE", "SBIN", "MIS", "BUY", 0, 800); none.Total != 0 {
+		t.Errorf("want nothing for an empty order, got %+v", none)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	table, err := charges.Load("testdata/charges.huml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Overrides what the file sets, the rest stays Zerodha's
+	options, _ := table.Rates(charges.Options)
+	if options.BrokerageCap != 10 || options.STTSell != 0.001 || table.EquityIntraday != charges.Zerodha().EquityIntraday {
+		t.Errorf("unexpected table %+v", table)
+	}
+	if _, err := table.Rates("bonds"); err == nil {
+		t.Error("want an error for an unknown segment")
+	}
+	if _, err := charges.Load("testdata/missing.huml"); err == nil {
+		t.Error("want an error for a missing file")
+	}
+}
@@ -28,8 +28,49 @@ type Config struct {
 	Kite struct {
 		API_KEY    string `huml:"API_KEY"`
 		API_SECRET string `huml:"API_SECRET"`
-		Token      string `huml:"Token"`
+		INSTRUMENT string `huml:"INSTRUMENT"` // streamed by the watch route, e.g. "NIFTY current-week ATM CE"
 	} `huml:"kite"`
+
+	Calendar struct {
+		HOLIDAYS string `huml:"HOLIDAYS"` // .huml or .csv holiday file
+	} `huml:"calendar"`
+
+	Risk struct {
+		MAX_DAILY_LOSS         float64 `huml:"MAX_DAILY_LOSS"`
+		MAX_OPEN_POSITIONS     int     `huml:"MAX_OPEN_POSITIONS"`
+		MAX_QTY_PER_SYMBOL     int     `huml:"MAX_QTY_PER_SYMBOL"`
+		MAX_ORDER_NOTIONAL     float64 `huml:"MAX_ORDER_NOTIONAL"`
+		MAX_MARGIN_UTILISATION float64 `huml:"MAX_MARGIN_UTILISATION"` // 0.8 = 80% of equity margin
+		MAX_NET_DELTA          float64 `huml:"MAX_NET_DELTA"`          // option book delta per underlying, in units
+		MAX_NET_VEGA           float64 `huml:"MAX_NET_VEGA"`           // option book vega per underlying, rupees per vol point
+	} `huml:"risk"`
+
+	// Alerts pushed by charting tools to /api/webhooks/signal
+	Webhook struct {
+		SECRET        string  `huml:"SECRET"`        // must match the payload's secret, empty disables the webhook
+		MODE          string  `huml:"MODE"`          // paper (default) or live
+		PAPER_CAPITAL float64 `huml:"PAPER_CAPITAL"` // starting cash of the paper account
+	} `huml:"webhook"`
+
+	// Strategy instances started once logged in
+	Strategies struct {
+		FILE string `huml:"FILE"` // .huml strategy definitions, empty starts none
+	} `huml:"strategies"`
+
+	// Brokerage and taxes charged on paper fills, backtests and the P&L report
+	Charges struct {
+		RATES string `huml:"RATES"` // .huml rate table over Zerodha's rates, empty uses Zerodha's
+	} `huml:"charges"`
+
+	// Intraday square-off time per exchange as "HH:MM" IST, empty leaves the exchange alone
+	Squareoff struct {
+		NSE string `huml:"NSE"`
+		BSE string `huml:"BSE"`
+		NFO string `huml:"NFO"`
+		BFO string `huml:"BFO"`
+		CDS string `huml:"CDS"`
+		MCX string `huml:"MCX"`
+	} `huml:"squareoff"`
 }
 
 // Load Config
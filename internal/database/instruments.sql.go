@@ -134,3 +134,195 @@ func (q *Queries) TruncateInstrument(ctx context.Context) (*TruncateInstrumentRo
 	err := row.Scan()
 	return &i, err
 }
+
+const getInstrumentBySymbol = `-- name: GetInstrumentBySymbol :one
+SELECT 
+id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange
+FROM instruments 
+WHERE exchange = $1 AND tradingsymbol = $2
+LIMIT 1
+`
+
+type GetInstrumentBySymbolParams struct {
+	Exchange      string `json:"exchange"`
+	Tradingsymbol string `json:"tradingsymbol"`
+}
+
+func (q *Queries) GetInstrumentBySymbol(ctx context.Context, arg GetInstrumentBySymbolParams) (*Instrument, error) {
+	row := q.db.QueryRow(ctx, getInstrumentBySymbol, arg.Exchange, arg.Tradingsymbol)
+	var i Instrument
+	err := row.Scan(
+		&i.ID,
+		&i.InstrumentToken,
+		&i.ExchangeToken,
+		&i.Tradingsymbol,
+		&i.Name,
+		&i.LastPrice,
+		&i.Expiry,
+		&i.Strike,
+		&i.TickSize,
+		&i.LotSize,
+		&i.InstrumentType,
+		&i.Segment,
+		&i.Exchange,
+	)
+	return &i, err
+}
+
+const getInstrumentByToken = `-- name: GetInstrumentByToken :one
+SELECT 
+id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange
+FROM instruments 
+WHERE instrument_token = $1
+LIMIT 1
+`
+
+func (q *Queries) GetInstrumentByToken(ctx context.Context, instrumentToken int64) (*Instrument, error) {
+	row := q.db.QueryRow(ctx, getInstrumentByToken, instrumentToken)
+	var i Instrument
+	err := row.Scan(
+		&i.ID,
+		&i.InstrumentToken,
+		&i.ExchangeToken,
+		&i.Tradingsymbol,
+		&i.Name,
+		&i.LastPrice,
+		&i.Expiry,
+		&i.Strike,
+		&i.TickSize,
+		&i.LotSize,
+		&i.InstrumentType,
+		&i.Segment,
+		&i.Exchange,
+	)
+	return &i, err
+}
+
+const listOptionExpiries = `-- name: ListOptionExpiries :many
+SELECT DISTINCT expiry
+FROM instruments
+WHERE exchange = $1 AND name = $2 AND instrument_type IN ('CE', 'PE') AND expiry >= $3
+ORDER BY expiry
+`
+
+type ListOptionExpiriesParams struct {
+	Exchange string           `json:"exchange"`
+	Name     string           `json:"name"`
+	Expiry   pgtype.Timestamp `json:"expiry"`
+}
+
+func (q *Queries) ListOptionExpiries(ctx context.Context, arg ListOptionExpiriesParams) ([]pgtype.Timestamp, error) {
+	rows, err := q.db.Query(ctx, listOptionExpiries, arg.Exchange, arg.Name, arg.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.Timestamp
+	for rows.Next() {
+		var expiry pgtype.Timestamp
+		if err := rows.Scan(&expiry); err != nil {
+			return nil, err
+		}
+		items = append(items, expiry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOptionChain = `-- name: ListOptionChain :many
+SELECT 
+id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange
+FROM instruments 
+WHERE exchange = $1 AND name = $2 AND expiry = $3 AND instrument_type IN ('CE', 'PE')
+ORDER BY strike, instrument_type
+`
+
+type ListOptionChainParams struct {
+	Exchange string           `json:"exchange"`
+	Name     string           `json:"name"`
+	Expiry   pgtype.Timestamp `json:"expiry"`
+}
+
+func (q *Queries) ListOptionChain(ctx context.Context, arg ListOptionChainParams) ([]*Instrument, error) {
+	rows, err := q.db.Query(ctx, listOptionChain, arg.Exchange, arg.Name, arg.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Instrument
+	for rows.Next() {
+		var i Instrument
+		if err := rows.Scan(
+			&i.ID,
+			&i.InstrumentToken,
+			&i.ExchangeToken,
+			&i.Tradingsymbol,
+			&i.Name,
+			&i.LastPrice,
+			&i.Expiry,
+			&i.Strike,
+			&i.TickSize,
+			&i.LotSize,
+			&i.InstrumentType,
+			&i.Segment,
+			&i.Exchange,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFutures = `-- name: ListFutures :many
+SELECT 
+id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange
+FROM instruments 
+WHERE exchange = $1 AND name = $2 AND instrument_type = 'FUT' AND expiry >= $3
+ORDER BY expiry
+`
+
+type ListFuturesParams struct {
+	Exchange string           `json:"exchange"`
+	Name     string           `json:"name"`
+	Expiry   pgtype.Timestamp `json:"expiry"`
+}
+
+func (q *Queries) ListFutures(ctx context.Context, arg ListFuturesParams) ([]*Instrument, error) {
+	rows, err := q.db.Query(ctx, listFutures, arg.Exchange, arg.Name, arg.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Instrument
+	for rows.Next() {
+		var i Instrument
+		if err := rows.Scan(
+			&i.ID,
+			&i.InstrumentToken,
+			&i.ExchangeToken,
+			&i.Tradingsymbol,
+			&i.Name,
+			&i.LastPrice,
+			&i.Expiry,
+			&i.Strike,
+			&i.TickSize,
+			&i.LotSize,
+			&i.InstrumentType,
+			&i.Segment,
+			&i.Exchange,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -8,6 +8,32 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ExitRule struct {
+	ID              int64              `json:"id"`
+	InstrumentToken int64              `json:"instrument_token"`
+	Exchange        string             `json:"exchange"`
+	Tradingsymbol   string             `json:"tradingsymbol"`
+	Product         string             `json:"product"`
+	Quantity        int32              `json:"quantity"`
+	EntryPrice      float64            `json:"entry_price"`
+	StopMode        string             `json:"stop_mode"`
+	StopValue       float64            `json:"stop_value"`
+	TargetMode      string             `json:"target_mode"`
+	TargetValue     float64            `json:"target_value"`
+	TrailMode       string             `json:"trail_mode"`
+	TrailValue      float64            `json:"trail_value"`
+	StopLoss        float64            `json:"stop_loss"`
+	Target          float64            `json:"target"`
+	TrailDistance   float64            `json:"trail_distance"`
+	HighWater       float64            `json:"high_water"`
+	Status          string             `json:"status"`
+	ExitReason      string             `json:"exit_reason"`
+	ExitOrderID     string             `json:"exit_order_id"`
+	Version         int64              `json:"version"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
 type Instrument struct {
 	ID              int32            `json:"id"`
 	InstrumentToken int64            `json:"instrument_token"`
@@ -23,3 +49,28 @@ type Instrument struct {
 	Segment         string           `json:"segment"`
 	Exchange        string           `json:"exchange"`
 }
+
+type OiSnapshot struct {
+	ID              int64              `json:"id"`
+	Underlying      string             `json:"underlying"`
+	Expiry          pgtype.Timestamp   `json:"expiry"`
+	Strike          float64            `json:"strike"`
+	OptionType      string             `json:"option_type"`
+	InstrumentToken int64              `json:"instrument_token"`
+	Oi              int64              `json:"oi"`
+	Volume          int64              `json:"volume"`
+	LastPrice       float64            `json:"last_price"`
+	Close           float64            `json:"close"`
+	CapturedAt      pgtype.Timestamptz `json:"captured_at"`
+}
+
+type Signal struct {
+	ID              int64              `json:"id"`
+	Strategy        string             `json:"strategy"`
+	InstrumentToken int64              `json:"instrument_token"`
+	Side            string             `json:"side"`
+	Price           float64            `json:"price"`
+	Indicators      []byte             `json:"indicators"`
+	SignalledAt     pgtype.Timestamptz `json:"signalled_at"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+}
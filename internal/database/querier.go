@@ -6,13 +6,27 @@ package database
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
 	CountInstruments(ctx context.Context) (int64, error)
+	CreateExitRule(ctx context.Context, arg CreateExitRuleParams) (*ExitRule, error)
+	CreateOISnapshots(ctx context.Context, arg []CreateOISnapshotsParams) (int64, error)
+	CreateSignal(ctx context.Context, arg CreateSignalParams) error
+	GetInstrumentBySymbol(ctx context.Context, arg GetInstrumentBySymbolParams) (*Instrument, error)
+	GetInstrumentByToken(ctx context.Context, instrumentToken int64) (*Instrument, error)
 	InsertInstrument(ctx context.Context, arg InsertInstrumentParams) (*Instrument, error)
+	ListActiveExitRules(ctx context.Context) ([]*ExitRule, error)
+	ListFutures(ctx context.Context, arg ListFuturesParams) ([]*Instrument, error)
+	ListOISnapshots(ctx context.Context, arg ListOISnapshotsParams) ([]*OiSnapshot, error)
+	ListOptionChain(ctx context.Context, arg ListOptionChainParams) ([]*Instrument, error)
+	ListOptionExpiries(ctx context.Context, arg ListOptionExpiriesParams) ([]pgtype.Timestamp, error)
+	ListSignals(ctx context.Context, arg ListSignalsParams) ([]*Signal, error)
 	SearchSymbol(ctx context.Context, tradingsymbol string) ([]*Instrument, error)
 	TruncateInstrument(ctx context.Context) (*TruncateInstrumentRow, error)
+	UpdateExitRule(ctx context.Context, arg UpdateExitRuleParams) error
 }
 
 var _ Querier = (*Queries)(nil)
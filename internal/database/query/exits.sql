@@ -0,0 +1,17 @@
+-- name: CreateExitRule :one
+INSERT INTO exit_rules (
+    instrument_token, exchange, tradingsymbol, product, quantity, entry_price,
+    stop_mode, stop_value, target_mode, target_value, trail_mode, trail_value,
+    stop_loss, target, trail_distance, high_water) VALUES
+    ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+    RETURNING *;
+
+-- name: ListActiveExitRules :many
+SELECT * FROM exit_rules
+WHERE status = 'active'
+ORDER BY id;
+
+-- name: UpdateExitRule :exec
+UPDATE exit_rules
+SET stop_loss = $2, high_water = $3, status = $4, exit_reason = $5, exit_order_id = $6, version = $7, updated_at = NOW()
+WHERE id = $1 AND version < $7;
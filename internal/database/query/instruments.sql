@@ -20,3 +20,40 @@ id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, s
 FROM instruments 
 WHERE CONCAT(tradingsymbol, ' ', CAST(strike AS TEXT) ) ILIKE $1;
 
+
+-- name: GetInstrumentBySymbol :one
+SELECT 
+id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange
+FROM instruments 
+WHERE exchange = $1 AND tradingsymbol = $2
+LIMIT 1;
+
+-- name: GetInstrumentByToken :one
+SELECT 
+id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange
+FROM instruments 
+WHERE instrument_token = $1
+LIMIT 1;
+
+
+-- name: ListOptionExpiries :many
+SELECT DISTINCT expiry
+FROM instruments
+WHERE exchange = $1 AND name = $2 AND instrument_type IN ('CE', 'PE') AND expiry >= $3
+ORDER BY expiry;
+
+
+-- name: ListOptionChain :many
+SELECT 
+id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange
+FROM instruments 
+WHERE exchange = $1 AND name = $2 AND expiry = $3 AND instrument_type IN ('CE', 'PE')
+ORDER BY strike, instrument_type;
+
+
+-- name: ListFutures :many
+SELECT 
+id, instrument_token, exchange_token, tradingsymbol, name, last_price, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange
+FROM instruments 
+WHERE exchange = $1 AND name = $2 AND instrument_type = 'FUT' AND expiry >= $3
+ORDER BY expiry;
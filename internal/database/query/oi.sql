@@ -0,0 +1,10 @@
+-- name: CreateOISnapshots :copyfrom
+INSERT INTO oi_snapshots (
+    underlying, expiry, strike, option_type, instrument_token,
+    oi, volume, last_price, close, captured_at) VALUES
+    ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+
+-- name: ListOISnapshots :many
+SELECT * FROM oi_snapshots
+WHERE underlying = $1 AND expiry = $2 AND captured_at >= $3
+ORDER BY captured_at, strike, option_type;
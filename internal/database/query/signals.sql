@@ -0,0 +1,14 @@
+-- name: CreateSignal :exec
+INSERT INTO signals (
+    strategy, instrument_token, side, price, indicators, signalled_at) VALUES
+    ($1, $2, $3, $4, $5, $6);
+
+-- name: ListSignals :many
+SELECT * FROM signals
+WHERE (sqlc.arg(strategy)::text = '' OR strategy = sqlc.arg(strategy))
+  AND (sqlc.arg(instrument_token)::bigint = 0 OR instrument_token = sqlc.arg(instrument_token))
+  AND (sqlc.arg(side)::text = '' OR side = sqlc.arg(side))
+  AND signalled_at >= sqlc.arg(since)
+  AND (sqlc.narg(until)::timestamptz IS NULL OR signalled_at < sqlc.narg(until))
+ORDER BY signalled_at DESC, id DESC
+LIMIT sqlc.arg(row_limit);
@@ -16,4 +16,65 @@ CREATE TABLE IF NOT EXISTS instruments(
     instrument_type     TEXT NOT NULL,
     segment             TEXT NOT NULL,
     exchange            TEXT NOT NULL
-);
\ No newline at end of file
+);
+
+-- Exit Rules :- stop-loss, target and trailing stop managed by the server
+CREATE TABLE IF NOT EXISTS exit_rules(
+    id                  BIGSERIAL PRIMARY KEY,
+    instrument_token    BIGINT NOT NULL,
+    exchange            TEXT NOT NULL,
+    tradingsymbol       TEXT NOT NULL,
+    product             TEXT NOT NULL,
+    quantity            INT NOT NULL,               -- signed, negative is short
+    entry_price         FLOAT8 NOT NULL,
+    stop_mode           TEXT NOT NULL DEFAULT '',
+    stop_value          FLOAT8 NOT NULL DEFAULT 0,
+    target_mode         TEXT NOT NULL DEFAULT '',
+    target_value        FLOAT8 NOT NULL DEFAULT 0,
+    trail_mode          TEXT NOT NULL DEFAULT '',
+    trail_value         FLOAT8 NOT NULL DEFAULT 0,
+    stop_loss           FLOAT8 NOT NULL DEFAULT 0,  -- price level, 0 is none
+    target              FLOAT8 NOT NULL DEFAULT 0,  -- price level, 0 is none
+    trail_distance      FLOAT8 NOT NULL DEFAULT 0,  -- points, 0 is no trailing
+    high_water          FLOAT8 NOT NULL,            -- best price since entry
+    status              TEXT NOT NULL DEFAULT 'active',
+    exit_reason         TEXT NOT NULL DEFAULT '',
+    exit_order_id       TEXT NOT NULL DEFAULT '',
+    version             BIGINT NOT NULL DEFAULT 0,  -- bumped on every change, older saves are dropped
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS exit_rules_status_idx ON exit_rules(status);
+
+-- OI Snapshots :- option chain open interest sampled through the day
+CREATE TABLE IF NOT EXISTS oi_snapshots(
+    id                  BIGSERIAL PRIMARY KEY,
+    underlying          TEXT NOT NULL,
+    expiry              TIMESTAMP NOT NULL,
+    strike              FLOAT8 NOT NULL,
+    option_type         TEXT NOT NULL,              -- CE or PE
+    instrument_token    BIGINT NOT NULL,
+    oi                  BIGINT NOT NULL,
+    volume              BIGINT NOT NULL,
+    last_price          FLOAT8 NOT NULL,
+    close               FLOAT8 NOT NULL DEFAULT 0,  -- previous day's, prices the build-up
+    captured_at         TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS oi_snapshots_chain_idx ON oi_snapshots(underlying, expiry, captured_at);
+
+-- Signals :- every BUY or SELL a strategy raised, with the indicators behind it
+CREATE TABLE IF NOT EXISTS signals(
+    id                  BIGSERIAL PRIMARY KEY,
+    strategy            TEXT NOT NULL,              -- instance id, e.g. sma:256265
+    instrument_token    BIGINT NOT NULL,
+    side                TEXT NOT NULL,              -- BUY or SELL
+    price               FLOAT8 NOT NULL,
+    indicators          JSONB NOT NULL DEFAULT '{}',
+    signalled_at        TIMESTAMPTZ NOT NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS signals_strategy_idx ON signals(strategy, signalled_at);
+CREATE INDEX IF NOT EXISTS signals_token_idx ON signals(instrument_token, signalled_at);
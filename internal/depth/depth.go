@@ -0,0 +1,126 @@
+// Package depth turns the 5-level order book of full-mode ticks into features
+package depth
+
+import (
+	"sync"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// Features of one tick's order book. Changes are against the previous tick of
+// the same instrument and are zero on the first one.
+type Features struct {
+	Token     uint32    `json:"instrument_token"`
+	Timestamp time.Time `json:"timestamp"`
+
+	BestBid     float64 `json:"best_bid"`
+	BestAsk     float64 `json:"best_ask"`
+	Spread      float64 `json:"spread"`
+	SpreadBps   float64 `json:"spread_bps"` // spread over mid, in basis points
+	Mid         float64 `json:"mid"`
+	WeightedMid float64 `json:"weighted_mid"` // top of book mid leaning towards the thinner side
+
+	BidQty       int64   `json:"bid_qty"` // across all 5 levels
+	AskQty       int64   `json:"ask_qty"`
+	TopImbalance float64 `json:"top_imbalance"` // -1 all asks to +1 all bids, best level only
+	Imbalance    float64 `json:"imbalance"`     // same across all 5 levels
+
+	BidQtyChange    int64   `json:"bid_qty_change"`
+	AskQtyChange    int64   `json:"ask_qty_change"`
+	ImbalanceChange float64 `json:"imbalance_change"`
+	OrderFlow       float64 `json:"order_flow"` // best level order flow imbalance, positive is buying pressure
+}
+
+func imbalance(bid, ask float64) float64 {
+	if bid+ask == 0 {
+		return 0
+	}
+	return (bid - ask) / (bid + ask)
+}
+
+// Compute reads the features of a tick on its own, ok is false without a two sided book
+func Compute(tick kitemodels.Tick) (f Features, ok bool) {
+	bid, ask := tick.Depth.Buy[0], tick.Depth.Sell[0]
+	if bid.Price <= 0 || ask.Price <= 0 {
+		return Features{}, false
+	}
+
+	f = Features{
+		Token:     tick.InstrumentToken,
+		Timestamp: tick.Timestamp.Time,
+		BestBid:   bid.Price,
+		BestAsk:   ask.Price,
+		Spread:    ask.Price - bid.Price,
+		Mid:       (bid.Price + ask.Price) / 2,
+	}
+	f.SpreadBps = f.Spread / f.Mid * 1e4
+
+	// A thin ask is about to be lifted, so price leans towards it
+	f.WeightedMid = f.Mid
+	if top := float64(bid.Quantity + ask.Quantity); top > 0 {
+		f.WeightedMid = (bid.Price*float64(ask.Quantity) + ask.Price*float64(bid.Quantity)) / top
+	}
+
+	for i := range tick.Depth.Buy {
+		f.BidQty += int64(tick.Depth.Buy[i].Quantity)
+		f.AskQty += int64(tick.Depth.Sell[i].Quantity)
+	}
+	f.TopImbalance = imbalance(float64(bid.Quantity), float64(ask.Quantity))
+	f.Imbalance = imbalance(float64(f.BidQty), float64(f.AskQty))
+	return f, true
+}
+
+// Tracker computes features tick by tick, keeping the last book of every instrument
+type Tracker struct {
+	mu   sync.Mutex
+	last map[uint32]kitemodels.Tick
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{last: map[uint32]kitemodels.Tick{}}
+}
+
+// Update computes the tick's features along with how the book changed since the last tick
+func (t *Tracker) Update(tick kitemodels.Tick) (Features, bool) {
+	f, ok := Compute(tick)
+	if !ok {
+		return f, false
+	}
+
+	t.mu.Lock()
+	prev, seen := t.last[tick.InstrumentToken]
+	t.last[tick.InstrumentToken] = tick
+	t.mu.Unlock()
+	if !seen {
+		return f, true
+	}
+
+	p, _ := Compute(prev)
+	f.BidQtyChange = f.BidQty - p.BidQty
+	f.AskQtyChange = f.AskQty - p.AskQty
+	f.ImbalanceChange = f.Imbalance - p.Imbalance
+	f.OrderFlow = orderFlow(prev.Depth, tick.Depth)
+	return f, true
+}
+
+// orderFlow is Cont, Kukanov and Stoikov's order flow imbalance at the best level
+func orderFlow(prev, cur kitemodels.Depth) float64 {
+	pb, cb := prev.Buy[0], cur.Buy[0]
+	pa, ca := prev.Sell[0], cur.Sell[0]
+
+	e := 0.0
+	if cb.Price >= pb.Price {
+		e += float64(cb.Quantity)
+	}
+	if cb.Price <= pb.Price {
+		e -= float64(pb.Quantity)
+	}
+	if ca.Price <= pa.Price {
+		e -= float64(ca.Quantity)
+	}
+	if ca.Price >= pa.Price {
+		e += float64(pa.Quantity)
+	}
+	return e
+}
@@ -0,0 +1,65 @@
+package depth_test
+
+import (
+	"math"
+	"testing"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/depth"
+)
+
+// book builds a tick with bids stepping down and asks stepping up by 0.05
+func book(bid, ask float64, bidQty, askQty []uint32) kitemodels.Tick {
+	tick := kitemodels.Tick{InstrumentToken: 7}
+	for i := range 5 {
+		tick.Depth.Buy[i] = kitemodels.DepthItem{Price: bid - 0.05*float64(i), Quantity: bidQty[i]}
+		tick.Depth.Sell[i] = kitemodels.DepthItem{Price: ask + 0.05*float64(i), Quantity: askQty[i]}
+	}
+	return tick
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCompute(t *testing.T) {
+	f, ok := depth.Compute(book(100, 100.10, []uint32{300, 100, 100, 100, 100}, []uint32{100, 100, 100, 100, 100}))
+	if !ok {
+		t.Fatal("want features for a two sided book")
+	}
+	if !near(f.Spread, 0.10) || !near(f.Mid, 100.05) || !near(f.SpreadBps, 0.10/100.05*1e4) {
+		t.Errorf("unexpected spread %+v", f)
+	}
+	// Three times the size on the bid pulls the weighted mid towards the ask
+	if !near(f.WeightedMid, (100*100+100.10*300)/400.0) || f.WeightedMid <= f.Mid {
+		t.Errorf("want weighted mid above mid, got %v", f.WeightedMid)
+	}
+	if !near(f.TopImbalance, 0.5) || f.BidQty != 700 || f.AskQty != 500 || !near(f.Imbalance, 200.0/1200) {
+		t.Errorf("unexpected imbalance %+v", f)
+	}
+
+	if _, ok := depth.Compute(kitemodels.Tick{LastPrice: 100}); ok {
+		t.Error("want no features without depth")
+	}
+}
+
+func TestTrackerChanges(t *testing.T) {
+	tr := depth.NewTracker()
+	same := []uint32{100, 100, 100, 100, 100}
+
+	first, _ := tr.Update(book(100, 100.10, same, same))
+	if first.BidQtyChange != 0 || first.OrderFlow != 0 {
+		t.Errorf("want no change on the first tick, got %+v", first)
+	}
+
+	// Bid steps up with fresh size, asks thin out at the same price
+	f, _ := tr.Update(book(100.05, 100.10, []uint32{200, 100, 100, 100, 100}, []uint32{40, 100, 100, 100, 100}))
+	if f.BidQtyChange != 100 || f.AskQtyChange != -60 || f.ImbalanceChange <= 0 {
+		t.Errorf("unexpected depth change %+v", f)
+	}
+	// Improved bid adds its 200, unchanged ask nets 100 - 40
+	if !near(f.OrderFlow, 200+60) {
+		t.Errorf("want order flow 260, got %v", f.OrderFlow)
+	}
+}
@@ -0,0 +1,486 @@
+// Package exits manages stop-loss, target and trailing stops on the server,
+// evaluated on every tick and persisted so they survive restarts.
+package exits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/database"
+	"friction-trading/internal/strategy"
+)
+
+var (
+	ErrNotFound    = errors.New("exit rule not found")
+	ErrNoExit      = errors.New("rule needs a stop, target or trailing stop")
+	ErrUnknownMode = errors.New("unknown exit mode")
+	ErrNoPosition  = errors.New("rule needs a non zero quantity and entry price")
+	ErrFlat        = errors.New("position already flat")
+	ErrTrailMode   = errors.New("trailing stops take points or percent")
+)
+
+// Mode is how an exit distance is measured
+type Mode string
+
+const (
+	Points     Mode = "points"     // value is points from entry
+	Percent    Mode = "percent"    // value is percent of entry
+	ATR        Mode = "atr"        // value is a multiple of ATR
+	Supertrend Mode = "supertrend" // distance from entry to Supertrend, value is the band multiplier
+)
+
+// Indicator periods used for ATR and Supertrend exits
+const (
+	atrPeriod        = 14
+	supertrendPeriod = 7
+)
+
+// Rule statuses
+const (
+	StatusActive    = "active"
+	StatusTriggered = "triggered"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	// Trailed stops are saved at most this often, the engine holds the latest
+	trailSaveEvery = 5 * time.Second
+
+	// A failed exit is retried on the next tick past a doubling wait
+	retryFirst = time.Second
+	retryMax   = time.Minute
+)
+
+// Exit reasons
+const (
+	ReasonStopLoss     = "stop_loss"
+	ReasonTrailingStop = "trailing_stop"
+	ReasonTarget       = "target"
+)
+
+// Spec is one exit leg, e.g. {"mode": "atr", "value": 2}
+type Spec struct {
+	Mode  Mode    `json:"mode"`
+	Value float64 `json:"value"`
+}
+
+// Distance is the spec in points away from entry
+func (s Spec) Distance(entry float64, candles []strategy.Candle) (float64, error) {
+	switch s.Mode {
+	case Points:
+		return s.Value, nil
+	case Percent:
+		return entry * s.Value / 100, nil
+	case ATR:
+		if len(candles) < atrPeriod {
+			return 0, fmt.Errorf("need %d candles for ATR, have %d", atrPeriod, len(candles))
+		}
+		atr := strategy.CalculateATR(strategy.CalculateTR(candles), atrPeriod)
+		return atr[len(atr)-1] * s.Value, nil
+	case Supertrend:
+		if len(candles) < supertrendPeriod {
+			return 0, fmt.Errorf("need %d candles for Supertrend, have %d", supertrendPeriod, len(candles))
+		}
+		multiplier := s.Value
+		if multiplier == 0 {
+			multiplier = 3
+		}
+		atr := strategy.CalculateATR(strategy.CalculateTR(candles), supertrendPeriod)
+		st := strategy.CalculateSupertrend(candles, atr, multiplier)
+		return math.Abs(entry - st[len(st)-1]), nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrUnknownMode, s.Mode)
+	}
+}
+
+// Position is what a rule protects, Quantity is negative for shorts
+type Position struct {
+	InstrumentToken uint32  `json:"instrument_token"`
+	Exchange        string  `json:"exchange"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	Product         string  `json:"product"`
+	Quantity        int     `json:"quantity"`
+	EntryPrice      float64 `json:"entry_price"`
+}
+
+// Exit closes up to the rule's quantity of what is still open, returning the exit
+// order id, or ErrFlat when nothing is
+type Exit func(rule database.ExitRule, reason string) (string, error)
+
+// Engine evaluates active exit rules against ticks
+type Engine struct {
+	store database.Querier
+	exit  Exit
+	now   func() time.Time
+
+	mu       sync.Mutex
+	rules    map[int64]*database.ExitRule
+	pending  map[int64]*pending
+	onChange func()
+}
+
+// pending is what a rule has in flight that its saved row doesn't show yet
+type pending struct {
+	exiting  bool      // exit order out, the rule mustn't fire again
+	failures int       // exits failed in a row
+	retryAt  time.Time // no exit before this after a failure
+	trailed  bool      // stop moved since the last save
+	savedAt  time.Time
+}
+
+func New(store database.Querier, exit Exit) *Engine {
+	return &Engine{
+		store:   store,
+		exit:    exit,
+		now:     time.Now,
+		rules:   map[int64]*database.ExitRule{},
+		pending: map[int64]*pending{},
+	}
+}
+
+// SetClock replaces time.Now, for tests
+func (e *Engine) SetClock(now func() time.Time) {
+	e.now = now
+}
+
+// OnChange is called whenever the set of watched instruments may have changed
+func (e *Engine) OnChange(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onChange = fn
+}
+
+func (e *Engine) changed() {
+	e.mu.Lock()
+	fn := e.onChange
+	e.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// Load picks up active rules saved before a restart
+func (e *Engine) Load(ctx context.Context) error {
+	rules, err := e.store.ListActiveExitRules(ctx)
+	if err != nil {
+		return fmt.Errorf("load exit rules: %w", err)
+	}
+
+	// Rules already held may have trailed past their saved row
+	e.mu.Lock()
+	for _, r := range rules {
+		if _, ok := e.rules[r.ID]; !ok {
+			e.rules[r.ID] = r
+		}
+	}
+	e.mu.Unlock()
+
+	e.changed()
+	return nil
+}
+
+// Add saves and starts watching a rule for pos, candles price ATR and Supertrend exits
+func (e *Engine) Add(ctx context.Context, pos Position, stop, target, trail *Spec, candles []strategy.Candle) (database.ExitRule, error) {
+	if pos.Quantity == 0 || pos.EntryPrice <= 0 {
+		return database.ExitRule{}, ErrNoPosition
+	}
+	if stop == nil && target == nil && trail == nil {
+		return database.ExitRule{}, ErrNoExit
+	}
+
+	// Levels sit below entry for longs and above for shorts
+	side := 1.0
+	if pos.Quantity < 0 {
+		side = -1
+	}
+
+	params := database.CreateExitRuleParams{
+		InstrumentToken: int64(pos.InstrumentToken),
+		Exchange:        pos.Exchange,
+		Tradingsymbol:   pos.Tradingsymbol,
+		Product:         pos.Product,
+		Quantity:        int32(pos.Quantity),
+		EntryPrice:      pos.EntryPrice,
+		HighWater:       pos.EntryPrice,
+	}
+	if stop != nil {
+		d, err := stop.Distance(pos.EntryPrice, candles)
+		if err != nil {
+			return database.ExitRule{}, fmt.Errorf("stop: %w", err)
+		}
+		params.StopMode, params.StopValue = string(stop.Mode), stop.Value
+		params.StopLoss = pos.EntryPrice - side*d
+	}
+	if target != nil {
+		d, err := target.Distance(pos.EntryPrice, candles)
+		if err != nil {
+			return database.ExitRule{}, fmt.Errorf("target: %w", err)
+		}
+		params.TargetMode, params.TargetValue = string(target.Mode), target.Value
+		params.Target = pos.EntryPrice + side*d
+	}
+	if trail != nil {
+		// Indicator trails would need live candles to keep their level honest
+		if trail.Mode == ATR || trail.Mode == Supertrend {
+			return database.ExitRule{}, fmt.Errorf("trail: %w, not %q", ErrTrailMode, trail.Mode)
+		}
+		d, err := trail.Distance(pos.EntryPrice, candles)
+		if err != nil {
+			return database.ExitRule{}, fmt.Errorf("trail: %w", err)
+		}
+		params.TrailMode, params.TrailValue = string(trail.Mode), trail.Value
+		params.TrailDistance = d
+
+		// The trail starts at entry when it's tighter than the stop
+		if level := pos.EntryPrice - side*d; params.StopLoss == 0 || side*(level-params.StopLoss) > 0 {
+			params.StopLoss = level
+		}
+	}
+
+	rule, err := e.store.CreateExitRule(ctx, params)
+	if err != nil {
+		return database.ExitRule{}, fmt.Errorf("save exit rule: %w", err)
+	}
+
+	e.mu.Lock()
+	e.rules[rule.ID] = rule
+	e.mu.Unlock()
+
+	e.changed()
+	return *rule, nil
+}
+
+// Cancel stops watching a rule
+func (e *Engine) Cancel(ctx context.Context, id int64) (database.ExitRule, error) {
+	e.mu.Lock()
+	rule, ok := e.rules[id]
+	if !ok {
+		e.mu.Unlock()
+		return database.ExitRule{}, ErrNotFound
+	}
+	cancelled := e.done(rule, StatusCancelled)
+	e.mu.Unlock()
+
+	if err := e.save(ctx, cancelled); err != nil {
+		return cancelled, err
+	}
+	e.changed()
+	return cancelled, nil
+}
+
+// CancelMatching stops watching the rules match picks, for positions closed outside
+// the engine, e.g. by a square-off
+func (e *Engine) CancelMatching(ctx context.Context, match func(database.ExitRule) bool) ([]database.ExitRule, error) {
+	var cancelled []database.ExitRule
+	e.mu.Lock()
+	for _, r := range e.rules {
+		if match(*r) {
+			cancelled = append(cancelled, e.done(r, StatusCancelled))
+		}
+	}
+	e.mu.Unlock()
+	if len(cancelled) == 0 {
+		return nil, nil
+	}
+
+	var errs []error
+	for _, r := range cancelled {
+		if err := e.save(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	e.changed()
+	sort.Slice(cancelled, func(i, j int) bool { return cancelled[i].ID < cancelled[j].ID })
+	return cancelled, errors.Join(errs...)
+}
+
+// done takes a rule out of the book with its final status, caller holds e.mu
+func (e *Engine) done(r *database.ExitRule, status string) database.ExitRule {
+	delete(e.rules, r.ID)
+	delete(e.pending, r.ID)
+	r.Status = status
+	r.Version++
+	return *r
+}
+
+// pendingOf is a rule's in flight state, caller holds e.mu
+func (e *Engine) pendingOf(id int64) *pending {
+	p, ok := e.pending[id]
+	if !ok {
+		p = &pending{}
+		e.pending[id] = p
+	}
+	return p
+}
+
+// Rules lists active rules
+func (e *Engine) Rules() []database.ExitRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rules := make([]database.ExitRule, 0, len(e.rules))
+	for _, r := range e.rules {
+		rules = append(rules, *r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Tokens lists instruments with active rules
+func (e *Engine) Tokens() []uint32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var tokens []uint32
+	for _, r := range e.rules {
+		if t := uint32(r.InstrumentToken); !slices.Contains(tokens, t) {
+			tokens = append(tokens, t)
+		}
+	}
+	slices.Sort(tokens)
+	return tokens
+}
+
+// OnTick trails stops and fires exits hit by the tick
+func (e *Engine) OnTick(tick kitemodels.Tick) {
+	var (
+		trailed []database.ExitRule
+		fired   []database.ExitRule
+	)
+	now := e.now()
+
+	e.mu.Lock()
+	for id, r := range e.rules {
+		if uint32(r.InstrumentToken) != tick.InstrumentToken {
+			continue
+		}
+		p := e.pendingOf(id)
+		if p.exiting {
+			continue
+		}
+		price := tick.LastPrice
+		long := r.Quantity > 0
+
+		// Trail behind the best price seen
+		if (long && price > r.HighWater) || (!long && price < r.HighWater) {
+			r.HighWater = price
+			if r.TrailDistance > 0 {
+				d := r.TrailDistance
+				if Mode(r.TrailMode) == Percent {
+					d = price * r.TrailValue / 100
+				}
+				level := price - d
+				if !long {
+					level = price + d
+				}
+				if r.StopLoss == 0 || (long && level > r.StopLoss) || (!long && level < r.StopLoss) {
+					r.StopLoss = level
+					r.Version++
+					p.trailed = true
+				}
+			}
+		}
+		if p.trailed && now.Sub(p.savedAt) >= trailSaveEvery {
+			p.trailed, p.savedAt = false, now
+			trailed = append(trailed, *r)
+		}
+
+		reason := ""
+		switch {
+		case r.StopLoss != 0 && ((long && price <= r.StopLoss) || (!long && price >= r.StopLoss)):
+			reason = ReasonStopLoss
+			if r.TrailDistance > 0 && (r.StopMode == "" || r.HighWater != r.EntryPrice) {
+				reason = ReasonTrailingStop
+			}
+		case r.Target != 0 && ((long && price >= r.Target) || (!long && price <= r.Target)):
+			reason = ReasonTarget
+		}
+		if reason == "" || now.Before(p.retryAt) {
+			continue
+		}
+
+		// Flagged before the order goes, so a tick burst can't exit twice
+		p.exiting = true
+		fire := *r
+		fire.ExitReason = reason
+		fired = append(fired, fire)
+	}
+	e.mu.Unlock()
+
+	ctx := context.Background()
+	for _, r := range trailed {
+		if err := e.save(ctx, r); err != nil {
+			log.Printf("Error saving trailed stop for rule %d :- %v\n", r.ID, err)
+		}
+	}
+	for _, r := range fired {
+		e.fire(ctx, r, tick.LastPrice)
+	}
+}
+
+// fire places a rule's exit. A failed order leaves the rule active to retry after a wait.
+func (e *Engine) fire(ctx context.Context, r database.ExitRule, price float64) {
+	log.Printf("Exit %s on %s %d @ %.2f\n", r.ExitReason, r.Tradingsymbol, r.Quantity, price)
+	orderID, err := e.exit(r, r.ExitReason)
+
+	e.mu.Lock()
+	rule, ok := e.rules[r.ID]
+	if !ok {
+		// Cancelled while the order was out
+		e.mu.Unlock()
+		if err == nil {
+			log.Printf("Exit order %s placed for cancelled rule %d\n", orderID, r.ID)
+		}
+		return
+	}
+	p := e.pendingOf(r.ID)
+	p.exiting = false
+
+	status := StatusTriggered
+	switch {
+	case errors.Is(err, ErrFlat):
+		log.Printf("Exit rule %d on %s cancelled :- %v\n", r.ID, r.Tradingsymbol, err)
+		status = StatusCancelled
+	case err != nil:
+		p.failures++
+		wait := retryFirst
+		for i := 1; i < p.failures && wait < retryMax; i++ {
+			wait *= 2
+		}
+		wait = min(wait, retryMax)
+		p.retryAt = e.now().Add(wait)
+		failures := p.failures
+		e.mu.Unlock()
+		log.Printf("ALERT exit %s on %s failed %d times, still active, retrying in %s :- %v\n",
+			r.ExitReason, r.Tradingsymbol, failures, wait, err)
+		return
+	}
+	rule.ExitReason, rule.ExitOrderID = r.ExitReason, orderID
+	exited := e.done(rule, status)
+	e.mu.Unlock()
+
+	if err := e.save(ctx, exited); err != nil {
+		log.Printf("Error saving exit for rule %d :- %v\n", r.ID, err)
+	}
+	e.changed()
+}
+
+// save writes a rule as of its version, the row keeps a later one saved first
+func (e *Engine) save(ctx context.Context, r database.ExitRule) error {
+	return e.store.UpdateExitRule(ctx, database.UpdateExitRuleParams{
+		ID:          r.ID,
+		StopLoss:    r.StopLoss,
+		HighWater:   r.HighWater,
+		Status:      r.Status,
+		ExitReason:  r.ExitReason,
+		ExitOrderID: r.ExitOrderID,
+		Version:     r.Version,
+	})
+}
@@ -0,0 +1,325 @@
+package exits_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/database"
+	"friction-trading/internal/exits"
+	"friction-trading/internal/strategy"
+)
+
+// memRules stands in for the exit_rules table
+type memRules struct {
+	database.Querier
+
+	mu    sync.Mutex
+	rules map[int64]database.ExitRule
+}
+
+func newMemRules() *memRules {
+	return &memRules{rules: map[int64]database.ExitRule{}}
+}
+
+func (m *memRules) CreateExitRule(ctx context.Context, arg database.CreateExitRuleParams) (*database.ExitRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := database.ExitRule{
+		ID:              int64(len(m.rules) + 1),
+		InstrumentToken: arg.InstrumentToken,
+		Exchange:        arg.Exchange,
+		Tradingsymbol:   arg.Tradingsymbol,
+		Product:         arg.Product,
+		Quantity:        arg.Quantity,
+		EntryPrice:      arg.EntryPrice,
+		StopMode:        arg.StopMode,
+		StopValue:       arg.StopValue,
+		TargetMode:      arg.TargetMode,
+		TargetValue:     arg.TargetValue,
+		TrailMode:       arg.TrailMode,
+		TrailValue:      arg.TrailValue,
+		StopLoss:        arg.StopLoss,
+		Target:          arg.Target,
+		TrailDistance:   arg.TrailDistance,
+		HighWater:       arg.HighWater,
+		Status:          exits.StatusActive,
+	}
+	m.rules[r.ID] = r
+	return &r, nil
+}
+
+func (m *memRules) ListActiveExitRules(ctx context.Context) ([]*database.ExitRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*database.ExitRule
+	for _, r := range m.rules {
+		if r.Status == exits.StatusActive {
+			out = append(out, &r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memRules) UpdateExitRule(ctx context.Context, arg database.UpdateExitRuleParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.rules[arg.ID]
+	if r.Version >= arg.Version {
+		return nil
+	}
+	r.StopLoss, r.HighWater, r.Status = arg.StopLoss, arg.HighWater, arg.Status
+	r.ExitReason, r.ExitOrderID, r.Version = arg.ExitReason, arg.ExitOrderID, arg.Version
+	m.rules[arg.ID] = r
+	return nil
+}
+
+func (m *memRules) get(id int64) database.ExitRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rules[id]
+}
+
+// recordExits collects exits instead of placing orders, failing while err is set
+type recordExits struct {
+	reasons []string
+	err     error
+}
+
+func (r *recordExits) exit(rule database.ExitRule, reason string) (string, error) {
+	r.reasons = append(r.reasons, reason)
+	if r.err != nil {
+		return "", r.err
+	}
+	return "exit-order", nil
+}
+
+func tick(price float64) kitemodels.Tick {
+	return kitemodels.Tick{InstrumentToken: 1, LastPrice: price}
+}
+
+func TestStopAndTarget(t *testing.T) {
+	store := newMemRules()
+	var rec recordExits
+	e := exits.New(store, rec.exit)
+	ctx := context.Background()
+
+	long, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Tradingsymbol: "LONG", Quantity: 75, EntryPrice: 100},
+		&exits.Spec{Mode: exits.Points, Value: 5}, &exits.Spec{Mode: exits.Percent, Value: 10}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if long.StopLoss != 95 || long.Target != 110 {
+		t.Errorf("want stop 95 target 110, got %v %v", long.StopLoss, long.Target)
+	}
+
+	e.OnTick(tick(105))
+	if len(rec.reasons) != 0 {
+		t.Fatalf("no exit expected inside the band, got %v", rec.reasons)
+	}
+	e.OnTick(tick(110.5))
+	e.OnTick(tick(111))
+	if len(rec.reasons) != 1 || rec.reasons[0] != exits.ReasonTarget {
+		t.Fatalf("want one target exit, got %v", rec.reasons)
+	}
+	if got := store.get(long.ID); got.Status != exits.StatusTriggered || got.ExitOrderID != "exit-order" {
+		t.Errorf("want triggered rule saved, got %+v", got)
+	}
+
+	// Shorts stop out above entry
+	short, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Tradingsymbol: "SHORT", Quantity: -75, EntryPrice: 100},
+		&exits.Spec{Mode: exits.Points, Value: 5}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.OnTick(tick(105))
+	if len(rec.reasons) != 2 || rec.reasons[1] != exits.ReasonStopLoss || store.get(short.ID).Status != exits.StatusTriggered {
+		t.Errorf("want short stopped out, got %v", rec.reasons)
+	}
+	if len(e.Tokens()) != 0 {
+		t.Errorf("want no instruments watched, got %v", e.Tokens())
+	}
+}
+
+func TestTrailingStopSurvivesRestart(t *testing.T) {
+	store := newMemRules()
+	var rec recordExits
+	ctx := context.Background()
+
+	e := exits.New(store, rec.exit)
+	rule, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Quantity: 50, EntryPrice: 100},
+		&exits.Spec{Mode: exits.Points, Value: 10}, nil, &exits.Spec{Mode: exits.Points, Value: 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.StopLoss != 96 {
+		t.Errorf("want trail to start at 96, got %v", rule.StopLoss)
+	}
+
+	e.OnTick(tick(108))
+	e.OnTick(tick(106)) // pullback must not loosen the stop
+	if got := store.get(rule.ID); got.StopLoss != 104 {
+		t.Errorf("want trailed stop 104 saved, got %v", got.StopLoss)
+	}
+
+	// A fresh engine carries on from the saved stop
+	restarted := exits.New(store, rec.exit)
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	restarted.OnTick(tick(103.5))
+	if len(rec.reasons) != 1 || rec.reasons[0] != exits.ReasonTrailingStop {
+		t.Errorf("want trailing stop exit after restart, got %v", rec.reasons)
+	}
+}
+
+func TestFailedExitRetries(t *testing.T) {
+	store := newMemRules()
+	rec := recordExits{err: errors.New("order rejected")}
+	ctx := context.Background()
+
+	now := time.Date(2025, 10, 20, 10, 0, 0, 0, time.UTC)
+	e := exits.New(store, rec.exit)
+	e.SetClock(func() time.Time { return now })
+	rule, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Quantity: 50, EntryPrice: 100},
+		&exits.Spec{Mode: exits.Points, Value: 5}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.OnTick(tick(94))
+	e.OnTick(tick(94)) // inside the wait
+	if len(rec.reasons) != 1 || len(e.Rules()) != 1 || store.get(rule.ID).Status != exits.StatusActive {
+		t.Fatalf("want one failed exit with the rule still active, got %v %+v", rec.reasons, store.get(rule.ID))
+	}
+
+	now = now.Add(time.Second)
+	e.OnTick(tick(94))
+	if len(rec.reasons) != 2 {
+		t.Fatalf("want a retry after the wait, got %v", rec.reasons)
+	}
+
+	// Waits double, then the retry goes through
+	rec.err = nil
+	now = now.Add(time.Second)
+	e.OnTick(tick(94))
+	if len(rec.reasons) != 2 {
+		t.Fatalf("want no retry before 2s, got %v", rec.reasons)
+	}
+	now = now.Add(time.Second)
+	e.OnTick(tick(94))
+	if got := store.get(rule.ID); len(rec.reasons) != 3 || got.Status != exits.StatusTriggered || got.ExitOrderID != "exit-order" {
+		t.Errorf("want exit on the second retry, got %v %+v", rec.reasons, got)
+	}
+}
+
+func TestFlatPositionCancels(t *testing.T) {
+	store := newMemRules()
+	rec := recordExits{err: exits.ErrFlat}
+	ctx := context.Background()
+
+	e := exits.New(store, rec.exit)
+	flat, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Quantity: 50, EntryPrice: 100},
+		&exits.Spec{Mode: exits.Points, Value: 5}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.OnTick(tick(94))
+	if got := store.get(flat.ID); got.Status != exits.StatusCancelled || len(e.Rules()) != 0 {
+		t.Errorf("want rule on a flat position cancelled, got %+v", got)
+	}
+
+	// Square-offs cancel by symbol
+	for _, symbol := range []string{"A", "B"} {
+		if _, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Exchange: "NSE", Tradingsymbol: symbol, Product: "MIS", Quantity: 1, EntryPrice: 100},
+			&exits.Spec{Mode: exits.Points, Value: 5}, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancelled, err := e.CancelMatching(ctx, func(r database.ExitRule) bool { return r.Tradingsymbol == "A" })
+	if err != nil || len(cancelled) != 1 || store.get(cancelled[0].ID).Status != exits.StatusCancelled {
+		t.Errorf("want rule on A cancelled, got %+v, %v", cancelled, err)
+	}
+	if rules := e.Rules(); len(rules) != 1 || rules[0].Tradingsymbol != "B" {
+		t.Errorf("want B still watched, got %+v", rules)
+	}
+}
+
+func TestTrailSavesThrottled(t *testing.T) {
+	store := newMemRules()
+	var rec recordExits
+	ctx := context.Background()
+
+	now := time.Date(2025, 10, 20, 10, 0, 0, 0, time.UTC)
+	e := exits.New(store, rec.exit)
+	e.SetClock(func() time.Time { return now })
+	rule, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Quantity: 50, EntryPrice: 100},
+		nil, nil, &exits.Spec{Mode: exits.Points, Value: 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.OnTick(tick(101))
+	e.OnTick(tick(102))
+	e.OnTick(tick(103))
+	if got := store.get(rule.ID); got.StopLoss != 97 || e.Rules()[0].StopLoss != 99 {
+		t.Errorf("want the first trail saved and the engine ahead of it, got %v %v", got.StopLoss, e.Rules()[0].StopLoss)
+	}
+
+	// The next tick past the interval saves the latest stop, moved or not
+	now = now.Add(5 * time.Second)
+	e.OnTick(tick(102))
+	if got := store.get(rule.ID); got.StopLoss != 99 {
+		t.Errorf("want trailed stop 99 saved, got %v", got.StopLoss)
+	}
+}
+
+func TestPercentTrailFollowsPrice(t *testing.T) {
+	store := newMemRules()
+	var rec recordExits
+	e := exits.New(store, rec.exit)
+	ctx := context.Background()
+
+	rule, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Quantity: 50, EntryPrice: 100},
+		nil, nil, &exits.Spec{Mode: exits.Percent, Value: 5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.StopLoss != 95 {
+		t.Errorf("want trail to start at 95, got %v", rule.StopLoss)
+	}
+
+	// 5% of 200 is 10 points behind, not the 5 measured off entry
+	e.OnTick(tick(200))
+	if got := e.Rules()[0].StopLoss; got != 190 {
+		t.Errorf("want stop 190, got %v", got)
+	}
+
+	candles := make([]strategy.Candle, 20)
+	for _, mode := range []exits.Mode{exits.ATR, exits.Supertrend} {
+		_, err := e.Add(ctx, exits.Position{InstrumentToken: 1, Quantity: 50, EntryPrice: 100},
+			nil, nil, &exits.Spec{Mode: mode, Value: 2}, candles)
+		if !errors.Is(err, exits.ErrTrailMode) {
+			t.Errorf("want %s trail rejected, got %v", mode, err)
+		}
+	}
+}
+
+func TestATRDistance(t *testing.T) {
+	candles := make([]strategy.Candle, 20)
+	for i := range candles {
+		candles[i] = strategy.Candle{Open: 100, High: 103, Low: 97, Close: 100}
+	}
+
+	d, err := exits.Spec{Mode: exits.ATR, Value: 1.5}.Distance(100, candles)
+	if err != nil || d != 9 {
+		t.Errorf("want 1.5 x ATR 6 = 9, got %v, %v", d, err)
+	}
+	if _, err := (exits.Spec{Mode: exits.ATR, Value: 1}).Distance(100, candles[:5]); err == nil {
+		t.Error("want error with too few candles")
+	}
+}
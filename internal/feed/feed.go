@@ -0,0 +1,399 @@
+// Package feed multiplexes tick consumers over shared ticker connections,
+// reference counting tokens and sharding past the per-connection token limit
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+	kiteticker "github.com/zerodha/gokiteconnect/v4/ticker"
+)
+
+// Kite allows 3000 tokens on each of 3 connections per API key
+const (
+	MaxTokensPerConn = 3000
+	MaxConns         = 3
+)
+
+// Ticks queued per subscription, past it a slow consumer misses ticks rather than
+// holding up its connection
+const Queue = 1024
+
+var (
+	ErrNoTokens = errors.New("no tokens to subscribe")
+	ErrCapacity = errors.New("ticker connections are full")
+	ErrDropped  = errors.New("ticker connection closed")
+)
+
+// Modes from least to most data, a token streams in the richest mode any consumer wants
+var modeRank = map[kiteticker.Mode]int{kiteticker.ModeLTP: 1, kiteticker.ModeQuote: 2, kiteticker.ModeFull: 3}
+
+// Conn is one live ticker connection, replaced on every reconnect
+type Conn interface {
+	Subscribe(tokens []uint32) error
+	Unsubscribe(tokens []uint32) error
+	SetMode(mode kiteticker.Mode, tokens []uint32) error
+}
+
+// Dial serves one ticker connection until ctx is done, calling onConnect with the
+// live connection after every connect and reconnect. It returns once it gives up.
+type Dial func(ctx context.Context, onConnect func(Conn), onTick func(kitemodels.Tick)) error
+
+// shard is one ticker connection and the tokens on it
+type shard struct {
+	id     int
+	conn   Conn                       // nil while connecting
+	tokens map[uint32]struct{}        // placed on this connection
+	modes  map[uint32]kiteticker.Mode // subscribed on the wire
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Subscription is one consumer's interest in a set of tokens
+type Subscription struct {
+	m      *Manager
+	tokens []uint32
+	mode   kiteticker.Mode
+	onTick func(kitemodels.Tick)
+	queue  chan kitemodels.Tick
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// run delivers queued ticks in order until the subscription ends
+func (sub *Subscription) run() {
+	for {
+		select {
+		case <-sub.done:
+			return
+		case tick := <-sub.queue:
+			sub.onTick(tick)
+		}
+	}
+}
+
+// offer queues a tick, dropping it when the consumer is a full queue behind
+func (sub *Subscription) offer(tick kitemodels.Tick) {
+	select {
+	case sub.queue <- tick:
+	default:
+		sub.m.missed.Add(1)
+	}
+}
+
+// Done is closed once the subscription is closed or its connection gives up
+func (sub *Subscription) Done() <-chan struct{} {
+	return sub.done
+}
+
+// Err is why the subscription ended, nil when it was closed
+func (sub *Subscription) Err() error {
+	<-sub.done
+	return sub.err
+}
+
+// Close stops delivering ticks, tokens nobody else wants are unsubscribed
+func (sub *Subscription) Close() {
+	sub.m.release(sub, nil)
+}
+
+// ShardStats describes one connection
+type ShardStats struct {
+	ID        int                     `json:"id"`
+	Connected bool                    `json:"connected"`
+	Tokens    int                     `json:"tokens"`
+	Modes     map[kiteticker.Mode]int `json:"modes"`
+}
+
+// Stats describes every connection and consumer
+type Stats struct {
+	Subscriptions int          `json:"subscriptions"`
+	Dropped       uint64       `json:"dropped"` // ticks slow consumers missed
+	Shards        []ShardStats `json:"shards"`
+}
+
+// Manager owns the ticker connections
+type Manager struct {
+	ctx      context.Context
+	dial     Dial
+	perConn  int
+	maxConns int
+
+	mu       sync.Mutex
+	nextID   int
+	shards   []*shard
+	byToken  map[uint32]*shard
+	watchers map[uint32][]*Subscription
+	subs     map[*Subscription]struct{}
+
+	missed atomic.Uint64 // ticks dropped on full queues
+}
+
+// New returns a manager whose connections live until ctx is done
+func New(ctx context.Context, dial Dial) *Manager {
+	return &Manager{
+		ctx:      ctx,
+		dial:     dial,
+		perConn:  MaxTokensPerConn,
+		maxConns: MaxConns,
+		byToken:  map[uint32]*shard{},
+		watchers: map[uint32][]*Subscription{},
+		subs:     map[*Subscription]struct{}{},
+	}
+}
+
+// SetLimits overrides the tokens per connection and connection count
+func (m *Manager) SetLimits(perConn, maxConns int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.perConn, m.maxConns = perConn, maxConns
+}
+
+// Subscribe delivers ticks for tokens to onTick, in at least mode, until closed.
+// onTick runs on a goroutine of the subscription's own, ticks it falls Queue behind on are dropped.
+func (m *Manager) Subscribe(tokens []uint32, mode kiteticker.Mode, onTick func(kitemodels.Tick)) (*Subscription, error) {
+	tokens = slices.Compact(slices.Sorted(slices.Values(tokens)))
+	if len(tokens) == 0 {
+		return nil, ErrNoTokens
+	}
+	if _, ok := modeRank[mode]; !ok {
+		return nil, fmt.Errorf("unknown ticker mode %q", mode)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Place new tokens before touching anything, so a full manager changes nothing
+	var fresh []uint32
+	for _, t := range tokens {
+		if _, ok := m.byToken[t]; !ok {
+			fresh = append(fresh, t)
+		}
+	}
+	free := 0
+	for _, sh := range m.shards {
+		free += m.perConn - len(sh.tokens)
+	}
+	free += (m.maxConns - len(m.shards)) * m.perConn
+	if len(fresh) > free {
+		return nil, fmt.Errorf("%w: %d new tokens, room for %d", ErrCapacity, len(fresh), free)
+	}
+
+	sub := &Subscription{
+		m:      m,
+		tokens: tokens,
+		mode:   mode,
+		onTick: onTick,
+		queue:  make(chan kitemodels.Tick, Queue),
+		done:   make(chan struct{}),
+	}
+	go sub.run()
+	m.subs[sub] = struct{}{}
+	for _, t := range fresh {
+		sh := m.shardWithRoom()
+		sh.tokens[t] = struct{}{}
+		m.byToken[t] = sh
+	}
+	for _, t := range tokens {
+		m.watchers[t] = append(m.watchers[t], sub)
+	}
+	m.sync(tokens)
+	return sub, nil
+}
+
+// shardWithRoom is the first connection with room for a token, dialling a new one when all are full
+func (m *Manager) shardWithRoom() *shard {
+	for _, sh := range m.shards {
+		if len(sh.tokens) < m.perConn {
+			return sh
+		}
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.nextID++
+	sh := &shard{id: m.nextID, tokens: map[uint32]struct{}{}, modes: map[uint32]kiteticker.Mode{}, ctx: ctx, cancel: cancel}
+	m.shards = append(m.shards, sh)
+	go func() {
+		err := m.dial(ctx, func(c Conn) { m.connected(sh, c) }, m.dispatch)
+		m.dropped(sh, err)
+	}()
+	return sh
+}
+
+// sync brings the wire state of tokens in line with their watchers, callers hold m.mu
+func (m *Manager) sync(tokens []uint32) {
+	type change struct {
+		subscribe, unsubscribe []uint32
+		modes                  map[kiteticker.Mode][]uint32
+	}
+	changes := map[*shard]*change{}
+	for _, t := range tokens {
+		watchers := m.watchers[t]
+		sh, ok := m.byToken[t]
+		if !ok {
+			// Its connection dropped
+			if len(watchers) == 0 {
+				delete(m.watchers, t)
+			}
+			continue
+		}
+		c, ok := changes[sh]
+		if !ok {
+			c = &change{modes: map[kiteticker.Mode][]uint32{}}
+			changes[sh] = c
+		}
+
+		if len(watchers) == 0 {
+			delete(sh.tokens, t)
+			delete(sh.modes, t)
+			delete(m.byToken, t)
+			delete(m.watchers, t)
+			c.unsubscribe = append(c.unsubscribe, t)
+			continue
+		}
+
+		var want kiteticker.Mode
+		for _, sub := range watchers {
+			if modeRank[sub.mode] > modeRank[want] {
+				want = sub.mode
+			}
+		}
+		current, subscribed := sh.modes[t]
+		if !subscribed {
+			c.subscribe = append(c.subscribe, t)
+		}
+		if current != want {
+			sh.modes[t] = want
+			c.modes[want] = append(c.modes[want], t)
+		}
+	}
+
+	for sh, c := range changes {
+		// An empty connection is closed rather than kept idle
+		if len(sh.tokens) == 0 {
+			m.closeShard(sh)
+			continue
+		}
+		if sh.conn == nil {
+			continue // connected() subscribes everything
+		}
+		if err := sh.conn.Unsubscribe(c.unsubscribe); err != nil {
+			log.Printf("Ticker %d unsubscribe :- %v\n", sh.id, err)
+		}
+		if err := sh.conn.Subscribe(c.subscribe); err != nil {
+			log.Printf("Ticker %d subscribe :- %v\n", sh.id, err)
+		}
+		for mode, ts := range c.modes {
+			if err := sh.conn.SetMode(mode, ts); err != nil {
+				log.Printf("Ticker %d set mode %s :- %v\n", sh.id, mode, err)
+			}
+		}
+	}
+}
+
+// closeShard stops a connection, callers hold m.mu
+func (m *Manager) closeShard(sh *shard) {
+	sh.cancel()
+	m.shards = slices.DeleteFunc(m.shards, func(s *shard) bool { return s == sh })
+}
+
+// connected subscribes a fresh connection to all of its tokens in their modes
+func (m *Manager) connected(sh *shard, c Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sh.ctx.Err() != nil {
+		return
+	}
+	sh.conn = c
+
+	tokens := make([]uint32, 0, len(sh.modes))
+	byMode := map[kiteticker.Mode][]uint32{}
+	for t, mode := range sh.modes {
+		tokens = append(tokens, t)
+		byMode[mode] = append(byMode[mode], t)
+	}
+	if err := c.Subscribe(tokens); err != nil {
+		log.Printf("Ticker %d resubscribe :- %v\n", sh.id, err)
+		return
+	}
+	for mode, ts := range byMode {
+		if err := c.SetMode(mode, ts); err != nil {
+			log.Printf("Ticker %d set mode %s :- %v\n", sh.id, mode, err)
+		}
+	}
+}
+
+// dropped ends every subscription on a connection that gave up
+func (m *Manager) dropped(sh *shard, err error) {
+	if sh.ctx.Err() != nil {
+		return
+	}
+	if err == nil {
+		err = ErrDropped
+	}
+
+	m.mu.Lock()
+	m.closeShard(sh)
+	sh.conn = nil
+	affected := map[*Subscription]struct{}{}
+	for t := range sh.tokens {
+		delete(m.byToken, t)
+		for _, sub := range m.watchers[t] {
+			affected[sub] = struct{}{}
+		}
+	}
+	m.mu.Unlock()
+
+	for sub := range affected {
+		m.release(sub, err)
+	}
+}
+
+// release drops a subscription's interest in its tokens
+func (m *Manager) release(sub *Subscription, err error) {
+	sub.once.Do(func() {
+		m.mu.Lock()
+		delete(m.subs, sub)
+		for _, t := range sub.tokens {
+			m.watchers[t] = slices.DeleteFunc(m.watchers[t], func(s *Subscription) bool { return s == sub })
+		}
+		m.sync(sub.tokens)
+		m.mu.Unlock()
+
+		sub.err = err
+		close(sub.done)
+	})
+}
+
+// dispatch fans a tick out to everyone watching its token, never waiting on them
+func (m *Manager) dispatch(tick kitemodels.Tick) {
+	m.mu.Lock()
+	watchers := slices.Clone(m.watchers[tick.InstrumentToken])
+	m.mu.Unlock()
+	for _, sub := range watchers {
+		sub.offer(tick)
+	}
+}
+
+// Stats of every connection
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := Stats{Subscriptions: len(m.subs), Dropped: m.missed.Load(), Shards: []ShardStats{}}
+	for _, sh := range m.shards {
+		s := ShardStats{ID: sh.id, Connected: sh.conn != nil, Tokens: len(sh.tokens), Modes: map[kiteticker.Mode]int{}}
+		for _, mode := range sh.modes {
+			s.Modes[mode]++
+		}
+		stats.Shards = append(stats.Shards, s)
+	}
+	return stats
+}
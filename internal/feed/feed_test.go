@@ -0,0 +1,294 @@
+package feed_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+	kiteticker "github.com/zerodha/gokiteconnect/v4/ticker"
+
+	"friction-trading/internal/feed"
+)
+
+// fakeConn records what a connection was asked for
+type fakeConn struct {
+	mu    sync.Mutex
+	modes map[uint32]kiteticker.Mode
+}
+
+func (c *fakeConn) Subscribe(tokens []uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range tokens {
+		if _, ok := c.modes[t]; !ok {
+			c.modes[t] = kiteticker.ModeQuote
+		}
+	}
+	return nil
+}
+
+func (c *fakeConn) Unsubscribe(tokens []uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range tokens {
+		delete(c.modes, t)
+	}
+	return nil
+}
+
+func (c *fakeConn) SetMode(mode kiteticker.Mode, tokens []uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range tokens {
+		c.modes[t] = mode
+	}
+	return nil
+}
+
+func (c *fakeConn) mode(t uint32) (kiteticker.Mode, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.modes[t]
+	return m, ok
+}
+
+// fakeTicker is one dialled connection, reconnect swaps in a fresh conn
+type fakeTicker struct {
+	onConnect func(feed.Conn)
+	onTick    func(kitemodels.Tick)
+	fail      chan error
+
+	mu   sync.Mutex
+	live *fakeConn
+}
+
+type fakeDialer struct {
+	mu      sync.Mutex
+	tickers []*fakeTicker
+}
+
+func (d *fakeDialer) dial(ctx context.Context, onConnect func(feed.Conn), onTick func(kitemodels.Tick)) error {
+	t := &fakeTicker{onConnect: onConnect, onTick: onTick, fail: make(chan error)}
+	d.mu.Lock()
+	d.tickers = append(d.tickers, t)
+	d.mu.Unlock()
+	t.reconnect()
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-t.fail:
+		return err
+	}
+}
+
+func (t *fakeTicker) reconnect() {
+	c := &fakeConn{modes: map[uint32]kiteticker.Mode{}}
+	t.mu.Lock()
+	t.live = c
+	t.mu.Unlock()
+	t.onConnect(c)
+}
+
+func (t *fakeTicker) conn() *fakeConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.live
+}
+
+func (d *fakeDialer) ticker(i int) *fakeTicker {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		d.mu.Lock()
+		if i < len(d.tickers) && d.tickers[i].conn() != nil {
+			t := d.tickers[i]
+			d.mu.Unlock()
+			return t
+		}
+		d.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	panic("connection never connected")
+}
+
+func (d *fakeDialer) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.tickers)
+}
+
+func collect(ticks *[]uint32, mu *sync.Mutex) func(kitemodels.Tick) {
+	return func(tick kitemodels.Tick) {
+		mu.Lock()
+		*ticks = append(*ticks, tick.InstrumentToken)
+		mu.Unlock()
+	}
+}
+
+func eventually(t *testing.T, ok func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRefCountsAndModes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := &fakeDialer{}
+	m := feed.New(ctx, d.dial)
+
+	var mu sync.Mutex
+	var a, b []uint32
+	subA, err := m.Subscribe([]uint32{1, 2}, kiteticker.ModeLTP, collect(&a, &mu))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subB, err := m.Subscribe([]uint32{2, 3}, kiteticker.ModeFull, collect(&b, &mu))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk := d.ticker(0)
+	if d.count() != 1 {
+		t.Errorf("want one shared connection, got %d", d.count())
+	}
+	if mode, _ := tk.conn().mode(2); mode != kiteticker.ModeFull {
+		t.Errorf("want the shared token in full mode, got %s", mode)
+	}
+
+	for _, token := range []uint32{1, 2, 3} {
+		tk.onTick(kitemodels.Tick{InstrumentToken: token})
+	}
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Equal(a, []uint32{1, 2}) && slices.Equal(b, []uint32{2, 3})
+	}, "want ticks fanned out by token")
+
+	// B leaves, 3 goes and 2 drops back to LTP for A
+	subB.Close()
+	if subB.Err() != nil {
+		t.Errorf("want a clean close, got %v", subB.Err())
+	}
+	if _, ok := tk.conn().mode(3); ok {
+		t.Error("want token 3 unsubscribed")
+	}
+	if mode, _ := tk.conn().mode(2); mode != kiteticker.ModeLTP {
+		t.Errorf("want token 2 back in LTP, got %s", mode)
+	}
+
+	// Reconnect resubscribes in the right modes
+	tk.reconnect()
+	if mode, ok := tk.conn().mode(1); !ok || mode != kiteticker.ModeLTP {
+		t.Errorf("want token 1 resubscribed in LTP, got %q", mode)
+	}
+
+	subA.Close()
+	if stats := m.Stats(); stats.Subscriptions != 0 || len(stats.Shards) != 0 {
+		t.Errorf("want everything closed, got %+v", stats)
+	}
+}
+
+func TestSharding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := &fakeDialer{}
+	m := feed.New(ctx, d.dial)
+	m.SetLimits(2, 2)
+
+	noop := func(kitemodels.Tick) {}
+	if _, err := m.Subscribe([]uint32{1, 2, 3}, kiteticker.ModeFull, noop); err != nil {
+		t.Fatal(err)
+	}
+	d.ticker(1)
+	stats := m.Stats()
+	if len(stats.Shards) != 2 || stats.Shards[0].Tokens != 2 || stats.Shards[1].Tokens != 1 {
+		t.Errorf("want tokens split 2 and 1, got %+v", stats)
+	}
+
+	if _, err := m.Subscribe([]uint32{4, 5}, kiteticker.ModeFull, noop); !errors.Is(err, feed.ErrCapacity) {
+		t.Errorf("want ErrCapacity, got %v", err)
+	}
+	if _, err := m.Subscribe([]uint32{3, 4}, kiteticker.ModeFull, noop); err != nil {
+		t.Errorf("want room for one more token, got %v", err)
+	}
+}
+
+func TestDroppedConnection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := &fakeDialer{}
+	m := feed.New(ctx, d.dial)
+
+	sub, err := m.Subscribe([]uint32{1}, kiteticker.ModeFull, func(kitemodels.Tick) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ticker(0).fail <- errors.New("ticker gave up reconnecting")
+
+	select {
+	case <-sub.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription outlived its connection")
+	}
+	if sub.Err() == nil {
+		t.Error("want the connection error")
+	}
+
+	// The next subscriber dials afresh
+	if _, err := m.Subscribe([]uint32{1}, kiteticker.ModeFull, func(kitemodels.Tick) {}); err != nil {
+		t.Fatal(err)
+	}
+	d.ticker(1)
+}
+
+func TestSlowConsumer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := &fakeDialer{}
+	m := feed.New(ctx, d.dial)
+
+	// One consumer stuck on its first tick
+	entered, stuck := make(chan struct{}, 1), make(chan struct{})
+	defer close(stuck)
+	slow := func(kitemodels.Tick) {
+		entered <- struct{}{}
+		<-stuck
+	}
+	if _, err := m.Subscribe([]uint32{1, 2}, kiteticker.ModeLTP, slow); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var fast []uint32
+	if _, err := m.Subscribe([]uint32{1}, kiteticker.ModeLTP, collect(&fast, &mu)); err != nil {
+		t.Fatal(err)
+	}
+
+	tk := d.ticker(0)
+	tk.onTick(kitemodels.Tick{InstrumentToken: 1})
+	<-entered
+	for range 9 {
+		tk.onTick(kitemodels.Tick{InstrumentToken: 1})
+	}
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(fast) == 10
+	}, "want the other consumer served while one is stuck")
+
+	// 9 queued, the stuck one's queue fills and then misses ticks
+	for range feed.Queue - 9 + 1 {
+		tk.onTick(kitemodels.Tick{InstrumentToken: 2})
+	}
+	if dropped := m.Stats().Dropped; dropped != 1 {
+		t.Errorf("want 1 tick dropped, got %d", dropped)
+	}
+}
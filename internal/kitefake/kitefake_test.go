@@ -0,0 +1,169 @@
+package kitefake_test
+
+import (
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+	kiteticker "github.com/zerodha/gokiteconnect/v4/ticker"
+
+	"friction-trading/internal/kitefake"
+)
+
+func TestSessionAndREST(t *testing.T) {
+	f := kitefake.New()
+	defer f.Close()
+
+	kc := kiteconnect.New(kitefake.APIKey)
+	kc.SetBaseURI(f.URL)
+
+	if _, err := kc.GenerateSession("bad_token", kitefake.APISecret); err == nil {
+		t.Error("want error for bad request token")
+	}
+	session, err := kc.GenerateSession(kitefake.RequestToken, kitefake.APISecret)
+	if err != nil {
+		t.Fatal("GenerateSession Error :- ", err)
+	}
+	if session.AccessToken != kitefake.AccessToken {
+		t.Errorf("want access token %q, got %q", kitefake.AccessToken, session.AccessToken)
+	}
+	kc.SetAccessToken(session.AccessToken)
+
+	profile, err := kc.GetUserProfile()
+	if err != nil || profile.UserID != "FK1234" {
+		t.Errorf("GetUserProfile = %+v, %v", profile, err)
+	}
+
+	f.Update(func(f *kitefake.Server) {
+		f.Instruments = kiteconnect.Instruments{
+			{InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Name: "NIFTY 50", Exchange: "NSE", Segment: "INDICES"},
+			{InstrumentToken: 13238786, Tradingsymbol: "NIFTY25OCT25000CE", Name: "NIFTY", Exchange: "NFO",
+				Segment: "NFO-OPT", InstrumentType: "CE", StrikePrice: 25000, LotSize: 75, TickSize: 0.05,
+				Expiry: models.Time{Time: time.Date(2025, 10, 28, 0, 0, 0, 0, time.UTC)}},
+		}
+	})
+	nfo, err := kc.GetInstrumentsByExchange("NFO")
+	if err != nil {
+		t.Fatal("GetInstrumentsByExchange Error :- ", err)
+	}
+	if len(nfo) != 1 || nfo[0].StrikePrice != 25000 || nfo[0].LotSize != 75 {
+		t.Errorf("unexpected NFO instruments %+v", nfo)
+	}
+
+	f.SetQuote("NFO:NIFTY25OCT25000CE", kitefake.Quote{InstrumentToken: 13238786, LastPrice: 120.5})
+	ltp, err := kc.GetLTP("NFO:NIFTY25OCT25000CE")
+	if err != nil || ltp["NFO:NIFTY25OCT25000CE"].LastPrice != 120.5 {
+		t.Errorf("GetLTP = %+v, %v", ltp, err)
+	}
+
+	// Market orders fill at the LTP, limit orders rest until cancelled
+	market, err := kc.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE", TransactionType: kiteconnect.TransactionTypeBuy,
+		OrderType: kiteconnect.OrderTypeMarket, Product: kiteconnect.ProductNRML, Quantity: 75,
+	})
+	if err != nil {
+		t.Fatal("PlaceOrder Error :- ", err)
+	}
+	limit, err := kc.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE", TransactionType: kiteconnect.TransactionTypeSell,
+		OrderType: kiteconnect.OrderTypeLimit, Product: kiteconnect.ProductNRML, Quantity: 75, Price: 150,
+	})
+	if err != nil {
+		t.Fatal("PlaceOrder Error :- ", err)
+	}
+	if _, err := kc.CancelOrder(kiteconnect.VarietyRegular, limit.OrderID, nil); err != nil {
+		t.Error("CancelOrder Error :- ", err)
+	}
+
+	orders, err := kc.GetOrders()
+	if err != nil || len(orders) != 2 {
+		t.Fatalf("GetOrders = %+v, %v", orders, err)
+	}
+	for _, o := range orders {
+		switch o.OrderID {
+		case market.OrderID:
+			if o.Status != kiteconnect.OrderStatusComplete || o.AveragePrice != 120.5 {
+				t.Errorf("want market order filled at 120.5, got %+v", o)
+			}
+		case limit.OrderID:
+			if o.Status != kiteconnect.OrderStatusCancelled {
+				t.Errorf("want limit order cancelled, got %s", o.Status)
+			}
+		}
+	}
+
+	positions, err := kc.GetPositions()
+	if err != nil || len(positions.Net) != 1 || positions.Net[0].Quantity != 75 {
+		t.Errorf("GetPositions = %+v, %v", positions, err)
+	}
+
+	if got := f.RequestCount("GET /user/profile"); got != 1 {
+		t.Errorf("want 1 profile request, got %d", got)
+	}
+
+	// Wrong token is rejected
+	other := kiteconnect.New(kitefake.APIKey)
+	other.SetBaseURI(f.URL)
+	other.SetAccessToken("stale")
+	if _, err := other.GetUserProfile(); err == nil {
+		t.Error("want error for stale access token")
+	}
+}
+
+func TestTicker(t *testing.T) {
+	f := kitefake.New()
+	defer f.Close()
+
+	ticker := kiteticker.New(f.APIKey, f.AccessToken)
+	ticker.SetRootURL(f.TickerURL())
+
+	const nifty, option = 256265, 13238786
+	ticks := make(chan models.Tick, 10)
+	orders := make(chan kiteconnect.Order, 1)
+	ticker.OnConnect(func() {
+		_ = ticker.Subscribe([]uint32{nifty, option})
+		_ = ticker.SetMode(kiteticker.ModeFull, []uint32{nifty, option})
+	})
+	ticker.OnTick(func(tick models.Tick) { ticks <- tick })
+	ticker.OnOrderUpdate(func(order kiteconnect.Order) { orders <- order })
+	go ticker.Serve()
+	defer ticker.Stop()
+
+	if !f.WaitSubscribed(5*time.Second, nifty, option) {
+		t.Fatal("ticker did not subscribe")
+	}
+
+	f.PushTicks(
+		models.Tick{InstrumentToken: nifty, LastPrice: 25012.35, OHLC: models.OHLC{Open: 25000, High: 25050, Low: 24980, Close: 24990}},
+		models.Tick{InstrumentToken: option, LastPrice: 120.5, VolumeTraded: 1500, OI: 9000,
+			Depth: models.Depth{Buy: [5]models.DepthItem{{Price: 120.45, Quantity: 75, Orders: 1}}}},
+	)
+
+	got := map[uint32]models.Tick{}
+	for len(got) < 2 {
+		select {
+		case tick := <-ticks:
+			got[tick.InstrumentToken] = tick
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for ticks, got %+v", got)
+		}
+	}
+	if tick := got[nifty]; !tick.IsIndex || tick.LastPrice != 25012.35 || tick.OHLC.High != 25050 {
+		t.Errorf("unexpected index tick %+v", tick)
+	}
+	if tick := got[option]; tick.Mode != string(kiteticker.ModeFull) || tick.LastPrice != 120.5 ||
+		tick.OI != 9000 || tick.Depth.Buy[0].Price != 120.45 {
+		t.Errorf("unexpected option tick %+v", tick)
+	}
+
+	f.PushOrderUpdate(kiteconnect.Order{OrderID: "1", Status: kiteconnect.OrderStatusComplete})
+	select {
+	case order := <-orders:
+		if order.OrderID != "1" {
+			t.Errorf("unexpected order update %+v", order)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for order update")
+	}
+}
@@ -0,0 +1,503 @@
+package kitefake
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// Update runs fn with the fake locked, for changing exported fields while it serves
+func (f *Server) Update(fn func(f *Server)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(f)
+}
+
+func (f *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /session/token", f.sessionToken)
+	mux.HandleFunc("GET /ws", f.tickers.serve(f))
+
+	authed := map[string]http.HandlerFunc{
+		"GET /user/profile":                              f.userProfile,
+		"GET /user/margins":                              f.userMargins,
+		"GET /user/margins/{segment}":                    f.userSegmentMargins,
+		"GET /portfolio/holdings":                        f.holdings,
+		"GET /portfolio/positions":                       f.positions,
+		"GET /instruments":                               f.instruments,
+		"GET /instruments/{exchange}":                    f.instruments,
+		"GET /instruments/historical/{token}/{interval}": f.historicalData,
+		"GET /quote":                                     f.quote,
+		"GET /quote/ltp":                                 f.quote,
+		"GET /quote/ohlc":                                f.quote,
+		"GET /orders":                                    f.listOrders,
+		"GET /orders/{id}":                               f.orderHistory,
+		"GET /trades":                                    f.listTrades,
+		"POST /orders/{variety}":                         f.placeOrder,
+		"PUT /orders/{variety}/{id}":                     f.modifyOrder,
+		"DELETE /orders/{variety}/{id}":                  f.cancelOrder,
+		"POST /margins/orders":                           f.orderMargins,
+		"POST /margins/basket":                           f.basketMargins,
+	}
+	for pattern, h := range authed {
+		mux.HandleFunc(pattern, f.withAuth(pattern, h))
+	}
+
+	return mux
+}
+
+// withAuth rejects requests without the session token and counts calls
+func (f *Server) withAuth(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.Requests[r.Method+" "+r.URL.Path]++
+		f.mu.Unlock()
+
+		if !f.authorised(r) {
+			writeError(w, http.StatusForbidden, kiteconnect.TokenError, "Incorrect `api_key` or `access_token`.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (f *Server) sessionToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, kiteconnect.InputError, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Form.Get("api_key") != f.APIKey || r.Form.Get("request_token") != f.RequestToken {
+		writeError(w, http.StatusForbidden, kiteconnect.TokenError, "Token is invalid or has expired.")
+		return
+	}
+	if r.Form.Get("checksum") != checksum(f.APIKey, f.RequestToken, f.APISecret) {
+		writeError(w, http.StatusForbidden, kiteconnect.TokenError, "Invalid `checksum`.")
+		return
+	}
+
+	writeData(w, kiteconnect.UserSession{
+		UserProfile: f.Profile,
+		UserSessionTokens: kiteconnect.UserSessionTokens{
+			UserID:      f.Profile.UserID,
+			AccessToken: f.AccessToken,
+		},
+		UserID:    f.Profile.UserID,
+		APIKey:    f.APIKey,
+		LoginTime: models.Time{Time: time.Now()},
+	})
+}
+
+func (f *Server) userProfile(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeData(w, f.Profile)
+}
+
+func (f *Server) userMargins(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeData(w, f.Margins)
+}
+
+func (f *Server) userSegmentMargins(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.PathValue("segment") {
+	case kiteconnect.MarginsEquity:
+		writeData(w, f.Margins.Equity)
+	case kiteconnect.MarginsCommodity:
+		writeData(w, f.Margins.Commodity)
+	default:
+		writeError(w, http.StatusBadRequest, kiteconnect.InputError, "Invalid segment.")
+	}
+}
+
+func (f *Server) holdings(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeData(w, f.Holdings)
+}
+
+func (f *Server) positions(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeData(w, f.Positions)
+}
+
+// instruments serves the instruments dump as CSV, like Kite
+func (f *Server) instruments(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exchange := r.PathValue("exchange")
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	_ = cw.Write([]string{"instrument_token", "exchange_token", "tradingsymbol", "name", "last_price",
+		"expiry", "strike", "tick_size", "lot_size", "instrument_type", "segment", "exchange"})
+	for _, in := range f.Instruments {
+		if exchange != "" && in.Exchange != exchange {
+			continue
+		}
+		expiry := ""
+		if !in.Expiry.IsZero() {
+			expiry = in.Expiry.Format(time.DateOnly)
+		}
+		_ = cw.Write([]string{
+			strconv.Itoa(in.InstrumentToken),
+			strconv.Itoa(in.ExchangeToken),
+			in.Tradingsymbol,
+			in.Name,
+			strconv.FormatFloat(in.LastPrice, 'f', -1, 64),
+			expiry,
+			strconv.FormatFloat(in.StrikePrice, 'f', -1, 64),
+			strconv.FormatFloat(in.TickSize, 'f', -1, 64),
+			strconv.FormatFloat(in.LotSize, 'f', -1, 64),
+			in.InstrumentType,
+			in.Segment,
+			in.Exchange,
+		})
+	}
+	cw.Flush()
+
+	w.Header().Set("Content-Type", "text/csv")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// Kite reads historical ranges as exchange time
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+// historicalData serves candles in the [date, o, h, l, c, v, oi] array layout
+func (f *Server) historicalData(w http.ResponseWriter, r *http.Request) {
+	token, err := strconv.Atoi(r.PathValue("token"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, kiteconnect.InputError, "Invalid instrument token.")
+		return
+	}
+	from, _ := time.ParseInLocation(time.DateTime, r.URL.Query().Get("from"), ist)
+	to, _ := time.ParseInLocation(time.DateTime, r.URL.Query().Get("to"), ist)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	candles := [][]any{}
+	for _, c := range f.historical[token] {
+		if (!from.IsZero() && c.Date.Before(from)) || (!to.IsZero() && c.Date.After(to)) {
+			continue
+		}
+		candles = append(candles, []any{
+			c.Date.Format("2006-01-02T15:04:05-0700"), c.Open, c.High, c.Low, c.Close, c.Volume, c.OI,
+		})
+	}
+	writeData(w, map[string]any{"candles": candles})
+}
+
+// quote serves full, ltp and ohlc quotes for the "i" instruments
+func (f *Server) quote(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := map[string]any{}
+	for _, key := range r.URL.Query()["i"] {
+		q, ok := f.quotes[key]
+		if !ok {
+			continue
+		}
+		ohlc := models.OHLC{Open: q.OHLC[0], High: q.OHLC[1], Low: q.OHLC[2], Close: q.OHLC[3]}
+		switch r.URL.Path {
+		case "/quote/ltp":
+			out[key] = map[string]any{"instrument_token": q.InstrumentToken, "last_price": q.LastPrice}
+		case "/quote/ohlc":
+			out[key] = map[string]any{"instrument_token": q.InstrumentToken, "last_price": q.LastPrice, "ohlc": ohlc}
+		default:
+			out[key] = map[string]any{
+				"instrument_token": q.InstrumentToken,
+				"timestamp":        q.Timestamp.Format(time.DateTime),
+				"last_price":       q.LastPrice,
+				"volume":           q.Volume,
+				"oi":               q.OI,
+				"net_change":       q.LastPrice - q.OHLC[3],
+				"ohlc":             ohlc,
+			}
+		}
+	}
+	writeData(w, out)
+}
+
+func (f *Server) listOrders(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeData(w, append(kiteconnect.Orders{}, f.orders...))
+}
+
+func (f *Server) orderHistory(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, o := range f.orders {
+		if o.OrderID == r.PathValue("id") {
+			writeData(w, []kiteconnect.Order{o})
+			return
+		}
+	}
+	writeError(w, http.StatusBadRequest, kiteconnect.OrderError, "Couldn't find that `order_id`.")
+}
+
+func (f *Server) listTrades(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeData(w, append(kiteconnect.Trades{}, f.trades...))
+}
+
+// placeOrder fills MARKET orders at the quoted LTP, others stay OPEN
+func (f *Server) placeOrder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, kiteconnect.InputError, err.Error())
+		return
+	}
+	qty, _ := strconv.Atoi(r.Form.Get("quantity"))
+	price, _ := strconv.ParseFloat(r.Form.Get("price"), 64)
+	trigger, _ := strconv.ParseFloat(r.Form.Get("trigger_price"), 64)
+	if qty <= 0 || r.Form.Get("tradingsymbol") == "" {
+		writeError(w, http.StatusBadRequest, kiteconnect.InputError, "Invalid `quantity` or `tradingsymbol`.")
+		return
+	}
+
+	f.mu.Lock()
+	f.orderID++
+	now := time.Now()
+	order := kiteconnect.Order{
+		OrderID:         fmt.Sprintf("%d", 250000000000000+f.orderID),
+		Status:          "OPEN",
+		OrderTimestamp:  models.Time{Time: now},
+		Variety:         r.PathValue("variety"),
+		Exchange:        r.Form.Get("exchange"),
+		TradingSymbol:   r.Form.Get("tradingsymbol"),
+		OrderType:       r.Form.Get("order_type"),
+		TransactionType: r.Form.Get("transaction_type"),
+		Validity:        r.Form.Get("validity"),
+		Product:         r.Form.Get("product"),
+		Quantity:        float64(qty),
+		Price:           price,
+		TriggerPrice:    trigger,
+		PendingQuantity: float64(qty),
+		Tag:             r.Form.Get("tag"),
+	}
+	if q, ok := f.quotes[instrumentKey(order.Exchange, order.TradingSymbol)]; ok {
+		order.InstrumentToken = uint32(q.InstrumentToken)
+	}
+
+	if order.OrderType == kiteconnect.OrderTypeMarket {
+		f.fill(&order, now)
+	}
+	f.orders = append(f.orders, order)
+	f.mu.Unlock()
+
+	f.tickers.pushOrder(order)
+	writeData(w, kiteconnect.OrderResponse{OrderID: order.OrderID})
+}
+
+// fill completes an order and books it into trades and positions, caller holds f.mu
+func (f *Server) fill(order *kiteconnect.Order, at time.Time) {
+	price := order.Price
+	if q, ok := f.quotes[instrumentKey(order.Exchange, order.TradingSymbol)]; ok && (order.OrderType == kiteconnect.OrderTypeMarket || price == 0) {
+		price = q.LastPrice
+	}
+
+	order.Status = kiteconnect.OrderStatusComplete
+	order.AveragePrice = price
+	order.FilledQuantity = order.Quantity
+	order.PendingQuantity = 0
+	order.ExchangeTimestamp = models.Time{Time: at}
+
+	f.trades = append(f.trades, kiteconnect.Trade{
+		AveragePrice:    price,
+		Quantity:        order.Quantity,
+		TradeID:         order.OrderID,
+		Product:         order.Product,
+		FillTimestamp:   models.Time{Time: at},
+		OrderID:         order.OrderID,
+		TransactionType: order.TransactionType,
+		TradingSymbol:   order.TradingSymbol,
+		Exchange:        order.Exchange,
+		InstrumentToken: order.InstrumentToken,
+	})
+
+	qty := int(order.Quantity)
+	if order.TransactionType == kiteconnect.TransactionTypeSell {
+		qty = -qty
+	}
+	f.Positions.Net = bookPosition(f.Positions.Net, *order, qty, price)
+	f.Positions.Day = bookPosition(f.Positions.Day, *order, qty, price)
+}
+
+// bookPosition adds a fill of signed qty into the matching position
+func bookPosition(positions []kiteconnect.Position, order kiteconnect.Order, qty int, price float64) []kiteconnect.Position {
+	for i := range positions {
+		p := &positions[i]
+		if p.Exchange != order.Exchange || p.Tradingsymbol != order.TradingSymbol || p.Product != order.Product {
+			continue
+		}
+		if qty > 0 {
+			p.BuyValue += float64(qty) * price
+			p.BuyQuantity += qty
+			p.BuyPrice = p.BuyValue / float64(p.BuyQuantity)
+		} else {
+			p.SellValue += float64(-qty) * price
+			p.SellQuantity += -qty
+			p.SellPrice = p.SellValue / float64(p.SellQuantity)
+		}
+		p.Quantity += qty
+		p.LastPrice = price
+		p.PnL = p.SellValue - p.BuyValue + float64(p.Quantity)*price
+		p.M2M = p.PnL
+		if p.Quantity != 0 {
+			p.AveragePrice = price
+		}
+		return positions
+	}
+
+	p := kiteconnect.Position{
+		Tradingsymbol:   order.TradingSymbol,
+		Exchange:        order.Exchange,
+		InstrumentToken: order.InstrumentToken,
+		Product:         order.Product,
+		Quantity:        qty,
+		Multiplier:      1,
+		AveragePrice:    price,
+		LastPrice:       price,
+	}
+	if qty > 0 {
+		p.BuyQuantity, p.BuyPrice, p.BuyValue = qty, price, float64(qty)*price
+	} else {
+		p.SellQuantity, p.SellPrice, p.SellValue = -qty, price, float64(-qty)*price
+	}
+	return append(positions, p)
+}
+
+func (f *Server) modifyOrder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, kiteconnect.InputError, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	var order *kiteconnect.Order
+	for i := range f.orders {
+		if f.orders[i].OrderID == r.PathValue("id") {
+			order = &f.orders[i]
+		}
+	}
+	if order == nil || order.Status != "OPEN" {
+		f.mu.Unlock()
+		writeError(w, http.StatusBadRequest, kiteconnect.OrderError, "Order cannot be modified.")
+		return
+	}
+	if v, err := strconv.ParseFloat(r.Form.Get("price"), 64); err == nil {
+		order.Price = v
+	}
+	if v, err := strconv.ParseFloat(r.Form.Get("trigger_price"), 64); err == nil {
+		order.TriggerPrice = v
+	}
+	if v, err := strconv.Atoi(r.Form.Get("quantity")); err == nil && v > 0 {
+		order.Quantity, order.PendingQuantity = float64(v), float64(v)
+	}
+	if t := r.Form.Get("order_type"); t != "" {
+		order.OrderType = t
+	}
+	order.Modified = true
+	if order.OrderType == kiteconnect.OrderTypeMarket {
+		f.fill(order, time.Now())
+	}
+	updated := *order
+	f.mu.Unlock()
+
+	f.tickers.pushOrder(updated)
+	writeData(w, kiteconnect.OrderResponse{OrderID: updated.OrderID})
+}
+
+func (f *Server) cancelOrder(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	var order *kiteconnect.Order
+	for i := range f.orders {
+		if f.orders[i].OrderID == r.PathValue("id") {
+			order = &f.orders[i]
+		}
+	}
+	if order == nil || order.Status != "OPEN" {
+		f.mu.Unlock()
+		writeError(w, http.StatusBadRequest, kiteconnect.OrderError, "Order cannot be cancelled.")
+		return
+	}
+	order.Status = kiteconnect.OrderStatusCancelled
+	order.CancelledQuantity = order.PendingQuantity
+	order.PendingQuantity = 0
+	updated := *order
+	f.mu.Unlock()
+
+	f.tickers.pushOrder(updated)
+	writeData(w, kiteconnect.OrderResponse{OrderID: updated.OrderID})
+}
+
+// orderMargin blocks MarginRate of the order value, caller holds f.mu
+func (f *Server) orderMargin(p kiteconnect.OrderMarginParam) kiteconnect.OrderMargins {
+	price := p.Price
+	if q, ok := f.quotes[instrumentKey(p.Exchange, p.Tradingsymbol)]; ok && price == 0 {
+		price = q.LastPrice
+	}
+	total := p.Quantity * price * f.MarginRate
+	return kiteconnect.OrderMargins{
+		Type:          "equity",
+		TradingSymbol: p.Tradingsymbol,
+		Exchange:      p.Exchange,
+		SPAN:          total,
+		Total:         total,
+	}
+}
+
+func (f *Server) orderMargins(w http.ResponseWriter, r *http.Request) {
+	var params []kiteconnect.OrderMarginParam
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, kiteconnect.InputError, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]kiteconnect.OrderMargins, len(params))
+	for i, p := range params {
+		out[i] = f.orderMargin(p)
+	}
+	writeData(w, out)
+}
+
+// basketMargins sums order margins, hedged baskets get no benefit here
+func (f *Server) basketMargins(w http.ResponseWriter, r *http.Request) {
+	var params []kiteconnect.OrderMarginParam
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, kiteconnect.InputError, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out kiteconnect.BasketMargins
+	for _, p := range params {
+		m := f.orderMargin(p)
+		out.Orders = append(out.Orders, m)
+		out.Initial.Total += m.Total
+		out.Final.Total += m.Total
+	}
+	writeData(w, out)
+}
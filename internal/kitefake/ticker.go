@@ -0,0 +1,333 @@
+package kitefake
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+	kiteticker "github.com/zerodha/gokiteconnect/v4/ticker"
+)
+
+// The ticker reconnects after 5s of silence, so keep it fed
+const heartbeatInterval = time.Second
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+
+// tickerConn is one connected ticker and its subscriptions
+type tickerConn struct {
+	ws *websocket.Conn
+
+	// guards writes and modes
+	mu    sync.Mutex
+	modes map[uint32]kiteticker.Mode
+}
+
+func (c *tickerConn) write(kind int, b []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(time.Second))
+	return c.ws.WriteMessage(kind, b)
+}
+
+// tickerHub tracks connected tickers
+type tickerHub struct {
+	mu      sync.Mutex
+	conns   map[*tickerConn]struct{}
+	changed chan struct{}
+}
+
+func newTickerHub() *tickerHub {
+	return &tickerHub{conns: map[*tickerConn]struct{}{}, changed: make(chan struct{})}
+}
+
+// notify wakes anyone waiting on subscription changes, caller holds h.mu
+func (h *tickerHub) notify() {
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// serve upgrades /ws connections which carry the session in the query
+func (h *tickerHub) serve(f *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		ok := r.URL.Query().Get("api_key") == f.APIKey && r.URL.Query().Get("access_token") == f.AccessToken
+		f.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusForbidden, kiteconnect.TokenError, "Incorrect `api_key` or `access_token`.")
+			return
+		}
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn := &tickerConn{ws: ws, modes: map[uint32]kiteticker.Mode{}}
+
+		h.mu.Lock()
+		h.conns[conn] = struct{}{}
+		h.notify()
+		h.mu.Unlock()
+
+		done := make(chan struct{})
+		go h.heartbeat(conn, done)
+
+		defer func() {
+			close(done)
+			h.mu.Lock()
+			delete(h.conns, conn)
+			h.notify()
+			h.mu.Unlock()
+			_ = ws.Close()
+		}()
+
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			h.handle(conn, msg)
+		}
+	}
+}
+
+func (h *tickerHub) heartbeat(conn *tickerConn, done chan struct{}) {
+	t := time.NewTicker(heartbeatInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			if conn.write(websocket.BinaryMessage, []byte{0}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// handle applies subscribe, unsubscribe and mode requests
+func (h *tickerHub) handle(conn *tickerConn, msg []byte) {
+	var req struct {
+		A string          `json:"a"`
+		V json.RawMessage `json:"v"`
+	}
+	if json.Unmarshal(msg, &req) != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	switch req.A {
+	case "subscribe":
+		var tokens []uint32
+		if json.Unmarshal(req.V, &tokens) != nil {
+			return
+		}
+		for _, t := range tokens {
+			if _, ok := conn.modes[t]; !ok {
+				conn.modes[t] = kiteticker.ModeQuote
+			}
+		}
+	case "unsubscribe":
+		var tokens []uint32
+		if json.Unmarshal(req.V, &tokens) != nil {
+			return
+		}
+		for _, t := range tokens {
+			delete(conn.modes, t)
+		}
+	case "mode":
+		var v []json.RawMessage
+		if json.Unmarshal(req.V, &v) != nil || len(v) != 2 {
+			return
+		}
+		var mode kiteticker.Mode
+		var tokens []uint32
+		if json.Unmarshal(v[0], &mode) != nil || json.Unmarshal(v[1], &tokens) != nil {
+			return
+		}
+		for _, t := range tokens {
+			conn.modes[t] = mode
+		}
+	default:
+		return
+	}
+	h.notify()
+}
+
+// subscribed reports whether any connection subscribes to all tokens, caller holds h.mu
+func (h *tickerHub) subscribed(tokens []uint32) bool {
+	for conn := range h.conns {
+		conn.mu.Lock()
+		all := true
+		for _, t := range tokens {
+			if _, ok := conn.modes[t]; !ok {
+				all = false
+			}
+		}
+		conn.mu.Unlock()
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitSubscribed blocks until a ticker subscribes to tokens or timeout passes
+func (f *Server) WaitSubscribed(timeout time.Duration, tokens ...uint32) bool {
+	h := f.tickers
+	deadline := time.After(timeout)
+	for {
+		h.mu.Lock()
+		ok := h.subscribed(tokens)
+		changed := h.changed
+		h.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// PushTicks sends ticks to every ticker subscribed to them, in each ticker's mode
+func (f *Server) PushTicks(ticks ...models.Tick) {
+	h := f.tickers
+	h.mu.Lock()
+	conns := make([]*tickerConn, 0, len(h.conns))
+	for conn := range h.conns {
+		conns = append(conns, conn)
+	}
+	h.mu.Unlock()
+
+	for _, conn := range conns {
+		var packets [][]byte
+		conn.mu.Lock()
+		for _, tick := range ticks {
+			if mode, ok := conn.modes[tick.InstrumentToken]; ok {
+				packets = append(packets, EncodeTick(tick, mode))
+			}
+		}
+		conn.mu.Unlock()
+		if len(packets) > 0 {
+			_ = conn.write(websocket.BinaryMessage, encodeMessage(packets))
+		}
+	}
+}
+
+// PushOrderUpdate sends an order update text message to every ticker
+func (f *Server) PushOrderUpdate(order kiteconnect.Order) {
+	f.tickers.pushOrder(order)
+}
+
+func (h *tickerHub) pushOrder(order kiteconnect.Order) {
+	b, err := json.Marshal(map[string]any{"type": "order", "data": order})
+	if err != nil {
+		return
+	}
+	b = nullZeroTimes(b)
+
+	h.mu.Lock()
+	conns := make([]*tickerConn, 0, len(h.conns))
+	for conn := range h.conns {
+		conns = append(conns, conn)
+	}
+	h.mu.Unlock()
+
+	for _, conn := range conns {
+		_ = conn.write(websocket.TextMessage, b)
+	}
+}
+
+func (h *tickerHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for conn := range h.conns {
+		_ = conn.ws.Close()
+	}
+}
+
+// encodeMessage frames packets as [count][len][packet]...
+func encodeMessage(packets [][]byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(packets)))
+	for _, p := range packets {
+		b = binary.BigEndian.AppendUint16(b, uint16(len(p)))
+		b = append(b, p...)
+	}
+	return b
+}
+
+// EncodeTick packs a tick in the Kite binary layout for mode.
+// The segment is taken from the low byte of the instrument token.
+func EncodeTick(tick models.Tick, mode kiteticker.Mode) []byte {
+	seg := tick.InstrumentToken & 0xFF
+	price := func(v float64) uint32 {
+		switch seg {
+		case kiteticker.NseCD:
+			return uint32(math.Round(v * 10000000))
+		case kiteticker.BseCD:
+			return uint32(math.Round(v * 10000))
+		default:
+			return uint32(math.Round(v * 100))
+		}
+	}
+	u32 := binary.BigEndian.AppendUint32
+
+	b := u32(nil, tick.InstrumentToken)
+	b = u32(b, price(tick.LastPrice))
+	if mode == kiteticker.ModeLTP {
+		return b
+	}
+
+	if seg == kiteticker.Indices {
+		b = u32(b, price(tick.OHLC.High))
+		b = u32(b, price(tick.OHLC.Low))
+		b = u32(b, price(tick.OHLC.Open))
+		b = u32(b, price(tick.OHLC.Close))
+		b = u32(b, price(tick.NetChange))
+		if mode == kiteticker.ModeFull {
+			b = u32(b, uint32(tick.Timestamp.Unix()))
+		}
+		return b
+	}
+
+	b = u32(b, tick.LastTradedQuantity)
+	b = u32(b, price(tick.AverageTradePrice))
+	b = u32(b, tick.VolumeTraded)
+	b = u32(b, tick.TotalBuyQuantity)
+	b = u32(b, tick.TotalSellQuantity)
+	b = u32(b, price(tick.OHLC.Open))
+	b = u32(b, price(tick.OHLC.High))
+	b = u32(b, price(tick.OHLC.Low))
+	b = u32(b, price(tick.OHLC.Close))
+	if mode != kiteticker.ModeFull {
+		return b
+	}
+
+	b = u32(b, uint32(tick.LastTradeTime.Unix()))
+	b = u32(b, tick.OI)
+	b = u32(b, tick.OIDayHigh)
+	b = u32(b, tick.OIDayLow)
+	b = u32(b, uint32(tick.Timestamp.Unix()))
+	for _, side := range [][5]models.DepthItem{tick.Depth.Buy, tick.Depth.Sell} {
+		for _, d := range side {
+			b = u32(b, d.Quantity)
+			b = u32(b, price(d.Price))
+			b = binary.BigEndian.AppendUint16(b, uint16(d.Orders))
+			b = append(b, 0, 0)
+		}
+	}
+	return b
+}
@@ -0,0 +1,161 @@
+// Package oi reads positioning off an option chain's open interest
+package oi
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+const (
+	Call = "CE"
+	Put  = "PE"
+)
+
+// Build-ups from how price and OI moved together
+const (
+	LongBuildUp   = "long_build_up"  // price up, OI up
+	ShortBuildUp  = "short_build_up" // price down, OI up
+	ShortCovering = "short_covering" // price up, OI down
+	LongUnwinding = "long_unwinding" // price down, OI down
+)
+
+// Option is one contract of the chain
+type Option struct {
+	Token         uint32
+	Tradingsymbol string
+	Strike        float64
+	Type          string // CE or PE
+}
+
+// Quote is market data for an option, Close is the previous day's close
+type Quote struct {
+	OI        int64
+	Volume    int64
+	LastPrice float64
+	Close     float64
+}
+
+// Side is the call or put at a strike
+type Side struct {
+	Tradingsymbol string  `json:"tradingsymbol"`
+	OI            int64   `json:"oi"`
+	OIChange      int64   `json:"oi_change"` // since the day's first snapshot
+	Volume        int64   `json:"volume"`
+	LastPrice     float64 `json:"last_price"`
+	PriceChange   float64 `json:"price_change"` // since the previous close
+	BuildUp       string  `json:"build_up,omitempty"`
+}
+
+// Row is one strike of the chain
+type Row struct {
+	Strike float64 `json:"strike"`
+	Call   *Side   `json:"call,omitempty"`
+	Put    *Side   `json:"put,omitempty"`
+}
+
+// Analysis is the chain's open interest picture
+type Analysis struct {
+	PCR          float64 `json:"pcr"`        // put OI over call OI
+	VolumePCR    float64 `json:"volume_pcr"` // put volume over call volume
+	MaxPain      float64 `json:"max_pain"`
+	CallOI       int64   `json:"call_oi"`
+	PutOI        int64   `json:"put_oi"`
+	CallOIChange int64   `json:"call_oi_change"`
+	PutOIChange  int64   `json:"put_oi_change"`
+	Strikes      []Row   `json:"strikes"`
+}
+
+// Classify names the build-up behind a move, empty when either didn't move
+func Classify(priceChange float64, oiChange int64) string {
+	switch {
+	case priceChange > 0 && oiChange > 0:
+		return LongBuildUp
+	case priceChange < 0 && oiChange > 0:
+		return ShortBuildUp
+	case priceChange > 0 && oiChange < 0:
+		return ShortCovering
+	case priceChange < 0 && oiChange < 0:
+		return LongUnwinding
+	}
+	return ""
+}
+
+// MaxPain is the expiry price at which option buyers collect the least
+func MaxPain(rows []Row) float64 {
+	best, pain := 0.0, math.Inf(1)
+	for _, at := range rows {
+		total := 0.0
+		for _, r := range rows {
+			if r.Call != nil && at.Strike > r.Strike {
+				total += float64(r.Call.OI) * (at.Strike - r.Strike)
+			}
+			if r.Put != nil && at.Strike < r.Strike {
+				total += float64(r.Put.OI) * (r.Strike - at.Strike)
+			}
+		}
+		if total < pain {
+			best, pain = at.Strike, total
+		}
+	}
+	return best
+}
+
+// Analyse lines the chain up by strike. OI change is against baseline,
+// options missing from it or from quotes show no change.
+func Analyse(chain []Option, quotes map[uint32]Quote, baseline map[uint32]int64) Analysis {
+	byStrike := map[float64]*Row{}
+	var a Analysis
+	var callVolume, putVolume int64
+	for _, o := range chain {
+		q, ok := quotes[o.Token]
+		if !ok || (o.Type != Call && o.Type != Put) {
+			continue
+		}
+
+		side := &Side{
+			Tradingsymbol: o.Tradingsymbol,
+			OI:            q.OI,
+			Volume:        q.Volume,
+			LastPrice:     q.LastPrice,
+		}
+		if q.Close > 0 {
+			side.PriceChange = q.LastPrice - q.Close
+		}
+		if base, ok := baseline[o.Token]; ok {
+			side.OIChange = q.OI - base
+		}
+		side.BuildUp = Classify(side.PriceChange, side.OIChange)
+
+		r, ok := byStrike[o.Strike]
+		if !ok {
+			r = &Row{Strike: o.Strike}
+			byStrike[o.Strike] = r
+		}
+		if o.Type == Call {
+			r.Call = side
+			a.CallOI += side.OI
+			a.CallOIChange += side.OIChange
+			callVolume += side.Volume
+		} else {
+			r.Put = side
+			a.PutOI += side.OI
+			a.PutOIChange += side.OIChange
+			putVolume += side.Volume
+		}
+	}
+
+	for _, r := range byStrike {
+		a.Strikes = append(a.Strikes, *r)
+	}
+	slices.SortFunc(a.Strikes, func(x, y Row) int { return cmp.Compare(x.Strike, y.Strike) })
+
+	if a.CallOI > 0 {
+		a.PCR = float64(a.PutOI) / float64(a.CallOI)
+	}
+	if callVolume > 0 {
+		a.VolumePCR = float64(putVolume) / float64(callVolume)
+	}
+	a.MaxPain = MaxPain(a.Strikes)
+	return a
+}
@@ -0,0 +1,106 @@
+package oi_test
+
+import (
+	"math"
+	"testing"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/oi"
+)
+
+var chain = []oi.Option{
+	{Token: 1, Tradingsymbol: "NIFTY25O2324900CE", Strike: 24900, Type: oi.Call},
+	{Token: 2, Tradingsymbol: "NIFTY25O2324900PE", Strike: 24900, Type: oi.Put},
+	{Token: 3, Tradingsymbol: "NIFTY25O2325000CE", Strike: 25000, Type: oi.Call},
+	{Token: 4, Tradingsymbol: "NIFTY25O2325000PE", Strike: 25000, Type: oi.Put},
+	{Token: 5, Tradingsymbol: "NIFTY25O2325100CE", Strike: 25100, Type: oi.Call},
+	{Token: 6, Tradingsymbol: "NIFTY25O2325100PE", Strike: 25100, Type: oi.Put},
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		price float64
+		oi    int64
+		want  string
+	}{
+		{5, 100, oi.LongBuildUp},
+		{-5, 100, oi.ShortBuildUp},
+		{5, -100, oi.ShortCovering},
+		{-5, -100, oi.LongUnwinding},
+		{0, 100, ""},
+	}
+	for _, c := range cases {
+		if got := oi.Classify(c.price, c.oi); got != c.want {
+			t.Errorf("Classify(%v, %v) = %q, want %q", c.price, c.oi, got, c.want)
+		}
+	}
+}
+
+func TestAnalyse(t *testing.T) {
+	quotes := map[uint32]oi.Quote{
+		1: {OI: 1000, Volume: 500, LastPrice: 150, Close: 140},
+		2: {OI: 4000, Volume: 900, LastPrice: 40, Close: 50},
+		3: {OI: 3000, Volume: 1500, LastPrice: 90, Close: 80},
+		4: {OI: 3000, Volume: 1200, LastPrice: 80, Close: 90},
+		5: {OI: 5000, Volume: 2000, LastPrice: 50, Close: 60},
+		6: {OI: 500, Volume: 100, LastPrice: 130, Close: 120},
+	}
+	baseline := map[uint32]int64{1: 800, 2: 4500, 3: 3000, 5: 4000}
+
+	a := oi.Analyse(chain, quotes, baseline)
+	if a.CallOI != 9000 || a.PutOI != 7500 || math.Abs(a.PCR-7500.0/9000) > 1e-9 {
+		t.Errorf("want OI 9000/7500, got %v/%v pcr %v", a.CallOI, a.PutOI, a.PCR)
+	}
+	if math.Abs(a.VolumePCR-2200.0/4000) > 1e-9 {
+		t.Errorf("want volume PCR 0.55, got %v", a.VolumePCR)
+	}
+	if a.CallOIChange != 1200 || a.PutOIChange != -500 {
+		t.Errorf("want OI change +1200/-500, got %v/%v", a.CallOIChange, a.PutOIChange)
+	}
+
+	// Buyers collect 400k at 24900, 150k at 25000 and 500k at 25100
+	if a.MaxPain != 25000 {
+		t.Errorf("want max pain 25000, got %v", a.MaxPain)
+	}
+
+	if len(a.Strikes) != 3 || a.Strikes[0].Strike != 24900 || a.Strikes[2].Strike != 25100 {
+		t.Fatalf("want 3 strikes in order, got %+v", a.Strikes)
+	}
+	want := map[string]string{
+		"NIFTY25O2324900CE": oi.LongBuildUp,
+		"NIFTY25O2324900PE": oi.LongUnwinding,
+		"NIFTY25O2325000CE": "", // OI unchanged
+		"NIFTY25O2325000PE": "", // no baseline
+		"NIFTY25O2325100CE": oi.ShortBuildUp,
+	}
+	for _, r := range a.Strikes {
+		for _, side := range []*oi.Side{r.Call, r.Put} {
+			if b, ok := want[side.Tradingsymbol]; ok && side.BuildUp != b {
+				t.Errorf("%s: want %q, got %q", side.Tradingsymbol, b, side.BuildUp)
+			}
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tr := oi.NewTracker(chain, map[uint32]int64{3: 2000})
+
+	tick := func(token uint32, oi uint32, price float64) kitemodels.Tick {
+		return kitemodels.Tick{InstrumentToken: token, OI: oi, LastPrice: price, OHLC: kitemodels.OHLC{Close: 100}}
+	}
+	tr.OnTick(tick(3, 2500, 110))
+	tr.OnTick(tick(4, 1000, 90))
+	tr.OnTick(tick(4, 1200, 85))
+
+	a := tr.Analysis()
+	if a.CallOIChange != 500 || a.PutOIChange != 200 {
+		t.Errorf("want changes from baseline and first tick, got %v/%v", a.CallOIChange, a.PutOIChange)
+	}
+	if len(a.Strikes) != 1 || a.Strikes[0].Put.BuildUp != oi.ShortBuildUp {
+		t.Errorf("want one strike with put short build-up, got %+v", a.Strikes)
+	}
+	if len(tr.Tokens()) != len(chain) || len(tr.Quotes()) != 2 {
+		t.Errorf("want %d tokens and 2 quotes, got %v %v", len(chain), tr.Tokens(), tr.Quotes())
+	}
+}
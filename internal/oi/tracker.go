@@ -0,0 +1,69 @@
+package oi
+
+import (
+	"maps"
+	"sync"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// Tracker keeps the latest full-mode tick of every option in a chain
+type Tracker struct {
+	chain []Option
+
+	mu       sync.Mutex
+	quotes   map[uint32]Quote
+	baseline map[uint32]int64
+}
+
+// NewTracker follows chain, OI change is measured from baseline.
+// Options missing from baseline start from the first OI they tick with.
+func NewTracker(chain []Option, baseline map[uint32]int64) *Tracker {
+	if baseline == nil {
+		baseline = map[uint32]int64{}
+	}
+	return &Tracker{chain: chain, quotes: map[uint32]Quote{}, baseline: baseline}
+}
+
+// Chain is the options being followed
+func (t *Tracker) Chain() []Option {
+	return t.chain
+}
+
+// Tokens to subscribe to
+func (t *Tracker) Tokens() []uint32 {
+	tokens := make([]uint32, len(t.chain))
+	for i, o := range t.chain {
+		tokens[i] = o.Token
+	}
+	return tokens
+}
+
+// OnTick records a full-mode tick
+func (t *Tracker) OnTick(tick kitemodels.Tick) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.baseline[tick.InstrumentToken]; !ok {
+		t.baseline[tick.InstrumentToken] = int64(tick.OI)
+	}
+	t.quotes[tick.InstrumentToken] = Quote{
+		OI:        int64(tick.OI),
+		Volume:    int64(tick.VolumeTraded),
+		LastPrice: tick.LastPrice,
+		Close:     tick.OHLC.Close,
+	}
+}
+
+// Quotes is a copy of the latest quotes by token
+func (t *Tracker) Quotes() map[uint32]Quote {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return maps.Clone(t.quotes)
+}
+
+// Analysis of the chain as of the last tick
+func (t *Tracker) Analysis() Analysis {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Analyse(t.chain, t.quotes, t.baseline)
+}
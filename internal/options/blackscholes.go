@@ -0,0 +1,97 @@
+// Package options prices European options with Black-Scholes and analyses
+// multi-leg option positions.
+package options
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"friction-trading/internal/calendar"
+)
+
+// Instrument types, FUT legs move one for one with the underlying
+const (
+	Call   = "CE"
+	Put    = "PE"
+	Future = "FUT"
+)
+
+// DefaultRate is the risk-free rate used when none is given, roughly the 91 day T-bill
+const DefaultRate = 0.065
+
+var ErrNoIV = errors.New("no volatility gives this option price")
+
+// Bounds of the implied volatility search, annualised
+const (
+	minIV = 0.0001
+	maxIV = 5.0
+)
+
+// expiryClose is the 15:30 IST close on the expiry date
+func expiryClose(expiry time.Time) time.Time {
+	return time.Date(expiry.Year(), expiry.Month(), expiry.Day(), 15, 30, 0, 0, calendar.IST)
+}
+
+// YearsTo is the time from now until the close on the expiry date, in years
+func YearsTo(expiry, now time.Time) float64 {
+	t := expiryClose(expiry).Sub(now).Hours() / 24 / 365
+	return math.Max(t, 0)
+}
+
+func cdf(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func d1d2(spot, strike, t, rate, iv float64) (float64, float64) {
+	d1 := (math.Log(spot/strike) + (rate+iv*iv/2)*t) / (iv * math.Sqrt(t))
+	return d1, d1 - iv*math.Sqrt(t)
+}
+
+// Intrinsic is what the option is worth at expiry
+func Intrinsic(typ string, spot, strike float64) float64 {
+	switch typ {
+	case Call:
+		return math.Max(spot-strike, 0)
+	case Put:
+		return math.Max(strike-spot, 0)
+	default:
+		return spot
+	}
+}
+
+// Price is the Black-Scholes value of an option t years from expiry
+func Price(typ string, spot, strike, t, rate, iv float64) float64 {
+	if typ != Call && typ != Put {
+		return spot
+	}
+	if t <= 0 || iv <= 0 || spot <= 0 {
+		return Intrinsic(typ, spot, strike)
+	}
+
+	d1, d2 := d1d2(spot, strike, t, rate, iv)
+	discount := strike * math.Exp(-rate*t)
+	if typ == Call {
+		return spot*cdf(d1) - discount*cdf(d2)
+	}
+	return discount*cdf(-d2) - spot*cdf(-d1)
+}
+
+// ImpliedVol finds the volatility at which Price matches price, by bisection
+func ImpliedVol(typ string, price, spot, strike, t, rate float64) (float64, error) {
+	lo, hi := minIV, maxIV
+	if t <= 0 || price < Price(typ, spot, strike, t, rate, lo) || price > Price(typ, spot, strike, t, rate, hi) {
+		return 0, ErrNoIV
+	}
+
+	// Price rises with volatility
+	for i := 0; i < 100 && hi-lo > 1e-6; i++ {
+		mid := (lo + hi) / 2
+		if Price(typ, spot, strike, t, rate, mid) < price {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, nil
+}
@@ -0,0 +1,105 @@
+package options
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Greeks of a position, Delta in units of the underlying, Gamma in units per point,
+// Theta in rupees per day and Vega in rupees per vol point
+type Greeks struct {
+	Delta float64 `json:"delta"`
+	Gamma float64 `json:"gamma"`
+	Theta float64 `json:"theta"`
+	Vega  float64 `json:"vega"`
+}
+
+func (g Greeks) add(o Greeks) Greeks {
+	return Greeks{Delta: g.Delta + o.Delta, Gamma: g.Gamma + o.Gamma, Theta: g.Theta + o.Theta, Vega: g.Vega + o.Vega}
+}
+
+func pdf(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+// UnitGreeks are the Black-Scholes greeks of one unit of an option
+func UnitGreeks(typ string, spot, strike, t, rate, iv float64) Greeks {
+	if typ != Call && typ != Put {
+		return Greeks{Delta: 1}
+	}
+	if t <= 0 || iv <= 0 || spot <= 0 {
+		// Expired, all delta and nothing else
+		switch {
+		case typ == Call && spot > strike:
+			return Greeks{Delta: 1}
+		case typ == Put && spot < strike:
+			return Greeks{Delta: -1}
+		}
+		return Greeks{}
+	}
+
+	d1, d2 := d1d2(spot, strike, t, rate, iv)
+	sqrtT := math.Sqrt(t)
+	discount := strike * math.Exp(-rate*t)
+	g := Greeks{
+		Gamma: pdf(d1) / (spot * iv * sqrtT),
+		Vega:  spot * pdf(d1) * sqrtT / 100,
+	}
+	decay := -spot * pdf(d1) * iv / (2 * sqrtT)
+	if typ == Call {
+		g.Delta = cdf(d1)
+		g.Theta = (decay - rate*discount*cdf(d2)) / 365
+	} else {
+		g.Delta = cdf(d1) - 1
+		g.Theta = (decay + rate*discount*cdf(-d2)) / 365
+	}
+	return g
+}
+
+// Greeks of the whole leg at spot
+func (l Leg) Greeks(spot float64, now time.Time, rate float64) Greeks {
+	g := UnitGreeks(l.Type, spot, l.Strike, YearsTo(l.Expiry, now), rate, l.IV)
+	q := float64(l.Quantity)
+	return Greeks{Delta: g.Delta * q, Gamma: g.Gamma * q, Theta: g.Theta * q, Vega: g.Vega * q}
+}
+
+// PositionGreeks is one leg with its greeks
+type PositionGreeks struct {
+	Leg
+	Greeks Greeks `json:"greeks"`
+}
+
+// Exposure is the net greeks of every leg on one underlying
+type Exposure struct {
+	Underlying string           `json:"underlying"`
+	Spot       float64          `json:"spot"`
+	Net        Greeks           `json:"net"`
+	Positions  []PositionGreeks `json:"positions"`
+}
+
+// Exposures groups legs by underlying, priced at spots. Legs without a spot are left out.
+func Exposures(legs []Leg, spots map[string]float64, now time.Time, rate float64) []Exposure {
+	byUnderlying := map[string]*Exposure{}
+	for _, l := range legs {
+		spot, ok := spots[l.Underlying]
+		if !ok {
+			continue
+		}
+		e, ok := byUnderlying[l.Underlying]
+		if !ok {
+			e = &Exposure{Underlying: l.Underlying, Spot: spot}
+			byUnderlying[l.Underlying] = e
+		}
+		g := l.Greeks(spot, now, rate)
+		e.Positions = append(e.Positions, PositionGreeks{Leg: l, Greeks: g})
+		e.Net = e.Net.add(g)
+	}
+
+	out := make([]Exposure, 0, len(byUnderlying))
+	for _, e := range byUnderlying {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Underlying < out[j].Underlying })
+	return out
+}
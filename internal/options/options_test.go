@@ -0,0 +1,131 @@
+package options_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/options"
+)
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestPrice(t *testing.T) {
+	// Textbook values, S=100 K=100 one year r=5% vol=20%
+	if c := options.Price(options.Call, 100, 100, 1, 0.05, 0.2); !near(c, 10.4506, 1e-3) {
+		t.Errorf("want call 10.4506, got %v", c)
+	}
+	if p := options.Price(options.Put, 100, 100, 1, 0.05, 0.2); !near(p, 5.5735, 1e-3) {
+		t.Errorf("want put 5.5735, got %v", p)
+	}
+	if c := options.Price(options.Call, 110, 100, 0, 0.05, 0.2); c != 10 {
+		t.Errorf("want intrinsic 10 at expiry, got %v", c)
+	}
+
+	iv, err := options.ImpliedVol(options.Call, 10.4506, 100, 100, 1, 0.05)
+	if err != nil || !near(iv, 0.2, 1e-4) {
+		t.Errorf("want IV 0.2, got %v, %v", iv, err)
+	}
+	if _, err := options.ImpliedVol(options.Call, 1, 120, 100, 1, 0.05); err == nil {
+		t.Error("want error for a price under intrinsic")
+	}
+}
+
+func TestAnalyseIronCondor(t *testing.T) {
+	expiry := time.Date(2025, 10, 23, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 10, 20, 10, 0, 0, 0, calendar.IST)
+	legs := []options.Leg{
+		{Type: options.Call, Strike: 25200, Expiry: expiry, Quantity: -75, Premium: 40, IV: 0.12},
+		{Type: options.Put, Strike: 24800, Expiry: expiry, Quantity: -75, Premium: 45, IV: 0.13},
+		{Type: options.Call, Strike: 25300, Expiry: expiry, Quantity: 75, Premium: 20, IV: 0.12},
+		{Type: options.Put, Strike: 24700, Expiry: expiry, Quantity: 75, Premium: 25, IV: 0.13},
+	}
+
+	a, err := options.Analyse(legs, options.Settings{
+		Spot: 25000, Now: now, Rate: options.DefaultRate, Range: 0.05, Steps: 50, IVShocks: []float64{-0.03, 0.03},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Credit 40 a share, wings 100 wide
+	if !near(a.MaxProfit, 40*75, 1e-6) || !near(a.MaxLoss, -60*75, 1e-6) {
+		t.Errorf("want max profit 3000 and loss -4500, got %v %v", a.MaxProfit, a.MaxLoss)
+	}
+	if len(a.Breakevens) != 2 || !near(a.Breakevens[0], 24760, 1e-6) || !near(a.Breakevens[1], 25240, 1e-6) {
+		t.Errorf("want breakevens 24760 and 25240, got %v", a.Breakevens)
+	}
+	if a.UnboundedProfit || a.UnboundedLoss {
+		t.Error("a condor is bounded both ways")
+	}
+
+	// Short vega, higher IV hurts
+	if len(a.Scenarios) != 2 || a.Scenarios[0].PnL <= a.Scenarios[1].PnL || len(a.Scenarios[1].T0) != len(a.Points) {
+		t.Errorf("want IV up to lose more than IV down, got %+v", a.Scenarios)
+	}
+}
+
+func TestAnalyseBeyondRange(t *testing.T) {
+	expiry := time.Date(2025, 10, 23, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 10, 20, 10, 0, 0, 0, calendar.IST)
+	set := options.Settings{Spot: 100, Now: now, Range: 0.1, Steps: 20}
+
+	// A short put loses down to a price of zero, well past the range
+	a, err := options.Analyse([]options.Leg{
+		{Type: options.Put, Strike: 100, Expiry: expiry, Quantity: -1, Premium: 2, IV: 0.2},
+	}, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(a.MaxLoss, -98, 1e-9) || !near(a.MaxProfit, 2, 1e-9) || a.UnboundedLoss || a.UnboundedProfit {
+		t.Errorf("want short put bounded to a loss of 98 and profit of 2, got %+v", a)
+	}
+
+	// Futures alone settle on their own expiry and lose everything at zero
+	a, err = options.Analyse([]options.Leg{
+		{Type: options.Future, Expiry: expiry, Quantity: 1, Premium: 100},
+	}, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Expiry.Equal(expiry) || !near(a.MaxLoss, -100, 1e-9) || a.UnboundedLoss || !a.UnboundedProfit {
+		t.Errorf("want long future to lose at most 100 and gain without limit, got %+v", a)
+	}
+	if len(a.Breakevens) != 1 || !near(a.Breakevens[0], 100, 1e-9) {
+		t.Errorf("want breakeven at entry, got %v", a.Breakevens)
+	}
+}
+
+func TestExposures(t *testing.T) {
+	expiry := time.Date(2025, 10, 23, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 10, 20, 10, 0, 0, 0, calendar.IST)
+
+	call := options.UnitGreeks(options.Call, 25000, 25000, options.YearsTo(expiry, now), options.DefaultRate, 0.12)
+	put := options.UnitGreeks(options.Put, 25000, 25000, options.YearsTo(expiry, now), options.DefaultRate, 0.12)
+	if !near(call.Delta-put.Delta, 1, 1e-9) || !near(call.Gamma, put.Gamma, 1e-12) || !near(call.Vega, put.Vega, 1e-9) {
+		t.Errorf("want parity between call and put greeks, got %+v %+v", call, put)
+	}
+	if call.Theta >= 0 || call.Vega <= 0 {
+		t.Errorf("want long options to decay and gain on IV, got %+v", call)
+	}
+
+	// Short straddle plus a future hedge on NIFTY, BANKNIFTY has no spot and is left out
+	legs := []options.Leg{
+		{Underlying: "NIFTY", Type: options.Call, Strike: 25000, Expiry: expiry, Quantity: -75, IV: 0.12},
+		{Underlying: "NIFTY", Type: options.Put, Strike: 25000, Expiry: expiry, Quantity: -75, IV: 0.12},
+		{Underlying: "NIFTY", Type: options.Future, Expiry: expiry, Quantity: 75},
+		{Underlying: "BANKNIFTY", Type: options.Call, Strike: 56000, Expiry: expiry, Quantity: 35, IV: 0.14},
+	}
+	exposures := options.Exposures(legs, map[string]float64{"NIFTY": 25000}, now, options.DefaultRate)
+	if len(exposures) != 1 || len(exposures[0].Positions) != 3 {
+		t.Fatalf("want NIFTY only with 3 positions, got %+v", exposures)
+	}
+	net := exposures[0].Net
+	wantDelta := -75*(call.Delta+put.Delta) + 75
+	if !near(net.Delta, wantDelta, 1e-9) || net.Gamma >= 0 || net.Theta <= 0 || net.Vega >= 0 {
+		t.Errorf("want short gamma and vega, long theta, delta %v, got %+v", wantDelta, net)
+	}
+}
@@ -0,0 +1,166 @@
+package options
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"time"
+)
+
+var ErrNoPositions = errors.New("nothing to analyse")
+
+// Leg is one position of a structure, Quantity is negative for shorts
+type Leg struct {
+	Underlying    string    `json:"underlying,omitempty"`
+	Tradingsymbol string    `json:"tradingsymbol,omitempty"`
+	Type          string    `json:"type"` // CE, PE or FUT
+	Strike        float64   `json:"strike"`
+	Expiry        time.Time `json:"expiry"`
+	Quantity      int       `json:"quantity"`
+	Premium       float64   `json:"premium"` // entry price
+	IV            float64   `json:"iv"`      // annualised, 0.14 is 14%
+}
+
+// value is the leg's price at spot t years before its expiry, with iv shifted
+func (l Leg) value(spot, t, rate, shift float64) float64 {
+	return Price(l.Type, spot, l.Strike, t, rate, math.Max(l.IV+shift, minIV))
+}
+
+// Settings are the inputs of a payoff analysis
+type Settings struct {
+	Spot     float64
+	Now      time.Time
+	Rate     float64
+	Range    float64   // prices span spot ± Range, as a fraction of spot
+	Steps    int       // prices across the range, strikes are added on top
+	IVShocks []float64 // absolute shifts of every leg's IV, 0.05 is 5 vol points up
+}
+
+// Point is P&L at one underlying price
+type Point struct {
+	Price  float64 `json:"price"`
+	Expiry float64 `json:"expiry"` // at the nearest expiry
+	T0     float64 `json:"t0"`     // today, at current IVs
+}
+
+// Scenario is the T+0 curve with every IV shocked
+type Scenario struct {
+	IVShock float64   `json:"iv_shock"`
+	PnL     float64   `json:"pnl"` // at the current spot
+	T0      []float64 `json:"t0"`  // lines up with Analysis.Points
+}
+
+// Analysis is what the dashboard charts
+type Analysis struct {
+	Spot            float64    `json:"spot"`
+	Expiry          time.Time  `json:"expiry"`
+	Legs            []Leg      `json:"legs"`
+	Points          []Point    `json:"points"`
+	Breakevens      []float64  `json:"breakevens"`
+	MaxProfit       float64    `json:"max_profit"` // at expiry, from a price of zero to the top strike or range
+	MaxLoss         float64    `json:"max_loss"`
+	UnboundedProfit bool       `json:"unbounded_profit"` // grows without limit as the price rises, falls stop at zero
+	UnboundedLoss   bool       `json:"unbounded_loss"`
+	Scenarios       []Scenario `json:"scenarios"`
+}
+
+// Analyse works out the payoff of legs at the nearest expiry and today.
+// Legs expiring later are still priced with their remaining time at that expiry.
+func Analyse(legs []Leg, set Settings) (Analysis, error) {
+	if len(legs) == 0 {
+		return Analysis{}, ErrNoPositions
+	}
+	if set.Spot <= 0 {
+		return Analysis{}, errors.New("spot must be positive")
+	}
+	if set.Range <= 0 {
+		set.Range = 0.1
+	}
+	if set.Steps < 2 {
+		set.Steps = 100
+	}
+
+	// Futures only structures settle at the nearest future
+	a := Analysis{Spot: set.Spot, Legs: legs}
+	hasOptions := slices.ContainsFunc(legs, func(l Leg) bool { return l.Type != Future })
+	for _, l := range legs {
+		if (l.Type != Future || !hasOptions) && (a.Expiry.IsZero() || l.Expiry.Before(a.Expiry)) {
+			a.Expiry = l.Expiry
+		}
+	}
+	if a.Expiry.IsZero() {
+		a.Expiry = set.Now
+	}
+
+	// Payoffs bend at strikes, having them on the grid keeps breakevens exact
+	lo, hi := set.Spot*(1-set.Range), set.Spot*(1+set.Range)
+	var prices []float64
+	for i := 0; i <= set.Steps; i++ {
+		prices = append(prices, lo+(hi-lo)*float64(i)/float64(set.Steps))
+	}
+	for _, l := range legs {
+		if l.Type != Future && l.Strike > lo && l.Strike < hi {
+			prices = append(prices, l.Strike)
+		}
+	}
+	slices.Sort(prices)
+	prices = slices.Compact(prices)
+
+	pnl := func(spot float64, at time.Time, shift float64) float64 {
+		total := 0.0
+		for _, l := range legs {
+			total += float64(l.Quantity) * (l.value(spot, YearsTo(l.Expiry, at), set.Rate, shift) - l.Premium)
+		}
+		return total
+	}
+
+	// At the close of the nearest expiry
+	atExpiry := expiryClose(a.Expiry)
+	for _, p := range prices {
+		a.Points = append(a.Points, Point{Price: p, Expiry: pnl(p, atExpiry, 0), T0: pnl(p, set.Now, 0)})
+	}
+
+	// The expiry payoff turns only at strikes, so its extremes sit at one of them, at a
+	// price of zero or past the range
+	edges := append([]float64{0}, prices...)
+	for _, l := range legs {
+		if l.Type != Future {
+			edges = append(edges, l.Strike)
+		}
+	}
+	slices.Sort(edges)
+	edges = slices.Compact(edges)
+	a.MaxProfit, a.MaxLoss = math.Inf(-1), math.Inf(1)
+	var last Point
+	for i, p := range edges {
+		q := Point{Price: p, Expiry: pnl(p, atExpiry, 0)}
+		a.MaxProfit = math.Max(a.MaxProfit, q.Expiry)
+		a.MaxLoss = math.Min(a.MaxLoss, q.Expiry)
+		switch {
+		case q.Expiry == 0:
+			a.Breakevens = append(a.Breakevens, q.Price)
+		case i > 0 && last.Expiry*q.Expiry < 0:
+			a.Breakevens = append(a.Breakevens, last.Price+(q.Price-last.Price)*last.Expiry/(last.Expiry-q.Expiry))
+		}
+		last = q
+	}
+
+	// Past the top strike P&L moves with net calls and futures. Below the bottom one the
+	// price stops at zero, which the edges already cover
+	up := 0
+	for _, l := range legs {
+		if l.Type != Put {
+			up += l.Quantity
+		}
+	}
+	a.UnboundedProfit, a.UnboundedLoss = up > 0, up < 0
+
+	for _, shock := range set.IVShocks {
+		sc := Scenario{IVShock: shock, PnL: pnl(set.Spot, set.Now, shock)}
+		for _, p := range prices {
+			sc.T0 = append(sc.T0, pnl(p, set.Now, shock))
+		}
+		a.Scenarios = append(a.Scenarios, sc)
+	}
+	return a, nil
+}
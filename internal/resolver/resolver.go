@@ -0,0 +1,315 @@
+// Package resolver turns human instrument specs like "NIFTY current-week ATM CE",
+// "BANKNIFTY next-month FUT" or "RELIANCE NSE EQ" into instruments
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/database"
+)
+
+var (
+	ErrBadSpec  = errors.New("invalid instrument spec")
+	ErrNotFound = errors.New("no instrument matches spec")
+)
+
+// Expiries relative to today, weeks count every listed expiry and months
+// only the last expiry of each month
+const (
+	CurrentWeek  = "current-week"
+	NextWeek     = "next-week"
+	CurrentMonth = "current-month"
+	NextMonth    = "next-month"
+)
+
+// Instrument types
+const (
+	Call   = "CE"
+	Put    = "PE"
+	Future = "FUT"
+	Equity = "EQ"
+)
+
+// Index derivatives listed on BSE
+var bseIndices = []string{"SENSEX", "BANKEX", "SENSEX50"}
+
+// Spec is a parsed instrument spec
+type Spec struct {
+	Underlying string `json:"underlying"`
+	Exchange   string `json:"exchange"`
+	Expiry     string `json:"expiry,omitempty"` // CurrentWeek etc. or YYYY-MM-DD
+	Strike     string `json:"strike,omitempty"` // ATM, ATM+2, ATM-1 or a price
+	Type       string `json:"type"`             // CE, PE, FUT or EQ
+}
+
+func (sp Spec) String() string {
+	parts := []string{sp.Underlying, sp.Exchange, sp.Expiry, sp.Strike, sp.Type}
+	return strings.Join(slices.DeleteFunc(parts, func(p string) bool { return p == "" }), " ")
+}
+
+func isExchange(word string) bool {
+	switch word {
+	case calendar.NSE, calendar.BSE, calendar.NFO, calendar.BFO, calendar.CDS, calendar.MCX:
+		return true
+	}
+	return false
+}
+
+// Parse reads a spec, words are case insensitive and in any order after the underlying.
+// Options default to the current week, futures to the current month, exchanges to
+// NSE for equity and NFO (BFO for BSE indices) for derivatives.
+func Parse(s string) (Spec, error) {
+	words := strings.Fields(strings.ToUpper(s))
+	if len(words) < 2 {
+		return Spec{}, fmt.Errorf("%w: %q, want e.g. \"NIFTY current-week ATM CE\"", ErrBadSpec, s)
+	}
+
+	sp := Spec{Underlying: words[0]}
+	for _, w := range words[1:] {
+		switch {
+		case w == Call || w == Put || w == Future || w == Equity:
+			sp.Type = w
+		case isExchange(w):
+			sp.Exchange = w
+		case w == "WEEKLY":
+			sp.Expiry = CurrentWeek
+		case w == "MONTHLY":
+			sp.Expiry = CurrentMonth
+		case slices.Contains([]string{CurrentWeek, NextWeek, CurrentMonth, NextMonth}, strings.ToLower(w)):
+			sp.Expiry = strings.ToLower(w)
+		case strings.HasPrefix(w, "ATM"):
+			if off := strings.TrimPrefix(w, "ATM"); off != "" {
+				if _, err := strconv.Atoi(off); err != nil {
+					return Spec{}, fmt.Errorf("%w: strike %q, want ATM, ATM+2 or ATM-1", ErrBadSpec, w)
+				}
+			}
+			sp.Strike = w
+		default:
+			if _, err := time.Parse(time.DateOnly, w); err == nil {
+				sp.Expiry = w
+				continue
+			}
+			if _, err := strconv.ParseFloat(w, 64); err == nil {
+				sp.Strike = w
+				continue
+			}
+			return Spec{}, fmt.Errorf("%w: unexpected %q in %q", ErrBadSpec, w, s)
+		}
+	}
+
+	switch sp.Type {
+	case "":
+		return Spec{}, fmt.Errorf("%w: %q needs a type, CE, PE, FUT or EQ", ErrBadSpec, s)
+	case Equity:
+		if sp.Expiry != "" || sp.Strike != "" {
+			return Spec{}, fmt.Errorf("%w: equity has no expiry or strike", ErrBadSpec)
+		}
+		if sp.Exchange == "" {
+			sp.Exchange = calendar.NSE
+		}
+		return sp, nil
+	case Future:
+		if sp.Strike != "" {
+			return Spec{}, fmt.Errorf("%w: futures have no strike", ErrBadSpec)
+		}
+		if sp.Expiry == "" {
+			sp.Expiry = CurrentMonth
+		}
+	default:
+		if sp.Strike == "" {
+			sp.Strike = "ATM"
+		}
+		if sp.Expiry == "" {
+			sp.Expiry = CurrentWeek
+		}
+	}
+	if sp.Exchange == "" {
+		sp.Exchange = calendar.NFO
+		if slices.Contains(bseIndices, sp.Underlying) {
+			sp.Exchange = calendar.BFO
+		}
+	}
+	return sp, nil
+}
+
+// Resolver looks specs up in the instruments table
+type Resolver struct {
+	store database.Querier
+	spot  func(underlying string) (float64, error)
+	now   func() time.Time
+}
+
+// New resolves against store, ATM is the listed strike nearest to spot
+func New(store database.Querier, spot func(underlying string) (float64, error)) *Resolver {
+	return &Resolver{store: store, spot: spot, now: time.Now}
+}
+
+// SetClock replaces the clock used to pick expiries
+func (r *Resolver) SetClock(now func() time.Time) {
+	r.now = now
+}
+
+// Resolve parses and looks up a spec
+func (r *Resolver) Resolve(ctx context.Context, spec string) (*database.Instrument, error) {
+	sp, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return r.ResolveSpec(ctx, sp)
+}
+
+// ResolveSpec looks up a parsed spec. On expiry day contracts expiring today
+// are skipped, so specs roll over to the next expiry.
+func (r *Resolver) ResolveSpec(ctx context.Context, sp Spec) (*database.Instrument, error) {
+	if sp.Type == Equity {
+		inst, err := r.store.GetInstrumentBySymbol(ctx, database.GetInstrumentBySymbolParams{
+			Exchange:      sp.Exchange,
+			Tradingsymbol: sp.Underlying,
+		})
+		if err != nil && strings.Contains(err.Error(), "no rows in result set") {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, sp)
+		}
+		return inst, err
+	}
+
+	// Expiries are stored as dates, anything expiring today has rolled over
+	now := r.now().In(calendar.IST)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	from := pgtype.Timestamp{Time: tomorrow, Valid: true}
+
+	if sp.Type == Future {
+		futures, err := r.store.ListFutures(ctx, database.ListFuturesParams{Exchange: sp.Exchange, Name: sp.Underlying, Expiry: from})
+		if err != nil {
+			return nil, fmt.Errorf("list futures: %w", err)
+		}
+		expiries := make([]time.Time, len(futures))
+		for i, f := range futures {
+			expiries[i] = f.Expiry.Time
+		}
+		i, err := pickExpiry(expiries, sp.Expiry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, sp)
+		}
+		return futures[i], nil
+	}
+
+	listed, err := r.store.ListOptionExpiries(ctx, database.ListOptionExpiriesParams{Exchange: sp.Exchange, Name: sp.Underlying, Expiry: from})
+	if err != nil {
+		return nil, fmt.Errorf("list expiries: %w", err)
+	}
+	expiries := make([]time.Time, 0, len(listed))
+	for _, e := range listed {
+		if e.Valid {
+			expiries = append(expiries, e.Time)
+		}
+	}
+	i, err := pickExpiry(expiries, sp.Expiry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, sp)
+	}
+
+	chain, err := r.store.ListOptionChain(ctx, database.ListOptionChainParams{
+		Exchange: sp.Exchange,
+		Name:     sp.Underlying,
+		Expiry:   pgtype.Timestamp{Time: expiries[i], Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("option chain: %w", err)
+	}
+	return r.pickStrike(chain, sp)
+}
+
+// pickExpiry finds the expiry a spec asks for in ascending expiries
+func pickExpiry(expiries []time.Time, want string) (int, error) {
+	if len(expiries) == 0 {
+		return 0, ErrNotFound
+	}
+
+	// Last expiry of each month
+	var monthly []int
+	for i, e := range expiries {
+		if i+1 == len(expiries) || expiries[i+1].Month() != e.Month() || expiries[i+1].Year() != e.Year() {
+			monthly = append(monthly, i)
+		}
+	}
+
+	nth := func(list []int, n int) (int, error) {
+		if n >= len(list) {
+			return 0, ErrNotFound
+		}
+		return list[n], nil
+	}
+
+	switch want {
+	case CurrentWeek:
+		return 0, nil
+	case NextWeek:
+		if len(expiries) < 2 {
+			return 0, ErrNotFound
+		}
+		return 1, nil
+	case CurrentMonth:
+		return nth(monthly, 0)
+	case NextMonth:
+		return nth(monthly, 1)
+	}
+	for i, e := range expiries {
+		if e.Format(time.DateOnly) == want {
+			return i, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// pickStrike finds the spec's strike and type in one expiry's chain
+func (r *Resolver) pickStrike(chain []*database.Instrument, sp Spec) (*database.Instrument, error) {
+	var strikes []float64
+	for _, in := range chain {
+		if !slices.Contains(strikes, in.Strike) {
+			strikes = append(strikes, in.Strike)
+		}
+	}
+	if len(strikes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, sp)
+	}
+	slices.Sort(strikes)
+
+	strike, err := strconv.ParseFloat(sp.Strike, 64)
+	if err != nil {
+		offset := 0
+		if off := strings.TrimPrefix(sp.Strike, "ATM"); off != "" {
+			offset, _ = strconv.Atoi(off)
+		}
+		spot, err := r.spot(sp.Underlying)
+		if err != nil {
+			return nil, fmt.Errorf("spot for %s: %w", sp.Underlying, err)
+		}
+		atm := 0
+		for i, k := range strikes {
+			if math.Abs(k-spot) < math.Abs(strikes[atm]-spot) {
+				atm = i
+			}
+		}
+		if atm+offset < 0 || atm+offset >= len(strikes) {
+			return nil, fmt.Errorf("%w: %s, ATM is %.2f", ErrNotFound, sp, strikes[atm])
+		}
+		strike = strikes[atm+offset]
+	}
+
+	for _, in := range chain {
+		if in.Strike == strike && in.InstrumentType == sp.Type {
+			return in, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, sp)
+}
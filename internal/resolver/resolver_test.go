@@ -0,0 +1,152 @@
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/database"
+	"friction-trading/internal/resolver"
+)
+
+// stubStore serves instruments from memory
+type stubStore struct {
+	database.Querier
+	instruments []*database.Instrument
+}
+
+func (s *stubStore) GetInstrumentBySymbol(ctx context.Context, arg database.GetInstrumentBySymbolParams) (*database.Instrument, error) {
+	for _, in := range s.instruments {
+		if in.Exchange == arg.Exchange && in.Tradingsymbol == arg.Tradingsymbol {
+			return in, nil
+		}
+	}
+	return nil, errors.New("no rows in result set")
+}
+
+func (s *stubStore) ListFutures(ctx context.Context, arg database.ListFuturesParams) ([]*database.Instrument, error) {
+	var out []*database.Instrument
+	for _, in := range s.instruments {
+		if in.Exchange == arg.Exchange && in.Name == arg.Name && in.InstrumentType == "FUT" && !in.Expiry.Time.Before(arg.Expiry.Time) {
+			out = append(out, in)
+		}
+	}
+	slices.SortFunc(out, func(a, b *database.Instrument) int { return a.Expiry.Time.Compare(b.Expiry.Time) })
+	return out, nil
+}
+
+func (s *stubStore) ListOptionExpiries(ctx context.Context, arg database.ListOptionExpiriesParams) ([]pgtype.Timestamp, error) {
+	var out []pgtype.Timestamp
+	for _, in := range s.instruments {
+		if in.Exchange != arg.Exchange || in.Name != arg.Name || in.InstrumentType == "FUT" || in.Expiry.Time.Before(arg.Expiry.Time) {
+			continue
+		}
+		if !slices.ContainsFunc(out, func(e pgtype.Timestamp) bool { return e.Time.Equal(in.Expiry.Time) }) {
+			out = append(out, in.Expiry)
+		}
+	}
+	slices.SortFunc(out, func(a, b pgtype.Timestamp) int { return a.Time.Compare(b.Time) })
+	return out, nil
+}
+
+func (s *stubStore) ListOptionChain(ctx context.Context, arg database.ListOptionChainParams) ([]*database.Instrument, error) {
+	var out []*database.Instrument
+	for _, in := range s.instruments {
+		if in.Exchange == arg.Exchange && in.Name == arg.Name && in.InstrumentType != "FUT" && in.Expiry.Time.Equal(arg.Expiry.Time) {
+			out = append(out, in)
+		}
+	}
+	return out, nil
+}
+
+func date(month time.Month, day int) pgtype.Timestamp {
+	return pgtype.Timestamp{Time: time.Date(2025, month, day, 0, 0, 0, 0, time.UTC), Valid: true}
+}
+
+// NIFTY weeklies on Oct 20, 23 and 28 (the monthly) and Nov 25, futures for Oct and Nov
+func newStore() *stubStore {
+	s := &stubStore{instruments: []*database.Instrument{
+		{InstrumentToken: 1, Exchange: "NSE", Tradingsymbol: "RELIANCE", InstrumentType: "EQ"},
+		{InstrumentToken: 2, Exchange: "NFO", Tradingsymbol: "NIFTY25OCTFUT", Name: "NIFTY", InstrumentType: "FUT", Expiry: date(10, 28)},
+		{InstrumentToken: 3, Exchange: "NFO", Tradingsymbol: "NIFTY25NOVFUT", Name: "NIFTY", InstrumentType: "FUT", Expiry: date(11, 25)},
+	}}
+	token := int64(100)
+	for _, expiry := range []pgtype.Timestamp{date(10, 20), date(10, 23), date(10, 28), date(11, 25)} {
+		for strike := 24900.0; strike <= 25100; strike += 50 {
+			for _, typ := range []string{"CE", "PE"} {
+				token++
+				s.instruments = append(s.instruments, &database.Instrument{
+					InstrumentToken: token, Exchange: "NFO", Name: "NIFTY", Expiry: expiry, Strike: strike, InstrumentType: typ,
+					Tradingsymbol: fmt.Sprintf("NIFTY%s%.0f%s", expiry.Time.Format("060102"), strike, typ),
+				})
+			}
+		}
+	}
+	return s
+}
+
+func TestParse(t *testing.T) {
+	cases := map[string]resolver.Spec{
+		"NIFTY current-week ATM CE": {Underlying: "NIFTY", Exchange: "NFO", Expiry: resolver.CurrentWeek, Strike: "ATM", Type: "CE"},
+		"banknifty next-month FUT":  {Underlying: "BANKNIFTY", Exchange: "NFO", Expiry: resolver.NextMonth, Type: "FUT"},
+		"RELIANCE NSE EQ":           {Underlying: "RELIANCE", Exchange: "NSE", Type: "EQ"},
+		"SENSEX monthly ATM-2 PE":   {Underlying: "SENSEX", Exchange: "BFO", Expiry: resolver.CurrentMonth, Strike: "ATM-2", Type: "PE"},
+		"NIFTY 2025-10-23 25000 CE": {Underlying: "NIFTY", Exchange: "NFO", Expiry: "2025-10-23", Strike: "25000", Type: "CE"},
+	}
+	for in, want := range cases {
+		got, err := resolver.Parse(in)
+		if err != nil || got != want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", in, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"NIFTY", "NIFTY ATM", "RELIANCE ATM EQ", "NIFTY ATMX CE", "NIFTY sometime CE"} {
+		if _, err := resolver.Parse(bad); !errors.Is(err, resolver.ErrBadSpec) {
+			t.Errorf("Parse(%q) want ErrBadSpec, got %v", bad, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r := resolver.New(newStore(), func(string) (float64, error) { return 25030, nil })
+	at := func(month time.Month, day int) func() time.Time {
+		return func() time.Time { return time.Date(2025, month, day, 10, 0, 0, 0, calendar.IST) }
+	}
+
+	cases := []struct {
+		now  func() time.Time
+		spec string
+		want string
+	}{
+		{at(10, 17), "NIFTY current-week ATM CE", "NIFTY25102025050CE"},
+		{at(10, 17), "NIFTY next-week ATM+1 PE", "NIFTY25102325100PE"},
+		{at(10, 17), "NIFTY monthly 24900 CE", "NIFTY25102824900CE"},
+		{at(10, 17), "NIFTY next-month FUT", "NIFTY25NOVFUT"},
+		{at(10, 17), "RELIANCE NSE EQ", "RELIANCE"},
+
+		// Rolled over on expiry day
+		{at(10, 20), "NIFTY current-week ATM CE", "NIFTY25102325050CE"},
+		{at(10, 28), "NIFTY current-month FUT", "NIFTY25NOVFUT"},
+		{at(10, 28), "NIFTY current-month ATM-1 PE", "NIFTY25112525000PE"},
+	}
+	for _, c := range cases {
+		r.SetClock(c.now)
+		inst, err := r.Resolve(context.Background(), c.spec)
+		if err != nil || inst.Tradingsymbol != c.want {
+			t.Errorf("%s on %s: got %+v, %v, want %s", c.spec, c.now().Format(time.DateOnly), inst, err, c.want)
+		}
+	}
+
+	r.SetClock(at(10, 17))
+	for _, spec := range []string{"NIFTY current-week ATM+5 CE", "NIFTY 2025-12-30 ATM CE", "TCS NSE EQ"} {
+		if _, err := r.Resolve(context.Background(), spec); !errors.Is(err, resolver.ErrNotFound) {
+			t.Errorf("%s: want ErrNotFound, got %v", spec, err)
+		}
+	}
+}
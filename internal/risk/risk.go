@@ -0,0 +1,297 @@
+// Package risk checks orders against account limits before they reach the broker
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/broker"
+	"friction-trading/internal/options"
+)
+
+var ErrKilled = errors.New("kill switch engaged, trading is halted")
+
+// Limits on the account, a zero limit is not enforced
+type Limits struct {
+	MaxDailyLoss         float64 `huml:"max_daily_loss" json:"max_daily_loss"`                 // rupees lost today across positions
+	MaxOpenPositions     int     `huml:"max_open_positions" json:"max_open_positions"`         // symbols with a non zero net position
+	MaxQtyPerSymbol      int     `huml:"max_qty_per_symbol" json:"max_qty_per_symbol"`         // absolute net quantity after the order
+	MaxOrderNotional     float64 `huml:"max_order_notional" json:"max_order_notional"`         // quantity x price of one order
+	MaxMarginUtilisation float64 `huml:"max_margin_utilisation" json:"max_margin_utilisation"` // fraction of equity margin used after the order
+	MaxNetDelta          float64 `huml:"max_net_delta" json:"max_net_delta"`                   // absolute option book delta per underlying, in units
+	MaxNetVega           float64 `huml:"max_net_vega" json:"max_net_vega"`                     // absolute option book vega per underlying, rupees per vol point
+}
+
+// Exposure works out the net greeks of positions by underlying
+type Exposure func(positions []kiteconnect.Position) (map[string]options.Greeks, error)
+
+// Violation lists every limit an order breaks
+type Violation struct {
+	Reasons []string `json:"reasons"`
+}
+
+func (v *Violation) Error() string {
+	return "order rejected by risk: " + strings.Join(v.Reasons, "; ")
+}
+
+// KillReport is what the kill switch did
+type KillReport struct {
+	At         time.Time `json:"at"`
+	Cancelled  []string  `json:"cancelled"`
+	SquaredOff []string  `json:"squared_off"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Engine gates orders to a broker
+type Engine struct {
+	broker   broker.Broker
+	limits   Limits
+	exposure Exposure
+
+	// order serialises checks with placement, so concurrent orders are
+	// checked against each other's positions rather than the same snapshot
+	order sync.Mutex
+
+	mu     sync.Mutex
+	killed *KillReport
+}
+
+func New(b broker.Broker, limits Limits) *Engine {
+	return &Engine{broker: b, limits: limits}
+}
+
+// SetExposure enables the greeks limits, call it before trading starts
+func (e *Engine) SetExposure(fn Exposure) {
+	e.exposure = fn
+}
+
+func (e *Engine) Limits() Limits {
+	return e.limits
+}
+
+// Killed returns the last kill report while the kill switch is engaged
+func (e *Engine) Killed() *KillReport {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.killed
+}
+
+// Check validates an order, ltp prices market orders. While the kill
+// switch is engaged only orders which shrink a position are allowed
+func (e *Engine) Check(params kiteconnect.OrderParams, ltp float64) error {
+	if params.Quantity <= 0 {
+		return &Violation{Reasons: []string{"quantity must be positive"}}
+	}
+
+	positions, err := e.broker.Positions()
+	if err != nil {
+		if e.Killed() != nil {
+			return ErrKilled
+		}
+		return fmt.Errorf("risk: fetch positions: %w", err)
+	}
+
+	qty := params.Quantity
+	if params.TransactionType == kiteconnect.TransactionTypeSell {
+		qty = -qty
+	}
+
+	// Net quantity in the symbol and open symbols across products
+	held := 0
+	open := map[string]bool{}
+	for _, p := range positions.Net {
+		if p.Quantity == 0 {
+			continue
+		}
+		open[p.Exchange+":"+p.Tradingsymbol] = true
+		if p.Exchange == params.Exchange && p.Tradingsymbol == params.Tradingsymbol {
+			held += p.Quantity
+		}
+	}
+
+	// Orders which only shrink a position are always let through, the kill
+	// switch included
+	if held != 0 && (held > 0) != (qty > 0) && abs(qty) <= abs(held) {
+		return nil
+	}
+	if e.Killed() != nil {
+		return ErrKilled
+	}
+
+	var reasons []string
+
+	if e.limits.MaxDailyLoss > 0 {
+		pnl := 0.0
+		for _, p := range positions.Day {
+			pnl += p.PnL
+		}
+		if -pnl >= e.limits.MaxDailyLoss {
+			reasons = append(reasons, fmt.Sprintf("daily loss %.2f has hit the limit of %.2f", -pnl, e.limits.MaxDailyLoss))
+		}
+	}
+
+	if e.limits.MaxOpenPositions > 0 && held == 0 && len(open) >= e.limits.MaxOpenPositions {
+		reasons = append(reasons, fmt.Sprintf("%d open positions, limit is %d", len(open), e.limits.MaxOpenPositions))
+	}
+
+	if e.limits.MaxQtyPerSymbol > 0 && abs(held+qty) > e.limits.MaxQtyPerSymbol {
+		reasons = append(reasons, fmt.Sprintf("net quantity %d in %s exceeds %d", held+qty, params.Tradingsymbol, e.limits.MaxQtyPerSymbol))
+	}
+
+	price := params.Price
+	if price == 0 || params.OrderType == kiteconnect.OrderTypeMarket {
+		price = ltp
+	}
+	if notional := float64(params.Quantity) * price; e.limits.MaxOrderNotional > 0 && notional > e.limits.MaxOrderNotional {
+		reasons = append(reasons, fmt.Sprintf("order value %.2f exceeds %.2f", notional, e.limits.MaxOrderNotional))
+	}
+
+	if e.limits.MaxMarginUtilisation > 0 {
+		util, err := e.utilisation(params)
+		if err != nil {
+			return err
+		}
+		if util > e.limits.MaxMarginUtilisation {
+			reasons = append(reasons, fmt.Sprintf("margin utilisation %.0f%% exceeds %.0f%%", util*100, e.limits.MaxMarginUtilisation*100))
+		}
+	}
+
+	if (e.limits.MaxNetDelta > 0 || e.limits.MaxNetVega > 0) && e.exposure != nil {
+		breaches, err := e.greeksBreaches(positions.Net, params, qty, price)
+		if err != nil {
+			return err
+		}
+		reasons = append(reasons, breaches...)
+	}
+
+	if len(reasons) > 0 {
+		return &Violation{Reasons: reasons}
+	}
+	return nil
+}
+
+// greeksBreaches lists greeks limits the order pushes further past, orders
+// which bring an over limit book back towards the limit are let through
+func (e *Engine) greeksBreaches(net []kiteconnect.Position, params kiteconnect.OrderParams, qty int, price float64) ([]string, error) {
+	before, err := e.exposure(net)
+	if err != nil {
+		return nil, fmt.Errorf("risk: greeks: %w", err)
+	}
+	after, err := e.exposure(append(slices.Clone(net), kiteconnect.Position{
+		Exchange:      params.Exchange,
+		Tradingsymbol: params.Tradingsymbol,
+		Product:       params.Product,
+		Quantity:      qty,
+		AveragePrice:  price,
+		LastPrice:     price,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("risk: greeks: %w", err)
+	}
+
+	var reasons []string
+	for underlying, g := range after {
+		was := before[underlying]
+		if limit := e.limits.MaxNetDelta; limit > 0 && math.Abs(g.Delta) > limit && math.Abs(g.Delta) > math.Abs(was.Delta) {
+			reasons = append(reasons, fmt.Sprintf("net delta %.0f on %s exceeds %.0f", g.Delta, underlying, limit))
+		}
+		if limit := e.limits.MaxNetVega; limit > 0 && math.Abs(g.Vega) > limit && math.Abs(g.Vega) > math.Abs(was.Vega) {
+			reasons = append(reasons, fmt.Sprintf("net vega %.0f on %s exceeds %.0f", g.Vega, underlying, limit))
+		}
+	}
+	sort.Strings(reasons)
+	return reasons, nil
+}
+
+// utilisation is the equity margin fraction in use once the order is placed
+func (e *Engine) utilisation(params kiteconnect.OrderParams) (float64, error) {
+	margins, err := e.broker.Margins()
+	if err != nil {
+		return 0, fmt.Errorf("risk: fetch margins: %w", err)
+	}
+	required, err := e.broker.OrderMargin(params)
+	if err != nil {
+		return 0, fmt.Errorf("risk: order margin: %w", err)
+	}
+
+	used := margins.Equity.Used.Debits
+	total := margins.Equity.Net + used
+	if total <= 0 {
+		return math.Inf(1), nil
+	}
+	return (used + required) / total, nil
+}
+
+// PlaceOrder places the order if it passes Check
+func (e *Engine) PlaceOrder(variety string, params kiteconnect.OrderParams, ltp float64) (string, error) {
+	e.order.Lock()
+	defer e.order.Unlock()
+	if err := e.Check(params, ltp); err != nil {
+		return "", err
+	}
+	return e.broker.PlaceOrder(variety, params)
+}
+
+// Kill halts trading, cancels open orders and squares off intraday positions
+func (e *Engine) Kill() (KillReport, error) {
+	report := KillReport{At: time.Now()}
+	e.mu.Lock()
+	e.killed = &report
+	e.mu.Unlock()
+
+	cancelled, cancelErr := broker.CancelOpenOrders(e.broker)
+	squared, squareErr := broker.SquareOff(e.broker, kiteconnect.ProductMIS)
+	report.Cancelled, report.SquaredOff = cancelled, squared
+
+	err := errors.Join(cancelErr, squareErr)
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	e.mu.Lock()
+	e.killed = &report
+	e.mu.Unlock()
+	return report, err
+}
+
+// Resume lifts the kill switch
+func (e *Engine) Resume() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.killed = nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Tighten returns the stricter of each limit, a zero limit on either side defers to the other
+func (l Limits) Tighten(o Limits) Limits {
+	return Limits{
+		MaxDailyLoss:         stricter(l.MaxDailyLoss, o.MaxDailyLoss),
+		MaxOpenPositions:     stricter(l.MaxOpenPositions, o.MaxOpenPositions),
+		MaxQtyPerSymbol:      stricter(l.MaxQtyPerSymbol, o.MaxQtyPerSymbol),
+		MaxOrderNotional:     stricter(l.MaxOrderNotional, o.MaxOrderNotional),
+		MaxMarginUtilisation: stricter(l.MaxMarginUtilisation, o.MaxMarginUtilisation),
+		MaxNetDelta:          stricter(l.MaxNetDelta, o.MaxNetDelta),
+		MaxNetVega:           stricter(l.MaxNetVega, o.MaxNetVega),
+	}
+}
+
+func stricter[T int | float64](a, b T) T {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,228 @@
+package risk_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/options"
+	"friction-trading/internal/risk"
+)
+
+// stubBroker serves fixed account state and records orders
+type stubBroker struct {
+	positions kiteconnect.Positions
+	margins   kiteconnect.AllMargins
+	orders    kiteconnect.Orders
+	margin    float64
+
+	placed    []kiteconnect.OrderParams
+	cancelled []string
+}
+
+func (b *stubBroker) PlaceOrder(variety string, p kiteconnect.OrderParams) (string, error) {
+	b.placed = append(b.placed, p)
+	return "placed", nil
+}
+
+func (b *stubBroker) CancelOrder(variety, orderID string) error {
+	b.cancelled = append(b.cancelled, orderID)
+	return nil
+}
+
+func (b *stubBroker) Orders() (kiteconnect.Orders, error)       { return b.orders, nil }
+func (b *stubBroker) Positions() (kiteconnect.Positions, error) { return b.positions, nil }
+func (b *stubBroker) Margins() (kiteconnect.AllMargins, error)  { return b.margins, nil }
+func (b *stubBroker) OrderMargin(kiteconnect.OrderParams) (float64, error) {
+	return b.margin, nil
+}
+
+func (b *stubBroker) BasketMargin([]kiteconnect.OrderParams) (float64, error) {
+	return b.margin, nil
+}
+
+func newStub() *stubBroker {
+	b := &stubBroker{margin: 20000}
+	b.positions.Net = []kiteconnect.Position{
+		{Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE", Product: "MIS", Quantity: 75},
+		{Exchange: "NSE", Tradingsymbol: "SBIN", Product: "CNC", Quantity: 10},
+	}
+	b.positions.Day = []kiteconnect.Position{{PnL: -1000}}
+	b.margins.Equity.Net = 60000
+	b.margins.Equity.Used.Debits = 40000
+	return b
+}
+
+func buy(symbol string, qty int, price float64) kiteconnect.OrderParams {
+	return kiteconnect.OrderParams{
+		Exchange: "NFO", Tradingsymbol: symbol, TransactionType: kiteconnect.TransactionTypeBuy,
+		OrderType: kiteconnect.OrderTypeLimit, Product: "MIS", Quantity: qty, Price: price,
+	}
+}
+
+func TestCheck(t *testing.T) {
+	limits := risk.Limits{
+		MaxDailyLoss:         5000,
+		MaxOpenPositions:     2,
+		MaxQtyPerSymbol:      150,
+		MaxOrderNotional:     50000,
+		MaxMarginUtilisation: 0.7,
+	}
+
+	sell := buy("NIFTY25OCT25000CE", 75, 100)
+	sell.TransactionType = kiteconnect.TransactionTypeSell
+
+	tests := []struct {
+		name    string
+		setup   func(b *stubBroker)
+		order   kiteconnect.OrderParams
+		ltp     float64
+		reasons int
+	}{
+		{"within limits", nil, buy("NIFTY25OCT25000CE", 75, 100), 0, 0},
+		{"too many positions", nil, buy("NIFTY25OCT25100CE", 75, 100), 0, 1},
+		{"quantity per symbol", nil, buy("NIFTY25OCT25000CE", 150, 100), 0, 1},
+		{"notional priced off ltp", nil, kiteconnect.OrderParams{Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE",
+			TransactionType: "BUY", OrderType: "MARKET", Quantity: 75}, 1000, 1},
+		{"margin utilisation", func(b *stubBroker) { b.margin = 40000 }, buy("NIFTY25OCT25000CE", 75, 100), 0, 1},
+		{"daily loss", func(b *stubBroker) { b.positions.Day[0].PnL = -6000 }, buy("NIFTY25OCT25000CE", 75, 100), 0, 1},
+		{"exits pass despite loss", func(b *stubBroker) { b.positions.Day[0].PnL = -6000 }, sell, 0, 0},
+		{"every reason reported", func(b *stubBroker) { b.positions.Day[0].PnL = -6000; b.margin = 40000 },
+			buy("NIFTY25OCT25100CE", 600, 100), 0, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newStub()
+			if tt.setup != nil {
+				tt.setup(b)
+			}
+			err := risk.New(b, limits).Check(tt.order, tt.ltp)
+
+			var violation *risk.Violation
+			switch {
+			case tt.reasons == 0 && err != nil:
+				t.Errorf("want order allowed, got %v", err)
+			case tt.reasons > 0 && !errors.As(err, &violation):
+				t.Errorf("want violation, got %v", err)
+			case tt.reasons > 0 && len(violation.Reasons) != tt.reasons:
+				t.Errorf("want %d reasons, got %q", tt.reasons, violation.Reasons)
+			}
+		})
+	}
+}
+
+func TestKillSwitch(t *testing.T) {
+	b := newStub()
+	b.orders = kiteconnect.Orders{
+		{OrderID: "1", Variety: "regular", Status: "OPEN"},
+		{OrderID: "2", Variety: "regular", Status: "COMPLETE"},
+		{OrderID: "3", Variety: "co", Status: "TRIGGER PENDING"},
+	}
+	e := risk.New(b, risk.Limits{})
+
+	report, err := e.Kill()
+	if err != nil {
+		t.Fatal("Kill Error :- ", err)
+	}
+	if len(report.Cancelled) != 2 || len(b.cancelled) != 2 {
+		t.Errorf("want 2 open orders cancelled, got %v", b.cancelled)
+	}
+
+	// Only the intraday position is squared off, by selling it
+	if len(b.placed) != 1 || b.placed[0].Tradingsymbol != "NIFTY25OCT25000CE" ||
+		b.placed[0].TransactionType != kiteconnect.TransactionTypeSell || b.placed[0].Quantity != 75 {
+		t.Errorf("unexpected square off orders %+v", b.placed)
+	}
+
+	if _, err := e.PlaceOrder("regular", buy("SBIN", 1, 500), 0); !errors.Is(err, risk.ErrKilled) {
+		t.Errorf("want ErrKilled, got %v", err)
+	}
+
+	// Closing out what is left is still allowed, flipping through it is not
+	exit := buy("SBIN", 10, 500)
+	exit.Exchange, exit.Product, exit.TransactionType = "NSE", "CNC", kiteconnect.TransactionTypeSell
+	if _, err := e.PlaceOrder("regular", exit, 0); err != nil {
+		t.Errorf("want reducing order through the kill switch, got %v", err)
+	}
+	exit.Quantity = 11
+	if _, err := e.PlaceOrder("regular", exit, 0); !errors.Is(err, risk.ErrKilled) {
+		t.Errorf("want ErrKilled for an order flipping the position, got %v", err)
+	}
+	e.Resume()
+	if _, err := e.PlaceOrder("regular", buy("SBIN", 1, 500), 0); err != nil {
+		t.Errorf("want order after resume, got %v", err)
+	}
+}
+
+// fillingBroker fills every order into its net positions, after a round
+// trip long enough for concurrent checks to overlap it
+type fillingBroker struct {
+	stubBroker
+}
+
+func (b *fillingBroker) PlaceOrder(variety string, p kiteconnect.OrderParams) (string, error) {
+	time.Sleep(time.Millisecond)
+	b.positions.Net = append(b.positions.Net, kiteconnect.Position{
+		Exchange: p.Exchange, Tradingsymbol: p.Tradingsymbol, Product: p.Product, Quantity: p.Quantity,
+	})
+	return b.stubBroker.PlaceOrder(variety, p)
+}
+
+func TestPlaceOrderSerialised(t *testing.T) {
+	b := &fillingBroker{}
+	e := risk.New(b, risk.Limits{MaxQtyPerSymbol: 150})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.PlaceOrder("regular", buy("NIFTY25OCT25000CE", 75, 100), 0)
+		}()
+	}
+	wg.Wait()
+
+	if len(b.placed) != 2 {
+		t.Errorf("want 2 orders placed under the quantity limit, got %d", len(b.placed))
+	}
+}
+
+func TestGreeksLimits(t *testing.T) {
+	b := newStub()
+	e := risk.New(b, risk.Limits{MaxNetDelta: 100})
+
+	// Every NIFTY option is worth half a unit of delta per unit held
+	e.SetExposure(func(positions []kiteconnect.Position) (map[string]options.Greeks, error) {
+		net := map[string]options.Greeks{}
+		for _, p := range positions {
+			if p.Exchange == "NFO" {
+				g := net["NIFTY"]
+				g.Delta += float64(p.Quantity) / 2
+				net["NIFTY"] = g
+			}
+		}
+		return net, nil
+	})
+
+	// 75 held is 37.5 delta, 150 more takes it to 112.5
+	var violation *risk.Violation
+	if err := e.Check(buy("NIFTY25OCT25100CE", 150, 50), 50); !errors.As(err, &violation) || !strings.Contains(violation.Error(), "net delta") {
+		t.Errorf("want net delta violation, got %v", err)
+	}
+	if err := e.Check(buy("NIFTY25OCT25100CE", 75, 50), 50); err != nil {
+		t.Errorf("want order inside the delta limit through, got %v", err)
+	}
+
+	// Once over the limit, trades that cut delta still go
+	b.positions.Net[0].Quantity = 300
+	sell := buy("NIFTY25OCT25100CE", 75, 50)
+	sell.TransactionType = kiteconnect.TransactionTypeSell
+	if err := e.Check(sell, 50); err != nil {
+		t.Errorf("want delta reducing order through, got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxPeriod caps indicator periods, far past any chart yet small enough that
+// lookbacks and warm up spans can't overflow
+const maxPeriod = 10000
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+// parser is a recursive descent parser, lowest precedence first:
+//
+//	or, and, not, comparisons and crosses_above/crosses_below, + -, * /, unary -
+type parser struct {
+	src  string
+	toks []token
+	at   int
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("%w: %s at %d in %q", ErrSyntax, fmt.Sprintf(format, args...), t.pos+1, p.src)
+}
+
+func (p *parser) lex() error {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			p.toks = append(p.toks, token{tokNumber, src[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '_') {
+				i++
+			}
+			p.toks = append(p.toks, token{tokIdent, strings.ToLower(src[start:i]), start})
+		default:
+			op := string(c)
+			if i+1 < len(src) && src[i+1] == '=' {
+				op = src[i : i+2]
+			}
+			switch op {
+			case "(", ")", ",", "+", "-", "*", "/", "<", ">", "<=", ">=", "==", "!=":
+			default:
+				return p.errorf(token{pos: i}, "unexpected %q", op)
+			}
+			p.toks = append(p.toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	p.toks = append(p.toks, token{tokEOF, "end of rule", len(src)})
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.at]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.at]
+	if t.kind != tokEOF {
+		p.at++
+	}
+	return t
+}
+
+// accept consumes the next token when it's one of ops
+func (p *parser) accept(ops ...string) (token, bool) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return t, false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.at++
+			return t, true
+		}
+	}
+	return t, false
+}
+
+func (p *parser) expect(op string) error {
+	if t, ok := p.accept(op); !ok {
+		return p.errorf(t, "want %q, got %q", op, t.text)
+	}
+	return nil
+}
+
+func (p *parser) expr() (node, error) {
+	return p.logical("or", p.and)
+}
+
+func (p *parser) and() (node, error) {
+	return p.logical("and", p.not)
+}
+
+// logical parses operands joined by op, both sides must be conditions
+func (p *parser) logical(op string, operand func() (node, error)) (node, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.accept(op)
+		if !ok {
+			return x, nil
+		}
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() != kindBool || y.kind() != kindBool {
+			return nil, p.errorf(t, "%q joins conditions, not numbers", op)
+		}
+		x = &binary{op: op, x: x, y: y}
+	}
+}
+
+func (p *parser) not() (node, error) {
+	t, ok := p.accept("not")
+	if !ok {
+		return p.comparison()
+	}
+	x, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	if x.kind() != kindBool {
+		return nil, p.errorf(t, "not needs a condition")
+	}
+	return &unary{op: "not", x: x}, nil
+}
+
+func (p *parser) comparison() (node, error) {
+	x, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := p.accept("<", "<=", ">", ">=", "==", "!=", "crosses_above", "crosses_below")
+	if !ok {
+		return x, nil
+	}
+	y, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if x.kind() != kindNumber || y.kind() != kindNumber {
+		return nil, p.errorf(t, "%q compares numbers", t.text)
+	}
+	return &binary{op: t.text, x: x, y: y}, nil
+}
+
+func (p *parser) sum() (node, error) {
+	return p.arithmetic(p.product, "+", "-")
+}
+
+func (p *parser) product() (node, error) {
+	return p.arithmetic(p.unary, "*", "/")
+}
+
+func (p *parser) arithmetic(operand func() (node, error), ops ...string) (node, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.accept(ops...)
+		if !ok {
+			return x, nil
+		}
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() != kindNumber || y.kind() != kindNumber {
+			return nil, p.errorf(t, "%q needs numbers", t.text)
+		}
+		x = &binary{op: t.text, x: x, y: y}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	t, ok := p.accept("-")
+	if !ok {
+		return p.primary()
+	}
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if x.kind() != kindNumber {
+		return nil, p.errorf(t, "cannot negate a condition")
+	}
+	return &unary{op: "-", x: x}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "bad number %q", t.text)
+		}
+		return number(v), nil
+	case tokIdent:
+		if pick, ok := fields[t.text]; ok {
+			return &field{name: t.text, pick: pick}, nil
+		}
+		if fn, ok := functions[t.text]; ok {
+			return p.call(t, fn)
+		}
+		return nil, p.errorf(t, "unknown name %q", t.text)
+	case tokOp:
+		if t.text == "(" {
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+// call parses an indicator's arguments, they must be positive constants and periods
+// whole and at most maxPeriod
+func (p *parser) call(name token, fn function) (node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	arity := p.errorf(name, "%s takes %s", name.text, strings.Join(fn.params, ", "))
+	var args []float64
+	for len(args) < len(fn.params) {
+		if len(args) > 0 {
+			if _, ok := p.accept(","); !ok {
+				return nil, arity
+			}
+		}
+		t := p.next()
+		v, err := strconv.ParseFloat(t.text, 64)
+		if t.kind != tokNumber || err != nil || v <= 0 {
+			return nil, p.errorf(t, "%s %s must be a positive number", name.text, fn.params[len(args)])
+		}
+		if strings.HasSuffix(fn.params[len(args)], "period") {
+			if v != math.Trunc(v) {
+				return nil, p.errorf(t, "%s %s must be whole", name.text, fn.params[len(args)])
+			}
+			if v > maxPeriod {
+				return nil, p.errorf(t, "%s %s must be at most %d", name.text, fn.params[len(args)], maxPeriod)
+			}
+		}
+		args = append(args, v)
+	}
+	if _, ok := p.accept(")"); !ok {
+		return nil, arity
+	}
+	return &call{name: name.text, fn: fn, args: args}, nil
+}
@@ -0,0 +1,340 @@
+// Package rules compiles entry and exit rules like "close > supertrend(7,3) and rsi(14) < 70"
+// against the strategy indicators, so simple ideas don't need Go code.
+//
+// Rules read open, high, low and close of the latest candle, call sma, ema, rsi, atr, highest
+// and lowest with a period and supertrend with an ATR period and multiplier, and combine them
+// with + - * /, comparisons, crosses_above, crosses_below, and, or and not.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"friction-trading/internal/strategy"
+)
+
+var ErrSyntax = errors.New("invalid rule")
+
+// Rule is a compiled boolean expression over candles, safe to share between instances
+type Rule struct {
+	src      string
+	root     node
+	lookback int
+}
+
+// Compile parses and type checks a rule
+func Compile(src string) (*Rule, error) {
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	if root.kind() != kindBool {
+		return nil, fmt.Errorf("%w: %q is a number, want a condition like close > sma(20)", ErrSyntax, src)
+	}
+	return &Rule{src: src, root: root, lookback: root.lookback()}, nil
+}
+
+func (r *Rule) String() string {
+	return r.src
+}
+
+// Lookback is how many candles the rule needs before it's meaningful
+func (r *Rule) Lookback() int {
+	return r.lookback
+}
+
+// Eval reports whether the rule holds on the last candle
+func (r *Rule) Eval(candles []strategy.Candle) bool {
+	if len(candles) == 0 {
+		return false
+	}
+	return r.root.eval(newEnv(candles), len(candles)-1) == 1
+}
+
+// Values of every indicator and price the rule reads, on the last candle
+func (r *Rule) Values(candles []strategy.Candle) map[string]float64 {
+	values := map[string]float64{}
+	if len(candles) == 0 {
+		return values
+	}
+	e, i := newEnv(candles), len(candles)-1
+	walk(r.root, func(n node) {
+		switch n.(type) {
+		case *field, *call:
+			if v := n.eval(e, i); !math.IsNaN(v) {
+				values[n.String()] = v
+			}
+		}
+	})
+	return values
+}
+
+// env is one evaluation, indicator series are worked out once per call
+type env struct {
+	candles []strategy.Candle
+	series  map[string][]float64
+}
+
+func newEnv(candles []strategy.Candle) *env {
+	return &env{candles: candles, series: map[string][]float64{}}
+}
+
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindBool
+)
+
+// node evaluates at candle i, conditions give 1 or 0 and missing values NaN
+type node interface {
+	eval(e *env, i int) float64
+	kind() kind
+	lookback() int
+	String() string
+}
+
+func walk(n node, fn func(node)) {
+	fn(n)
+	switch n := n.(type) {
+	case *unary:
+		walk(n.x, fn)
+	case *binary:
+		walk(n.x, fn)
+		walk(n.y, fn)
+	}
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// number is a literal
+type number float64
+
+func (n number) eval(*env, int) float64 { return float64(n) }
+func (n number) kind() kind             { return kindNumber }
+func (n number) lookback() int          { return 0 }
+func (n number) String() string         { return strconv.FormatFloat(float64(n), 'f', -1, 64) }
+
+// field is a candle price
+type field struct {
+	name string
+	pick func(strategy.Candle) float64
+}
+
+var fields = map[string]func(strategy.Candle) float64{
+	"open":  func(c strategy.Candle) float64 { return c.Open },
+	"high":  func(c strategy.Candle) float64 { return c.High },
+	"low":   func(c strategy.Candle) float64 { return c.Low },
+	"close": func(c strategy.Candle) float64 { return c.Close },
+}
+
+func (f *field) eval(e *env, i int) float64 { return f.pick(e.candles[i]) }
+func (f *field) kind() kind                 { return kindNumber }
+func (f *field) lookback() int              { return 1 }
+func (f *field) String() string             { return f.name }
+
+// function is an indicator the rules can call
+type function struct {
+	params   []string // names, for errors
+	series   func(candles []strategy.Candle, args []float64) []float64
+	lookback func(args []float64) int
+}
+
+// period reads the first argument, whole by the time it compiles
+func period(args []float64) int { return int(args[0]) }
+
+var functions = map[string]function{
+	"sma": {
+		params: []string{"period"},
+		series: func(c []strategy.Candle, a []float64) []float64 {
+			return strategy.CalculateSMA(strategy.Closes(c), period(a))
+		},
+		lookback: period,
+	},
+	"ema": {
+		params: []string{"period"},
+		series: func(c []strategy.Candle, a []float64) []float64 {
+			return strategy.CalculateEMA(strategy.Closes(c), period(a))
+		},
+		lookback: period,
+	},
+	"rsi": {
+		params: []string{"period"},
+		series: func(c []strategy.Candle, a []float64) []float64 {
+			return strategy.CalculateRSI(strategy.Closes(c), period(a))
+		},
+		lookback: func(a []float64) int { return period(a) + 1 },
+	},
+	"atr": {
+		params: []string{"period"},
+		series: func(c []strategy.Candle, a []float64) []float64 {
+			return strategy.CalculateATR(strategy.CalculateTR(c), period(a))
+		},
+		lookback: period,
+	},
+	"supertrend": {
+		params: []string{"atr_period", "multiplier"},
+		series: func(c []strategy.Candle, a []float64) []float64 {
+			return strategy.CalculateSupertrend(c, strategy.CalculateATR(strategy.CalculateTR(c), period(a)), a[1])
+		},
+		lookback: func(a []float64) int { return period(a) + 1 },
+	},
+	"highest": {
+		params:   []string{"period"},
+		series:   func(c []strategy.Candle, a []float64) []float64 { return strategy.CalculateHighest(c, period(a)) },
+		lookback: period,
+	},
+	"lowest": {
+		params:   []string{"period"},
+		series:   func(c []strategy.Candle, a []float64) []float64 { return strategy.CalculateLowest(c, period(a)) },
+		lookback: period,
+	},
+}
+
+// call is an indicator with constant arguments
+type call struct {
+	name string
+	fn   function
+	args []float64
+}
+
+func (c *call) eval(e *env, i int) float64 {
+	key := c.String()
+	series, ok := e.series[key]
+	if !ok {
+		series = c.fn.series(e.candles, c.args)
+		e.series[key] = series
+	}
+	if i >= len(series) {
+		return math.NaN()
+	}
+	return series[i]
+}
+
+func (c *call) kind() kind    { return kindNumber }
+func (c *call) lookback() int { return c.fn.lookback(c.args) }
+
+func (c *call) String() string {
+	args := make([]string, len(c.args))
+	for i, a := range c.args {
+		args[i] = number(a).String()
+	}
+	return c.name + "(" + strings.Join(args, ",") + ")"
+}
+
+// unary is not or negation
+type unary struct {
+	op string
+	x  node
+}
+
+func (u *unary) eval(e *env, i int) float64 {
+	v := u.x.eval(e, i)
+	if u.op == "not" {
+		return boolean(v != 1)
+	}
+	return -v
+}
+
+func (u *unary) kind() kind {
+	if u.op == "not" {
+		return kindBool
+	}
+	return kindNumber
+}
+
+func (u *unary) lookback() int { return u.x.lookback() }
+
+func (u *unary) String() string {
+	if u.op == "not" {
+		return "not " + u.x.String()
+	}
+	return "-" + u.x.String()
+}
+
+// binary is arithmetic, a comparison, a crossover or a logical operator
+type binary struct {
+	op   string
+	x, y node
+}
+
+func (b *binary) eval(e *env, i int) float64 {
+	switch b.op {
+	case "and":
+		return boolean(b.x.eval(e, i) == 1 && b.y.eval(e, i) == 1)
+	case "or":
+		return boolean(b.x.eval(e, i) == 1 || b.y.eval(e, i) == 1)
+	case "crosses_above", "crosses_below":
+		if i == 0 {
+			return 0
+		}
+		x0, y0, x1, y1 := b.x.eval(e, i-1), b.y.eval(e, i-1), b.x.eval(e, i), b.y.eval(e, i)
+		if b.op == "crosses_above" {
+			return boolean(x0 <= y0 && x1 > y1)
+		}
+		return boolean(x0 >= y0 && x1 < y1)
+	}
+
+	x, y := b.x.eval(e, i), b.y.eval(e, i)
+	switch b.op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		if y == 0 {
+			return math.NaN()
+		}
+		return x / y
+	case "<":
+		return boolean(x < y)
+	case "<=":
+		return boolean(x <= y)
+	case ">":
+		return boolean(x > y)
+	case ">=":
+		return boolean(x >= y)
+	case "==":
+		return boolean(x == y)
+	case "!=":
+		return boolean(x != y && !math.IsNaN(x) && !math.IsNaN(y))
+	}
+	return math.NaN()
+}
+
+func (b *binary) kind() kind {
+	switch b.op {
+	case "+", "-", "*", "/":
+		return kindNumber
+	}
+	return kindBool
+}
+
+func (b *binary) lookback() int {
+	n := max(b.x.lookback(), b.y.lookback())
+	if b.op == "crosses_above" || b.op == "crosses_below" {
+		n++
+	}
+	return n
+}
+
+func (b *binary) String() string {
+	return "(" + b.x.String() + " " + b.op + " " + b.y.String() + ")"
+}
@@ -0,0 +1,107 @@
+package rules_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"friction-trading/internal/backtest"
+	"friction-trading/internal/rules"
+	"friction-trading/internal/strategy"
+)
+
+// closing builds one candle per close, a point either side for high and low
+func closing(closes ...float64) []strategy.Candle {
+	start := time.Date(2025, 10, 20, 9, 15, 0, 0, time.UTC)
+	candles := make([]strategy.Candle, len(closes))
+	for i, c := range closes {
+		candles[i] = strategy.Candle{Timestamp: start.Add(time.Duration(i) * time.Minute), Open: c, High: c + 1, Low: c - 1, Close: c, LastPrice: c}
+	}
+	return candles
+}
+
+func TestCompile(t *testing.T) {
+	for _, src := range []string{
+		"close > supertrend(7,3) and rsi(14) < 70",
+		"close crosses_above sma(20) or not (ema(9) <= ema(21))",
+		"(high - low) / close * 100 > 1.5 and close >= highest(20)",
+		"CLOSE > SMA(5)",
+		"close > sma(10000)",
+	} {
+		if _, err := rules.Compile(src); err != nil {
+			t.Errorf("%q: %v", src, err)
+		}
+	}
+
+	for _, src := range []string{
+		"",
+		"close",
+		"close > vwap(20)",
+		"close > sma(0)",
+		"close > sma(2.5)",
+		"close > sma(10001)",
+		"close > supertrend(10000000000000000000,3)",
+		"close > supertrend(7)",
+		"close > sma(close)",
+		"close > 1 and 2",
+		"close >> 1",
+		"close > (sma(5)",
+		"not close",
+		"close & open",
+	} {
+		if _, err := rules.Compile(src); !errors.Is(err, rules.ErrSyntax) {
+			t.Errorf("%q: want a syntax error, got %v", src, err)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	candles := closing(10, 11, 12, 13, 14)
+	r, err := rules.Compile("close > sma(3) and sma(3) - sma(5) == 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Lookback() != 5 {
+		t.Errorf("want a lookback of 5, got %d", r.Lookback())
+	}
+	if !r.Eval(candles) {
+		t.Error("want the rule to hold over a rising series")
+	}
+	if r.Eval(candles[:4]) {
+		t.Error("want sma(5) missing on 4 candles to fail the rule")
+	}
+	if v := r.Values(candles); v["close"] != 14 || v["sma(3)"] != 13 || v["sma(5)"] != 12 {
+		t.Errorf("unexpected values %v", v)
+	}
+
+	cross, _ := rules.Compile("close crosses_below sma(2)")
+	if !cross.Eval(closing(10, 12, 9)) || cross.Eval(closing(10, 9, 8)) {
+		t.Error("want a cross only where close goes from above to below")
+	}
+}
+
+func TestRuleStrategy(t *testing.T) {
+	st, err := rules.New("close crosses_above sma(3)", "close < sma(3)", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Falls, pops above the average, drifts up, then breaks below it
+	res, err := backtest.Run(st, closing(20, 19, 18, 17, 21, 22, 23, 18), backtest.Options{Token: 7, Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Signals) != 2 || res.Signals[0].Side != "BUY" || res.Signals[0].Price != 21 || res.Signals[1].Side != "SELL" || res.Signals[1].Price != 18 {
+		t.Fatalf("want BUY at 21 and SELL at 18, got %+v", res.Signals)
+	}
+	if res.Signals[0].Values["sma(3)"] == 0 || res.Signals[0].Token != 7 {
+		t.Errorf("want the SMA behind the call on the signal, got %+v", res.Signals[0])
+	}
+	if len(res.Trades) != 1 || res.NetPnL != -6 {
+		t.Errorf("want one losing round trip of 2 x -3, got %+v", res.Trades)
+	}
+
+	if _, err := rules.Factory("close > sma(5)", "close <"); !errors.Is(err, rules.ErrSyntax) {
+		t.Errorf("want a bad exit rejected up front, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package rules
+
+import (
+	"maps"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/strategy"
+)
+
+// Name of rule strategies in definitions and backtests
+const Name = "rules"
+
+// Candles kept per instance, at least this many or 4x the rules' lookback
+const minWindow = 200
+
+// Strategy trades long only, BUY when the entry rule holds while flat and
+// SELL when the exit rule holds while long. Rules are checked on closed candles.
+type Strategy struct {
+	entry, exit *Rule
+	bars        *strategy.Bars
+	window      int
+
+	// Owned by the instance goroutine
+	candles []strategy.Candle
+	long    bool
+	signals []strategy.Signal
+}
+
+// Factory compiles entry and exit once, so mistakes show up when a definition loads
+func Factory(entry, exit string) (strategy.Factory, error) {
+	in, err := Compile(entry)
+	if err != nil {
+		return nil, err
+	}
+	out, err := Compile(exit)
+	if err != nil {
+		return nil, err
+	}
+	return func(p strategy.Params) (strategy.Strategy, error) {
+		return newStrategy(in, out, p), nil
+	}, nil
+}
+
+// New builds a rule strategy, params :- timeframe
+func New(entry, exit string, p strategy.Params) (strategy.Strategy, error) {
+	factory, err := Factory(entry, exit)
+	if err != nil {
+		return nil, err
+	}
+	return factory(p)
+}
+
+func newStrategy(entry, exit *Rule, p strategy.Params) *Strategy {
+	return &Strategy{
+		entry:  entry,
+		exit:   exit,
+		bars:   strategy.NewBars(strategy.TimeframeParam(p)),
+		window: max(minWindow, 4*max(entry.Lookback(), exit.Lookback())),
+	}
+}
+
+func (st *Strategy) OnTick(tick kitemodels.Tick) {
+	candle, closed := st.bars.Add(tick)
+	if !closed {
+		return
+	}
+	st.add(candle)
+
+	rule, side := st.entry, "BUY"
+	if st.long {
+		rule, side = st.exit, "SELL"
+	}
+	if len(st.candles) < rule.Lookback() || !rule.Eval(st.candles) {
+		return
+	}
+
+	st.long = !st.long
+	values := st.entry.Values(st.candles)
+	maps.Copy(values, st.exit.Values(st.candles))
+	st.signals = append(st.signals, strategy.Signal{
+		Token:  tick.InstrumentToken,
+		Side:   side,
+		Price:  candle.Close,
+		Values: values,
+	})
+}
+
+func (st *Strategy) add(candle strategy.Candle) {
+	st.candles = append(st.candles, candle)
+	if len(st.candles) > st.window {
+		st.candles = st.candles[len(st.candles)-st.window:]
+	}
+}
+
+// Warmup asks for the whole window
+func (st *Strategy) Warmup() (time.Duration, int) {
+	return st.bars.Frame(), st.window
+}
+
+// Warm primes the indicators from history. It doesn't replay entries and
+// exits, the instance starts flat whatever the rules said in the past.
+func (st *Strategy) Warm(token uint32, candles []strategy.Candle) {
+	for _, c := range candles {
+		st.add(c)
+	}
+}
+
+// Bars the strategy folds ticks into
+func (st *Strategy) Bars() *strategy.Bars {
+	return st.bars
+}
+
+// Signals raised by the last tick
+func (st *Strategy) Signals() []strategy.Signal {
+	signals := st.signals
+	st.signals = nil
+	return signals
+}
+
+func (st *Strategy) State() map[string]any {
+	return map[string]any{
+		"candles": len(st.candles),
+		"long":    st.long,
+		"entry":   st.entry.String(),
+		"exit":    st.exit.String(),
+	}
+}
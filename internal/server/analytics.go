@@ -0,0 +1,277 @@
+// Option Analytics Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/database"
+	"friction-trading/internal/options"
+)
+
+// IV assumed when a leg's price doesn't give one
+const defaultIV = 0.15
+
+// payoffLegReq is a hypothetical leg, its IV is implied from premium when left out
+type payoffLegReq struct {
+	Type     string  `json:"type"` // CE, PE or FUT
+	Strike   float64 `json:"strike"`
+	Expiry   string  `json:"expiry"` // YYYY-MM-DD
+	Quantity int     `json:"quantity"`
+	Premium  float64 `json:"premium"`
+	IV       float64 `json:"iv"`
+}
+
+// payoffReq is the body of POST /api/analytics/payoff, legs come from open
+// positions, a basket priced at market, explicit legs, or all three
+type payoffReq struct {
+	Underlying string         `json:"underlying"`
+	Positions  bool           `json:"positions"`
+	Basket     *basketReq     `json:"basket"`
+	Legs       []payoffLegReq `json:"legs"`
+	Spot       float64        `json:"spot"`      // LTP of the underlying by default
+	Range      float64        `json:"range"`     // ± fraction of spot, 0.1 by default
+	Steps      int            `json:"steps"`     // 100 by default
+	Rate       float64        `json:"rate"`      // risk-free rate, 6.5% by default
+	IVShocks   []float64      `json:"iv_shocks"` // vol point shifts, ±5 by default
+}
+
+// openLegs turns open F&O positions into legs alongside their last prices.
+// Positions missing from the instruments table are skipped, an empty underlying keeps all.
+func (s *Server) openLegs(ctx context.Context, positions []kiteconnect.Position, underlying string) ([]options.Leg, []float64, error) {
+	var (
+		legs   []options.Leg
+		market []float64
+	)
+	for _, p := range positions {
+		if p.Quantity == 0 {
+			continue
+		}
+		inst, err := s.Store.GetInstrumentBySymbol(ctx, database.GetInstrumentBySymbolParams{
+			Exchange:      p.Exchange,
+			Tradingsymbol: p.Tradingsymbol,
+		})
+		if err != nil && strings.Contains(err.Error(), ErrNoRowsFound.Error()) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("lookup %s:%s: %w", p.Exchange, p.Tradingsymbol, err)
+		}
+		if inst.InstrumentType == "EQ" || (underlying != "" && inst.Name != underlying) {
+			continue
+		}
+
+		legs = append(legs, options.Leg{
+			Underlying:    inst.Name,
+			Tradingsymbol: p.Tradingsymbol,
+			Type:          inst.InstrumentType,
+			Strike:        inst.Strike,
+			Expiry:        inst.Expiry.Time,
+			Quantity:      p.Quantity,
+			Premium:       p.AveragePrice,
+		})
+		market = append(market, p.LastPrice)
+	}
+	return legs, market, nil
+}
+
+// withIV fills missing IVs from the legs' market prices, or premiums when there's no price
+func withIV(legs []options.Leg, market []float64, spots map[string]float64, now time.Time, rate float64) {
+	for i := range legs {
+		l := &legs[i]
+		spot, ok := spots[l.Underlying]
+		if l.IV > 0 || !ok || (l.Type != options.Call && l.Type != options.Put) {
+			continue
+		}
+		price := market[i]
+		if price == 0 {
+			price = l.Premium
+		}
+		iv, err := options.ImpliedVol(l.Type, price, spot, l.Strike, options.YearsTo(l.Expiry, now), rate)
+		if err != nil {
+			log.Printf("No IV for %s %.2f @ %.2f, assuming %.0f%% :- %v\n", l.Type, l.Strike, price, defaultIV*100, err)
+			iv = defaultIV
+		}
+		l.IV = iv
+	}
+}
+
+// exposures are the greeks of open F&O positions by underlying.
+// Underlyings without a spot price, like commodities, are left out.
+func (s *Server) exposures(ctx context.Context, positions []kiteconnect.Position) ([]options.Exposure, error) {
+	legs, market, err := s.openLegs(ctx, positions, "")
+	if err != nil {
+		return nil, err
+	}
+
+	spots := map[string]float64{}
+	for _, l := range legs {
+		if _, ok := spots[l.Underlying]; ok {
+			continue
+		}
+		spot, err := s.spotLTP(l.Underlying)
+		if err != nil {
+			log.Printf("No spot for %s, leaving it out of greeks :- %v\n", l.Underlying, err)
+			continue
+		}
+		spots[l.Underlying] = spot
+	}
+
+	now := s.now()
+	withIV(legs, market, spots, now, options.DefaultRate)
+	return options.Exposures(legs, spots, now, options.DefaultRate), nil
+}
+
+// netGreeks feeds the risk engine's greeks limits
+func (s *Server) netGreeks(positions []kiteconnect.Position) (map[string]options.Greeks, error) {
+	exposures, err := s.exposures(s.ctx, positions)
+	if err != nil {
+		return nil, err
+	}
+	net := make(map[string]options.Greeks, len(exposures))
+	for _, e := range exposures {
+		net[e.Underlying] = e.Net
+	}
+	return net, nil
+}
+
+// basketLegs prices a basket at market, each leg entered at its LTP
+func (s *Server) basketLegs(ctx context.Context, req basketReq) ([]options.Leg, []float64, error) {
+	orders, err := s.resolveBasket(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := make([]string, len(orders))
+	for i, o := range orders {
+		keys[i] = o.Exchange + ":" + o.Tradingsymbol
+	}
+	quotes, err := s.KiteClient.GetLTP(keys...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch LTP for basket: %w", err)
+	}
+
+	legs := make([]options.Leg, len(orders))
+	market := make([]float64, len(orders))
+	for i, o := range orders {
+		inst, err := s.Store.GetInstrumentBySymbol(ctx, database.GetInstrumentBySymbolParams{
+			Exchange:      o.Exchange,
+			Tradingsymbol: o.Tradingsymbol,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("lookup %s: %w", keys[i], err)
+		}
+		qty := o.Quantity
+		if o.TransactionType == kiteconnect.TransactionTypeSell {
+			qty = -qty
+		}
+		market[i] = quotes[keys[i]].LastPrice
+		legs[i] = options.Leg{
+			Underlying:    inst.Name,
+			Tradingsymbol: o.Tradingsymbol,
+			Type:          inst.InstrumentType,
+			Strike:        inst.Strike,
+			Expiry:        inst.Expiry.Time,
+			Quantity:      qty,
+			Premium:       market[i],
+		}
+	}
+	return legs, market, nil
+}
+
+// Payoff :- expiry and T+0 P&L across underlying prices, with IV shocks
+func (s *Server) payoffHandler(w http.ResponseWriter, r *http.Request) {
+	var req payoffReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+	underlying := strings.ToUpper(req.Underlying)
+	if underlying == "" {
+		SendJSONResp(nil, errors.New("underlying is required"), http.StatusBadRequest, w)
+		return
+	}
+	if req.Rate == 0 {
+		req.Rate = options.DefaultRate
+	}
+	if req.IVShocks == nil {
+		req.IVShocks = []float64{-0.05, 0.05}
+	}
+
+	var (
+		legs   []options.Leg
+		market []float64
+	)
+	if req.Positions {
+		positions, err := s.broker.Positions()
+		if err != nil {
+			SendJSONResp(nil, err, http.StatusBadGateway, w)
+			return
+		}
+		open, prices, err := s.openLegs(r.Context(), positions.Net, underlying)
+		if err != nil {
+			SendJSONResp(nil, err, http.StatusInternalServerError, w)
+			return
+		}
+		legs, market = append(legs, open...), append(market, prices...)
+	}
+	if req.Basket != nil {
+		req.Basket.Underlying = underlying
+		structure, prices, err := s.basketLegs(r.Context(), *req.Basket)
+		if err != nil {
+			SendJSONResp(nil, err, basketErrStatus(err), w)
+			return
+		}
+		legs, market = append(legs, structure...), append(market, prices...)
+	}
+	for _, l := range req.Legs {
+		leg := options.Leg{Underlying: underlying, Type: strings.ToUpper(l.Type), Strike: l.Strike, Quantity: l.Quantity, Premium: l.Premium, IV: l.IV}
+		if leg.Type != options.Future {
+			expiry, err := time.Parse(time.DateOnly, l.Expiry)
+			if err != nil {
+				SendJSONResp(nil, fmt.Errorf("leg expiry %q, want YYYY-MM-DD", l.Expiry), http.StatusBadRequest, w)
+				return
+			}
+			leg.Expiry = expiry
+		}
+		legs, market = append(legs, leg), append(market, 0)
+	}
+
+	spot := req.Spot
+	if spot == 0 {
+		var err error
+		if spot, err = s.spotLTP(underlying); err != nil {
+			SendJSONResp(nil, err, http.StatusBadGateway, w)
+			return
+		}
+	}
+
+	now := s.now()
+	withIV(legs, market, map[string]float64{underlying: spot}, now, req.Rate)
+	analysis, err := options.Analyse(legs, options.Settings{
+		Spot:     spot,
+		Now:      now,
+		Rate:     req.Rate,
+		Range:    req.Range,
+		Steps:    req.Steps,
+		IVShocks: req.IVShocks,
+	})
+	if errors.Is(err, options.ErrNoPositions) {
+		SendJSONResp(nil, err, http.StatusNotFound, w)
+		return
+	}
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	SendJSONResp(analysis, nil, http.StatusOK, w)
+}
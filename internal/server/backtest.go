@@ -0,0 +1,253 @@
+// Backtest Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/backtest"
+	"friction-trading/internal/charges"
+	"friction-trading/internal/rules"
+	"friction-trading/internal/strategy"
+)
+
+// Kite candle interval backtests run on unless asked otherwise
+const defaultBacktestInterval = "5minute"
+
+// backtestReq is the body of POST /api/backtests
+type backtestReq struct {
+	Strategy string          `json:"strategy"` // a registered strategy or rules
+	Params   strategy.Params `json:"params"`
+	Entry    string          `json:"entry"` // rules only
+	Exit     string          `json:"exit"`  // rules only
+	Token    uint32          `json:"token"`
+	Interval string          `json:"interval"` // Kite candle interval, 5minute by default
+	From     string          `json:"from"`     // YYYY-MM-DD or RFC 3339
+	To       string          `json:"to"`       // now by default
+	Quantity int             `json:"quantity"`
+	Short    bool            `json:"short"`
+	Product  string          `json:"product"` // equity is charged as MIS unless CNC
+	Segment  string          `json:"segment"` // charges segment, read from the instrument by default
+}
+
+// optimiseReq is the body of POST /api/backtests/optimise, a backtest with params to search.
+// Params are fixed for every set, space holds the ones searched.
+type optimiseReq struct {
+	backtestReq
+	backtest.Search
+	Space backtest.Space `json:"space"`
+	Top   int            `json:"top"` // best sets returned, all when 0
+}
+
+// monteCarloReq is the body of POST /api/backtests/montecarlo. Trades from an earlier
+// backtest are resampled as they are, without them the backtest is run first.
+type monteCarloReq struct {
+	backtestReq
+	backtest.MonteCarlo
+	Trades []backtest.Trade `json:"trades"`
+}
+
+// backtestFactory builds fresh instances of the requested strategy, the same code that runs live.
+// A timeframe the length of the candles is dropped, the strategy then reads each candle
+// as it closes rather than a candle late.
+func (s *Server) backtestFactory(req backtestReq) (strategy.Factory, error) {
+	var factory strategy.Factory
+	if req.Strategy == rules.Name {
+		var err error
+		if factory, err = rules.Factory(req.Entry, req.Exit); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := s.strategies.Build(req.Strategy, req.Params); err != nil {
+			return nil, err
+		}
+		factory = func(p strategy.Params) (strategy.Strategy, error) {
+			return s.strategies.Build(req.Strategy, p)
+		}
+	}
+
+	interval := req.Interval
+	if interval == "" {
+		interval = defaultBacktestInterval
+	}
+	frame := intervalFrame(interval)
+	return func(p strategy.Params) (strategy.Strategy, error) {
+		if frame > 0 && strategy.TimeframeParam(p) == frame {
+			p = maps.Clone(p)
+			delete(p, "timeframe")
+		}
+		return factory(p)
+	}, nil
+}
+
+// backtestCharges charges each fill at the rates of the instrument's segment
+func (s *Server) backtestCharges(ctx context.Context, req backtestReq) (func(side string, quantity int, price float64) float64, error) {
+	segment := charges.Segment(req.Segment)
+	if segment == "" {
+		in, err := s.Store.GetInstrumentByToken(ctx, int64(req.Token))
+		if err != nil {
+			return nil, fmt.Errorf("instrument %d not found, give its segment for charges", req.Token)
+		}
+		product := req.Product
+		if product == "" {
+			product = kiteconnect.ProductMIS
+		}
+		segment = charges.SegmentOf(in.Exchange, in.Tradingsymbol, product)
+	}
+	rates, err := s.charges.Rates(segment)
+	if err != nil {
+		return nil, err
+	}
+	return func(side string, quantity int, price float64) float64 {
+		return rates.Order(side, float64(quantity), price).Total
+	}, nil
+}
+
+// backtestRange reads the request's instrument history window
+func (s *Server) backtestRange(req backtestReq) (from, to time.Time, interval string, err error) {
+	if req.Token == 0 || req.From == "" {
+		return from, to, "", errors.New("token and from are required")
+	}
+	if from, err = parseSignalTime(req.From); err != nil {
+		return from, to, "", err
+	}
+	to = s.now()
+	if req.To != "" {
+		if to, err = parseSignalTime(req.To); err != nil {
+			return from, to, "", err
+		}
+	}
+	if !from.Before(to) {
+		return from, to, "", fmt.Errorf("from %s is not before to %s", req.From, req.To)
+	}
+	interval = req.Interval
+	if interval == "" {
+		interval = defaultBacktestInterval
+	}
+	return from, to, interval, nil
+}
+
+// runBacktest replays the request's history, the status goes with the error
+func (s *Server) runBacktest(ctx context.Context, req backtestReq) (*backtest.Result, int, error) {
+	factory, err := s.backtestFactory(req)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	st, err := factory(req.Params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	from, to, interval, err := s.backtestRange(req)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	costs, err := s.backtestCharges(ctx, req)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	candles, err := s.historicalCandles(int(req.Token), interval, from, to)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+
+	res, err := backtest.Run(st, candles, backtest.Options{Token: req.Token, Quantity: req.Quantity, Short: req.Short, Charges: costs})
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+	return res, http.StatusOK, nil
+}
+
+// Backtest :- replay Kite history through a strategy or entry/exit rules
+func (s *Server) backtestHandler(w http.ResponseWriter, r *http.Request) {
+	var req backtestReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+	res, code, err := s.runBacktest(r.Context(), req)
+	SendJSONResp(res, err, code, w)
+}
+
+// Optimise :- search strategy params over Kite history with walk-forward windows
+func (s *Server) optimiseHandler(w http.ResponseWriter, r *http.Request) {
+	var req optimiseReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	factory, err := s.backtestFactory(req.backtestReq)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+	from, to, interval, err := s.backtestRange(req.backtestReq)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+	costs, err := s.backtestCharges(r.Context(), req.backtestReq)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+	candles, err := s.historicalCandles(int(req.Token), interval, from, to)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadGateway, w)
+		return
+	}
+
+	search := req.Search
+	search.Base = req.Params
+	search.Options = backtest.Options{Token: req.Token, Quantity: req.Quantity, Short: req.Short, Charges: costs}
+	res, err := backtest.Optimise(factory, candles, req.Space, search)
+	switch {
+	case errors.Is(err, backtest.ErrSearch):
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	case err != nil:
+		SendJSONResp(nil, err, http.StatusUnprocessableEntity, w)
+		return
+	}
+	if req.Top > 0 && len(res.Results) > req.Top {
+		res.Results = res.Results[:req.Top]
+	}
+	SendJSONResp(res, nil, http.StatusOK, w)
+}
+
+// Monte Carlo :- resample a backtest's trades to see how much of its result was luck
+func (s *Server) monteCarloHandler(w http.ResponseWriter, r *http.Request) {
+	var req monteCarloReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	trades := req.Trades
+	if len(trades) == 0 {
+		res, code, err := s.runBacktest(r.Context(), req.backtestReq)
+		if err != nil {
+			SendJSONResp(nil, err, code, w)
+			return
+		}
+		trades = res.Trades
+	}
+
+	res, err := backtest.Simulate(trades, req.MonteCarlo)
+	switch {
+	case errors.Is(err, backtest.ErrMonteCarlo):
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	case err != nil:
+		SendJSONResp(nil, err, http.StatusUnprocessableEntity, w)
+		return
+	}
+	SendJSONResp(res, nil, http.StatusOK, w)
+}
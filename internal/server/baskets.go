@@ -0,0 +1,201 @@
+// Option Basket Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/basket"
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/database"
+	"friction-trading/internal/strategy"
+)
+
+// Index underlyings trade under a different name in the cash market
+var spotSymbols = map[string]string{
+	"NIFTY":      "NSE:NIFTY 50",
+	"BANKNIFTY":  "NSE:NIFTY BANK",
+	"FINNIFTY":   "NSE:NIFTY FIN SERVICE",
+	"MIDCPNIFTY": "NSE:NIFTY MID SELECT",
+	"SENSEX":     "BSE:SENSEX",
+	"BANKEX":     "BSE:BANKEX",
+}
+
+// basketReq is the body of POST /api/baskets, either a named strategy
+// e.g. {"underlying": "NIFTY", "strategy": "iron_condor", "params": {"width": 4, "wing": 2}}
+// or custom legs relative to ATM
+type basketReq struct {
+	Underlying string          `json:"underlying"`
+	Exchange   string          `json:"exchange"` // of the options, NFO by default
+	Expiry     string          `json:"expiry"`   // weekly, monthly or YYYY-MM-DD
+	Strategy   string          `json:"strategy"`
+	Params     strategy.Params `json:"params"`
+	Legs       []basket.Leg    `json:"legs"`
+	Product    string          `json:"product"`
+}
+
+// resolveBasket turns a basket request into one market order per leg
+func (s *Server) resolveBasket(ctx context.Context, req basketReq) ([]kiteconnect.OrderParams, error) {
+	underlying := strings.ToUpper(req.Underlying)
+	product := req.Product
+	if product == "" {
+		product = kiteconnect.ProductNRML
+	}
+
+	legs := req.Legs
+	if req.Strategy != "" {
+		var err error
+		if legs, err = basket.Legs(req.Strategy, req.Params); err != nil {
+			return nil, err
+		}
+	}
+
+	chain, _, err := s.optionChain(ctx, underlying, req.Exchange, req.Expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	spot, err := s.spotLTP(underlying)
+	if err != nil {
+		return nil, err
+	}
+
+	return basket.Resolve(chain, spot, legs, product)
+}
+
+// optionChain lists an underlying's options at one expiry, picked as weekly, monthly or YYYY-MM-DD
+func (s *Server) optionChain(ctx context.Context, underlying, exchange, pick string) ([]*database.Instrument, time.Time, error) {
+	exchange = chainExchange(exchange, underlying)
+
+	// Expiries are stored as dates, compare against today's date in IST
+	listed, err := s.Store.ListOptionExpiries(ctx, database.ListOptionExpiriesParams{
+		Exchange: exchange,
+		Name:     underlying,
+		Expiry:   pgtype.Timestamp{Time: s.today(), Valid: true},
+	})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("list expiries: %w", err)
+	}
+	expiries := make([]time.Time, 0, len(listed))
+	for _, e := range listed {
+		if e.Valid {
+			expiries = append(expiries, e.Time)
+		}
+	}
+	expiry, err := basket.PickExpiry(expiries, pick)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	chain, err := s.Store.ListOptionChain(ctx, database.ListOptionChainParams{
+		Exchange: exchange,
+		Name:     underlying,
+		Expiry:   pgtype.Timestamp{Time: expiry, Valid: true},
+	})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("option chain: %w", err)
+	}
+	return chain, expiry, nil
+}
+
+// chainExchange is where an underlying's options trade, NFO unless it's a BSE index
+func chainExchange(exchange, underlying string) string {
+	if exchange != "" {
+		return strings.ToUpper(exchange)
+	}
+	if strings.HasPrefix(spotSymbols[underlying], "BSE:") {
+		return calendar.BFO
+	}
+	return calendar.NFO
+}
+
+// today is the IST date, as the UTC midnight expiries are stored at
+func (s *Server) today() time.Time {
+	now := s.now().In(calendar.IST)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// spotLTP is the last price of an underlying in the cash market
+func (s *Server) spotLTP(underlying string) (float64, error) {
+	key, ok := spotSymbols[underlying]
+	if !ok {
+		key = "NSE:" + underlying
+	}
+	exchange, symbol, _ := strings.Cut(key, ":")
+	return s.ltp(exchange, symbol)
+}
+
+// map basket errors to HTTP status codes
+func basketErrStatus(err error) int {
+	switch {
+	case errors.Is(err, basket.ErrNoChain), errors.Is(err, basket.ErrNoExpiry), errors.Is(err, basket.ErrNoStrike):
+		return http.StatusNotFound
+	case errors.Is(err, basket.ErrInsufficientMargin):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, basket.ErrLegFailed):
+		return http.StatusBadGateway
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Basket Preview :- resolved legs and the margin they block, without ordering
+func (s *Server) basketPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	var req basketReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	orders, err := s.resolveBasket(r.Context(), req)
+	if err != nil {
+		SendJSONResp(nil, err, basketErrStatus(err), w)
+		return
+	}
+	required, available, err := s.baskets.Margin(orders)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadGateway, w)
+		return
+	}
+
+	legs := make([]basket.LegResult, len(orders))
+	for i, o := range orders {
+		legs[i] = basket.LegResult{
+			Exchange:        o.Exchange,
+			Tradingsymbol:   o.Tradingsymbol,
+			TransactionType: o.TransactionType,
+			Quantity:        o.Quantity,
+		}
+	}
+	SendJSONResp(basket.Report{Margin: required, Available: available, Legs: legs}, nil, http.StatusOK, w)
+}
+
+// Place Basket :- hedges first, rolled back if a leg fails
+func (s *Server) placeBasketHandler(w http.ResponseWriter, r *http.Request) {
+	var req basketReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	orders, err := s.resolveBasket(r.Context(), req)
+	if err != nil {
+		SendJSONResp(nil, err, basketErrStatus(err), w)
+		return
+	}
+
+	report, err := s.baskets.Execute(orders)
+	if err != nil {
+		SendJSONResp(report, err, basketErrStatus(err), w)
+		return
+	}
+	SendJSONResp(report, nil, http.StatusCreated, w)
+}
@@ -0,0 +1,367 @@
+// Strategy Definition Routes
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/broker"
+	"friction-trading/internal/bus"
+	"friction-trading/internal/database"
+	"friction-trading/internal/risk"
+	"friction-trading/internal/rules"
+	"friction-trading/internal/sizing"
+	"friction-trading/internal/strategy"
+)
+
+// Time allowed to resolve a definition's instruments or size one of its orders
+const definitionTimeout = 10 * time.Second
+
+// deployment is a declared strategy and the instances trading it, one per instrument
+type deployment struct {
+	def         strategy.Definition
+	instances   []string
+	err         string
+	instruments map[uint32]*database.Instrument
+
+	// The paper or Kite account, its engine's kill switch halts this deployment too
+	broker  broker.Broker
+	account *risk.Engine
+	engine  *risk.Engine // account limits tightened by the definition's
+
+	signals *bus.Subscription[strategy.Signal]
+	orders  *bus.Subscription[orderEvent]
+
+	// Net quantity held per instrument. Orders book it as they go out and give
+	// back what ends unfilled.
+	mu     sync.Mutex
+	net    map[uint32]int
+	placed map[string]placedOrder
+	ended  map[string]int // unfilled quantity of orders which ended before placing returned
+}
+
+// placedOrder is a deployment order still working
+type placedOrder struct {
+	token    uint32
+	quantity int // negative for sells
+}
+
+// definitionResp is a declared strategy and how it's doing
+type definitionResp struct {
+	strategy.Definition
+	Instances []string        `json:"instances,omitempty"`
+	Status    strategy.Status `json:"status,omitempty"` // running while every instance is
+	Error     string          `json:"error,omitempty"`
+}
+
+// definitionStrategy is the registered strategy a definition runs, rules get one each
+func definitionStrategy(def strategy.Definition) string {
+	if def.Strategy == rules.Name {
+		return rules.Name + ":" + def.Name
+	}
+	return def.Strategy
+}
+
+// loadDefinitions reads the strategy file, checks every strategy and sizing policy
+// exists and compiles entry and exit rules
+func (s *Server) loadDefinitions(path string) ([]strategy.Definition, error) {
+	defs, err := strategy.LoadDefinitions(path)
+	if err != nil {
+		return nil, err
+	}
+	names := s.strategies.Names()
+	for _, def := range defs {
+		switch {
+		case def.Strategy == rules.Name:
+			factory, err := rules.Factory(def.Entry, def.Exit)
+			if err != nil {
+				return nil, fmt.Errorf("strategy %q: %w", def.Name, err)
+			}
+			s.strategies.Register(definitionStrategy(def), factory)
+		case def.Entry != "" || def.Exit != "":
+			return nil, fmt.Errorf("strategy %q: entry and exit rules need strategy %q", def.Name, rules.Name)
+		case !slices.Contains(names, def.Strategy):
+			return nil, fmt.Errorf("strategy %q: %w: %q", def.Name, strategy.ErrUnknownStrategy, def.Strategy)
+		}
+		if def.Sizing != nil {
+			if _, err := sizing.New(def.Sizing.Policy, def.Sizing.Params); err != nil {
+				return nil, fmt.Errorf("strategy %q: %w", def.Name, err)
+			}
+		}
+	}
+	return defs, nil
+}
+
+// startDefinitions starts every declared strategy which isn't running, once logged in
+func (s *Server) startDefinitions() {
+	s.deployMu.Lock()
+	defer s.deployMu.Unlock()
+
+	for _, def := range s.definitions {
+		if d := s.deployments[def.Name]; d != nil {
+			if s.deploymentStatus(d) == strategy.StatusRunning {
+				continue
+			}
+			s.undeploy(d)
+		}
+
+		d, err := s.deploy(def)
+		if err != nil {
+			log.Printf("Error starting strategy %s :- %v\n", def.Name, err)
+			d = &deployment{def: def, err: err.Error()}
+		} else {
+			log.Printf("Strategy %s started as %v on %s\n", def.Name, d.instances, def.Mode)
+		}
+		s.deployments[def.Name] = d
+	}
+}
+
+// deploymentStatus is running while every instance runs, or the first instance's status otherwise
+func (s *Server) deploymentStatus(d *deployment) strategy.Status {
+	status := strategy.Status("")
+	for _, id := range d.instances {
+		info, err := s.strategies.Get(id)
+		if err != nil {
+			return strategy.StatusStopped
+		}
+		if info.Status != strategy.StatusRunning {
+			return info.Status
+		}
+		status = info.Status
+	}
+	return status
+}
+
+// undeploy stops a deployment's instances and its trading, positions stay open
+func (s *Server) undeploy(d *deployment) {
+	for _, id := range d.instances {
+		if info, err := s.strategies.Get(id); err == nil && info.Status == strategy.StatusRunning {
+			_, _ = s.strategies.Stop(id)
+		}
+	}
+	if d.signals != nil {
+		d.signals.Close()
+	}
+	if d.orders != nil {
+		d.orders.Close()
+	}
+}
+
+// deploy resolves a definition's instruments, starts an instance on each and trades their signals
+func (s *Server) deploy(def strategy.Definition) (*deployment, error) {
+	d := &deployment{
+		def:         def,
+		instruments: map[uint32]*database.Instrument{},
+		broker:      s.paper,
+		account:     s.paperRisk,
+		net:         map[uint32]int{},
+		placed:      map[string]placedOrder{},
+		ended:       map[string]int{},
+	}
+	account := accountPaper
+	if def.Mode == strategy.ModeLive {
+		d.broker, d.account, account = s.broker, s.risk, accountLive
+	}
+	d.engine = risk.New(d.broker, d.account.Limits().Tighten(def.Risk))
+	d.engine.SetExposure(s.netGreeks)
+
+	ctx, cancel := context.WithTimeout(s.ctx, definitionTimeout)
+	defer cancel()
+	tokens := make([]uint32, 0, len(def.Instruments))
+	for _, symbol := range def.Instruments {
+		inst, err := s.lookupInstrument(ctx, symbol, "")
+		if err != nil {
+			return nil, fmt.Errorf("instrument %q: %w", symbol, err)
+		}
+		token := uint32(inst.InstrumentToken)
+		if _, ok := d.instruments[token]; ok {
+			return nil, fmt.Errorf("instrument %q is listed twice", symbol)
+		}
+		d.instruments[token] = inst
+		tokens = append(tokens, token)
+	}
+
+	// Subscribed before the start so the first signal isn't missed, orders go out
+	// one at a time in signal order and none are skipped
+	name := definitionStrategy(def)
+	for _, token := range tokens {
+		d.instances = append(d.instances, strategy.InstanceID(name, []uint32{token}))
+	}
+	d.orders = s.events.orders.Subscribe(bus.Options{
+		Name:       "definition " + def.Name,
+		Policy:     bus.Block,
+		Strategies: []string{broker.OwnTag(def.Name)},
+	}, func(o orderEvent) {
+		if o.Account == account {
+			d.settle(o.Order)
+		}
+	})
+	d.signals = s.events.signals.Subscribe(bus.Options{
+		Name:       "definition " + def.Name,
+		Policy:     bus.Block,
+		Strategies: d.instances,
+	}, func(sig strategy.Signal) { s.tradeSignal(d, sig) })
+
+	for _, token := range tokens {
+		if _, err := s.strategies.Start(name, def.StartParams(), []uint32{token}); err != nil {
+			s.undeploy(d)
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// tradeSignal moves a deployment's position in the signal's instrument to where the signal points.
+// BUY goes long and SELL goes flat, or short when the definition allows. Entries are sized,
+// exits close exactly what is open.
+func (s *Server) tradeSignal(d *deployment, sig strategy.Signal) {
+	inst, ok := d.instruments[sig.Token]
+	if !ok {
+		log.Printf("Signal from %s for unknown token %d ignored\n", d.def.Name, sig.Token)
+		return
+	}
+	if d.account.Killed() != nil {
+		log.Printf("Signal from %s ignored :- %v\n", d.def.Name, risk.ErrKilled)
+		return
+	}
+
+	d.mu.Lock()
+	open := d.net[sig.Token]
+	d.mu.Unlock()
+	buy := sig.Side == kiteconnect.TransactionTypeBuy
+	if (buy && open > 0) || (!buy && open < 0) || (!buy && open == 0 && !d.def.Short) {
+		return
+	}
+
+	params := kiteconnect.OrderParams{
+		Exchange:        inst.Exchange,
+		Tradingsymbol:   inst.Tradingsymbol,
+		TransactionType: sig.Side,
+		OrderType:       kiteconnect.OrderTypeMarket,
+		Product:         d.def.Product,
+		Validity:        kiteconnect.ValidityDay,
+		Quantity:        abs(open),
+		Tag:             d.def.Name,
+	}
+	if params.Product == "" {
+		params.Product = kiteconnect.ProductMIS
+	}
+	if buy || d.def.Short {
+		entry, err := s.entryQuantity(d, inst, params)
+		if err != nil {
+			log.Printf("Error sizing %s order for %s :- %v\n", d.def.Name, inst.Tradingsymbol, err)
+			if open == 0 {
+				return
+			}
+			// Still close what is open
+			entry = 0
+		}
+		params.Quantity += entry
+	}
+
+	// placeOrderWith logs rejections
+	orderID, err := s.placeOrderWith(d.engine, kiteconnect.VarietyRegular, params)
+	if err != nil {
+		return
+	}
+	quantity := params.Quantity
+	if !buy {
+		quantity = -quantity
+	}
+	d.book(orderID, sig.Token, quantity)
+}
+
+// entryQuantity is the size of a new position, the definition's quantity, a lot or its sizing policy's
+func (s *Server) entryQuantity(d *deployment, inst *database.Instrument, params kiteconnect.OrderParams) (int, error) {
+	if d.def.Sizing == nil {
+		if d.def.Quantity > 0 {
+			return d.def.Quantity, nil
+		}
+		return max(1, int(inst.LotSize)), nil
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, definitionTimeout)
+	defer cancel()
+	return s.sizeOrderOn(ctx, d.broker, params, sizingReq{Policy: d.def.Sizing.Policy, Params: d.def.Sizing.Params})
+}
+
+// book adds a placed order to the position, less whatever it ended without filling
+func (d *deployment) book(orderID string, token uint32, quantity int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if unfilled, ok := d.ended[orderID]; ok {
+		delete(d.ended, orderID)
+		d.net[token] += quantity - sign(quantity)*unfilled
+		return
+	}
+	d.placed[orderID] = placedOrder{token: token, quantity: quantity}
+	d.net[token] += quantity
+}
+
+// settle gives back the unfilled part of a deployment order once it completes, is rejected or cancelled
+func (d *deployment) settle(order kiteconnect.Order) {
+	switch order.Status {
+	case "COMPLETE", "REJECTED", "CANCELLED":
+	default:
+		return
+	}
+	unfilled := int(order.Quantity - order.FilledQuantity)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	placed, ok := d.placed[order.OrderID]
+	if !ok {
+		d.ended[order.OrderID] = unfilled
+		return
+	}
+	delete(d.placed, order.OrderID)
+	d.net[placed.token] -= sign(placed.quantity) * unfilled
+}
+
+// flatten forgets the position in instruments closed outside the deployment, e.g. by a square-off
+func (d *deployment) flatten(closed func(inst *database.Instrument) bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for token, inst := range d.instruments {
+		if closed(inst) {
+			d.net[token] = 0
+		}
+	}
+}
+
+func sign(n int) int {
+	if n < 0 {
+		return -1
+	}
+	return 1
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// List Strategy Definitions :- declared strategies and the instances running them
+func (s *Server) listDefinitionsHandler(w http.ResponseWriter, r *http.Request) {
+	s.deployMu.Lock()
+	defer s.deployMu.Unlock()
+
+	resp := make([]definitionResp, 0, len(s.definitions))
+	for _, def := range s.definitions {
+		item := definitionResp{Definition: def}
+		if d := s.deployments[def.Name]; d != nil {
+			item.Instances, item.Error = d.instances, d.err
+			item.Status = s.deploymentStatus(d)
+		}
+		resp = append(resp, item)
+	}
+	SendJSONResp(resp, nil, http.StatusOK, w)
+}
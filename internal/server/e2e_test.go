@@ -0,0 +1,1515 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/backtest"
+	"friction-trading/internal/broker"
+	"friction-trading/internal/bus"
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/charges"
+	"friction-trading/internal/config"
+	"friction-trading/internal/database"
+	"friction-trading/internal/exits"
+	"friction-trading/internal/feed"
+	"friction-trading/internal/kitefake"
+	"friction-trading/internal/oi"
+	"friction-trading/internal/options"
+	"friction-trading/internal/risk"
+	"friction-trading/internal/strategy"
+)
+
+// memStore keeps instruments in memory in place of Postgres
+type memStore struct {
+	database.Store
+
+	mu          sync.Mutex
+	instruments []*database.Instrument
+	exitRules   []*database.ExitRule
+	oiSnapshots []*database.OiSnapshot
+	signals     []*database.Signal
+}
+
+func (m *memStore) CountInstruments(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.instruments)), nil
+}
+
+func (m *memStore) InsertInstrument(ctx context.Context, arg database.InsertInstrumentParams) (*database.Instrument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in := &database.Instrument{
+		ID:              int32(len(m.instruments) + 1),
+		InstrumentToken: arg.InstrumentToken,
+		ExchangeToken:   arg.ExchangeToken,
+		Tradingsymbol:   arg.Tradingsymbol,
+		Name:            arg.Name,
+		LastPrice:       arg.LastPrice,
+		Expiry:          arg.Expiry,
+		Strike:          arg.Strike,
+		TickSize:        arg.TickSize,
+		LotSize:         arg.LotSize,
+		InstrumentType:  arg.InstrumentType,
+		Segment:         arg.Segment,
+		Exchange:        arg.Exchange,
+	}
+	m.instruments = append(m.instruments, in)
+	return in, nil
+}
+
+func (m *memStore) SearchSymbol(ctx context.Context, tradingsymbol string) ([]*database.Instrument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	term := strings.Trim(tradingsymbol, "%")
+	var out []*database.Instrument
+	for _, in := range m.instruments {
+		if strings.Contains(in.Tradingsymbol, term) {
+			out = append(out, in)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) GetInstrumentBySymbol(ctx context.Context, arg database.GetInstrumentBySymbolParams) (*database.Instrument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, in := range m.instruments {
+		if in.Exchange == arg.Exchange && in.Tradingsymbol == arg.Tradingsymbol {
+			return in, nil
+		}
+	}
+	return nil, errors.New(ErrNoRowsFound.Error())
+}
+
+func (m *memStore) GetInstrumentByToken(ctx context.Context, instrumentToken int64) (*database.Instrument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, in := range m.instruments {
+		if in.InstrumentToken == instrumentToken {
+			return in, nil
+		}
+	}
+	return nil, errors.New(ErrNoRowsFound.Error())
+}
+
+func (m *memStore) ListOptionExpiries(ctx context.Context, arg database.ListOptionExpiriesParams) ([]pgtype.Timestamp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []pgtype.Timestamp
+	for _, in := range m.instruments {
+		if in.Exchange != arg.Exchange || in.Name != arg.Name || in.Expiry.Time.Before(arg.Expiry.Time) {
+			continue
+		}
+		if !slices.ContainsFunc(out, func(e pgtype.Timestamp) bool { return e.Time.Equal(in.Expiry.Time) }) {
+			out = append(out, in.Expiry)
+		}
+	}
+	slices.SortFunc(out, func(a, b pgtype.Timestamp) int { return a.Time.Compare(b.Time) })
+	return out, nil
+}
+
+func (m *memStore) ListOptionChain(ctx context.Context, arg database.ListOptionChainParams) ([]*database.Instrument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*database.Instrument
+	for _, in := range m.instruments {
+		if in.Exchange == arg.Exchange && in.Name == arg.Name && in.Expiry.Time.Equal(arg.Expiry.Time) {
+			out = append(out, in)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) TruncateInstrument(ctx context.Context) (*database.TruncateInstrumentRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.instruments = nil
+	return &database.TruncateInstrumentRow{}, nil
+}
+
+func (m *memStore) CreateExitRule(ctx context.Context, arg database.CreateExitRuleParams) (*database.ExitRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &database.ExitRule{
+		ID:              int64(len(m.exitRules) + 1),
+		InstrumentToken: arg.InstrumentToken,
+		Exchange:        arg.Exchange,
+		Tradingsymbol:   arg.Tradingsymbol,
+		Product:         arg.Product,
+		Quantity:        arg.Quantity,
+		EntryPrice:      arg.EntryPrice,
+		StopLoss:        arg.StopLoss,
+		Target:          arg.Target,
+		TrailDistance:   arg.TrailDistance,
+		HighWater:       arg.HighWater,
+		Status:          exits.StatusActive,
+	}
+	m.exitRules = append(m.exitRules, r)
+	copied := *r
+	return &copied, nil
+}
+
+func (m *memStore) ListActiveExitRules(ctx context.Context) ([]*database.ExitRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*database.ExitRule
+	for _, r := range m.exitRules {
+		if r.Status == exits.StatusActive {
+			copied := *r
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) UpdateExitRule(ctx context.Context, arg database.UpdateExitRuleParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.exitRules {
+		if r.ID == arg.ID && r.Version < arg.Version {
+			r.StopLoss, r.HighWater, r.Status = arg.StopLoss, arg.HighWater, arg.Status
+			r.ExitReason, r.ExitOrderID, r.Version = arg.ExitReason, arg.ExitOrderID, arg.Version
+		}
+	}
+	return nil
+}
+
+func (m *memStore) CreateOISnapshots(ctx context.Context, args []database.CreateOISnapshotsParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, arg := range args {
+		m.oiSnapshots = append(m.oiSnapshots, &database.OiSnapshot{
+			ID:              int64(len(m.oiSnapshots) + 1),
+			Underlying:      arg.Underlying,
+			Expiry:          arg.Expiry,
+			Strike:          arg.Strike,
+			OptionType:      arg.OptionType,
+			InstrumentToken: arg.InstrumentToken,
+			Oi:              arg.Oi,
+			Volume:          arg.Volume,
+			LastPrice:       arg.LastPrice,
+			Close:           arg.Close,
+			CapturedAt:      arg.CapturedAt,
+		})
+	}
+	return int64(len(args)), nil
+}
+
+func (m *memStore) ListOISnapshots(ctx context.Context, arg database.ListOISnapshotsParams) ([]*database.OiSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*database.OiSnapshot
+	for _, snap := range m.oiSnapshots {
+		if snap.Underlying == arg.Underlying && snap.Expiry.Time.Equal(arg.Expiry.Time) && !snap.CapturedAt.Time.Before(arg.CapturedAt.Time) {
+			out = append(out, snap)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) CreateSignal(ctx context.Context, arg database.CreateSignalParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signals = append(m.signals, &database.Signal{
+		ID:              int64(len(m.signals) + 1),
+		Strategy:        arg.Strategy,
+		InstrumentToken: arg.InstrumentToken,
+		Side:            arg.Side,
+		Price:           arg.Price,
+		Indicators:      arg.Indicators,
+		SignalledAt:     arg.SignalledAt,
+	})
+	return nil
+}
+
+func (m *memStore) ListSignals(ctx context.Context, arg database.ListSignalsParams) ([]*database.Signal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*database.Signal
+	for i := len(m.signals) - 1; i >= 0 && len(out) < int(arg.RowLimit); i-- {
+		sig := m.signals[i]
+		if (arg.Strategy != "" && sig.Strategy != arg.Strategy) ||
+			(arg.InstrumentToken != 0 && sig.InstrumentToken != arg.InstrumentToken) ||
+			(arg.Side != "" && sig.Side != arg.Side) ||
+			sig.SignalledAt.Time.Before(arg.Since.Time) || (arg.Until.Valid && !sig.SignalledAt.Time.Before(arg.Until.Time)) {
+			continue
+		}
+		out = append(out, sig)
+	}
+	return out, nil
+}
+
+// newE2EServer runs the server against the fake Kite, during market hours
+func newE2EServer(t *testing.T) (*Server, *kitefake.Server, *httptest.Server) {
+	t.Helper()
+	fake := kitefake.New()
+	t.Cleanup(fake.Close)
+
+	c := &config.Config{}
+	c.Kite.API_KEY = kitefake.APIKey
+	c.Kite.API_SECRET = kitefake.APISecret
+
+	kc := kiteconnect.New(kitefake.APIKey)
+	kc.SetBaseURI(fake.URL)
+
+	s := newServer(c, &memStore{}, kc)
+	tickerURL := fake.TickerURL()
+	s.tickerURL = &tickerURL
+	s.now = func() time.Time { return time.Date(2025, 10, 20, 10, 0, 0, 0, calendar.IST) }
+	t.Cleanup(s.strategies.StopAll)
+	t.Cleanup(s.stopExits)
+	t.Cleanup(s.stopOIWatches)
+	t.Cleanup(s.events.close)
+
+	ts := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(ts.Close)
+	return s, fake, ts
+}
+
+// decodeData unwraps a SendJSONResp body into v
+func decodeData(r io.Reader, v any) error {
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		return err
+	}
+	return json.Unmarshal(resp.Data, v)
+}
+
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode < 300 {
+		if err := decodeData(resp.Body, v); err != nil {
+			t.Fatalf("decode %s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// login completes the Kite login callback against the fake
+func login(t *testing.T, ts *httptest.Server) {
+	t.Helper()
+	if code := getJSON(t, ts.URL+"/api/user/callback/kite/?request_token="+kitefake.RequestToken, nil); code != http.StatusOK {
+		t.Fatalf("login callback status %d", code)
+	}
+}
+
+func postJSON(t *testing.T, url, body string, v any) int {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		_ = decodeData(resp.Body, v)
+	}
+	return resp.StatusCode
+}
+
+func TestEndToEnd(t *testing.T) {
+	_, fake, ts := newE2EServer(t)
+
+	const token = 13238786
+	fake.Update(func(f *kitefake.Server) {
+		f.Instruments = kiteconnect.Instruments{
+			{InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Name: "NIFTY 50", Exchange: "NSE", Segment: "INDICES"},
+			{InstrumentToken: token, Tradingsymbol: "NIFTY25OCT25000CE", Name: "NIFTY", Exchange: "NFO",
+				Segment: "NFO-OPT", InstrumentType: "CE", StrikePrice: 25000, LotSize: 75},
+		}
+	})
+
+	// Locked out until login completes
+	if code := getJSON(t, ts.URL+"/api/user/profile", nil); code != http.StatusUnauthorized {
+		t.Fatalf("want 401 before login, got %d", code)
+	}
+
+	login(t, ts)
+
+	var profile map[string]any
+	if code := getJSON(t, ts.URL+"/api/user/profile", &profile); code != http.StatusOK {
+		t.Fatalf("profile status %d", code)
+	}
+	for _, key := range []string{"portfolio", "positions", "margins"} {
+		if _, ok := profile[key]; !ok {
+			t.Errorf("profile missing %q: %v", key, profile)
+		}
+	}
+
+	// Sync instruments then search them
+	resp, err := http.Get(ts.URL + "/api/instruments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "2" {
+		t.Errorf("want 2 instruments synced, got %q", body)
+	}
+
+	var search map[string][]database.Instrument
+	resp, err = http.Get(ts.URL + "/api/symbol_search?text=25000CE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&search)
+	resp.Body.Close()
+	if len(search["results"]) != 1 || search["results"][0].InstrumentToken != token {
+		t.Errorf("unexpected search results %+v", search)
+	}
+
+	// Start a strategy and stream it ticks over the fake ticker
+	resp, err = http.Post(ts.URL+"/api/strategies", "application/json",
+		strings.NewReader(`{"name":"sma","params":{"period":3},"tokens":[13238786]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info strategy.Info
+	_ = decodeData(resp.Body, &info)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("start strategy status %d", resp.StatusCode)
+	}
+
+	if !fake.WaitSubscribed(5*time.Second, token) {
+		t.Fatal("strategy did not subscribe over the ticker")
+	}
+	for _, price := range []float64{100, 101, 102, 103} {
+		fake.PushTicks(kitemodels.Tick{InstrumentToken: token, LastPrice: price})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		getJSON(t, ts.URL+"/api/strategies/"+info.ID, &info)
+		if info.State["ltp"] == 103.0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ticks did not reach the strategy: %+v", info)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info.State["sma"] != 102.0 {
+		t.Errorf("want sma 102, got %v", info.State["sma"])
+	}
+
+	resp, err = http.Post(ts.URL+"/api/strategies/"+info.ID+"/stop", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = decodeData(resp.Body, &info)
+	resp.Body.Close()
+	if info.Status != strategy.StatusStopped {
+		t.Errorf("want stopped, got %s", info.Status)
+	}
+}
+
+func TestKillSwitch(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	s.risk = risk.New(s.broker, risk.Limits{MaxOrderNotional: 50000})
+	login(t, ts)
+
+	fake.SetQuote("NFO:NIFTY25OCT25000CE", kitefake.Quote{InstrumentToken: 13238786, LastPrice: 120})
+
+	order := `{"exchange":"NFO","tradingsymbol":"NIFTY25OCT25000CE","transaction_type":"BUY","product":"MIS",`
+	if code := postJSON(t, ts.URL+"/api/orders", order+`"order_type":"MARKET","quantity":75}`, nil); code != http.StatusCreated {
+		t.Fatalf("market order status %d", code)
+	}
+	if code := postJSON(t, ts.URL+"/api/orders", order+`"order_type":"LIMIT","quantity":75,"price":110}`, nil); code != http.StatusCreated {
+		t.Fatalf("limit order status %d", code)
+	}
+
+	// 750 x 120 breaks the notional limit
+	var violation risk.Violation
+	if code := postJSON(t, ts.URL+"/api/orders", order+`"order_type":"MARKET","quantity":750}`, &violation); code != http.StatusUnprocessableEntity {
+		t.Fatalf("want 422 for oversized order, got %d", code)
+	}
+	if len(violation.Reasons) != 1 {
+		t.Errorf("unexpected reasons %q", violation.Reasons)
+	}
+
+	var report risk.KillReport
+	if code := postJSON(t, ts.URL+"/api/risk/kill", "", &report); code != http.StatusOK {
+		t.Fatalf("kill switch status %d: %+v", code, report)
+	}
+	if len(report.Cancelled) != 1 || len(report.SquaredOff) != 1 {
+		t.Errorf("want 1 order cancelled and 1 position squared off, got %+v", report)
+	}
+	positions, err := fake.Client().GetPositions()
+	if err != nil || len(positions.Net) != 1 || positions.Net[0].Quantity != 0 {
+		t.Errorf("want flat position after kill, got %+v, %v", positions.Net, err)
+	}
+
+	if code := postJSON(t, ts.URL+"/api/orders", order+`"order_type":"MARKET","quantity":75}`, nil); code != http.StatusLocked {
+		t.Errorf("want 423 while killed, got %d", code)
+	}
+	postJSON(t, ts.URL+"/api/risk/resume", "", nil)
+	if code := postJSON(t, ts.URL+"/api/orders", order+`"order_type":"MARKET","quantity":75}`, nil); code != http.StatusCreated {
+		t.Errorf("want order after resume, got %d", code)
+	}
+}
+
+func TestSizedOrder(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+
+	const token = 13238786
+	s.Store.(*memStore).instruments = []*database.Instrument{
+		{InstrumentToken: token, Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE", LotSize: 75},
+	}
+	fake.SetQuote("NFO:NIFTY25OCT25000CE", kitefake.Quote{InstrumentToken: token, LastPrice: 120})
+
+	// 20 candles ranging 10 points each, ending just before now
+	var candles []kiteconnect.HistoricalData
+	for i := 20; i > 0; i-- {
+		candles = append(candles, kiteconnect.HistoricalData{
+			Date: kitemodels.Time{Time: s.now().Add(-time.Duration(i) * 5 * time.Minute)},
+			Open: 120, High: 125, Low: 115, Close: 120,
+		})
+	}
+	fake.SetHistorical(token, candles)
+
+	order := `{"exchange":"NFO","tradingsymbol":"NIFTY25OCT25000CE","transaction_type":"BUY","product":"MIS","order_type":"MARKET",`
+
+	// Stop 2 x 10 points, risking 5000 buys 250 units, 3 lots
+	var preview map[string]int
+	if code := postJSON(t, ts.URL+"/api/sizing", order+`"policy":"fixed_risk","params":{"risk":5000}}`, &preview); code != http.StatusOK {
+		t.Fatalf("sizing preview status %d", code)
+	}
+	if preview["quantity"] != 225 {
+		t.Errorf("want 225, got %v", preview)
+	}
+
+	if code := postJSON(t, ts.URL+"/api/orders", order+`"sizing":{"policy":"fixed_lots","params":{"lots":2}}}`, nil); code != http.StatusCreated {
+		t.Fatalf("sized order status %d", code)
+	}
+	if orders := fake.Orders(); len(orders) != 1 || orders[0].Quantity != 150 {
+		t.Errorf("want one order of 150, got %+v", orders)
+	}
+
+	if code := postJSON(t, ts.URL+"/api/orders", order+`"sizing":{"policy":"fixed_risk","params":{"risk":100}}}`, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("want 422 for size under a lot, got %d", code)
+	}
+}
+
+func TestExitRule(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+
+	const token = 13238786
+	s.Store.(*memStore).instruments = []*database.Instrument{
+		{InstrumentToken: token, Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE", LotSize: 75},
+	}
+	fake.SetQuote("NFO:NIFTY25OCT25000CE", kitefake.Quote{InstrumentToken: token, LastPrice: 120})
+
+	// 25 of the 75 were sold by hand, the exit must not go short
+	fake.Update(func(f *kitefake.Server) {
+		f.Positions.Net = []kiteconnect.Position{{Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE", Product: "MIS", Quantity: 50}}
+	})
+
+	var rule database.ExitRule
+	body := `{"exchange":"NFO","tradingsymbol":"NIFTY25OCT25000CE","product":"MIS","quantity":75,"entry_price":120,` +
+		`"stop":{"mode":"points","value":10},"target":{"mode":"percent","value":25}}`
+	if code := postJSON(t, ts.URL+"/api/exits", body, &rule); code != http.StatusCreated {
+		t.Fatalf("add exit status %d", code)
+	}
+	if rule.StopLoss != 110 || rule.Target != 150 {
+		t.Errorf("want stop 110 target 150, got %+v", rule)
+	}
+
+	if !fake.WaitSubscribed(5*time.Second, token) {
+		t.Fatal("exit engine did not subscribe")
+	}
+	fake.PushTicks(kitemodels.Tick{InstrumentToken: token, LastPrice: 109})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fake.Orders()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stop-loss did not place an exit order")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if o := fake.Orders()[0]; o.TransactionType != kiteconnect.TransactionTypeSell || o.Quantity != 50 || o.Product != "MIS" {
+		t.Errorf("want exit capped at the 50 open, got %+v", o)
+	}
+
+	var active []database.ExitRule
+	getJSON(t, ts.URL+"/api/exits", &active)
+	if len(active) != 0 {
+		t.Errorf("want no active rules after exit, got %+v", active)
+	}
+
+	// The kill switch squares off MIS positions, their rules go with them
+	if code := postJSON(t, ts.URL+"/api/exits", body, &rule); code != http.StatusCreated {
+		t.Fatalf("add exit status %d", code)
+	}
+	postJSON(t, ts.URL+"/api/risk/kill", "", nil)
+	getJSON(t, ts.URL+"/api/exits", &active)
+	if len(active) != 0 {
+		t.Errorf("want rules cancelled by the kill switch, got %+v", active)
+	}
+}
+
+func TestSquareOffCancelsExits(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+
+	const token = 13238786
+	s.Store.(*memStore).instruments = []*database.Instrument{
+		{InstrumentToken: token, Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE", LotSize: 75},
+	}
+	fake.SetQuote("NFO:NIFTY25OCT25000CE", kitefake.Quote{InstrumentToken: token, LastPrice: 120})
+
+	order := `{"exchange":"NFO","tradingsymbol":"NIFTY25OCT25000CE","transaction_type":"BUY","product":"MIS","order_type":"MARKET","quantity":75}`
+	if code := postJSON(t, ts.URL+"/api/orders", order, nil); code != http.StatusCreated {
+		t.Fatalf("market order status %d", code)
+	}
+	body := `{"exchange":"NFO","tradingsymbol":"NIFTY25OCT25000CE","product":"MIS","quantity":75,"entry_price":120,"stop":{"mode":"points","value":10}}`
+	if code := postJSON(t, ts.URL+"/api/exits", body, nil); code != http.StatusCreated {
+		t.Fatalf("add exit status %d", code)
+	}
+
+	if code := postJSON(t, ts.URL+"/api/squareoff?exchange=NFO", "", nil); code != http.StatusOK {
+		t.Fatalf("square-off status %d", code)
+	}
+	var active []database.ExitRule
+	getJSON(t, ts.URL+"/api/exits", &active)
+	if len(active) != 0 {
+		t.Errorf("want the rule cancelled with its position, got %+v", active)
+	}
+}
+
+// seedNiftyChain lists weekly and monthly NIFTY options around a 25030 spot
+func seedNiftyChain(s *Server, fake *kitefake.Server) {
+	fake.SetQuote("NSE:NIFTY 50", kitefake.Quote{InstrumentToken: 256265, LastPrice: 25030})
+	token := 1000
+	for _, day := range []int{23, 28} {
+		expiry := pgtype.Timestamp{Time: time.Date(2025, 10, day, 0, 0, 0, 0, time.UTC), Valid: true}
+		for strike := 24900; strike <= 25200; strike += 50 {
+			for _, typ := range []string{"CE", "PE"} {
+				token++
+				symbol := fmt.Sprintf("NIFTY25O%d%d%s", day, strike, typ)
+				s.Store.(*memStore).instruments = append(s.Store.(*memStore).instruments, &database.Instrument{
+					InstrumentToken: int64(token), Exchange: "NFO", Tradingsymbol: symbol, Name: "NIFTY",
+					Expiry: expiry, Strike: float64(strike), LotSize: 75, InstrumentType: typ,
+				})
+
+				// Cheaper the further out of the money
+				otm := float64(strike - 25030)
+				if typ == "PE" {
+					otm = -otm
+				}
+				fake.SetQuote("NFO:"+symbol, kitefake.Quote{InstrumentToken: token, LastPrice: max(100-otm/3, 10)})
+			}
+		}
+	}
+}
+
+func TestIronCondorBasket(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+
+	seedNiftyChain(s, fake)
+
+	condor := `{"underlying":"NIFTY","strategy":"iron_condor","params":{"width":2,"wing":1},"product":"MIS"}`
+	var preview struct {
+		Margin float64 `json:"margin"`
+		Legs   []struct {
+			Tradingsymbol string `json:"tradingsymbol"`
+		} `json:"legs"`
+	}
+	if code := postJSON(t, ts.URL+"/api/baskets/preview", condor, &preview); code != http.StatusOK {
+		t.Fatalf("basket preview status %d", code)
+	}
+	if len(preview.Legs) != 4 || preview.Legs[0].Tradingsymbol != "NIFTY25O2325150CE" || preview.Legs[3].Tradingsymbol != "NIFTY25O2324900PE" {
+		t.Errorf("want weekly legs around ATM 25050, got %+v", preview.Legs)
+	}
+	if len(fake.Orders()) != 0 {
+		t.Fatal("preview must not place orders")
+	}
+
+	if code := postJSON(t, ts.URL+"/api/baskets", condor, nil); code != http.StatusCreated {
+		t.Fatalf("basket status %d", code)
+	}
+	orders := fake.Orders()
+	if len(orders) != 4 {
+		t.Fatalf("want 4 legs placed, got %d", len(orders))
+	}
+	for i, o := range orders {
+		want := kiteconnect.TransactionTypeBuy
+		if i >= 2 {
+			want = kiteconnect.TransactionTypeSell
+		}
+		if o.TransactionType != want || o.Quantity != 75 || !strings.HasPrefix(o.Tag, "ft") {
+			t.Errorf("leg %d: want hedges bought before shorts, got %s %v %s", i, o.TransactionType, o.Quantity, o.Tag)
+		}
+	}
+
+	// Not enough margin, nothing goes out
+	fake.Update(func(f *kitefake.Server) { f.Margins.Equity.Net = 100 })
+	if code := postJSON(t, ts.URL+"/api/baskets", condor, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("want 422 without margin, got %d", code)
+	}
+	if len(fake.Orders()) != 4 {
+		t.Error("want no legs placed without margin")
+	}
+}
+
+func TestPayoff(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedNiftyChain(s, fake)
+
+	// Short straddle for 200 of premium breaks even 200 either side
+	straddle := `{"underlying":"NIFTY","spot":25000,"range":0.02,"legs":[
+		{"type":"CE","strike":25000,"expiry":"2025-10-23","quantity":-75,"premium":100},
+		{"type":"PE","strike":25000,"expiry":"2025-10-23","quantity":-75,"premium":100}]}`
+	var analysis options.Analysis
+	if code := postJSON(t, ts.URL+"/api/analytics/payoff", straddle, &analysis); code != http.StatusOK {
+		t.Fatalf("payoff status %d", code)
+	}
+	if len(analysis.Breakevens) != 2 || analysis.Breakevens[0] != 24800 || analysis.Breakevens[1] != 25200 {
+		t.Errorf("want breakevens 24800 and 25200, got %v", analysis.Breakevens)
+	}
+	if analysis.MaxProfit != 15000 || !analysis.UnboundedLoss || analysis.UnboundedProfit || len(analysis.Scenarios) != 2 {
+		t.Errorf("want max profit 15000, loss unbounded on the upside and two IV scenarios, got %+v", analysis)
+	}
+
+	// Open positions from a basket, analysed together with a hypothetical hedge
+	if code := postJSON(t, ts.URL+"/api/baskets", `{"underlying":"NIFTY","strategy":"short_strangle","params":{"width":2},"product":"MIS"}`, nil); code != http.StatusCreated {
+		t.Fatalf("basket status %d", code)
+	}
+	body := `{"underlying":"NIFTY","positions":true,"basket":{"strategy":"long_strangle","params":{"width":3}}}`
+	if code := postJSON(t, ts.URL+"/api/analytics/payoff", body, &analysis); code != http.StatusOK {
+		t.Fatalf("positions payoff status %d", code)
+	}
+	if len(analysis.Legs) != 4 || analysis.UnboundedLoss || analysis.UnboundedProfit {
+		t.Errorf("want 4 legs of a bounded structure, got %+v", analysis.Legs)
+	}
+	for _, l := range analysis.Legs {
+		if l.IV <= 0 || l.Strike == 0 {
+			t.Errorf("want legs priced off the chain, got %+v", l)
+		}
+	}
+
+	if code := postJSON(t, ts.URL+"/api/analytics/payoff", `{"underlying":"NIFTY"}`, nil); code != http.StatusNotFound {
+		t.Errorf("want 404 with nothing to analyse, got %d", code)
+	}
+}
+
+func TestPortfolioGreeks(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedNiftyChain(s, fake)
+
+	if code := postJSON(t, ts.URL+"/api/baskets", `{"underlying":"NIFTY","strategy":"short_straddle","product":"MIS"}`, nil); code != http.StatusCreated {
+		t.Fatalf("basket status %d", code)
+	}
+
+	var profile struct {
+		Greeks []options.Exposure `json:"greeks"`
+	}
+	if code := getJSON(t, ts.URL+"/api/user/profile", &profile); code != http.StatusOK {
+		t.Fatalf("profile status %d", code)
+	}
+	if len(profile.Greeks) != 1 || profile.Greeks[0].Underlying != "NIFTY" || len(profile.Greeks[0].Positions) != 2 {
+		t.Fatalf("want NIFTY greeks over both legs, got %+v", profile.Greeks)
+	}
+	if net := profile.Greeks[0].Net; net.Vega >= 0 || net.Theta <= 0 || net.Gamma >= 0 {
+		t.Errorf("want a short straddle short vega and gamma, long theta, got %+v", net)
+	}
+}
+
+func TestOIAnalytics(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedNiftyChain(s, fake)
+
+	// Weekly chain with puts heavier below spot and calls above
+	weekly := time.Date(2025, 10, 23, 0, 0, 0, 0, time.UTC)
+	for _, in := range s.Store.(*memStore).instruments {
+		if !in.Expiry.Time.Equal(weekly) {
+			continue
+		}
+		interest := 1000.0
+		switch {
+		case in.InstrumentType == "PE" && in.Strike <= 25000:
+			interest = 6000
+		case in.InstrumentType == "CE" && in.Strike >= 25100:
+			interest = 5000
+		}
+		fake.SetQuote("NFO:"+in.Tradingsymbol, kitefake.Quote{
+			InstrumentToken: int(in.InstrumentToken), LastPrice: 50, Volume: 100, OI: interest, OHLC: [4]float64{0, 0, 0, 60},
+		})
+	}
+
+	// Morning snapshot of the 25200 call, OI has grown since as price fell
+	var call *database.Instrument
+	for _, in := range s.Store.(*memStore).instruments {
+		if in.Tradingsymbol == "NIFTY25O2325200CE" {
+			call = in
+		}
+	}
+	s.Store.CreateOISnapshots(context.Background(), []database.CreateOISnapshotsParams{{
+		Underlying: "NIFTY", Expiry: call.Expiry, Strike: call.Strike, OptionType: "CE", InstrumentToken: call.InstrumentToken,
+		Oi: 3000, Volume: 10, LastPrice: 55, Close: 60, CapturedAt: pgtype.Timestamptz{Time: time.Date(2025, 10, 20, 9, 20, 0, 0, calendar.IST), Valid: true},
+	}})
+
+	var chain oiResp
+	if code := getJSON(t, ts.URL+"/api/analytics/oi?underlying=NIFTY", &chain); code != http.StatusOK {
+		t.Fatalf("oi status %d", code)
+	}
+	if chain.Live || !chain.Expiry.Equal(weekly) || len(chain.Strikes) != 7 {
+		t.Fatalf("want 7 weekly strikes from quotes, got %+v", chain)
+	}
+	// Puts 3x6000 + 4x1000, calls 4x1000 + 3x5000
+	if math.Abs(chain.PCR-22000.0/19000) > 1e-9 || chain.VolumePCR != 1 {
+		t.Errorf("want PCR %v and volume PCR 1, got %v %v", 22000.0/19000, chain.PCR, chain.VolumePCR)
+	}
+	if chain.MaxPain != 25050 {
+		t.Errorf("want max pain 25050 between the OI walls, got %v", chain.MaxPain)
+	}
+	if top := chain.Strikes[6].Call; top.OIChange != 2000 || top.BuildUp != oi.ShortBuildUp {
+		t.Errorf("want 25200 call short build-up of 2000, got %+v", top)
+	}
+
+	// Watch the chain live, ticks replace the quotes
+	var watch oiWatch
+	if code := postJSON(t, ts.URL+"/api/analytics/oi/watch", `{"underlying":"NIFTY"}`, &watch); code != http.StatusCreated {
+		t.Fatalf("watch status %d", code)
+	}
+	if watch.Options != 14 {
+		t.Errorf("want 14 options watched, got %+v", watch)
+	}
+	if !fake.WaitSubscribed(5*time.Second, uint32(call.InstrumentToken)) {
+		t.Fatal("OI watch did not subscribe")
+	}
+	fake.PushTicks(kitemodels.Tick{InstrumentToken: uint32(call.InstrumentToken), LastPrice: 70, OI: 2500, VolumeTraded: 400, OHLC: kitemodels.OHLC{Close: 60}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		getJSON(t, ts.URL+"/api/analytics/oi?underlying=NIFTY", &chain)
+		if chain.Live && len(chain.Strikes) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("live OI never showed the tick, got %+v", chain)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := chain.Strikes[0].Call; c.OIChange != -500 || c.BuildUp != oi.ShortCovering {
+		t.Errorf("want 25200 call short covering of 500, got %+v", c)
+	}
+
+	s.oiMu.Lock()
+	live := s.oiWatches[oiKey("NIFTY", weekly)]
+	s.oiMu.Unlock()
+	if err := s.snapshotOI(context.Background(), live); err != nil {
+		t.Fatal(err)
+	}
+	var history []oiPoint
+	if code := getJSON(t, ts.URL+"/api/analytics/oi/history?underlying=NIFTY", &history); code != http.StatusOK {
+		t.Fatalf("history status %d", code)
+	}
+	if len(history) != 2 || history[1].CallOI != 2500 || history[1].CallOIChange != -500 {
+		t.Fatalf("want the morning and live snapshots, got %+v", history)
+	}
+	if c := history[1].Strikes[0].Call; c.PriceChange != 10 || c.BuildUp != oi.ShortCovering {
+		t.Errorf("want saved snapshots priced off the close, got %+v", c)
+	}
+}
+
+func TestMarketStream(t *testing.T) {
+	_, fake, ts := newE2EServer(t)
+	login(t, ts)
+
+	const token = 13238786
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/market/stream?tokens=13238786", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("want an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if !fake.WaitSubscribed(5*time.Second, token) {
+		t.Fatal("stream did not subscribe")
+	}
+	var stats feed.Stats
+	if code := getJSON(t, ts.URL+"/api/ticker", &stats); code != http.StatusOK {
+		t.Fatalf("ticker stats status %d", code)
+	}
+	if stats.Subscriptions != 1 || len(stats.Shards) != 1 || stats.Shards[0].Tokens != 1 {
+		t.Errorf("want one subscription on one connection, got %+v", stats)
+	}
+	var topics map[string]bus.Stats
+	if code := getJSON(t, ts.URL+"/api/events", &topics); code != http.StatusOK {
+		t.Fatalf("event stats status %d", code)
+	}
+	if subs := topics["ticks"].Subscribers; len(subs) != 1 || !strings.HasPrefix(subs[0].Name, "market-stream") {
+		t.Errorf("want the stream on the tick bus, got %+v", topics["ticks"])
+	}
+
+	tick := kitemodels.Tick{InstrumentToken: token, LastPrice: 120}
+	tick.Depth.Buy[0] = kitemodels.DepthItem{Price: 119.95, Quantity: 300}
+	tick.Depth.Sell[0] = kitemodels.DepthItem{Price: 120.05, Quantity: 100}
+	fake.PushTicks(tick)
+	tick.Depth.Buy[0].Quantity = 500
+	fake.PushTicks(tick)
+
+	var events []streamTick
+	lines := bufio.NewScanner(resp.Body)
+	for len(events) < 2 && lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event streamTick
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 || events[0].Depth == nil || events[1].Depth == nil {
+		t.Fatalf("want 2 ticks with depth, got %+v", events)
+	}
+	first, second := events[0].Depth, events[1].Depth
+	if first.TopImbalance != 0.5 || math.Abs(first.Spread-0.10) > 1e-9 || first.WeightedMid <= first.Mid {
+		t.Errorf("unexpected book features %+v", first)
+	}
+	if second.BidQtyChange != 200 || second.ImbalanceChange <= 0 {
+		t.Errorf("want the bid to have grown by 200, got %+v", second)
+	}
+}
+
+func TestWatchResolvesInstrument(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedNiftyChain(s, fake)
+
+	var inst database.Instrument
+	if code := getJSON(t, ts.URL+"/api/instruments/resolve?spec=NIFTY+next-week+ATM-1+PE", &inst); code != http.StatusOK {
+		t.Fatalf("resolve status %d", code)
+	}
+	if inst.Tradingsymbol != "NIFTY25O2825000PE" {
+		t.Errorf("want NIFTY25O2825000PE, got %s", inst.Tradingsymbol)
+	}
+	if code := getJSON(t, ts.URL+"/api/instruments/resolve?spec=NIFTY+ATM", nil); code != http.StatusBadRequest {
+		t.Errorf("want 400 for a spec without a type, got %d", code)
+	}
+
+	// Nothing configured, the current week ATM call
+	var info strategy.Info
+	if code := getJSON(t, ts.URL+"/api/watch-nifty50-option", &info); code != http.StatusOK {
+		t.Fatalf("watch status %d", code)
+	}
+	atm, _ := s.Store.GetInstrumentBySymbol(context.Background(), database.GetInstrumentBySymbolParams{Exchange: "NFO", Tradingsymbol: "NIFTY25O2325050CE"})
+	if len(info.Tokens) != 1 || info.Tokens[0] != uint32(atm.InstrumentToken) {
+		t.Errorf("want SMA on %d, got %+v", atm.InstrumentToken, info)
+	}
+}
+
+func TestSignalHistory(t *testing.T) {
+	_, fake, ts := newE2EServer(t)
+	login(t, ts)
+
+	const token = 13238786
+	var info strategy.Info
+	if code := postJSON(t, ts.URL+"/api/strategies", `{"name":"sma","params":{"period":2},"tokens":[13238786]}`, &info); code != http.StatusCreated {
+		t.Fatalf("start strategy status %d", code)
+	}
+	if !fake.WaitSubscribed(5*time.Second, token) {
+		t.Fatal("strategy did not subscribe over the ticker")
+	}
+
+	// Starts bearish at 100, crosses up at 110 and back down at 90
+	at := time.Date(2025, 10, 20, 9, 30, 0, 0, calendar.IST)
+	for i, price := range []float64{100, 100, 110, 90} {
+		tick := kitemodels.Tick{InstrumentToken: token, LastPrice: price}
+		tick.Timestamp.Time = at.Add(time.Duration(i) * time.Second)
+		fake.PushTicks(tick)
+	}
+
+	var signals []signalResp
+	deadline := time.Now().Add(5 * time.Second)
+	for len(signals) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want 2 stored signals, got %+v", signals)
+		}
+		time.Sleep(10 * time.Millisecond)
+		getJSON(t, ts.URL+"/api/signals?strategy="+info.ID, &signals)
+	}
+	if signals[0].Side != "SELL" || signals[0].Price != 90 || signals[1].Side != "BUY" || signals[1].Price != 110 {
+		t.Errorf("want SELL at 90 after BUY at 110, got %+v", signals)
+	}
+
+	var buys []signalResp
+	getJSON(t, ts.URL+"/api/signals?side=buy&token=13238786&from=2025-10-20", &buys)
+	if len(buys) != 1 || buys[0].Indicators["sma"] != 105 || !buys[0].SignalledAt.Equal(at.Add(2*time.Second)) {
+		t.Errorf("want the BUY with its SMA and tick time, got %+v", buys)
+	}
+
+	var none, day []signalResp
+	getJSON(t, ts.URL+"/api/signals?to=2025-10-19", &none)
+	if len(none) != 0 {
+		t.Errorf("want nothing up to the day before, got %+v", none)
+	}
+	getJSON(t, ts.URL+"/api/signals?from=2025-10-20&to=2025-10-20", &day)
+	if len(day) != 2 {
+		t.Errorf("want a date-only to to include its day, got %+v", day)
+	}
+	if code := getJSON(t, ts.URL+"/api/signals?side=HOLD", nil); code != http.StatusBadRequest {
+		t.Errorf("want 400 for a bad side, got %d", code)
+	}
+}
+
+func TestSignalWebhook(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedNiftyChain(s, fake)
+	s.config.Webhook.SECRET = "hook"
+
+	s.Store.(*memStore).instruments = append(s.Store.(*memStore).instruments, &database.Instrument{
+		InstrumentToken: 779521, Exchange: "NSE", Tradingsymbol: "SBIN", LotSize: 1, InstrumentType: "EQ",
+	})
+	fake.SetQuote("NSE:SBIN", kitefake.Quote{InstrumentToken: 779521, LastPrice: 800})
+
+	hook := ts.URL + "/api/webhooks/signal"
+	if code := postJSON(t, hook, `{"secret":"nope","symbol":"NSE:SBIN","action":"buy","quantity":10}`, nil); code != http.StatusForbidden {
+		t.Errorf("want 403 for a bad secret, got %d", code)
+	}
+	if code := postJSON(t, hook, `{"secret":"hook","symbol":"NSE:TCS","action":"buy","quantity":10}`, nil); code != http.StatusNotFound {
+		t.Errorf("want 404 for an unknown symbol, got %d", code)
+	}
+	if code := postJSON(t, hook, `{"secret":"hook","symbol":"SBIN","action":"hold","quantity":10}`, nil); code != http.StatusBadRequest {
+		t.Errorf("want 400 for a bad action, got %d", code)
+	}
+	if code := postJSON(t, hook, `{"secret":"hook","symbol":"NIFTY current-week ATM CE","action":"buy","quantity":10}`, nil); code != http.StatusBadRequest {
+		t.Errorf("want 400 for a part lot, got %d", code)
+	}
+	for _, body := range []string{
+		`{"secret":"hook","symbol":"NSE:SBIN","action":"sell","quantity":10,"order_type":"LIMIT"}`,
+		`{"secret":"hook","symbol":"NSE:SBIN","action":"sell","quantity":10,"order_type":"SL","price":790}`,
+		`{"secret":"hook","symbol":"NSE:SBIN","action":"sell","quantity":10,"order_type":"SL-M","trigger_price":-1}`,
+	} {
+		if code := postJSON(t, hook, body, nil); code != http.StatusBadRequest {
+			t.Errorf("want 400 for a limit or stop without its prices, got %d for %s", code, body)
+		}
+	}
+
+	// Paper by default
+	var placed webhookSignalResp
+	if code := postJSON(t, hook, `{"secret":"hook","symbol":"NSE:SBIN","action":"buy","quantity":10,"strategy":"tv"}`, &placed); code != http.StatusCreated {
+		t.Fatalf("webhook status %d", code)
+	}
+	if placed.Mode != "paper" || !strings.HasPrefix(placed.OrderID, "paper-") {
+		t.Errorf("want a paper order, got %+v", placed)
+	}
+	if code := postJSON(t, hook, `{"secret":"hook","symbol":"NIFTY current-week ATM CE","action":"sell","quantity":75,"strategy":"tv"}`, &placed); code != http.StatusCreated {
+		t.Fatalf("spec webhook status %d", code)
+	}
+	if placed.Tradingsymbol != "NIFTY25O2325050CE" {
+		t.Errorf("want the spec resolved to the ATM call, got %+v", placed)
+	}
+
+	var account struct {
+		Positions []kiteconnect.Position `json:"positions"`
+	}
+	getJSON(t, ts.URL+"/api/paper", &account)
+	if len(account.Positions) != 2 || account.Positions[0].Quantity != 10 || account.Positions[1].Quantity != -75 {
+		t.Errorf("want long SBIN and short the call on paper, got %+v", account.Positions)
+	}
+
+	var signals []signalResp
+	deadline := time.Now().Add(5 * time.Second)
+	for len(signals) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want both alerts stored as signals, got %+v", signals)
+		}
+		time.Sleep(10 * time.Millisecond)
+		getJSON(t, ts.URL+"/api/signals?strategy=webhook:tv", &signals)
+	}
+	if signals[1].Side != "BUY" || signals[1].Price != 800 || signals[1].InstrumentToken != 779521 {
+		t.Errorf("unexpected SBIN signal %+v", signals[1])
+	}
+
+	// Live goes to Kite
+	s.config.Webhook.MODE = "live"
+	if code := postJSON(t, hook, `{"secret":"hook","symbol":"NSE:SBIN","action":"buy","quantity":10}`, &placed); code != http.StatusCreated {
+		t.Fatalf("live webhook status %d", code)
+	}
+	if placed.Mode != "live" || strings.HasPrefix(placed.OrderID, "paper-") {
+		t.Errorf("want a Kite order, got %+v", placed)
+	}
+}
+
+func TestOrderEventStream(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	s.config.Webhook.SECRET = "hook"
+	s.Store.(*memStore).instruments = append(s.Store.(*memStore).instruments, &database.Instrument{
+		InstrumentToken: 779521, Exchange: "NSE", Tradingsymbol: "SBIN", LotSize: 1, InstrumentType: "EQ",
+	})
+	fake.SetQuote("NSE:SBIN", kitefake.Quote{InstrumentToken: 779521, LastPrice: 800})
+
+	var ignored any
+	if code := getJSON(t, ts.URL+"/api/events/stream?topic=ticks", &ignored); code != http.StatusBadRequest {
+		t.Errorf("want 400 for a topic without a stream, got %d", code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/events/stream?topic=orders&account=paper", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want an event stream, got %d", resp.StatusCode)
+	}
+	var topics map[string]bus.Stats
+	getJSON(t, ts.URL+"/api/events", &topics)
+	if subs := topics["orders"].Subscribers; len(subs) != 1 || !strings.HasPrefix(subs[0].Name, "event-stream") {
+		t.Errorf("want the stream on the order bus, got %+v", topics["orders"])
+	}
+	if _, ok := topics["candles"]; !ok {
+		t.Errorf("want a candles topic, got %+v", topics)
+	}
+
+	// A live update is filtered out, the paper fill comes through tagged
+	s.onOrderUpdate(kiteconnect.Order{OrderID: "live-1", Status: "COMPLETE", TradingSymbol: "INFY"})
+	if code := postJSON(t, ts.URL+"/api/webhooks/signal", `{"secret":"hook","symbol":"NSE:SBIN","action":"buy","quantity":10}`, nil); code != http.StatusCreated {
+		t.Fatalf("webhook status %d", code)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Account       string `json:"account"`
+			OrderID       string `json:"order_id"`
+			TradingSymbol string `json:"tradingsymbol"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Account != accountPaper || event.TradingSymbol != "SBIN" || !strings.HasPrefix(event.OrderID, "paper-") {
+			t.Errorf("want the paper SBIN order, got %+v", event)
+		}
+		return
+	}
+	t.Fatal("stream ended without an order")
+}
+
+func TestStrategyDefinitions(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	for _, inst := range []struct {
+		token  int64
+		symbol string
+	}{{779521, "SBIN"}, {408065, "INFY"}, {424961, "ITC"}} {
+		s.Store.(*memStore).instruments = append(s.Store.(*memStore).instruments, &database.Instrument{
+			InstrumentToken: inst.token, Exchange: "NSE", Tradingsymbol: inst.symbol, LotSize: 1, InstrumentType: "EQ",
+		})
+		fake.SetQuote("NSE:"+inst.symbol, kitefake.Quote{InstrumentToken: int(inst.token), LastPrice: 800})
+	}
+	s.definitions = []strategy.Definition{
+		{Name: "sbin-sma", Strategy: "sma", Instruments: []string{"NSE:SBIN"}, Params: strategy.Params{"period": 2}, Mode: strategy.ModePaper, Quantity: 10},
+		{Name: "pair-sma", Strategy: "sma", Instruments: []string{"NSE:INFY", "NSE:ITC"}, Params: strategy.Params{"period": 2}, Mode: strategy.ModePaper, Quantity: 10, Short: true},
+		{Name: "tcs-sma", Strategy: "sma", Instruments: []string{"NSE:TCS"}, Mode: strategy.ModePaper},
+	}
+
+	// Nothing starts before login
+	if infos := s.strategies.List(); len(infos) != 0 {
+		t.Fatalf("want nothing running before login, got %+v", infos)
+	}
+
+	login(t, ts)
+	const sbin, infy = 779521, 408065
+	if !fake.WaitSubscribed(5*time.Second, sbin, infy, 424961) {
+		t.Fatal("declared strategies did not subscribe")
+	}
+	var defs []definitionResp
+	getJSON(t, ts.URL+"/api/strategies/definitions", &defs)
+	if len(defs) != 3 || defs[0].Status != strategy.StatusRunning || defs[2].Error == "" {
+		t.Errorf("want SBIN running and TCS failed to resolve, got %+v", defs)
+	}
+	if pair := defs[1]; pair.Status != strategy.StatusRunning || len(pair.Instances) != 2 {
+		t.Errorf("want an instance per instrument of the pair, got %+v", pair)
+	}
+
+	// Bullish from 110, then crosses down at 90, up at 120 and down at 80. The first
+	// SELL has nothing to close on SBIN and opens a short on INFY.
+	for _, price := range []float64{100, 110, 90, 120, 80} {
+		fake.PushTicks(kitemodels.Tick{InstrumentToken: sbin, LastPrice: price}, kitemodels.Tick{InstrumentToken: infy, LastPrice: price})
+	}
+
+	type paperOrder struct {
+		TransactionType string  `json:"transaction_type"`
+		Tradingsymbol   string  `json:"tradingsymbol"`
+		Quantity        float64 `json:"quantity"`
+		Tag             string  `json:"tag"`
+	}
+	var account struct {
+		Orders    []paperOrder           `json:"orders"`
+		Positions []kiteconnect.Position `json:"positions"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(account.Orders) < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want the signals traded on paper, got %+v", account.Orders)
+		}
+		time.Sleep(10 * time.Millisecond)
+		getJSON(t, ts.URL+"/api/paper", &account)
+	}
+	traded := map[string][]string{}
+	for _, o := range account.Orders {
+		tag := strings.TrimPrefix(o.Tag, broker.TagPrefix)
+		traded[tag] = append(traded[tag], fmt.Sprintf("%s %s %v", o.TransactionType, o.Tradingsymbol, o.Quantity))
+	}
+	if got := strings.Join(traded["sbin-sma"], ", "); got != "BUY SBIN 10, SELL SBIN 10" {
+		t.Errorf("want long only SBIN closed exactly, got %s", got)
+	}
+	if got := strings.Join(traded["pair-sma"], ", "); got != "SELL INFY 10, BUY INFY 20, SELL INFY 20" {
+		t.Errorf("want INFY reversed between short and long, got %s", got)
+	}
+	for _, pos := range account.Positions {
+		want := map[string]int{"SBIN": 0, "INFY": -10}[pos.Tradingsymbol]
+		if pos.Quantity != want {
+			t.Errorf("want %s at %d, got %d", pos.Tradingsymbol, want, pos.Quantity)
+		}
+	}
+
+	// Starting again, as a fresh login does, leaves the running instances alone
+	s.startDefinitions()
+	if infos := s.strategies.List(); len(infos) != 3 {
+		t.Errorf("want three instances, got %+v", infos)
+	}
+}
+
+// seedSBIN adds the cash SBIN instrument backtests are charged as
+func seedSBIN(s *Server) {
+	store := s.Store.(*memStore)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.instruments = append(store.instruments, &database.Instrument{
+		InstrumentToken: 779521, Exchange: "NSE", Tradingsymbol: "SBIN", LotSize: 1, InstrumentType: "EQ",
+	})
+}
+
+func TestBacktest(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedSBIN(s)
+
+	// Falls, pops above the average, drifts up, then breaks below it
+	const token = 779521
+	open := time.Date(2025, 10, 17, 9, 15, 0, 0, calendar.IST)
+	var candles []kiteconnect.HistoricalData
+	for i, c := range []float64{20, 19, 18, 17, 21, 22, 23, 18} {
+		candles = append(candles, kiteconnect.HistoricalData{
+			Date: kitemodels.Time{Time: open.Add(time.Duration(i) * 5 * time.Minute)},
+			Open: c, High: c + 1, Low: c - 1, Close: c,
+		})
+	}
+	fake.SetHistorical(token, candles)
+
+	var res backtest.Result
+	body := `{"strategy":"rules","entry":"close crosses_above sma(3)","exit":"close < sma(3)","token":779521,"from":"2025-10-17","to":"2025-10-18","quantity":2}`
+	if code := postJSON(t, ts.URL+"/api/backtests", body, &res); code != http.StatusOK {
+		t.Fatalf("backtest status %d", code)
+	}
+	if res.Candles != 8 || len(res.Trades) != 1 || res.Trades[0].EntryPrice != 21 || res.Trades[0].ExitPrice != 18 || res.Charges <= 0 || math.Abs(res.NetPnL-(-6-res.Charges)) > 1e-9 {
+		t.Errorf("want one round trip from 21 to 18 less charges, got %+v", res)
+	}
+	intraday := res.Charges
+
+	// A timeframe of the candle interval reads each candle as it closes, not a candle late
+	var framed backtest.Result
+	if code := postJSON(t, ts.URL+"/api/backtests", strings.Replace(body, `"quantity":2`, `"quantity":2,"params":{"timeframe":300}`, 1), &framed); code != http.StatusOK {
+		t.Fatalf("framed backtest status %d", code)
+	}
+	if len(framed.Trades) != 1 || framed.Trades[0].EntryPrice != 21 || framed.Trades[0].ExitPrice != 18 || !framed.Trades[0].EntryTime.Equal(res.Trades[0].EntryTime) {
+		t.Errorf("want the same round trip with a 5m timeframe, got %+v", framed.Trades)
+
+	}
+
+	// Delivery pays STT both ways, instruments the store doesn't know need their segment
+	if code := postJSON(t, ts.URL+"/api/backtests", strings.Replace(body, `"quantity":2`, `"quantity":2,"product":"CNC"`, 1), &res); code != http.StatusOK || res.Charges <= intraday {
+		t.Errorf("want delivery charged more than intraday, got %d %+v", code, res)
+	}
+	fake.SetHistorical(256265, candles)
+	if code := postJSON(t, ts.URL+"/api/backtests", strings.Replace(body, "779521", "256265", 1), nil); code != http.StatusBadRequest {
+		t.Errorf("want 400 for an unknown instrument, got %d", code)
+	}
+	if code := postJSON(t, ts.URL+"/api/backtests", strings.Replace(body, "779521", `256265,"segment":"futures"`, 1), &res); code != http.StatusOK || res.Charges <= 0 {
+		t.Errorf("want futures charges, got %d %+v", code, res)
+	}
+
+	// Registered strategies replay the same way
+	if code := postJSON(t, ts.URL+"/api/backtests", `{"strategy":"sma","params":{"period":3},"token":779521,"from":"2025-10-17","to":"2025-10-18"}`, &res); code != http.StatusOK {
+		t.Fatalf("sma backtest status %d", code)
+	}
+	if len(res.Signals) == 0 || res.Signals[0].Values["period"] != 3 {
+		t.Errorf("want SMA signals, got %+v", res.Signals)
+	}
+
+	for body, want := range map[string]int{
+		`{"strategy":"rules","entry":"close > vwap(20)","exit":"close < 1","token":779521,"from":"2025-10-17"}`: http.StatusBadRequest,
+		`{"strategy":"momentum","token":779521,"from":"2025-10-17"}`:                                            http.StatusBadRequest,
+		`{"strategy":"sma","from":"2025-10-17"}`:                                                                http.StatusBadRequest,
+	} {
+		if code := postJSON(t, ts.URL+"/api/backtests", body, nil); code != want {
+			t.Errorf("%s: want %d, got %d", body, want, code)
+		}
+	}
+}
+
+func TestOptimiseBacktest(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedSBIN(s)
+
+	// Two days of swings around 100
+	const token = 779521
+	open := time.Date(2025, 10, 16, 9, 15, 0, 0, calendar.IST)
+	var candles []kiteconnect.HistoricalData
+	for i := range 150 {
+		c := 100 + 10*math.Sin(float64(i)/6)
+		candles = append(candles, kiteconnect.HistoricalData{
+			Date: kitemodels.Time{Time: open.Add(time.Duration(i) * 5 * time.Minute)},
+			Open: c, High: c + 1, Low: c - 1, Close: c,
+		})
+	}
+	fake.SetHistorical(token, candles)
+
+	var res backtest.Optimisation
+	body := `{"strategy":"supertrend","token":779521,"from":"2025-10-16","to":"2025-10-18","short":true,
+		"space":{"atr_period":{"min":5,"max":10,"step":5},"multiplier":{"min":1,"max":3,"step":1}},
+		"windows":2,"top":4}`
+	if code := postJSON(t, ts.URL+"/api/backtests/optimise", body, &res); code != http.StatusOK {
+		t.Fatalf("optimise status %d", code)
+	}
+	if res.Method != backtest.MethodGrid || res.Candles != 150 || len(res.Windows) != 2 || len(res.Results) != 4 {
+		t.Fatalf("want 2 windows and the top 4 of 6 sets, got %+v", res)
+	}
+	for i, row := range res.Results {
+		if row.Rank != i+1 || row.Params["atr_period"] == 0 || row.Params["multiplier"] == 0 {
+			t.Errorf("unexpected row %+v", row)
+		}
+		if i > 0 && row.OutOfSample.Score > res.Results[i-1].OutOfSample.Score {
+			t.Errorf("rank %d scores above rank %d", row.Rank, row.Rank-1)
+		}
+	}
+
+	for body, want := range map[string]int{
+		`{"strategy":"supertrend","token":779521,"from":"2025-10-16","space":{"multiplier":{"min":1,"max":3}}}`:                               http.StatusBadRequest,
+		`{"strategy":"supertrend","token":779521,"from":"2025-10-16","space":{"multiplier":{"min":1,"max":3,"step":1}},"method":"annealing"}`: http.StatusBadRequest,
+		`{"strategy":"momentum","token":779521,"from":"2025-10-16","space":{"period":{"min":1,"max":3,"step":1}}}`:                            http.StatusBadRequest,
+	} {
+		if code := postJSON(t, ts.URL+"/api/backtests/optimise", body, nil); code != want {
+			t.Errorf("%s: want %d, got %d", body, want, code)
+		}
+	}
+}
+
+func TestMonteCarloBacktest(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedSBIN(s)
+
+	// Three round trips on a rising and falling close
+	const token = 779521
+	open := time.Date(2025, 10, 17, 9, 15, 0, 0, calendar.IST)
+	var candles []kiteconnect.HistoricalData
+	for i, c := range []float64{20, 19, 18, 17, 21, 22, 23, 18, 17, 16, 20, 22, 18, 17, 21, 24} {
+		candles = append(candles, kiteconnect.HistoricalData{
+			Date: kitemodels.Time{Time: open.Add(time.Duration(i) * 5 * time.Minute)},
+			Open: c, High: c + 1, Low: c - 1, Close: c,
+		})
+	}
+	fake.SetHistorical(token, candles)
+
+	var res backtest.MonteCarloResult
+	body := `{"strategy":"rules","entry":"close crosses_above sma(3)","exit":"close < sma(3)","token":779521,"from":"2025-10-17","to":"2025-10-18",
+		"capital":1000,"simulations":500,"seed":3,"method":"shuffle"}`
+	if code := postJSON(t, ts.URL+"/api/backtests/montecarlo", body, &res); code != http.StatusOK {
+		t.Fatalf("monte carlo status %d", code)
+	}
+	if res.Trades != 3 || res.Simulations != 500 || res.Seed != 3 || res.FinalEquity.Min != res.Equity || res.FinalEquity.Max != res.Equity {
+		t.Errorf("want 3 trades shuffled to the same final equity, got %+v", res)
+	}
+
+	// Trades from an earlier backtest skip the replay
+	trades := `[{"side":"BUY","quantity":1,"pnl":100},{"side":"BUY","quantity":1,"pnl":-40}]`
+	if code := postJSON(t, ts.URL+"/api/backtests/montecarlo", `{"capital":1000,"slippage":1,"trades":`+trades+`}`, &res); code != http.StatusOK {
+		t.Fatalf("monte carlo on trades status %d", code)
+	}
+	if res.Trades != 2 || res.Method != backtest.MethodBootstrap || res.Equity != 1060 || res.FinalEquity.Max >= 1200 {
+		t.Errorf("unexpected bootstrap %+v", res)
+	}
+
+	for body, want := range map[string]int{
+		`{"trades":` + trades + `}`:                                     http.StatusBadRequest,
+		`{"capital":1000,"method":"jackknife","trades":` + trades + `}`: http.StatusBadRequest,
+		`{"capital":1000,"strategy":"sma","from":"2025-10-17"}`:         http.StatusBadRequest,
+		`{"capital":1000,"strategy":"rules","entry":"close > 100","exit":"close < 1","token":779521,"from":"2025-10-17","to":"2025-10-18"}`: http.StatusUnprocessableEntity,
+	} {
+		if code := postJSON(t, ts.URL+"/api/backtests/montecarlo", body, nil); code != want {
+			t.Errorf("%s: want %d, got %d", body, want, code)
+		}
+	}
+}
+
+func TestRuleDefinitions(t *testing.T) {
+	s, _, _ := newE2EServer(t)
+	write := func(body string) string {
+		path := filepath.Join(t.TempDir(), "strategies.huml")
+		if err := os.WriteFile(path, []byte("strategies::\n  - ::\n    name: \"sbin\"\n    instruments::\n      - \"NSE:SBIN\"\n"+body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	defs, err := s.loadDefinitions(write("    strategy: \"rules\"\n    entry: \"close > supertrend(7,3) and rsi(14) < 70\"\n    exit: \"close < supertrend(7,3)\"\n"))
+	if err != nil || len(defs) != 1 {
+		t.Fatalf("want the rules definition loaded, got %v %v", defs, err)
+	}
+	if !slices.Contains(s.strategies.Names(), "rules:sbin") {
+		t.Errorf("want the compiled rules registered, got %v", s.strategies.Names())
+	}
+
+	for body, want := range map[string]string{
+		"    strategy: \"rules\"\n    entry: \"close > supertrend(7)\"\n    exit: \"close < 1\"\n": "multiplier",
+		"    strategy: \"sma\"\n    entry: \"close > 1\"\n":                                        "need strategy",
+		"    strategy: \"momentum\"\n":                                                             "unknown strategy",
+	} {
+		if _, err := s.loadDefinitions(write(body)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("want an error about %s, got %v", want, err)
+		}
+	}
+}
+
+func TestStrategyWarmup(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	s.now = func() time.Time { return time.Date(2025, 10, 20, 10, 0, 0, 0, calendar.IST) }
+	login(t, ts)
+
+	// Five minute candles up to the one forming at 10:00
+	const token = 13238786
+	var candles []kiteconnect.HistoricalData
+	for i := range 10 {
+		c := 100 + float64(i)
+		candles = append(candles, kiteconnect.HistoricalData{
+			Date: kitemodels.Time{Time: time.Date(2025, 10, 20, 9, 15+5*i, 0, 0, calendar.IST)},
+			Open: c, High: c + 1, Low: c - 1, Close: c,
+		})
+	}
+	fake.SetHistorical(token, candles)
+
+	// 15 minute candles at 09:30 and 09:45, the 10:00 one isn't closed yet
+	got, err := s.strategyHistory(context.Background(), token, 15*time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].Timestamp.Equal(time.Date(2025, 10, 20, 9, 30, 0, 0, calendar.IST)) ||
+		got[0].Open != 103 || got[0].High != 106 || got[0].Close != 105 || got[1].Close != 108 {
+		t.Errorf("want the 09:30 and 09:45 candles, got %+v", got)
+	}
+	if _, err := s.strategyHistory(context.Background(), token, 90*time.Second, 2); err == nil {
+		t.Error("want an error for a timeframe Kite can't build")
+	}
+
+	var info strategy.Info
+	if code := postJSON(t, ts.URL+"/api/strategies", `{"name":"sma","params":{"period":2,"timeframe":900},"tokens":[13238786]}`, &info); code != http.StatusCreated {
+		t.Fatalf("start strategy status %d", code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for info.Warmed == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		getJSON(t, ts.URL+"/api/strategies/"+info.ID, &info)
+	}
+	if info.Warmed != 2 || info.WarmupErr != "" || info.State["candles"] != float64(2) {
+		t.Errorf("want SMA primed with 2 candles, got %+v", info)
+	}
+}
+
+func TestPnLReport(t *testing.T) {
+	s, fake, ts := newE2EServer(t)
+	login(t, ts)
+	seedSBIN(s)
+	s.config.Webhook.SECRET = "hook"
+
+	// A paper round trip in SBIN, bought at 800 and sold at 810
+	hook := ts.URL + "/api/webhooks/signal"
+	fake.SetQuote("NSE:SBIN", kitefake.Quote{InstrumentToken: 779521, LastPrice: 800})
+	if code := postJSON(t, hook, `{"secret":"hook","symbol":"NSE:SBIN","action":"buy","quantity":10}`, nil); code != http.StatusCreated {
+		t.Fatalf("buy status %d", code)
+	}
+	fake.SetQuote("NSE:SBIN", kitefake.Quote{InstrumentToken: 779521, LastPrice: 810})
+	if code := postJSON(t, hook, `{"secret":"hook","symbol":"NSE:SBIN","action":"sell","quantity":10}`, nil); code != http.StatusCreated {
+		t.Fatalf("sell status %d", code)
+	}
+
+	var report pnlResp
+	if code := getJSON(t, ts.URL+"/api/pnl?account=paper", &report); code != http.StatusOK {
+		t.Fatalf("pnl status %d", code)
+	}
+	z := charges.Zerodha()
+	want := z.Order("NSE", "SBIN", "MIS", "BUY", 10, 800).Add(z.Order("NSE", "SBIN", "MIS", "SELL", 10, 810))
+	if len(report.Positions) != 1 || report.Positions[0].Segment != charges.EquityIntraday || report.Realised != 100 ||
+		math.Abs(report.Charges.Total-want.Total) > 1e-9 || math.Abs(report.Net-(100-want.Total)) > 1e-9 {
+		t.Errorf("want 100 realised less %.4f charges, got %+v", want.Total, report)
+	}
+
+	// The paper account paid the same charges out of cash
+	var account struct {
+		Charges charges.Breakdown `json:"charges"`
+	}
+	getJSON(t, ts.URL+"/api/paper", &account)
+	if math.Abs(account.Charges.Total-want.Total) > 1e-9 {
+		t.Errorf("want paper charges %.4f, got %+v", want.Total, account.Charges)
+	}
+
+	// Kite positions without fills today carry no charges
+	fake.Positions = kiteconnect.Positions{Day: []kiteconnect.Position{{Exchange: "NFO", Tradingsymbol: "NIFTY25O2325050CE", Product: "NRML", Realised: 500}}}
+	if code := getJSON(t, ts.URL+"/api/pnl", &report); code != http.StatusOK {
+		t.Fatalf("live pnl status %d", code)
+	}
+	if report.Account != "live" || len(report.Positions) != 1 || report.Positions[0].Segment != charges.Options || report.Net != 500 {
+		t.Errorf("unexpected live report %+v", report)
+	}
+}
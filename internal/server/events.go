@@ -0,0 +1,161 @@
+// Event Bus Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/bus"
+	"friction-trading/internal/strategy"
+)
+
+// Accounts an order update can come from
+const (
+	accountLive  = "live"
+	accountPaper = "paper"
+)
+
+// orderEvent is an order update and the account it happened on
+type orderEvent struct {
+	Account string `json:"account"` // live or paper
+	kiteconnect.Order
+}
+
+// events fan ticks, candles, orders and signals out to every consumer, each on its own queue
+type events struct {
+	ticks   *bus.Bus[kitemodels.Tick]
+	candles *bus.Bus[strategy.CandleEvent]
+	orders  *bus.Bus[orderEvent]
+	signals *bus.Bus[strategy.Signal]
+}
+
+func newEvents() *events {
+	return &events{
+		ticks: bus.New(func(tick kitemodels.Tick) bus.Key {
+			return bus.Key{Token: tick.InstrumentToken}
+		}),
+		candles: bus.New(func(c strategy.CandleEvent) bus.Key {
+			return bus.Key{Token: c.Token, Strategy: c.Strategy}
+		}),
+		orders: bus.New(func(order orderEvent) bus.Key {
+			return bus.Key{Token: order.InstrumentToken, Strategy: order.Tag}
+		}),
+		signals: bus.New(func(sig strategy.Signal) bus.Key {
+			return bus.Key{Token: sig.Token, Strategy: sig.Strategy}
+		}),
+	}
+}
+
+// close ends every subscription
+func (e *events) close() {
+	e.ticks.Close()
+	e.candles.Close()
+	e.orders.Close()
+	e.signals.Close()
+}
+
+// Event Bus Stats :- published events, queue depth and drops per subscriber by topic
+func (s *Server) eventStatsHandler(w http.ResponseWriter, r *http.Request) {
+	SendJSONResp(map[string]bus.Stats{
+		"ticks":   s.events.ticks.Stats(),
+		"candles": s.events.candles.Stats(),
+		"orders":  s.events.orders.Stats(),
+		"signals": s.events.signals.Stats(),
+	}, nil, http.StatusOK, w)
+}
+
+// Event Stream :- server-sent candles, orders or signals, ?topic=orders&account=paper.
+// ?tokens= and ?strategies= narrow any topic, orders match their tag as the strategy.
+func (s *Server) eventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := bus.Options{Name: "event-stream " + r.RemoteAddr, Queue: streamQueue}
+	if raw := q.Get("tokens"); raw != "" {
+		tokens, err := parseTokens(raw)
+		if err != nil {
+			SendJSONResp(nil, err, http.StatusBadRequest, w)
+			return
+		}
+		opts.Tokens = tokens
+	}
+	if raw := q.Get("strategies"); raw != "" {
+		opts.Strategies = strings.Split(raw, ",")
+	}
+
+	account := q.Get("account")
+	if account != "" && account != accountLive && account != accountPaper {
+		SendJSONResp(nil, fmt.Errorf("unknown account %q, want live or paper", account), http.StatusBadRequest, w)
+		return
+	}
+
+	switch topic := q.Get("topic"); topic {
+	case "candles":
+		streamEvents(w, r, s.events.candles, topic, opts, nil)
+	case "orders":
+		streamEvents(w, r, s.events.orders, topic, opts, func(o orderEvent) bool {
+			return account == "" || o.Account == account
+		})
+	case "signals":
+		streamEvents(w, r, s.events.signals, topic, opts, nil)
+	default:
+		SendJSONResp(nil, fmt.Errorf("unknown topic %q, want candles, orders or signals", topic), http.StatusBadRequest, w)
+	}
+}
+
+// streamEvents writes what b publishes to a subscription of opts as server-sent events until the client leaves.
+// keep, when given, drops events the bus keys can't filter out.
+func streamEvents[T any](w http.ResponseWriter, r *http.Request, b *bus.Bus[T], topic string, opts bus.Options, keep func(T) bool) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	events := make(chan T, 1)
+	sub := b.Subscribe(opts, func(v T) {
+		if keep != nil && !keep(v) {
+			return
+		}
+		select {
+		case events <- v:
+		case <-ctx.Done():
+		}
+	})
+	defer sub.Close()
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Event stream can't flush :- %v\n", err)
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Done():
+			return
+		case v := <-events:
+			data, err := json.Marshal(v)
+			if err != nil {
+				log.Printf("Error marshalling %s event :- %v\n", topic, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", topic, data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
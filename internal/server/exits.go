@@ -0,0 +1,177 @@
+// Exit Rule Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/broker"
+	"friction-trading/internal/database"
+	"friction-trading/internal/exits"
+	"friction-trading/internal/strategy"
+)
+
+// addExitReq is the body of POST /api/exits
+type addExitReq struct {
+	Exchange      string      `json:"exchange"`
+	Tradingsymbol string      `json:"tradingsymbol"`
+	Product       string      `json:"product"`
+	Quantity      int         `json:"quantity"` // negative for shorts
+	EntryPrice    float64     `json:"entry_price"`
+	Stop          *exits.Spec `json:"stop"`
+	Target        *exits.Spec `json:"target"`
+	Trail         *exits.Spec `json:"trail"` // points or percent
+}
+
+// exitPosition closes the position behind a rule that fired, never more than is still
+// open, so a position trimmed by hand isn't flipped the other way
+func (s *Server) exitPosition(rule database.ExitRule, reason string) (string, error) {
+	positions, err := s.broker.Positions()
+	if err != nil {
+		return "", fmt.Errorf("fetch positions: %w", err)
+	}
+	open := 0
+	for _, p := range positions.Net {
+		if p.Exchange == rule.Exchange && p.Tradingsymbol == rule.Tradingsymbol && p.Product == rule.Product {
+			open += p.Quantity
+		}
+	}
+
+	qty := int(rule.Quantity)
+	if open == 0 || (open > 0) != (qty > 0) {
+		return "", exits.ErrFlat
+	}
+	if (qty > 0 && open < qty) || (qty < 0 && open > qty) {
+		qty = open
+	}
+
+	params := broker.ExitParams(kiteconnect.Position{
+		Exchange:      rule.Exchange,
+		Tradingsymbol: rule.Tradingsymbol,
+		Product:       rule.Product,
+		Quantity:      qty,
+	})
+	return s.placeOrder(kiteconnect.VarietyRegular, params)
+}
+
+// watchExits restarts the exit tick feed on the instruments with active rules
+func (s *Server) watchExits() {
+	s.exitFeedMu.Lock()
+	defer s.exitFeedMu.Unlock()
+
+	if s.exitFeedCancel != nil {
+		s.exitFeedCancel()
+		s.exitFeedCancel = nil
+	}
+
+	tokens := s.exits.Tokens()
+	if len(tokens) == 0 || s.AccessToken == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.exitFeedCancel = cancel
+	go func() {
+		if err := s.tickerFeed(ctx, tokens, s.exits.OnTick); err != nil {
+			log.Printf("Exit feed stopped :- %v\n", err)
+		}
+	}()
+}
+
+// stopExits stops watching exit rules, they stay saved for the next start
+func (s *Server) stopExits() {
+	s.exitFeedMu.Lock()
+	defer s.exitFeedMu.Unlock()
+	if s.exitFeedCancel != nil {
+		s.exitFeedCancel()
+		s.exitFeedCancel = nil
+	}
+}
+
+// resumeExits picks up exit rules saved before a restart, once logged in
+func (s *Server) resumeExits() {
+	if err := s.exits.Load(s.ctx); err != nil {
+		log.Printf("Error resuming exit rules :- %v\n", err)
+	}
+}
+
+// List Exit Rules
+func (s *Server) listExitsHandler(w http.ResponseWriter, r *http.Request) {
+	SendJSONResp(s.exits.Rules(), nil, http.StatusOK, w)
+}
+
+// Add Exit Rule :- stop-loss, target and trailing stop for a position
+func (s *Server) addExitHandler(w http.ResponseWriter, r *http.Request) {
+	var req addExitReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	exchange := strings.ToUpper(req.Exchange)
+	inst, err := s.Store.GetInstrumentBySymbol(r.Context(), database.GetInstrumentBySymbolParams{
+		Exchange:      exchange,
+		Tradingsymbol: req.Tradingsymbol,
+	})
+	if err != nil {
+		SendJSONResp(nil, err, sizingErrStatus(err), w)
+		return
+	}
+
+	// Indicator based exits are measured off recent candles
+	var candles []strategy.Candle
+	for _, spec := range []*exits.Spec{req.Stop, req.Target} {
+		if spec != nil && (spec.Mode == exits.ATR || spec.Mode == exits.Supertrend) && candles == nil {
+			if candles, err = s.recentCandles(int(inst.InstrumentToken)); err != nil {
+				SendJSONResp(nil, err, http.StatusBadGateway, w)
+				return
+			}
+		}
+	}
+
+	pos := exits.Position{
+		InstrumentToken: uint32(inst.InstrumentToken),
+		Exchange:        exchange,
+		Tradingsymbol:   req.Tradingsymbol,
+		Product:         req.Product,
+		Quantity:        req.Quantity,
+		EntryPrice:      req.EntryPrice,
+	}
+	rule, err := s.exits.Add(r.Context(), pos, req.Stop, req.Target, req.Trail, candles)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	SendJSONResp(rule, nil, http.StatusCreated, w)
+}
+
+// Cancel Exit Rule
+func (s *Server) cancelExitHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	rule, err := s.exits.Cancel(r.Context(), id)
+	if errors.Is(err, exits.ErrNotFound) {
+		SendJSONResp(nil, err, http.StatusNotFound, w)
+		return
+	}
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusInternalServerError, w)
+		return
+	}
+
+	SendJSONResp(rule, nil, http.StatusOK, w)
+}
@@ -0,0 +1,49 @@
+// Market Hours Routes
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"friction-trading/internal/calendar"
+)
+
+// errMarketClosed is returned when trading is attempted outside market hours
+func errMarketClosed(cal *calendar.Calendar, exchange string, now time.Time) error {
+	next := cal.NextOpen(exchange, now)
+	if next.IsZero() {
+		return fmt.Errorf("%s market is closed", exchange)
+	}
+	return fmt.Errorf("%s market is closed, opens at %s", exchange, next.Format("2006-01-02 15:04 MST"))
+}
+
+// Market Status :- sessions for today, open now and next open
+func (s *Server) marketStatusHandler(w http.ResponseWriter, r *http.Request) {
+	exchange := strings.ToUpper(r.URL.Query().Get("exchange"))
+	if exchange == "" {
+		exchange = calendar.NSE
+	}
+
+	now := s.now().In(calendar.IST)
+	sessions, err := s.calendar.Sessions(exchange, now)
+	if errors.Is(err, calendar.ErrUnknownExchange) {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	resp := map[string]any{
+		"exchange":  exchange,
+		"time":      now,
+		"open":      s.calendar.IsOpen(exchange, now),
+		"next_open": s.calendar.NextOpen(exchange, now),
+		"sessions":  sessions,
+	}
+	if session, ok := s.calendar.SessionAt(exchange, now); ok {
+		resp["session"] = session
+	}
+
+	SendJSONResp(resp, nil, http.StatusOK, w)
+}
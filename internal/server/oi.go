@@ -0,0 +1,346 @@
+// Open Interest Analytics Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/database"
+	"friction-trading/internal/oi"
+)
+
+const (
+	// How often a watched chain's OI is saved
+	oiSnapshotInterval = time.Minute
+	// Kite quotes at most this many instruments a call
+	quoteLimit = 500
+)
+
+// oiWatch streams one chain's ticks into a tracker
+type oiWatch struct {
+	Underlying string    `json:"underlying"`
+	Exchange   string    `json:"exchange"`
+	Expiry     time.Time `json:"expiry"`
+	Options    int       `json:"options"`
+	tracker    *oi.Tracker
+	cancel     context.CancelFunc
+}
+
+// oiWatchReq is the body of POST /api/analytics/oi/watch
+type oiWatchReq struct {
+	Underlying string `json:"underlying"`
+	Exchange   string `json:"exchange"` // NFO by default
+	Expiry     string `json:"expiry"`   // weekly, monthly or YYYY-MM-DD
+}
+
+// oiResp is the chain's OI picture, live when it's being watched
+type oiResp struct {
+	Underlying string    `json:"underlying"`
+	Expiry     time.Time `json:"expiry"`
+	Live       bool      `json:"live"`
+	oi.Analysis
+}
+
+// oiPoint is the chain at one snapshot
+type oiPoint struct {
+	CapturedAt time.Time `json:"captured_at"`
+	oi.Analysis
+}
+
+func oiKey(underlying string, expiry time.Time) string {
+	return underlying + ":" + expiry.Format(time.DateOnly)
+}
+
+func chainOptions(chain []*database.Instrument) []oi.Option {
+	options := make([]oi.Option, 0, len(chain))
+	for _, in := range chain {
+		options = append(options, oi.Option{
+			Token:         uint32(in.InstrumentToken),
+			Tradingsymbol: in.Tradingsymbol,
+			Strike:        in.Strike,
+			Type:          in.InstrumentType,
+		})
+	}
+	return options
+}
+
+// oiChain resolves the chain asked for by the underlying, exchange and expiry query params
+func (s *Server) oiChain(ctx context.Context, underlying, exchange, expiry string) ([]oi.Option, time.Time, error) {
+	if underlying == "" {
+		return nil, time.Time{}, errors.New("underlying is required")
+	}
+	chain, at, err := s.optionChain(ctx, underlying, exchange, expiry)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return chainOptions(chain), at, nil
+}
+
+// oiSnapshots are today's saved snapshots of a chain
+func (s *Server) oiSnapshots(ctx context.Context, underlying string, expiry time.Time) ([]*database.OiSnapshot, error) {
+	midnight := s.today()
+	since := time.Date(midnight.Year(), midnight.Month(), midnight.Day(), 0, 0, 0, 0, calendar.IST)
+	return s.Store.ListOISnapshots(ctx, database.ListOISnapshotsParams{
+		Underlying: underlying,
+		Expiry:     pgtype.Timestamp{Time: expiry, Valid: true},
+		CapturedAt: pgtype.Timestamptz{Time: since, Valid: true},
+	})
+}
+
+// oiBaseline is each option's OI at the day's first snapshot
+func oiBaseline(snapshots []*database.OiSnapshot) map[uint32]int64 {
+	baseline := map[uint32]int64{}
+	for _, snap := range snapshots {
+		if !snap.CapturedAt.Time.Equal(snapshots[0].CapturedAt.Time) {
+			break
+		}
+		baseline[uint32(snap.InstrumentToken)] = snap.Oi
+	}
+	return baseline
+}
+
+// chainQuotes fetches full quotes for every option of a chain
+func (s *Server) chainQuotes(exchange string, chain []oi.Option) (map[uint32]oi.Quote, error) {
+	keys := make([]string, len(chain))
+	for i, o := range chain {
+		keys[i] = exchange + ":" + o.Tradingsymbol
+	}
+	out := make(map[uint32]oi.Quote, len(chain))
+	for batch := range slices.Chunk(keys, quoteLimit) {
+		quotes, err := s.KiteClient.GetQuote(batch...)
+		if err != nil {
+			return nil, fmt.Errorf("fetch chain quotes: %w", err)
+		}
+		for _, q := range quotes {
+			out[uint32(q.InstrumentToken)] = oi.Quote{
+				OI:        int64(q.OI),
+				Volume:    int64(q.Volume),
+				LastPrice: q.LastPrice,
+				Close:     q.OHLC.Close,
+			}
+		}
+	}
+	return out, nil
+}
+
+// snapshotOI saves the latest quotes of a watched chain in one batch, so a failed save
+// leaves no half snapshot behind
+func (s *Server) snapshotOI(ctx context.Context, w *oiWatch) error {
+	at := pgtype.Timestamptz{Time: s.now(), Valid: true}
+	quotes := w.tracker.Quotes()
+	var rows []database.CreateOISnapshotsParams
+	for _, o := range w.tracker.Chain() {
+		q, ok := quotes[o.Token]
+		if !ok {
+			continue
+		}
+		rows = append(rows, database.CreateOISnapshotsParams{
+			Underlying:      w.Underlying,
+			Expiry:          pgtype.Timestamp{Time: w.Expiry, Valid: true},
+			Strike:          o.Strike,
+			OptionType:      o.Type,
+			InstrumentToken: int64(o.Token),
+			Oi:              q.OI,
+			Volume:          q.Volume,
+			LastPrice:       q.LastPrice,
+			Close:           q.Close,
+			CapturedAt:      at,
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if _, err := s.Store.CreateOISnapshots(ctx, rows); err != nil {
+		return fmt.Errorf("save %d options: %w", len(rows), err)
+	}
+	return nil
+}
+
+// runOIWatch streams the chain and saves it every interval the market is open
+func (s *Server) runOIWatch(ctx context.Context, w *oiWatch) {
+	go func() {
+		if err := s.tickerFeed(ctx, w.tracker.Tokens(), w.tracker.OnTick); err != nil {
+			log.Printf("OI feed for %s stopped :- %v\n", w.Underlying, err)
+		}
+	}()
+
+	ticker := time.NewTicker(oiSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.calendar.IsOpen(w.Exchange, s.now()) {
+				continue
+			}
+			if err := s.snapshotOI(ctx, w); err != nil {
+				log.Printf("Error saving OI snapshot for %s :- %v\n", w.Underlying, err)
+			}
+		}
+	}
+}
+
+// stopOIWatches stops every chain being watched
+func (s *Server) stopOIWatches() {
+	s.oiMu.Lock()
+	defer s.oiMu.Unlock()
+	for key, w := range s.oiWatches {
+		w.cancel()
+		delete(s.oiWatches, key)
+	}
+}
+
+// OI Analytics :- PCR, max pain and OI build-up by strike for a chain
+func (s *Server) oiHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	underlying := strings.ToUpper(q.Get("underlying"))
+	chain, expiry, err := s.oiChain(r.Context(), underlying, q.Get("exchange"), q.Get("expiry"))
+	if err != nil {
+		SendJSONResp(nil, err, basketErrStatus(err), w)
+		return
+	}
+
+	s.oiMu.Lock()
+	watch, live := s.oiWatches[oiKey(underlying, expiry)]
+	s.oiMu.Unlock()
+	if live {
+		SendJSONResp(oiResp{Underlying: underlying, Expiry: expiry, Live: true, Analysis: watch.tracker.Analysis()}, nil, http.StatusOK, w)
+		return
+	}
+
+	snapshots, err := s.oiSnapshots(r.Context(), underlying, expiry)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusInternalServerError, w)
+		return
+	}
+	quotes, err := s.chainQuotes(chainExchange(q.Get("exchange"), underlying), chain)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadGateway, w)
+		return
+	}
+	analysis := oi.Analyse(chain, quotes, oiBaseline(snapshots))
+	SendJSONResp(oiResp{Underlying: underlying, Expiry: expiry, Analysis: analysis}, nil, http.StatusOK, w)
+}
+
+// OI History :- today's saved snapshots of a chain, OI change from the first
+func (s *Server) oiHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	underlying := strings.ToUpper(q.Get("underlying"))
+	chain, expiry, err := s.oiChain(r.Context(), underlying, q.Get("exchange"), q.Get("expiry"))
+	if err != nil {
+		SendJSONResp(nil, err, basketErrStatus(err), w)
+		return
+	}
+
+	snapshots, err := s.oiSnapshots(r.Context(), underlying, expiry)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusInternalServerError, w)
+		return
+	}
+
+	baseline := oiBaseline(snapshots)
+	points := []oiPoint{}
+	for i := 0; i < len(snapshots); {
+		at := snapshots[i].CapturedAt.Time
+		quotes := map[uint32]oi.Quote{}
+		for ; i < len(snapshots) && snapshots[i].CapturedAt.Time.Equal(at); i++ {
+			snap := snapshots[i]
+			quotes[uint32(snap.InstrumentToken)] = oi.Quote{OI: snap.Oi, Volume: snap.Volume, LastPrice: snap.LastPrice, Close: snap.Close}
+		}
+		points = append(points, oiPoint{CapturedAt: at, Analysis: oi.Analyse(chain, quotes, baseline)})
+	}
+	SendJSONResp(points, nil, http.StatusOK, w)
+}
+
+// List OI Watches
+func (s *Server) listOIWatchesHandler(w http.ResponseWriter, r *http.Request) {
+	s.oiMu.Lock()
+	defer s.oiMu.Unlock()
+	watches := make([]*oiWatch, 0, len(s.oiWatches))
+	for _, watch := range s.oiWatches {
+		watches = append(watches, watch)
+	}
+	SendJSONResp(watches, nil, http.StatusOK, w)
+}
+
+// Watch OI :- stream a chain in full mode and save its OI through the day
+func (s *Server) watchOIHandler(w http.ResponseWriter, r *http.Request) {
+	var req oiWatchReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+	underlying := strings.ToUpper(req.Underlying)
+	chain, expiry, err := s.oiChain(r.Context(), underlying, req.Exchange, req.Expiry)
+	if err != nil {
+		SendJSONResp(nil, err, basketErrStatus(err), w)
+		return
+	}
+	if len(chain) == 0 {
+		SendJSONResp(nil, fmt.Errorf("no options for %s expiring %s", underlying, expiry.Format(time.DateOnly)), http.StatusNotFound, w)
+		return
+	}
+
+	// Carry on from today's snapshots after a restart
+	snapshots, err := s.oiSnapshots(r.Context(), underlying, expiry)
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusInternalServerError, w)
+		return
+	}
+
+	s.oiMu.Lock()
+	defer s.oiMu.Unlock()
+	key := oiKey(underlying, expiry)
+	if watch, ok := s.oiWatches[key]; ok {
+		SendJSONResp(watch, nil, http.StatusOK, w)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	watch := &oiWatch{
+		Underlying: underlying,
+		Exchange:   chainExchange(req.Exchange, underlying),
+		Expiry:     expiry,
+		Options:    len(chain),
+		tracker:    oi.NewTracker(chain, oiBaseline(snapshots)),
+		cancel:     cancel,
+	}
+	s.oiWatches[key] = watch
+	go s.runOIWatch(ctx, watch)
+
+	SendJSONResp(watch, nil, http.StatusCreated, w)
+}
+
+// Stop OI Watch :- saved snapshots are kept
+func (s *Server) stopOIWatchHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	underlying := strings.ToUpper(q.Get("underlying"))
+	_, expiry, err := s.oiChain(r.Context(), underlying, q.Get("exchange"), q.Get("expiry"))
+	if err != nil {
+		SendJSONResp(nil, err, basketErrStatus(err), w)
+		return
+	}
+
+	s.oiMu.Lock()
+	defer s.oiMu.Unlock()
+	key := oiKey(underlying, expiry)
+	watch, ok := s.oiWatches[key]
+	if !ok {
+		SendJSONResp(nil, fmt.Errorf("%s is not being watched", key), http.StatusNotFound, w)
+		return
+	}
+	watch.cancel()
+	delete(s.oiWatches, key)
+	SendJSONResp(watch, nil, http.StatusOK, w)
+}
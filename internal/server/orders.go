@@ -0,0 +1,174 @@
+// Order and Risk Routes
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/broker"
+	"friction-trading/internal/database"
+	"friction-trading/internal/risk"
+)
+
+// placeOrderReq is the body of POST /api/orders
+type placeOrderReq struct {
+	Variety         string  `json:"variety"`
+	Exchange        string  `json:"exchange"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	TransactionType string  `json:"transaction_type"`
+	OrderType       string  `json:"order_type"`
+	Product         string  `json:"product"`
+	Validity        string  `json:"validity"`
+	Quantity        int     `json:"quantity"`
+	Price           float64 `json:"price"`
+	TriggerPrice    float64 `json:"trigger_price"`
+	Tag             string  `json:"tag"`
+
+	// Sizes the quantity when set, e.g. {"policy": "fixed_lots", "params": {"lots": 2}}
+	Sizing *sizingReq `json:"sizing"`
+}
+
+func (req placeOrderReq) params() kiteconnect.OrderParams {
+	validity := req.Validity
+	if validity == "" {
+		validity = kiteconnect.ValidityDay
+	}
+	return kiteconnect.OrderParams{
+		Exchange:        strings.ToUpper(req.Exchange),
+		Tradingsymbol:   req.Tradingsymbol,
+		TransactionType: req.TransactionType,
+		OrderType:       req.OrderType,
+		Product:         req.Product,
+		Validity:        validity,
+		Quantity:        req.Quantity,
+		Price:           req.Price,
+		TriggerPrice:    req.TriggerPrice,
+		Tag:             req.Tag,
+	}
+}
+
+// placeOrder is the only way orders leave the server, every order passes risk.
+// Market orders are priced off the LTP for notional checks.
+func (s *Server) placeOrder(variety string, params kiteconnect.OrderParams) (string, error) {
+	return s.placeOrderWith(s.risk, variety, params)
+}
+
+// placeOrderWith places through a given risk engine, the paper account's or Kite's
+func (s *Server) placeOrderWith(engine *risk.Engine, variety string, params kiteconnect.OrderParams) (string, error) {
+	if variety == "" {
+		variety = kiteconnect.VarietyRegular
+	}
+	params.Tag = broker.OwnTag(params.Tag)
+
+	ltp := params.Price
+	if params.OrderType == kiteconnect.OrderTypeMarket || ltp == 0 {
+		var err error
+		if ltp, err = s.ltp(params.Exchange, params.Tradingsymbol); err != nil {
+			return "", err
+		}
+	}
+
+	orderID, err := engine.PlaceOrder(variety, params, ltp)
+	if err != nil {
+		log.Printf("Order %s %d %s rejected :- %v\n", params.TransactionType, params.Quantity, params.Tradingsymbol, err)
+		return "", err
+	}
+	log.Printf("Order %s placed :- %s %d %s\n", orderID, params.TransactionType, params.Quantity, params.Tradingsymbol)
+	return orderID, nil
+}
+
+// map risk errors to HTTP status codes
+func riskErrStatus(err error) int {
+	var violation *risk.Violation
+	switch {
+	case errors.Is(err, risk.ErrKilled):
+		return http.StatusLocked
+	case errors.As(err, &violation):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// Place Order :- checked by the risk engine first
+func (s *Server) placeOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req placeOrderReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	params := req.params()
+	if req.Sizing != nil {
+		qty, err := s.sizeOrder(r.Context(), params, *req.Sizing)
+		if err != nil {
+			SendJSONResp(nil, err, sizingErrStatus(err), w)
+			return
+		}
+		params.Quantity = qty
+	}
+
+	orderID, err := s.placeOrder(req.Variety, params)
+	if err != nil {
+		var violation *risk.Violation
+		if errors.As(err, &violation) {
+			// Send every reason back, not just the message
+			SendJSONResp(violation, err, riskErrStatus(err), w)
+			return
+		}
+		SendJSONResp(nil, err, riskErrStatus(err), w)
+		return
+	}
+
+	SendJSONResp(map[string]string{"order_id": orderID}, nil, http.StatusCreated, w)
+}
+
+// Risk Status :- limits and kill switch
+func (s *Server) riskStatusHandler(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]any{
+		"limits": s.risk.Limits(),
+		"killed": s.risk.Killed(),
+	}
+	SendJSONResp(resp, nil, http.StatusOK, w)
+}
+
+// Kill Switch :- halt trading, cancel open orders, square off intraday positions
+func (s *Server) killSwitchHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Kill switch engaged 🛑")
+
+	// Strategies must not keep trading after the kill
+	s.strategies.StopAll()
+
+	// Nor exit rules on the intraday positions it squares off
+	if _, err := s.exits.CancelMatching(r.Context(), func(rule database.ExitRule) bool {
+		return rule.Product == kiteconnect.ProductMIS
+	}); err != nil {
+		log.Printf("Error cancelling exit rules on kill :- %v\n", err)
+	}
+
+	// The paper account stops with it, webhook signals mustn't carry on trading either
+	if _, err := s.paperRisk.Kill(); err != nil {
+		log.Printf("Paper kill switch incomplete :- %v\n", err)
+	}
+
+	report, err := s.risk.Kill()
+	if err != nil {
+		log.Printf("Kill switch incomplete :- %v\n", err)
+		SendJSONResp(report, err, http.StatusBadGateway, w)
+		return
+	}
+
+	SendJSONResp(report, nil, http.StatusOK, w)
+}
+
+// Resume Trading :- lift the kill switch
+func (s *Server) resumeTradingHandler(w http.ResponseWriter, r *http.Request) {
+	s.risk.Resume()
+	s.paperRisk.Resume()
+	SendJSONResp(map[string]any{"killed": nil}, nil, http.StatusOK, w)
+}
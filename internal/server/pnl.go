@@ -0,0 +1,81 @@
+// P&L Report Routes
+package server
+
+import (
+	"net/http"
+
+	"friction-trading/internal/broker"
+	"friction-trading/internal/charges"
+)
+
+// pnlRow is one position's realised P&L for the day, less the charges of its fills
+type pnlRow struct {
+	Exchange      string            `json:"exchange"`
+	Tradingsymbol string            `json:"tradingsymbol"`
+	Product       string            `json:"product"`
+	Segment       charges.Segment   `json:"segment"`
+	Quantity      int               `json:"quantity"` // still open
+	Realised      float64           `json:"realised"`
+	Charges       charges.Breakdown `json:"charges"`
+	Net           float64           `json:"net"`
+}
+
+// pnlResp is the realised P&L report
+type pnlResp struct {
+	Account   string            `json:"account"`
+	Positions []pnlRow          `json:"positions"`
+	Realised  float64           `json:"realised"`
+	Charges   charges.Breakdown `json:"charges"`
+	Net       float64           `json:"net"`
+}
+
+type positionKey struct{ exchange, tradingsymbol, product string }
+
+// Realised P&L :- the day's booked P&L per position, net of brokerage and taxes.
+// ?account=paper reports the paper account, Kite otherwise.
+func (s *Server) pnlHandler(w http.ResponseWriter, r *http.Request) {
+	account, b := "live", broker.Broker(s.broker)
+	if r.URL.Query().Get("account") == "paper" {
+		account, b = "paper", s.paper
+	}
+
+	positions, err := b.Positions()
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadGateway, w)
+		return
+	}
+	orders, err := b.Orders()
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadGateway, w)
+		return
+	}
+
+	// Every fill is charged, positions still open included
+	paid := map[positionKey]charges.Breakdown{}
+	for _, o := range orders {
+		if o.FilledQuantity <= 0 {
+			continue
+		}
+		key := positionKey{o.Exchange, o.TradingSymbol, o.Product}
+		paid[key] = paid[key].Add(s.charges.Order(o.Exchange, o.TradingSymbol, o.Product, o.TransactionType, o.FilledQuantity, o.AveragePrice))
+	}
+
+	resp := pnlResp{Account: account, Positions: []pnlRow{}}
+	for _, pos := range positions.Day {
+		row := pnlRow{
+			Exchange:      pos.Exchange,
+			Tradingsymbol: pos.Tradingsymbol,
+			Product:       pos.Product,
+			Segment:       charges.SegmentOf(pos.Exchange, pos.Tradingsymbol, pos.Product),
+			Quantity:      pos.Quantity,
+			Realised:      pos.Realised,
+			Charges:       paid[positionKey{pos.Exchange, pos.Tradingsymbol, pos.Product}],
+		}
+		row.Net = row.Realised - row.Charges.Total
+		resp.Positions = append(resp.Positions, row)
+		resp.Realised += row.Realised
+		resp.Charges = resp.Charges.Add(row.Charges)
+	}
+	resp.Net = resp.Realised - resp.Charges.Total
+	SendJSONResp(resp, nil, http.StatusOK, w)
+}
@@ -0,0 +1,128 @@
+// Kite Order Postbacks
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+// How long a seen order update is remembered for de-duplication
+const orderUpdateTTL = 24 * time.Hour
+
+// postbackMeta holds the raw fields needed to verify a postback
+type postbackMeta struct {
+	OrderID        string `json:"order_id"`
+	OrderTimestamp string `json:"order_timestamp"`
+	Checksum       string `json:"checksum"`
+}
+
+// PostbackChecksum computes SHA-256(order_id + order_timestamp + api_secret) as hex
+func PostbackChecksum(orderID, orderTimestamp, apiSecret string) string {
+	sum := sha256.Sum256([]byte(orderID + orderTimestamp + apiSecret))
+	return hex.EncodeToString(sum[:])
+}
+
+// verify checks the postback checksum against the api secret
+func (p postbackMeta) verify(apiSecret string) bool {
+	if p.OrderID == "" || p.Checksum == "" {
+		return false
+	}
+	want := PostbackChecksum(p.OrderID, p.OrderTimestamp, apiSecret)
+	return subtle.ConstantTimeCompare([]byte(want), []byte(p.Checksum)) == 1
+}
+
+// orderDeduper drops order updates which already came in via the ticker or a postback
+type orderDeduper struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	ttl  time.Duration
+}
+
+func newOrderDeduper(ttl time.Duration) *orderDeduper {
+	return &orderDeduper{
+		seen: map[string]time.Time{},
+		ttl:  ttl,
+	}
+}
+
+// firstSeen reports whether this order state hasn't been seen before and marks it seen
+func (d *orderDeduper) firstSeen(order kiteconnect.Order) bool {
+	key := fmt.Sprintf("%s|%s|%v", order.OrderID, order.Status, order.FilledQuantity)
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop expired entries
+	for k, at := range d.seen {
+		if now.Sub(at) > d.ttl {
+			delete(d.seen, k)
+		}
+	}
+
+	if _, ok := d.seen[key]; ok {
+		return false
+	}
+	d.seen[key] = now
+	return true
+}
+
+// Order update pipeline :- shared by the ticker and Kite postbacks
+func (s *Server) onOrderUpdate(order kiteconnect.Order) {
+	if !s.orderUpdates.firstSeen(order) {
+		return
+	}
+
+	log.Printf("Order: %s %s %s %s filled %v/%v @ %.2f\n", order.OrderID, order.Status,
+		order.TransactionType, order.TradingSymbol, order.FilledQuantity, order.Quantity, order.AveragePrice)
+	s.events.orders.Publish(orderEvent{Account: accountLive, Order: order})
+}
+
+// Paper order updates :- published apart from Kite's, the paper account reports each once
+func (s *Server) onPaperUpdate(order kiteconnect.Order) {
+	log.Printf("Paper order: %s %s %s %s filled %v/%v @ %.2f\n", order.OrderID, order.Status,
+		order.TransactionType, order.TradingSymbol, order.FilledQuantity, order.Quantity, order.AveragePrice)
+	s.events.orders.Publish(orderEvent{Account: accountPaper, Order: order})
+}
+
+// Kite Postback Handler :- order updates pushed by Kite over HTTP
+func (s *Server) kitePostbackHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Error reading postback", http.StatusBadRequest)
+		return
+	}
+
+	var meta postbackMeta
+	if err := json.Unmarshal(body, &meta); err != nil {
+		http.Error(w, "Invalid postback payload", http.StatusBadRequest)
+		return
+	}
+
+	if !meta.verify(s.config.Kite.API_SECRET) {
+		log.Printf("Postback checksum mismatch for order %q\n", meta.OrderID)
+		http.Error(w, "Invalid checksum", http.StatusForbidden)
+		return
+	}
+
+	var order kiteconnect.Order
+	if err := json.Unmarshal(body, &order); err != nil {
+		log.Printf("Error decoding postback order. Err: %v\n", err)
+		http.Error(w, "Invalid postback payload", http.StatusBadRequest)
+		return
+	}
+
+	s.onOrderUpdate(order)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/bus"
+	"friction-trading/internal/config"
+)
+
+func newPostbackServer() *Server {
+	c := &config.Config{}
+	c.Kite.API_SECRET = "secret"
+	return &Server{config: c, orderUpdates: newOrderDeduper(orderUpdateTTL), events: newEvents()}
+}
+
+func postbackBody(checksum string) []byte {
+	return []byte(fmt.Sprintf(`{"order_id":"220303000308932","order_timestamp":"2022-03-03 09:24:25",`+
+		`"status":"COMPLETE","tradingsymbol":"SBIN","transaction_type":"BUY","quantity":1,`+
+		`"filled_quantity":1,"average_price":470,"parent_order_id":null,"tag":null,"checksum":"%s"}`, checksum))
+}
+
+func TestKitePostbackChecksum(t *testing.T) {
+	s := newPostbackServer()
+	valid := PostbackChecksum("220303000308932", "2022-03-03 09:24:25", "secret")
+
+	tests := []struct {
+		name     string
+		checksum string
+		want     int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"tampered", PostbackChecksum("220303000308932", "2022-03-03 09:24:25", "other"), http.StatusForbidden},
+		{"missing", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/postback/kite/", bytes.NewReader(postbackBody(tt.checksum)))
+			rec := httptest.NewRecorder()
+			s.kitePostbackHandler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("want status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestOrderDeduper(t *testing.T) {
+	d := newOrderDeduper(orderUpdateTTL)
+	open := kiteconnect.Order{OrderID: "1", Status: "OPEN"}
+	complete := kiteconnect.Order{OrderID: "1", Status: "COMPLETE", FilledQuantity: 1}
+
+	if !d.firstSeen(open) {
+		t.Error("first OPEN update should pass")
+	}
+	if !d.firstSeen(complete) {
+		t.Error("COMPLETE update should pass")
+	}
+	// Same update arriving again, e.g. via the ticker after a postback
+	if d.firstSeen(complete) {
+		t.Error("duplicate COMPLETE update should be dropped")
+	}
+}
+
+func TestOrderUpdatesPublished(t *testing.T) {
+	s := newPostbackServer()
+	defer s.events.close()
+	orders := make(chan orderEvent, 2)
+	s.events.orders.Subscribe(bus.Options{Name: "test"}, func(o orderEvent) { orders <- o })
+
+	s.onOrderUpdate(kiteconnect.Order{OrderID: "1", Status: "COMPLETE"})
+	s.onOrderUpdate(kiteconnect.Order{OrderID: "1", Status: "COMPLETE"})
+
+	select {
+	case o := <-orders:
+		if o.OrderID != "1" || o.Account != accountLive {
+			t.Errorf("unexpected order %+v", o)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("order update never published")
+	}
+	select {
+	case o := <-orders:
+		t.Errorf("want duplicates held back, got %+v", o)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -51,6 +51,9 @@ func (s *Server) RegisterRoutes() http.Handler {
 	r.Get("/check-login", s.checkLogin)
 	r.Get("/api/user/callback/kite/", s.loginCallbackHandler)
 
+	// Kite order postbacks, verified by checksum
+	r.Post("/api/user/postback/kite/", s.kitePostbackHandler)
+
 	// "/api" routes
 	r.Route(`/api`, func(r chi.Router) {
 		// Check Whether AccessToken is Fetched or not
@@ -62,6 +65,56 @@ func (s *Server) RegisterRoutes() http.Handler {
 		r.Get("/watch-nifty50-option", s.watchNifty50OptionHandler)
 		r.Get("/instruments", s.fetchAllInstruments)
 		r.Get("/symbol_search", s.searchSymbol)
+		r.Get("/instruments/resolve", s.resolveInstrumentHandler)
+		r.Get("/ticker", s.tickerStatsHandler)
+		r.Get("/events", s.eventStatsHandler)
+		r.Get("/events/stream", s.eventStreamHandler)
+
+		// Strategy Routes
+		r.Get("/strategies", s.listStrategiesHandler)
+		r.Post("/strategies", s.startStrategyHandler)
+		r.Get("/strategies/definitions", s.listDefinitionsHandler)
+		r.Get("/strategies/{id}", s.getStrategyHandler)
+		r.Post("/strategies/{id}/stop", s.stopStrategyHandler)
+		r.Get("/signals", s.listSignalsHandler)
+		r.Post("/backtests", s.backtestHandler)
+		r.Post("/backtests/optimise", s.optimiseHandler)
+		r.Post("/backtests/montecarlo", s.monteCarloHandler)
+
+		// Orders and Risk
+		r.Post("/orders", s.placeOrderHandler)
+		r.Post("/sizing", s.sizingPreviewHandler)
+		r.Post("/baskets", s.placeBasketHandler)
+		r.Post("/baskets/preview", s.basketPreviewHandler)
+
+		// Exit Rules
+		r.Get("/exits", s.listExitsHandler)
+		r.Post("/exits", s.addExitHandler)
+		r.Delete("/exits/{id}", s.cancelExitHandler)
+		r.Get("/risk", s.riskStatusHandler)
+		r.Post("/risk/kill", s.killSwitchHandler)
+		r.Post("/risk/resume", s.resumeTradingHandler)
+		r.Get("/squareoff", s.squareoffStatusHandler)
+		r.Post("/squareoff", s.squareoffHandler)
+
+		// Signal Webhooks
+		r.Post("/webhooks/signal", s.signalWebhookHandler)
+		r.Get("/paper", s.paperAccountHandler)
+
+		// P&L Report
+		r.Get("/pnl", s.pnlHandler)
+
+		// Option Analytics
+		r.Post("/analytics/payoff", s.payoffHandler)
+		r.Get("/analytics/oi", s.oiHandler)
+		r.Get("/analytics/oi/history", s.oiHistoryHandler)
+		r.Get("/analytics/oi/watch", s.listOIWatchesHandler)
+		r.Post("/analytics/oi/watch", s.watchOIHandler)
+		r.Delete("/analytics/oi/watch", s.stopOIWatchHandler)
+
+		// Market Hours
+		r.Get("/market/status", s.marketStatusHandler)
+		r.Get("/market/stream", s.marketStreamHandler)
 	})
 
 	return r
@@ -119,6 +172,12 @@ func (s *Server) loginCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	s.KiteClient.SetAccessToken(s.AccessToken)
 	s.AccessTokenCh <- s.AccessToken
 
+	// Exit rules carry on across restarts
+	s.resumeExits()
+
+	// Declared strategies need Kite for instruments and ticks
+	s.startDefinitions()
+
 	// Respond to the client
 	jsonResp, err := json.Marshal(map[string]string{"message": "Login Successful triggered"})
 	if err != nil {
@@ -6,14 +6,25 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	kiteconnect "github.com/zerodha/gokiteconnect/v4"
-	kiteticker "github.com/zerodha/gokiteconnect/v4/ticker"
 
+	"friction-trading/internal/basket"
+	"friction-trading/internal/broker"
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/charges"
 	"friction-trading/internal/config"
 	"friction-trading/internal/database"
+	"friction-trading/internal/exits"
+	"friction-trading/internal/feed"
+	"friction-trading/internal/resolver"
+	"friction-trading/internal/risk"
+	"friction-trading/internal/squareoff"
+	"friction-trading/internal/strategy"
 )
 
 type Server struct {
@@ -26,13 +37,64 @@ type Server struct {
 	AccessToken   string // Access token for Kite Connect API 🔥
 	AccessTokenCh chan string
 
-	// Ticker
-	ticker          *kiteticker.Ticker
-	tickCtxCancelFn context.CancelFunc
+	// Kite ticker root, nil uses Kite's default
+	tickerURL *url.URL
+
+	// Orders go to the broker through the risk engine
+	broker broker.Broker
+	risk   *risk.Engine
+
+	// In-memory account webhook signals trade unless configured live
+	paper     *broker.Paper
+	paperRisk *risk.Engine
+
+	// Multi-leg option orders, legs go through placeOrder
+	baskets *basket.Executor
+
+	// Server managed stop-loss, target and trailing stops
+	exits          *exits.Engine
+	exitFeedMu     sync.Mutex
+	exitFeedCancel context.CancelFunc
+
+	// Option chains streamed for OI analytics
+	oiMu      sync.Mutex
+	oiWatches map[string]*oiWatch
+
+	// Intraday square-off before exchange cut-offs
+	squareoff *squareoff.Scheduler
+
+	// Instrument specs to tokens, from the instruments table
+	resolver *resolver.Resolver
+
+	// Shared ticker connections every tick consumer subscribes through
+	ticks *feed.Manager
+
+	// Ticks, orders and signals for any number of independent consumers
+	events *events
+
+	// Running Strategies
+	strategies *strategy.Manager
+
+	// Strategies declared in the strategy file, started after login
+	definitions []strategy.Definition
+	deployMu    sync.Mutex
+	deployments map[string]*deployment
+
+	// Market Calendar
+	calendar *calendar.Calendar
+
+	// Brokerage and taxes by segment
+	charges *charges.Table
+
+	// Order updates from the ticker and postbacks
+	orderUpdates *orderDeduper
 
 	// Base Context
 	ctx context.Context
 
+	// Clock for market hours checks
+	now func() time.Time
+
 	// Config
 	config *config.Config
 }
@@ -47,14 +109,8 @@ func NewServer(c *config.Config) *Server {
 
 	conn := database.Connect(c)
 
-	NewServer := &Server{
-		port:          port,
-		Store:         database.NewStore(conn),
-		KiteClient:    kc,
-		ctx:           context.Background(),
-		AccessTokenCh: make(chan string, 1),
-		config:        c,
-	}
+	NewServer := newServer(c, database.NewStore(conn), kc)
+	NewServer.port = port
 
 	// Declare Server config
 	server := &http.Server{
@@ -73,5 +129,115 @@ func NewServer(c *config.Config) *Server {
 
 	NewServer.HttpServer = server
 
+	// Square off intraday positions at the configured cut-offs
+	go NewServer.squareoff.Run(NewServer.ctx)
+	// Fill resting paper orders as the market reaches them
+	go NewServer.paper.Run(NewServer.ctx, paperMatchInterval)
+
+	return NewServer
+}
+
+// newServer wires a server around a store and Kite client, without listening
+func newServer(c *config.Config, store database.Store, kc *kiteconnect.Client) *Server {
+	// Load Exchange Holidays
+	var err error
+	marketCalendar := calendar.New()
+	if c.Calendar.HOLIDAYS != "" {
+		marketCalendar, err = calendar.Load(c.Calendar.HOLIDAYS)
+		if err != nil {
+			log.Fatalf("error loading market calendar. Err: %v", err)
+		}
+	}
+
+	// Charges, Zerodha's unless a rate table overrides them
+	rates := charges.Zerodha()
+	if c.Charges.RATES != "" {
+		rates, err = charges.Load(c.Charges.RATES)
+		if err != nil {
+			log.Fatalf("error loading charges. Err: %v", err)
+		}
+	}
+
+	NewServer := &Server{
+		Store:         store,
+		KiteClient:    kc,
+		ctx:           context.Background(),
+		now:           time.Now,
+		AccessTokenCh: make(chan string, 1),
+		orderUpdates:  newOrderDeduper(orderUpdateTTL),
+		oiWatches:     map[string]*oiWatch{},
+		deployments:   map[string]*deployment{},
+		config:        c,
+		calendar:      marketCalendar,
+		charges:       rates,
+	}
+
+	// Pre-trade risk on the Kite account
+	NewServer.broker = broker.NewKite(kc)
+	limits := risk.Limits{
+		MaxDailyLoss:         c.Risk.MAX_DAILY_LOSS,
+		MaxOpenPositions:     c.Risk.MAX_OPEN_POSITIONS,
+		MaxQtyPerSymbol:      c.Risk.MAX_QTY_PER_SYMBOL,
+		MaxOrderNotional:     c.Risk.MAX_ORDER_NOTIONAL,
+		MaxMarginUtilisation: c.Risk.MAX_MARGIN_UTILISATION,
+		MaxNetDelta:          c.Risk.MAX_NET_DELTA,
+		MaxNetVega:           c.Risk.MAX_NET_VEGA,
+	}
+	NewServer.risk = risk.New(NewServer.broker, limits)
+	NewServer.risk.SetExposure(NewServer.netGreeks)
+
+	// Paper account for webhook signals, under the same limits
+	capital := c.Webhook.PAPER_CAPITAL
+	if capital <= 0 {
+		capital = defaultPaperCapital
+	}
+	NewServer.paper = broker.NewPaper(capital, NewServer.ltp)
+	NewServer.paper.SetClock(func() time.Time { return NewServer.now() })
+	NewServer.paper.OnUpdate(NewServer.onPaperUpdate)
+	NewServer.paper.SetCharges(rates)
+	NewServer.paperRisk = risk.New(NewServer.paper, limits)
+	NewServer.paperRisk.SetExposure(NewServer.netGreeks)
+
+	NewServer.baskets = basket.NewExecutor(NewServer.broker, NewServer.placeOrder)
+
+	// Exits watch ticks of their own instruments
+	NewServer.exits = exits.New(store, NewServer.exitPosition)
+	NewServer.exits.OnChange(NewServer.watchExits)
+
+	// Cut-offs per exchange, clamped to the calendar close
+	NewServer.squareoff, err = squareoff.New(marketCalendar, NewServer.broker, map[string]string{
+		"NSE": c.Squareoff.NSE,
+		"BSE": c.Squareoff.BSE,
+		"NFO": c.Squareoff.NFO,
+		"BFO": c.Squareoff.BFO,
+		"CDS": c.Squareoff.CDS,
+		"MCX": c.Squareoff.MCX,
+	})
+	if err != nil {
+		log.Fatalf("error loading square-off times. Err: %v", err)
+	}
+	NewServer.squareoff.OnSquareOff(NewServer.afterSquareOff)
+
+	NewServer.resolver = resolver.New(store, NewServer.spotLTP)
+	NewServer.resolver.SetClock(func() time.Time { return NewServer.now() })
+
+	// Strategies, exits and streams share Kite ticker connections
+	NewServer.ticks = feed.New(NewServer.ctx, NewServer.dialTicker)
+	NewServer.events = newEvents()
+	NewServer.strategies = strategy.NewManager(NewServer.ctx, NewServer.tickerFeed)
+	NewServer.strategies.OnSignal(NewServer.events.signals.Publish)
+	NewServer.strategies.OnCandle(NewServer.events.candles.Publish)
+	NewServer.strategies.SetHistory(NewServer.strategyHistory)
+	NewServer.storeSignals()
+	NewServer.registerStrategies()
+
+	// Declared strategies, checked now so a bad file fails at boot rather than at login
+	if c.Strategies.FILE != "" {
+		NewServer.definitions, err = NewServer.loadDefinitions(c.Strategies.FILE)
+		if err != nil {
+			log.Fatalf("error loading strategy definitions. Err: %v", err)
+		}
+	}
+
 	return NewServer
 }
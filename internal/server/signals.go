@@ -0,0 +1,163 @@
+// Signal History Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"friction-trading/internal/bus"
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/database"
+	"friction-trading/internal/strategy"
+)
+
+// Signals returned by /api/signals unless ?limit= says otherwise, and at most
+const (
+	defaultSignalLimit = 100
+	maxSignalLimit     = 1000
+)
+
+// signalResp is one stored signal
+type signalResp struct {
+	ID              int64              `json:"id"`
+	Strategy        string             `json:"strategy"`
+	InstrumentToken int64              `json:"instrument_token"`
+	Side            string             `json:"side"`
+	Price           float64            `json:"price"`
+	Indicators      map[string]float64 `json:"indicators"`
+	SignalledAt     time.Time          `json:"signalled_at"`
+}
+
+// storeSignals saves every signal on the bus, blocking rather than losing any
+func (s *Server) storeSignals() {
+	s.events.signals.Subscribe(bus.Options{Name: "signal-store", Queue: 1024, Policy: bus.Block}, s.saveSignal)
+}
+
+func (s *Server) saveSignal(sig strategy.Signal) {
+	log.Printf("Signal: %s %s %d @ %.2f\n", sig.Strategy, sig.Side, sig.Token, sig.Price)
+
+	indicators, err := json.Marshal(sig.Values)
+	if err != nil || sig.Values == nil {
+		indicators = []byte("{}")
+	}
+	at := sig.Timestamp
+	if at.IsZero() {
+		at = s.now()
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+	err = s.Store.CreateSignal(ctx, database.CreateSignalParams{
+		Strategy:        sig.Strategy,
+		InstrumentToken: int64(sig.Token),
+		Side:            sig.Side,
+		Price:           sig.Price,
+		Indicators:      indicators,
+		SignalledAt:     pgtype.Timestamptz{Time: at, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error saving signal from %s :- %v\n", sig.Strategy, err)
+	}
+}
+
+// parseSignalTime reads RFC 3339 timestamps or IST dates
+func parseSignalTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, raw, calendar.IST)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want YYYY-MM-DD or RFC 3339", raw)
+	}
+	return t, nil
+}
+
+// parseSignalEnd reads the end of a range, a date runs to the next midnight so the day is included
+func parseSignalEnd(raw string) (time.Time, error) {
+	t, err := parseSignalTime(raw)
+	if err == nil && len(raw) == len(time.DateOnly) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, err
+}
+
+// List Signals :- newest first, filtered by ?strategy=, ?token=, ?side=, ?from=, ?to= and ?limit=
+func (s *Server) listSignalsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	arg := database.ListSignalsParams{
+		Strategy: q.Get("strategy"),
+		Side:     strings.ToUpper(q.Get("side")),
+		Since:    pgtype.Timestamptz{Time: time.Unix(0, 0), Valid: true},
+		RowLimit: defaultSignalLimit,
+	}
+
+	if arg.Side != "" && arg.Side != "BUY" && arg.Side != "SELL" {
+		SendJSONResp(nil, fmt.Errorf("invalid side %q, want BUY or SELL", arg.Side), http.StatusBadRequest, w)
+		return
+	}
+	if raw := q.Get("token"); raw != "" {
+		token, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			SendJSONResp(nil, fmt.Errorf("invalid token %q", raw), http.StatusBadRequest, w)
+			return
+		}
+		arg.InstrumentToken = int64(token)
+	}
+	if raw := q.Get("from"); raw != "" {
+		from, err := parseSignalTime(raw)
+		if err != nil {
+			SendJSONResp(nil, err, http.StatusBadRequest, w)
+			return
+		}
+		arg.Since.Time = from
+	}
+	if raw := q.Get("to"); raw != "" {
+		to, err := parseSignalEnd(raw)
+		if err != nil {
+			SendJSONResp(nil, err, http.StatusBadRequest, w)
+			return
+		}
+		arg.Until = pgtype.Timestamptz{Time: to, Valid: true}
+	}
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			SendJSONResp(nil, fmt.Errorf("invalid limit %q", raw), http.StatusBadRequest, w)
+			return
+		}
+		arg.RowLimit = int32(min(limit, maxSignalLimit))
+	}
+
+	signals, err := s.Store.ListSignals(r.Context(), arg)
+	if err != nil {
+		log.Printf("Error listing signals :- %v\n", err)
+		SendJSONResp(nil, err, http.StatusInternalServerError, w)
+		return
+	}
+
+	resp := make([]signalResp, 0, len(signals))
+	for _, sig := range signals {
+		indicators := map[string]float64{}
+		if err := json.Unmarshal(sig.Indicators, &indicators); err != nil {
+			log.Printf("Error decoding indicators of signal %d :- %v\n", sig.ID, err)
+		}
+		resp = append(resp, signalResp{
+			ID:              sig.ID,
+			Strategy:        sig.Strategy,
+			InstrumentToken: sig.InstrumentToken,
+			Side:            sig.Side,
+			Price:           sig.Price,
+			Indicators:      indicators,
+			SignalledAt:     sig.SignalledAt.Time,
+		})
+	}
+	SendJSONResp(resp, nil, http.StatusOK, w)
+}
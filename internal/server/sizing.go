@@ -0,0 +1,166 @@
+// Position Sizing Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/broker"
+	"friction-trading/internal/database"
+	"friction-trading/internal/sizing"
+	"friction-trading/internal/strategy"
+)
+
+// Candles fetched for ATR based stops
+const (
+	sizingInterval = "5minute"
+	sizingLookback = 7 * 24 * time.Hour
+)
+
+// sizingReq picks a sizing policy, e.g. {"policy": "fixed_risk", "params": {"risk": 2000}}
+type sizingReq struct {
+	Policy string          `json:"policy"`
+	Params strategy.Params `json:"params"`
+}
+
+// sizingPreviewReq is the body of POST /api/sizing
+type sizingPreviewReq struct {
+	sizingReq
+	Exchange        string `json:"exchange"`
+	Tradingsymbol   string `json:"tradingsymbol"`
+	TransactionType string `json:"transaction_type"`
+	Product         string `json:"product"`
+}
+
+// ltp is the last traded price of exchange:tradingsymbol
+func (s *Server) ltp(exchange, tradingsymbol string) (float64, error) {
+	key := exchange + ":" + tradingsymbol
+	quotes, err := s.KiteClient.GetLTP(key)
+	if err != nil {
+		return 0, fmt.Errorf("fetch LTP for %s: %w", key, err)
+	}
+	q, ok := quotes[key]
+	if !ok {
+		return 0, fmt.Errorf("no LTP for %s", key)
+	}
+	return q.LastPrice, nil
+}
+
+// recentCandles pulls the last week of candles for ATR based stops
+func (s *Server) recentCandles(token int) ([]strategy.Candle, error) {
+	now := s.now()
+	return s.historicalCandles(token, sizingInterval, now.Add(-sizingLookback), now)
+}
+
+// historicalCandles pulls candles from Kite historical data
+func (s *Server) historicalCandles(token int, interval string, from, to time.Time) ([]strategy.Candle, error) {
+	data, err := s.KiteClient.GetHistoricalData(token, interval, from, to, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("fetch candles: %w", err)
+	}
+	candles := make([]strategy.Candle, len(data))
+	for i, d := range data {
+		candles[i] = strategy.Candle{
+			Timestamp: d.Date.Time,
+			Open:      d.Open,
+			High:      d.High,
+			Low:       d.Low,
+			Close:     d.Close,
+			LastPrice: d.Close,
+		}
+	}
+	return candles, nil
+}
+
+// sizeOrder works out the order quantity in whole lots of the instrument, on the Kite account
+func (s *Server) sizeOrder(ctx context.Context, params kiteconnect.OrderParams, req sizingReq) (int, error) {
+	return s.sizeOrderOn(ctx, s.broker, params, req)
+}
+
+// sizeOrderOn sizes against the margins of a given account, the paper one or Kite
+func (s *Server) sizeOrderOn(ctx context.Context, b broker.Broker, params kiteconnect.OrderParams, req sizingReq) (int, error) {
+	policy, err := sizing.New(req.Policy, req.Params)
+	if err != nil {
+		return 0, err
+	}
+
+	inst, err := s.Store.GetInstrumentBySymbol(ctx, database.GetInstrumentBySymbolParams{
+		Exchange:      params.Exchange,
+		Tradingsymbol: params.Tradingsymbol,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("lookup %s:%s: %w", params.Exchange, params.Tradingsymbol, err)
+	}
+
+	in := sizing.Input{Price: params.Price, LotSize: int(inst.LotSize)}
+	if in.Price == 0 || params.OrderType == kiteconnect.OrderTypeMarket {
+		if in.Price, err = s.ltp(params.Exchange, params.Tradingsymbol); err != nil {
+			return 0, err
+		}
+	}
+
+	switch policy.(type) {
+	case sizing.FixedRisk:
+		if in.Candles, err = s.recentCandles(int(inst.InstrumentToken)); err != nil {
+			return 0, err
+		}
+	case sizing.PercentMargin, sizing.Kelly:
+		margins, err := b.Margins()
+		if err != nil {
+			return 0, fmt.Errorf("fetch margins: %w", err)
+		}
+		in.AvailableMargin = margins.Equity.Net
+
+		// What one lot blocks, futures and option writes need far less than the lot value
+		lot := params
+		lot.Quantity = in.LotSize
+		if in.MarginPerLot, err = b.OrderMargin(lot); err != nil {
+			return 0, fmt.Errorf("fetch lot margin: %w", err)
+		}
+	}
+
+	return policy.Quantity(in)
+}
+
+// map sizing errors to HTTP status codes
+func sizingErrStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), ErrNoRowsFound.Error()):
+		return http.StatusNotFound
+	case errors.Is(err, sizing.ErrBelowOneLot), errors.Is(err, sizing.ErrNoLotSize):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Sizing Preview :- quantity a policy would trade, without ordering
+func (s *Server) sizingPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	var req sizingPreviewReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	params := kiteconnect.OrderParams{
+		Exchange:        strings.ToUpper(req.Exchange),
+		Tradingsymbol:   req.Tradingsymbol,
+		TransactionType: req.TransactionType,
+		OrderType:       kiteconnect.OrderTypeMarket,
+		Product:         req.Product,
+	}
+	qty, err := s.sizeOrder(r.Context(), params, req.sizingReq)
+	if err != nil {
+		SendJSONResp(nil, err, sizingErrStatus(err), w)
+		return
+	}
+
+	SendJSONResp(map[string]any{"quantity": qty}, nil, http.StatusOK, w)
+}
@@ -0,0 +1,80 @@
+// Square-off Routes
+package server
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/database"
+	"friction-trading/internal/squareoff"
+	"friction-trading/internal/strategy"
+)
+
+// afterSquareOff drops exit rules on the positions a square-off closed and flattens
+// the live deployments holding them
+func (s *Server) afterSquareOff(report squareoff.Report) {
+	if len(report.SquaredOff) == 0 {
+		return
+	}
+	s.cancelSquaredExits(report)
+
+	s.deployMu.Lock()
+	defer s.deployMu.Unlock()
+	for _, d := range s.deployments {
+		if d.def.Mode != strategy.ModeLive || d.instruments == nil {
+			continue
+		}
+		product := d.def.Product
+		if product == "" {
+			product = kiteconnect.ProductMIS
+		}
+		if product != kiteconnect.ProductMIS {
+			continue
+		}
+		d.flatten(func(inst *database.Instrument) bool {
+			return slices.Contains(report.SquaredOff, inst.Exchange+":"+inst.Tradingsymbol)
+		})
+	}
+}
+
+// cancelSquaredExits drops exit rules on the positions a square-off closed
+func (s *Server) cancelSquaredExits(report squareoff.Report) {
+	if _, err := s.exits.CancelMatching(s.ctx, func(rule database.ExitRule) bool {
+		return rule.Product == kiteconnect.ProductMIS && slices.Contains(report.SquaredOff, rule.Exchange+":"+rule.Tradingsymbol)
+	}); err != nil {
+		log.Printf("Error cancelling exit rules after square-off :- %v\n", err)
+	}
+}
+
+// Square-off Status :- next scheduled cut-off and what ran so far
+func (s *Server) squareoffStatusHandler(w http.ResponseWriter, r *http.Request) {
+	next, exchanges := s.squareoff.Next(s.now())
+	resp := map[string]any{
+		"next":      nil,
+		"exchanges": exchanges,
+		"reports":   s.squareoff.Reports(),
+	}
+	if !next.IsZero() {
+		resp["next"] = next
+	}
+	SendJSONResp(resp, nil, http.StatusOK, w)
+}
+
+// Square Off Now :- e.g. /api/squareoff?exchange=NFO,NSE, every configured exchange by default
+func (s *Server) squareoffHandler(w http.ResponseWriter, r *http.Request) {
+	exchanges := s.squareoff.Exchanges()
+	if exchange := strings.ToUpper(r.URL.Query().Get("exchange")); exchange != "" {
+		exchanges = strings.Split(exchange, ",")
+	}
+
+	report, err := s.squareoff.SquareOff(exchanges...)
+	if err != nil {
+		SendJSONResp(report, err, http.StatusBadGateway, w)
+		return
+	}
+	SendJSONResp(report, nil, http.StatusOK, w)
+}
@@ -0,0 +1,99 @@
+// Strategy Lifecycle Routes
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/strategy"
+)
+
+// startStrategyReq is the body of POST /api/strategies
+type startStrategyReq struct {
+	Name     string          `json:"name"`
+	Params   strategy.Params `json:"params"`
+	Tokens   []uint32        `json:"tokens"`
+	Exchange string          `json:"exchange"`
+}
+
+// Register all strategies the server can run
+func (s *Server) registerStrategies() {
+	s.strategies.Register("sma", strategy.NewSMA)
+	s.strategies.Register("supertrend", strategy.NewSupertrend)
+}
+
+// map strategy manager errors to HTTP status codes
+func strategyErrStatus(err error) int {
+	switch {
+	case errors.Is(err, strategy.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, strategy.ErrAlreadyRunning):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// List Strategies :- running and stopped instances plus available strategies
+func (s *Server) listStrategiesHandler(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]any{
+		"available": s.strategies.Names(),
+		"instances": s.strategies.List(),
+	}
+	SendJSONResp(resp, nil, http.StatusOK, w)
+}
+
+// Start Strategy
+func (s *Server) startStrategyHandler(w http.ResponseWriter, r *http.Request) {
+	var req startStrategyReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	exchange := strings.ToUpper(req.Exchange)
+	if exchange == "" {
+		exchange = calendar.NFO
+	}
+
+	// Don't start strategies while the market is shut
+	if now := s.now(); !s.calendar.IsOpen(exchange, now) {
+		SendJSONResp(nil, errMarketClosed(s.calendar, exchange, now), http.StatusConflict, w)
+		return
+	}
+
+	info, err := s.strategies.Start(req.Name, req.Params, req.Tokens)
+	if err != nil {
+		SendJSONResp(nil, err, strategyErrStatus(err), w)
+		return
+	}
+
+	SendJSONResp(info, nil, http.StatusCreated, w)
+}
+
+// Inspect Strategy
+func (s *Server) getStrategyHandler(w http.ResponseWriter, r *http.Request) {
+	info, err := s.strategies.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		SendJSONResp(nil, err, strategyErrStatus(err), w)
+		return
+	}
+
+	SendJSONResp(info, nil, http.StatusOK, w)
+}
+
+// Stop Strategy
+func (s *Server) stopStrategyHandler(w http.ResponseWriter, r *http.Request) {
+	info, err := s.strategies.Stop(chi.URLParam(r, "id"))
+	if err != nil {
+		SendJSONResp(nil, err, strategyErrStatus(err), w)
+		return
+	}
+
+	SendJSONResp(info, nil, http.StatusOK, w)
+}
@@ -0,0 +1,125 @@
+// Market Stream Routes
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/bus"
+	"friction-trading/internal/depth"
+)
+
+// Ticks queued on the bus for a slow stream client before they're dropped
+const streamQueue = 256
+
+// streamTick is one server-sent event of the market stream
+type streamTick struct {
+	InstrumentToken uint32          `json:"instrument_token"`
+	Timestamp       time.Time       `json:"timestamp"`
+	LastPrice       float64         `json:"last_price"`
+	Volume          uint32          `json:"volume"`
+	OI              uint32          `json:"oi"`
+	Depth           *depth.Features `json:"depth,omitempty"`
+}
+
+// parseTokens reads a comma separated list of instrument tokens
+func parseTokens(raw string) ([]uint32, error) {
+	var tokens []uint32
+	for _, field := range strings.Split(raw, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		token, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token %q", field)
+		}
+		tokens = append(tokens, uint32(token))
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("tokens are required")
+	}
+	return tokens, nil
+}
+
+// Market Stream :- server-sent ticks with order book features, ?tokens=256265,260105
+func (s *Server) marketStreamHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := parseTokens(r.URL.Query().Get("tokens"))
+	if err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Ticks come off the bus, the feed only keeps the tokens subscribed
+	ticks := make(chan kitemodels.Tick, 1)
+	sub := s.events.ticks.Subscribe(bus.Options{Name: "market-stream " + r.RemoteAddr, Queue: streamQueue, Tokens: tokens}, func(tick kitemodels.Tick) {
+		select {
+		case ticks <- tick:
+		case <-ctx.Done():
+		}
+	})
+	defer sub.Close()
+
+	feedErr := make(chan error, 1)
+	go func() {
+		feedErr <- s.tickerFeed(ctx, tokens, func(kitemodels.Tick) {})
+	}()
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Market stream can't flush :- %v\n", err)
+		return
+	}
+
+	book := depth.NewTracker()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-feedErr:
+			if err != nil {
+				fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+				rc.Flush()
+			}
+			return
+		case tick := <-ticks:
+			event := streamTick{
+				InstrumentToken: tick.InstrumentToken,
+				Timestamp:       tick.Timestamp.Time,
+				LastPrice:       tick.LastPrice,
+				Volume:          tick.VolumeTraded,
+				OI:              tick.OI,
+			}
+			if f, ok := book.Update(tick); ok {
+				event.Depth = &f
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error marshalling stream tick :- %v\n", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: tick\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
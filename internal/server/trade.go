@@ -2,277 +2,216 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"os"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/jackc/pgx/v5/pgtype"
-	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
 	kiteticker "github.com/zerodha/gokiteconnect/v4/ticker"
 
 	"friction-trading/internal/database"
-	. "friction-trading/internal/utils"
+	"friction-trading/internal/feed"
+	"friction-trading/internal/resolver"
 )
 
 var ErrNoRowsFound = errors.New("no rows in result set")
 
-// Candle represents a 5-minute OHLC candle.
-type Candle struct {
-	Timestamp time.Time
-	Open      float64
-	High      float64
-	Low       float64
-	Close     float64
-	LastPrice float64
-}
+// Instrument watched when neither the request nor config names one
+const defaultWatchSpec = "NIFTY current-week ATM CE"
 
-var candlesData []Candle
+// Triggered when any error is raised
+func onError(err error) {
+	fmt.Println("Error: ", err)
+}
 
-const SMA_PERIOD int = 10
+// Triggered when websocket connection is closed
+func onClose(code int, reason string) {
+	fmt.Println("Close: ", code, reason)
+}
 
-var positionStatus string = "NONE"
+// Triggered when reconnection is attempted which is enabled by default
+func onReconnect(attempt int, delay time.Duration) {
+	fmt.Printf("Reconnect attempt %d in %fs\n", attempt, delay.Seconds())
+}
 
-// Supertrend Strategy
-func Supertrend(candleData []Candle) {
-	atrPeriod := 7    // Standard ATR period
-	multiplier := 3.0 // Standard multiplier
+// Triggered when maximum number of reconnect attempt is made and the program is terminated
+func onNoReconnect(attempt int) {
+	fmt.Printf("Maximum no of reconnect attempt reached: %d", attempt)
+}
 
-	tr := CalculateTR(candlesData)
-	atr := CalculateATR(tr, atrPeriod)
-	supertrend := CalculateSupertrend(candlesData, atr, multiplier)
-	signals := GenerateSignals(candlesData, supertrend)
-	if len(signals) > 1 {
-		for i, signal := range signals {
-			if signal != "" {
-				fmt.Printf("At %s (close %.4f): %s\n", candlesData[i].Timestamp.Format("2006-01-02 15:04"), candlesData[i].Close, signal)
-			}
-		}
-	} else {
-		fmt.Printf("No Signal....✊\n")
-	}
+// wsConn subscribes on a live ticker socket. Frames go straight to the socket since
+// kiteticker's own Subscribe keeps an unguarded token map it replays on reconnect,
+// the feed manager resubscribes from its own state instead.
+type wsConn struct {
+	mu sync.Mutex
+	ws *websocket.Conn
 }
 
-// CalculateTR computes the True Range for each candle.
-func CalculateTR(candles []Candle) []float64 {
-	if len(candles) == 0 {
-		return nil
-	}
-	tr := make([]float64, len(candles))
-	tr[0] = candles[0].High - candles[0].Low // First TR is just High - Low
-	for i := 1; i < len(candles); i++ {
-		prevClose := candles[i-1].Close
-		tr[i] = max(candles[i].High-candles[i].Low,
-			max(Abs(candles[i].High-prevClose), Abs(candles[i].Low-prevClose)))
-	}
-	return tr
+func (c *wsConn) send(action string, value any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws.WriteJSON(map[string]any{"a": action, "v": value})
 }
 
-// CalculateATR computes the Average True Range (SMA of TR).
-func CalculateATR(tr []float64, period int) []float64 {
-	if period <= 0 || len(tr) == 0 {
+func (c *wsConn) Subscribe(tokens []uint32) error {
+	if len(tokens) == 0 {
 		return nil
 	}
-	atr := make([]float64, len(tr))
-	for i := 0; i < len(tr); i++ {
-		start := max(0, i-period+1)
-		sum := 0.0
-		for j := start; j <= i; j++ {
-			sum += tr[j]
-		}
-		count := float64(i - start + 1)
-		atr[i] = sum / count
-	}
-	return atr
+	return c.send("subscribe", tokens)
 }
 
-// CalculateSupertrend computes the Supertrend indicator.
-func CalculateSupertrend(candles []Candle, atr []float64, multiplier float64) []float64 {
-	if len(candles) != len(atr) || len(candles) == 0 {
+func (c *wsConn) Unsubscribe(tokens []uint32) error {
+	if len(tokens) == 0 {
 		return nil
 	}
-	supertrend := make([]float64, len(candles))
-	var direction int = 1 // 1 for uptrend (lower band), -1 for downtrend (upper band)
-
-	for i := 0; i < len(candles); i++ {
-		basic := (candles[i].High + candles[i].Low) / 2
-		upper := basic + multiplier*atr[i]
-		lower := basic - multiplier*atr[i]
-
-		if i == 0 {
-			supertrend[i] = lower // Start with lower band assuming uptrend
-			continue
-		}
-
-		prevSuper := supertrend[i-1]
-		if direction == 1 { // Uptrend: use lower band, but adjust if crossed
-			supertrend[i] = max(lower, prevSuper)
-			if candles[i].Close < supertrend[i] {
-				direction = -1
-				supertrend[i] = upper
-			}
-		} else { // Downtrend: use upper band
-			supertrend[i] = min(upper, prevSuper)
-			if candles[i].Close > supertrend[i] {
-				direction = 1
-				supertrend[i] = lower
-			}
-		}
-	}
-	return supertrend
+	return c.send("unsubscribe", tokens)
 }
 
-// GenerateSignals produces BUY or SELL signals based on Supertrend.
-// BUY when close > supertrend (start of uptrend), SELL when close < supertrend (start of downtrend).
-// For each candle, signal is based on crossover from previous.
-func GenerateSignals(candles []Candle, supertrend []float64) []string {
-	if len(candles) != len(supertrend) || len(candles) < 2 {
+func (c *wsConn) SetMode(mode kiteticker.Mode, tokens []uint32) error {
+	if len(tokens) == 0 {
 		return nil
 	}
-	signals := make([]string, len(candles))
-	for i := 1; i < len(candles); i++ {
-		if candles[i-1].Close <= supertrend[i-1] && candles[i].Close > supertrend[i] {
-			signals[i] = "BUY"
-		} else if candles[i-1].Close >= supertrend[i-1] && candles[i].Close < supertrend[i] {
-			signals[i] = "SELL"
-		}
-	}
-	return signals
+	return c.send("mode", []any{mode, tokens})
 }
 
-func sumCandleData(candleData []Candle) float64 {
-	total := 0.0
-	for _, val := range candleData {
-		total += val.LastPrice
+// Serve one Kite ticker connection for the subscription manager until ctx is done
+func (s *Server) dialTicker(ctx context.Context, onConn func(feed.Conn), onTick func(kitemodels.Tick)) error {
+	// Create new Kite ticker instance
+	ticker := kiteticker.New(s.config.Kite.API_KEY, s.AccessToken)
+	if s.tickerURL != nil {
+		ticker.SetRootURL(*s.tickerURL)
 	}
-	return float64(total)
-}
 
-// Triggered when any error is raised
-func onError(err error) {
-	fmt.Println("Error: ", err)
-}
+	var (
+		mu     sync.Mutex
+		conn   io.Closer
+		gaveUp bool
+	)
 
-// Triggered when websocket connection is closed
-func onClose(code int, reason string) {
-	fmt.Println("Close: ", code, reason)
-}
-
-// Triggered when connection is established and ready to send and accept data
-func onConnect(s *Server, instToken string) func() {
-	return func() {
+	// Assign callbacks
+	ticker.OnError(onError)
+	ticker.OnClose(onClose)
+	ticker.OnConnect(func() {
+		mu.Lock()
+		conn = ticker.Conn
+		mu.Unlock()
 		fmt.Println("Connected")
-		fmt.Println("Subscribing to", instToken)
-
-		into, err := strconv.Atoi(instToken)
-		if err != nil {
-			fmt.Println("err: ", err)
+		onConn(&wsConn{ws: ticker.Conn})
+	})
+	ticker.OnReconnect(onReconnect)
+	ticker.OnNoReconnect(func(attempt int) {
+		mu.Lock()
+		gaveUp = true
+		mu.Unlock()
+		onNoReconnect(attempt)
+	})
+	ticker.OnTick(func(tick kitemodels.Tick) {
+		onTick(tick)
+		s.events.ticks.Publish(tick)
+	})
+	ticker.OnOrderUpdate(s.onOrderUpdate)
+
+	// Unblock the reader once the context is cancelled, the loop itself ends on ctx
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if conn != nil {
+			conn.Close()
 		}
+	})
+	defer stop()
 
-		err = s.ticker.Subscribe([]uint32{uint32(into)})
-		if err != nil {
-			fmt.Println("err: ", err)
-		}
-		// Set subscription mode for given list of tokens
-		// Default mode is Quote
-		err = s.ticker.SetMode(kiteticker.ModeFull, []uint32{uint32(into)})
-		if err != nil {
-			fmt.Println("err: ", err)
-		}
+	ticker.ServeWithContext(ctx)
 
-		fmt.Println("Subscribed to", instToken)
+	mu.Lock()
+	defer mu.Unlock()
+	if gaveUp {
+		return errors.New("ticker gave up reconnecting")
 	}
+	return nil
 }
 
-// Triggered when tick is recevived
-func onTick(tick kitemodels.Tick) {
-	// Create Candle from tick
-	candle := Candle{
-		Timestamp: tick.Timestamp.Time,
-		Close:     tick.OHLC.Close,
-		Open:      tick.OHLC.Open,
-		Low:       tick.OHLC.Low,
-		High:      tick.OHLC.High,
-		LastPrice: tick.LastPrice,
+// Stream ticks for tokens over the shared ticker connections until ctx is done
+func (s *Server) tickerFeed(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error {
+	sub, err := s.ticks.Subscribe(tokens, kiteticker.ModeFull, onTick)
+	if err != nil {
+		return err
 	}
+	defer sub.Close()
 
-	candlesData = append(candlesData, candle)
-
-	// Execute Supertrend Pattern
-	// Supertrend(candlesData)
-
-	if len(candlesData) > SMA_PERIOD {
-		candlesData = candlesData[1:]
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-sub.Done():
+		return sub.Err()
 	}
+}
 
-	if len(candlesData) == SMA_PERIOD {
-		sma := sumCandleData(candlesData) / float64(SMA_PERIOD)
-		fmt.Printf("LTP :- %.2f, | SMA :- %.2f\n", tick.LastPrice, sma)
-
-		if tick.LastPrice > sma && positionStatus == "BEAR" {
-			positionStatus = "BULL"
-			fmt.Printf("BUY at :- %.2f\n", tick.LastPrice)
-		}
+// Ticker Stats :- shared ticker connections and their subscriptions
+func (s *Server) tickerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	SendJSONResp(s.ticks.Stats(), nil, http.StatusOK, w)
+}
 
-		if tick.LastPrice < sma && positionStatus == "BULL" {
-			positionStatus = "BEAR"
-			fmt.Printf("SELL at :- %.2f\n", tick.LastPrice)
-		}
+// Watch Nifty 50 Option :- SMA on ?instrument=, the configured instrument by default
+func (s *Server) watchNifty50OptionHandler(w http.ResponseWriter, r *http.Request) {
+	spec := r.URL.Query().Get("instrument")
+	if spec == "" {
+		spec = s.config.Kite.INSTRUMENT
+	}
+	if spec == "" {
+		spec = defaultWatchSpec
+	}
 
-		// Set inital status
-		if positionStatus == "NONE" {
-			if tick.LastPrice > sma {
-				positionStatus = "BULL"
-			} else {
-				positionStatus = "BEAR"
-			}
-		}
+	inst, err := s.resolver.Resolve(r.Context(), spec)
+	if err != nil {
+		SendJSONResp(nil, err, resolverErrStatus(err), w)
+		return
+	}
 
+	// Don't stream ticks while the market is shut
+	if now := s.now(); !s.calendar.IsOpen(inst.Exchange, now) {
+		SendJSONResp(nil, errMarketClosed(s.calendar, inst.Exchange, now), http.StatusConflict, w)
+		return
 	}
-}
 
-// Triggered when reconnection is attempted which is enabled by default
-func onReconnect(attempt int, delay time.Duration) {
-	fmt.Printf("Reconnect attempt %d in %fs\n", attempt, delay.Seconds())
-}
+	info, err := s.strategies.Start("sma", nil, []uint32{uint32(inst.InstrumentToken)})
+	if err != nil {
+		SendJSONResp(nil, err, strategyErrStatus(err), w)
+		return
+	}
 
-// Triggered when maximum number of reconnect attempt is made and the program is terminated
-func onNoReconnect(attempt int) {
-	fmt.Printf("Maximum no of reconnect attempt reached: %d", attempt)
+	SendJSONResp(info, nil, http.StatusOK, w)
 }
 
-// Triggered when order update is received
-func onOrderUpdate(order kiteconnect.Order) {
-	fmt.Printf("Order: %s", order.OrderID)
+// map resolver errors to HTTP status codes
+func resolverErrStatus(err error) int {
+	switch {
+	case errors.Is(err, resolver.ErrBadSpec):
+		return http.StatusBadRequest
+	case errors.Is(err, resolver.ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadGateway
+	}
 }
 
-// Watch Nifty 50 Option
-func (s *Server) watchNifty50OptionHandler(w http.ResponseWriter, r *http.Request) {
-	// Create new Kite ticker instance
-	s.ticker = kiteticker.New(s.config.Kite.API_KEY, s.AccessToken)
-
-	// read instrument token from env
-	instToken := os.Getenv("TOKEN")
-
-	// Assign callbacks
-	s.ticker.OnError(onError)
-	s.ticker.OnClose(onClose)
-	s.ticker.OnConnect(onConnect(s, instToken))
-	s.ticker.OnReconnect(onReconnect)
-	s.ticker.OnNoReconnect(onNoReconnect)
-	s.ticker.OnTick(onTick)
-	s.ticker.OnOrderUpdate(onOrderUpdate)
-
-	// Spin up a Goroutine to start the Server and Control it with Context Cacelletation
-	go func() {
-		s.ticker.ServeWithContext(s.ctx)
-	}()
+// Resolve Instrument :- ?spec=NIFTY current-week ATM CE
+func (s *Server) resolveInstrumentHandler(w http.ResponseWriter, r *http.Request) {
+	inst, err := s.resolver.Resolve(r.Context(), r.URL.Query().Get("spec"))
+	if err != nil {
+		SendJSONResp(nil, err, resolverErrStatus(err), w)
+		return
+	}
+	SendJSONResp(inst, nil, http.StatusOK, w)
 }
 
 // fetch All Instruments and Store them in "instruments" table
@@ -31,10 +31,17 @@ func (s *Server) profileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Greeks of the F&O book, the profile still loads without them
+	greeks, err := s.exposures(r.Context(), userPositions.Net)
+	if err != nil {
+		log.Printf("Error computing position greeks. Err: %v\n", err)
+	}
+
 	resp := map[string]any{
 		"portfolio": userPortfolio,
 		"positions": userPositions,
 		"margins":   userMargins,
+		"greeks":    greeks,
 	}
 
 	SendJSONResp(resp, nil, http.StatusOK, w)
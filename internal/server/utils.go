@@ -30,6 +30,10 @@ func GracefulShutdown(server *Server, done chan bool, c *config.Config) {
 	log.Println("shutting down gracefully, press Ctrl+C again to force")
 	stop() // Allow Ctrl+C to force shutdown
 
+	// Stop running strategies and their tickers
+	server.strategies.StopAll()
+	server.stopExits()
+
 	// The context is used to inform the server it has 5 seconds to finish
 	// the request it is currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
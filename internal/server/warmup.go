@@ -0,0 +1,86 @@
+// Strategy Warm-up
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"friction-trading/internal/strategy"
+)
+
+// Kite candle intervals, longest first
+var kiteIntervals = []struct {
+	frame time.Duration
+	name  string
+}{
+	{24 * time.Hour, "day"},
+	{time.Hour, "60minute"},
+	{30 * time.Minute, "30minute"},
+	{15 * time.Minute, "15minute"},
+	{10 * time.Minute, "10minute"},
+	{5 * time.Minute, "5minute"},
+	{3 * time.Minute, "3minute"},
+	{time.Minute, "minute"},
+}
+
+// intervalFrame is the candle length of a Kite interval, 0 for intervals it doesn't know
+func intervalFrame(name string) time.Duration {
+	for _, interval := range kiteIntervals {
+		if interval.name == name {
+			return interval.frame
+		}
+	}
+	return 0
+}
+
+const (
+	// 09:15 to 15:30
+	sessionLength = 6*time.Hour + 15*time.Minute
+	// Kite stamps day candles at midnight IST, Bars at the open
+	dayCandleOpen = 9*time.Hour + 15*time.Minute
+)
+
+// strategyHistory primes strategy instances from Kite history. Timeframes Kite doesn't
+// serve are built from the longest interval that divides them.
+func (s *Server) strategyHistory(ctx context.Context, token uint32, frame time.Duration, count int) ([]strategy.Candle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	i := 0
+	for i < len(kiteIntervals) && frame%kiteIntervals[i].frame != 0 {
+		i++
+	}
+	if i == len(kiteIntervals) {
+		return nil, fmt.Errorf("no Kite interval fits a %s timeframe", frame)
+	}
+	interval := kiteIntervals[i]
+
+	// Trading days the candles span, then calendar days with weekends and some holidays
+	days := 1 + int(time.Duration(count)*frame/sessionLength)
+	if frame >= 24*time.Hour {
+		days = 1 + count*int(frame/(24*time.Hour))
+	}
+	to := s.now()
+	from := to.AddDate(0, 0, -(days*7/5 + 5))
+
+	candles, err := s.historicalCandles(int(token), interval.name, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if interval.frame == 24*time.Hour {
+		for i := range candles {
+			candles[i].Timestamp = candles[i].Timestamp.Add(dayCandleOpen)
+		}
+	}
+	candles = strategy.Resample(candles, frame)
+
+	// The last bucket may still be forming, the live ticks finish it
+	if n := len(candles); n > 0 && candles[n-1].Timestamp.Add(frame).After(to) {
+		candles = candles[:n-1]
+	}
+	if len(candles) > count {
+		candles = candles[len(candles)-count:]
+	}
+	return candles, nil
+}
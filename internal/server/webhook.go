@@ -0,0 +1,230 @@
+// Signal Webhook Routes
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/database"
+	"friction-trading/internal/resolver"
+	"friction-trading/internal/risk"
+	"friction-trading/internal/strategy"
+)
+
+const (
+	// Paper account cash unless configured
+	defaultPaperCapital = 1000000
+	// Resting paper orders are checked against the quote this often
+	paperMatchInterval = 2 * time.Second
+)
+
+// webhookSignalReq is the body of POST /api/webhooks/signal, as a charting tool alert sends it
+type webhookSignalReq struct {
+	Secret       string  `json:"secret"`
+	Symbol       string  `json:"symbol"`        // NSE:SBIN, SBIN or a spec like "NIFTY current-week ATM CE"
+	Exchange     string  `json:"exchange"`      // when the symbol has none, NSE by default
+	Action       string  `json:"action"`        // buy or sell
+	Quantity     int     `json:"quantity"`      // units, a multiple of the lot size
+	OrderType    string  `json:"order_type"`    // MARKET by default
+	Price        float64 `json:"price"`         // for LIMIT and SL orders
+	TriggerPrice float64 `json:"trigger_price"` // for SL and SL-M orders
+	Product      string  `json:"product"`       // MIS by default
+	Strategy     string  `json:"strategy"`      // tags the order and the stored signal
+}
+
+// webhookSignalResp is where a webhook order went
+type webhookSignalResp struct {
+	OrderID       string `json:"order_id"`
+	Mode          string `json:"mode"`
+	Exchange      string `json:"exchange"`
+	Tradingsymbol string `json:"tradingsymbol"`
+	Quantity      int    `json:"quantity"`
+}
+
+// webhookMode is paper unless the config asks for live
+func (s *Server) webhookMode() string {
+	if strings.EqualFold(s.config.Webhook.MODE, strategy.ModeLive) {
+		return strategy.ModeLive
+	}
+	return strategy.ModePaper
+}
+
+// lookupInstrument finds EXCHANGE:SYMBOL or SYMBOL on exchange in the instruments table,
+// symbols with spaces are resolver specs
+func (s *Server) lookupInstrument(ctx context.Context, symbol, exchange string) (*database.Instrument, error) {
+	symbol = strings.TrimSpace(symbol)
+	if symbol == "" {
+		return nil, fmt.Errorf("%w: symbol is required", resolver.ErrBadSpec)
+	}
+	if strings.Contains(symbol, " ") {
+		return s.resolver.Resolve(ctx, symbol)
+	}
+
+	if ex, sym, ok := strings.Cut(symbol, ":"); ok {
+		exchange, symbol = ex, sym
+	}
+	if exchange == "" {
+		exchange = calendar.NSE
+	}
+	inst, err := s.Store.GetInstrumentBySymbol(ctx, database.GetInstrumentBySymbolParams{
+		Exchange:      strings.ToUpper(exchange),
+		Tradingsymbol: strings.ToUpper(symbol),
+	})
+	if err != nil && err.Error() == ErrNoRowsFound.Error() {
+		return nil, fmt.Errorf("%w: %s:%s", resolver.ErrNotFound, strings.ToUpper(exchange), strings.ToUpper(symbol))
+	}
+	return inst, err
+}
+
+// Signal Webhook :- place an alert's order on the paper or live account, through risk
+func (s *Server) signalWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	secret := s.config.Webhook.SECRET
+	if secret == "" {
+		SendJSONResp(nil, errors.New("signal webhook is not configured"), http.StatusServiceUnavailable, w)
+		return
+	}
+
+	var req webhookSignalReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Secret), []byte(secret)) != 1 {
+		log.Printf("Signal webhook secret mismatch for %q\n", req.Symbol)
+		SendJSONResp(nil, errors.New("invalid secret"), http.StatusForbidden, w)
+		return
+	}
+
+	side := strings.ToUpper(req.Action)
+	if side != kiteconnect.TransactionTypeBuy && side != kiteconnect.TransactionTypeSell {
+		SendJSONResp(nil, fmt.Errorf("invalid action %q, want buy or sell", req.Action), http.StatusBadRequest, w)
+		return
+	}
+	if req.Quantity <= 0 {
+		SendJSONResp(nil, errors.New("quantity must be positive"), http.StatusBadRequest, w)
+		return
+	}
+
+	inst, err := s.lookupInstrument(r.Context(), req.Symbol, req.Exchange)
+	if err != nil {
+		SendJSONResp(nil, err, resolverErrStatus(err), w)
+		return
+	}
+	if lot := int(inst.LotSize); lot > 1 && req.Quantity%lot != 0 {
+		SendJSONResp(nil, fmt.Errorf("quantity %d is not a multiple of the %d lot size of %s", req.Quantity, lot, inst.Tradingsymbol), http.StatusBadRequest, w)
+		return
+	}
+
+	params := kiteconnect.OrderParams{
+		Exchange:        inst.Exchange,
+		Tradingsymbol:   inst.Tradingsymbol,
+		TransactionType: side,
+		OrderType:       strings.ToUpper(req.OrderType),
+		Product:         strings.ToUpper(req.Product),
+		Validity:        kiteconnect.ValidityDay,
+		Quantity:        req.Quantity,
+		Price:           req.Price,
+		TriggerPrice:    req.TriggerPrice,
+		Tag:             req.Strategy,
+	}
+	if params.OrderType == "" {
+		params.OrderType = kiteconnect.OrderTypeMarket
+	}
+	if err := checkOrderPrices(params); err != nil {
+		SendJSONResp(nil, err, http.StatusBadRequest, w)
+		return
+	}
+	if params.Product == "" {
+		params.Product = kiteconnect.ProductMIS
+	}
+
+	// The alert is kept whether or not risk lets the order through
+	price := req.Price
+	if price == 0 {
+		if price, err = s.ltp(params.Exchange, params.Tradingsymbol); err != nil {
+			SendJSONResp(nil, err, http.StatusBadGateway, w)
+			return
+		}
+	}
+	source := "webhook"
+	if req.Strategy != "" {
+		source += ":" + req.Strategy
+	}
+	s.events.signals.Publish(strategy.Signal{
+		Strategy:  source,
+		Token:     uint32(inst.InstrumentToken),
+		Timestamp: s.now(),
+		Side:      side,
+		Price:     price,
+		Values:    map[string]float64{"quantity": float64(req.Quantity)},
+	})
+
+	mode := s.webhookMode()
+	engine := s.paperRisk
+	if mode == strategy.ModeLive {
+		engine = s.risk
+	}
+	orderID, err := s.placeOrderWith(engine, kiteconnect.VarietyRegular, params)
+	if err != nil {
+		var violation *risk.Violation
+		if errors.As(err, &violation) {
+			SendJSONResp(violation, err, riskErrStatus(err), w)
+			return
+		}
+		SendJSONResp(nil, err, riskErrStatus(err), w)
+		return
+	}
+
+	SendJSONResp(webhookSignalResp{
+		OrderID:       orderID,
+		Mode:          mode,
+		Exchange:      inst.Exchange,
+		Tradingsymbol: inst.Tradingsymbol,
+		Quantity:      req.Quantity,
+	}, nil, http.StatusCreated, w)
+}
+
+// checkOrderPrices rejects limit and stop orders without the prices Kite needs
+func checkOrderPrices(params kiteconnect.OrderParams) error {
+	switch params.OrderType {
+	case kiteconnect.OrderTypeMarket:
+	case kiteconnect.OrderTypeLimit:
+		if params.Price <= 0 {
+			return errors.New("LIMIT orders need a positive price")
+		}
+	case kiteconnect.OrderTypeSL:
+		if params.Price <= 0 || params.TriggerPrice <= 0 {
+			return errors.New("SL orders need a positive price and trigger")
+		}
+	case kiteconnect.OrderTypeSLM:
+		if params.TriggerPrice <= 0 {
+			return errors.New("SL-M orders need a positive trigger")
+		}
+	default:
+		return fmt.Errorf("invalid order type %q, want MARKET, LIMIT, SL or SL-M", params.OrderType)
+	}
+	return nil
+}
+
+// Paper Account :- orders, positions and margins of the paper account
+func (s *Server) paperAccountHandler(w http.ResponseWriter, r *http.Request) {
+	orders, _ := s.paper.Orders()
+	positions, _ := s.paper.Positions()
+	margins, _ := s.paper.Margins()
+	SendJSONResp(map[string]any{
+		"orders":    orders,
+		"positions": positions.Net,
+		"margins":   margins.Equity,
+		"charges":   s.paper.Charges(),
+	}, nil, http.StatusOK, w)
+}
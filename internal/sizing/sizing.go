@@ -0,0 +1,171 @@
+// Package sizing decides how many units a signal trades
+package sizing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"friction-trading/internal/strategy"
+)
+
+var (
+	ErrUnknownPolicy = errors.New("unknown sizing policy")
+	ErrBelowOneLot   = errors.New("size is below one lot")
+	ErrNoLotSize     = errors.New("instrument lot size is unknown")
+)
+
+// Input is what a policy knows about the trade and the account
+type Input struct {
+	Price           float64           // expected entry price
+	LotSize         int               // from the instruments table
+	Candles         []strategy.Candle // recent candles, for ATR stops
+	AvailableMargin float64           // equity margin free to trade
+	MarginPerLot    float64           // margin one lot blocks, 0 assumes the full lot value
+}
+
+// lotValue is the capital one lot ties up
+func (in Input) lotValue() float64 {
+	if in.MarginPerLot > 0 {
+		return in.MarginPerLot
+	}
+	return in.Price * float64(in.LotSize)
+}
+
+// Policy sizes a trade in units, always a whole number of lots
+type Policy interface {
+	Quantity(in Input) (int, error)
+}
+
+// Lots turns a lot count into units, rejecting anything under a lot
+func Lots(lots, lotSize int) (int, error) {
+	if lotSize <= 0 {
+		return 0, ErrNoLotSize
+	}
+	if lots < 1 {
+		return 0, ErrBelowOneLot
+	}
+	return lots * lotSize, nil
+}
+
+// RoundLots rounds units down to whole lots
+func RoundLots(units float64, lotSize int) (int, error) {
+	if lotSize <= 0 {
+		return 0, ErrNoLotSize
+	}
+	return Lots(int(math.Floor(units/float64(lotSize))), lotSize)
+}
+
+// FixedLots always trades the same number of lots
+type FixedLots struct {
+	Lots int
+}
+
+func (p FixedLots) Quantity(in Input) (int, error) {
+	return Lots(p.Lots, in.LotSize)
+}
+
+// FixedRisk loses at most Risk rupees when a stop ATRMultiple x ATR away is hit
+type FixedRisk struct {
+	Risk        float64
+	ATRPeriod   int
+	ATRMultiple float64
+}
+
+func (p FixedRisk) Quantity(in Input) (int, error) {
+	stop, err := p.StopDistance(in.Candles)
+	if err != nil {
+		return 0, err
+	}
+	return RoundLots(p.Risk/stop, in.LotSize)
+}
+
+// StopDistance is the ATR based stop distance in points
+func (p FixedRisk) StopDistance(candles []strategy.Candle) (float64, error) {
+	if len(candles) < p.ATRPeriod {
+		return 0, fmt.Errorf("need %d candles for ATR, have %d", p.ATRPeriod, len(candles))
+	}
+	atr := strategy.CalculateATR(strategy.CalculateTR(candles), p.ATRPeriod)
+	stop := atr[len(atr)-1] * p.ATRMultiple
+	if stop <= 0 {
+		return 0, errors.New("ATR stop distance is zero")
+	}
+	return stop, nil
+}
+
+// PercentMargin commits Percent of available margin
+type PercentMargin struct {
+	Percent float64
+}
+
+func (p PercentMargin) Quantity(in Input) (int, error) {
+	lot := in.lotValue()
+	if lot <= 0 {
+		return 0, errors.New("lot value is zero")
+	}
+	return Lots(int(in.AvailableMargin*p.Percent/100/lot), in.LotSize)
+}
+
+// Kelly commits Fraction of the Kelly bet given the win rate and win/loss payoff
+type Kelly struct {
+	WinRate  float64
+	Payoff   float64
+	Fraction float64
+}
+
+// Bet is the share of capital to commit, zero when there's no edge
+func (p Kelly) Bet() float64 {
+	if p.Payoff <= 0 {
+		return 0
+	}
+	f := p.WinRate - (1-p.WinRate)/p.Payoff
+	return max(0, f*p.Fraction)
+}
+
+func (p Kelly) Quantity(in Input) (int, error) {
+	lot := in.lotValue()
+	if lot <= 0 {
+		return 0, errors.New("lot value is zero")
+	}
+	return Lots(int(in.AvailableMargin*p.Bet()/lot), in.LotSize)
+}
+
+// New builds a policy by name from params, e.g. "fixed_risk" {"risk": 2000}
+func New(name string, params strategy.Params) (Policy, error) {
+	switch name {
+	case "fixed_lots":
+		lots := params.Get("lots", 1)
+		if lots < 1 {
+			return nil, errors.New("lots must be at least 1")
+		}
+		return FixedLots{Lots: int(lots)}, nil
+	case "fixed_risk":
+		p := FixedRisk{
+			Risk:        params.Get("risk", 0),
+			ATRPeriod:   int(params.Get("atr_period", 14)),
+			ATRMultiple: params.Get("atr_multiplier", 2),
+		}
+		if p.Risk <= 0 || p.ATRPeriod < 1 || p.ATRMultiple <= 0 {
+			return nil, errors.New("risk, atr_period and atr_multiplier must be positive")
+		}
+		return p, nil
+	case "percent_margin":
+		p := PercentMargin{Percent: params.Get("percent", 10)}
+		if p.Percent <= 0 || p.Percent > 100 {
+			return nil, errors.New("percent must be within (0, 100]")
+		}
+		return p, nil
+	case "kelly":
+		p := Kelly{
+			WinRate:  params.Get("win_rate", 0),
+			Payoff:   params.Get("payoff", 0),
+			Fraction: params.Get("fraction", 0.5),
+		}
+		if p.WinRate <= 0 || p.WinRate >= 1 || p.Payoff <= 0 || p.Fraction <= 0 || p.Fraction > 1 {
+			return nil, errors.New("win_rate must be within (0, 1), payoff positive and fraction within (0, 1]")
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPolicy, name)
+	}
+}
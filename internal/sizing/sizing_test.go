@@ -0,0 +1,80 @@
+package sizing_test
+
+import (
+	"errors"
+	"testing"
+
+	"friction-trading/internal/sizing"
+	"friction-trading/internal/strategy"
+)
+
+// candles with a constant 10 point range
+func flatCandles(n int) []strategy.Candle {
+	candles := make([]strategy.Candle, n)
+	for i := range candles {
+		candles[i] = strategy.Candle{Open: 100, High: 105, Low: 95, Close: 100}
+	}
+	return candles
+}
+
+func TestPolicies(t *testing.T) {
+	in := sizing.Input{
+		Price:           200,
+		LotSize:         75,
+		Candles:         flatCandles(20),
+		AvailableMargin: 100000,
+	}
+
+	tests := []struct {
+		name   string
+		policy string
+		params strategy.Params
+		in     sizing.Input
+		want   int
+		err    error
+	}{
+		{"fixed lots", "fixed_lots", strategy.Params{"lots": 2}, in, 150, nil},
+		// stop 2 x 10 points, 5000 / 20 = 250 units, rounded down to 3 lots
+		{"fixed risk", "fixed_risk", strategy.Params{"risk": 5000}, in, 225, nil},
+		{"fixed risk under a lot", "fixed_risk", strategy.Params{"risk": 1000}, in, 0, sizing.ErrBelowOneLot},
+		// 25% of 100000 over 15000 a lot
+		{"percent margin", "percent_margin", strategy.Params{"percent": 25}, in, 75, nil},
+		{"percent margin per lot", "percent_margin", strategy.Params{"percent": 25},
+			sizing.Input{Price: 200, LotSize: 75, AvailableMargin: 100000, MarginPerLot: 5000}, 375, nil},
+		// edge 0.6 - 0.4/2 = 0.4, half Kelly commits 20000 over 15000 a lot
+		{"kelly", "kelly", strategy.Params{"win_rate": 0.6, "payoff": 2}, in, 75, nil},
+		{"kelly without edge", "kelly", strategy.Params{"win_rate": 0.3, "payoff": 1}, in, 0, sizing.ErrBelowOneLot},
+		{"unknown lot size", "fixed_lots", nil, sizing.Input{}, 0, sizing.ErrNoLotSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := sizing.New(tt.policy, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := policy.Quantity(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("want %d, got %d", tt.want, got)
+			}
+			if tt.in.LotSize > 0 && got%tt.in.LotSize != 0 {
+				t.Errorf("%d is not whole lots of %d", got, tt.in.LotSize)
+			}
+		})
+	}
+}
+
+func TestNewRejectsBadParams(t *testing.T) {
+	if _, err := sizing.New("martingale", nil); !errors.Is(err, sizing.ErrUnknownPolicy) {
+		t.Errorf("want ErrUnknownPolicy, got %v", err)
+	}
+	if _, err := sizing.New("fixed_risk", nil); err == nil {
+		t.Error("want error for fixed_risk without risk")
+	}
+	if _, err := sizing.New("kelly", strategy.Params{"win_rate": 1.2, "payoff": 2}); err == nil {
+		t.Error("want error for win rate above 1")
+	}
+}
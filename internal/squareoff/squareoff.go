@@ -0,0 +1,219 @@
+// Package squareoff closes our intraday positions before the exchange cut-off,
+// so the broker doesn't do it for us with a penalty.
+package squareoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/broker"
+	"friction-trading/internal/calendar"
+)
+
+// Cut-offs are looked up this many days ahead, enough to cover long holidays
+const lookahead = 10
+
+// Report is what one square-off did
+type Report struct {
+	Exchanges  []string  `json:"exchanges"`
+	At         time.Time `json:"at"`
+	Cancelled  []string  `json:"cancelled"`
+	SquaredOff []string  `json:"squared_off"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Scheduler squares off MIS positions at a per exchange time of day
+type Scheduler struct {
+	cal    *calendar.Calendar
+	broker broker.Broker
+	now    func() time.Time
+
+	// exchange to time after midnight IST
+	cutoffs map[string]time.Duration
+
+	mu          sync.Mutex
+	reports     []Report
+	onSquareOff func(Report)
+}
+
+// New takes cut-offs as "HH:MM" IST by exchange, e.g. {"NFO": "15:15", "MCX": "23:00"}.
+// Exchanges with an empty time are left alone.
+func New(cal *calendar.Calendar, b broker.Broker, cutoffs map[string]string) (*Scheduler, error) {
+	s := &Scheduler{cal: cal, broker: b, now: time.Now, cutoffs: map[string]time.Duration{}}
+	for exchange, at := range cutoffs {
+		if at == "" {
+			continue
+		}
+		var h, m int
+		if _, err := fmt.Sscanf(at, "%d:%d", &h, &m); err != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+			return nil, fmt.Errorf("square-off time %q for %s, want HH:MM", at, exchange)
+		}
+		s.cutoffs[strings.ToUpper(exchange)] = time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+	}
+	return s, nil
+}
+
+// SetClock replaces time.Now, for tests
+func (s *Scheduler) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// OnSquareOff is called with every square-off's report, scheduled or not
+func (s *Scheduler) OnSquareOff(fn func(Report)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSquareOff = fn
+}
+
+// Exchanges lists exchanges with a cut-off
+func (s *Scheduler) Exchanges() []string {
+	exchanges := make([]string, 0, len(s.cutoffs))
+	for exchange := range s.cutoffs {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+	return exchanges
+}
+
+// Cutoff is the square-off time for exchange on the day of t.
+// It never falls after the exchange close, so early closes and muhurat sessions are covered.
+func (s *Scheduler) Cutoff(exchange string, t time.Time) (time.Time, bool) {
+	offset, ok := s.cutoffs[exchange]
+	if !ok || !s.cal.IsTradingDay(exchange, t) {
+		return time.Time{}, false
+	}
+	day := t.In(calendar.IST)
+	at := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, calendar.IST).Add(offset)
+	if end := s.cal.Close(exchange, t); !end.IsZero() && at.After(end) {
+		at = end
+	}
+	return at, true
+}
+
+// Next returns the next cut-off after t and the exchanges squared off then
+func (s *Scheduler) Next(t time.Time) (time.Time, []string) {
+	var (
+		next      time.Time
+		exchanges []string
+	)
+	for i := 0; i < lookahead && next.IsZero(); i++ {
+		day := t.AddDate(0, 0, i)
+		for exchange := range s.cutoffs {
+			at, ok := s.Cutoff(exchange, day)
+			if !ok || !at.After(t) {
+				continue
+			}
+			switch {
+			case next.IsZero() || at.Before(next):
+				next, exchanges = at, []string{exchange}
+			case at.Equal(next):
+				exchanges = append(exchanges, exchange)
+			}
+		}
+	}
+	sort.Strings(exchanges)
+	return next, exchanges
+}
+
+// Run squares off at every cut-off until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		now := s.now()
+		next, exchanges := s.Next(now)
+		if next.IsZero() {
+			log.Println("Square-off :- no cut-off scheduled")
+			return
+		}
+		log.Printf("Square-off :- next at %s for %v\n", next.Format(time.DateTime), exchanges)
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		_, _ = s.SquareOff(exchanges...)
+	}
+}
+
+// SquareOff cancels our pending orders and closes our MIS positions on exchanges.
+// A position is ours when we placed an order in it today.
+func (s *Scheduler) SquareOff(exchanges ...string) (Report, error) {
+	report := Report{Exchanges: exchanges, At: s.now()}
+	on := func(exchange string) bool { return slices.Contains(exchanges, exchange) }
+
+	err := func() error {
+		orders, err := s.broker.Orders()
+		if err != nil {
+			return fmt.Errorf("fetch orders: %w", err)
+		}
+
+		var errs []error
+		ours := map[string]bool{}
+		for _, o := range orders {
+			if !broker.IsOwn(o) || !on(o.Exchange) {
+				continue
+			}
+			ours[o.Exchange+":"+o.TradingSymbol] = true
+			if !broker.IsOpen(o) {
+				continue
+			}
+			if err := s.broker.CancelOrder(o.Variety, o.OrderID); err != nil {
+				errs = append(errs, fmt.Errorf("cancel %s: %w", o.OrderID, err))
+				continue
+			}
+			report.Cancelled = append(report.Cancelled, o.OrderID)
+		}
+
+		positions, err := s.broker.Positions()
+		if err != nil {
+			return errors.Join(append(errs, fmt.Errorf("fetch positions: %w", err))...)
+		}
+		for _, p := range positions.Net {
+			if p.Quantity == 0 || p.Product != kiteconnect.ProductMIS || !ours[p.Exchange+":"+p.Tradingsymbol] {
+				continue
+			}
+			params := broker.ExitParams(p)
+			params.Tag = broker.OwnTag("squareoff")
+			id, err := s.broker.PlaceOrder(kiteconnect.VarietyRegular, params)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("square off %s: %w", p.Tradingsymbol, err))
+				continue
+			}
+			log.Printf("Square-off :- %s %d %s, order %s\n", params.TransactionType, params.Quantity, p.Tradingsymbol, id)
+			report.SquaredOff = append(report.SquaredOff, p.Exchange+":"+p.Tradingsymbol)
+		}
+		return errors.Join(errs...)
+	}()
+	if err != nil {
+		report.Error = err.Error()
+		log.Printf("Square-off on %v incomplete :- %v\n", exchanges, err)
+	}
+	log.Printf("Square-off on %v :- cancelled %v, squared off %v\n", exchanges, report.Cancelled, report.SquaredOff)
+
+	s.mu.Lock()
+	s.reports = append(s.reports, report)
+	fn := s.onSquareOff
+	s.mu.Unlock()
+	if fn != nil {
+		fn(report)
+	}
+	return report, err
+}
+
+// Reports lists square-offs done since start
+func (s *Scheduler) Reports() []Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.reports)
+}
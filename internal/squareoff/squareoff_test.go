@@ -0,0 +1,112 @@
+package squareoff_test
+
+import (
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+
+	"friction-trading/internal/calendar"
+	"friction-trading/internal/squareoff"
+)
+
+// stubBroker serves fixed account state and records orders
+type stubBroker struct {
+	positions kiteconnect.Positions
+	orders    kiteconnect.Orders
+
+	placed    []kiteconnect.OrderParams
+	cancelled []string
+}
+
+func (b *stubBroker) PlaceOrder(variety string, p kiteconnect.OrderParams) (string, error) {
+	b.placed = append(b.placed, p)
+	return "placed", nil
+}
+
+func (b *stubBroker) CancelOrder(variety, orderID string) error {
+	b.cancelled = append(b.cancelled, orderID)
+	return nil
+}
+
+func (b *stubBroker) Orders() (kiteconnect.Orders, error)       { return b.orders, nil }
+func (b *stubBroker) Positions() (kiteconnect.Positions, error) { return b.positions, nil }
+func (b *stubBroker) Margins() (kiteconnect.AllMargins, error)  { return kiteconnect.AllMargins{}, nil }
+func (b *stubBroker) OrderMargin(kiteconnect.OrderParams) (float64, error) {
+	return 0, nil
+}
+
+func (b *stubBroker) BasketMargin([]kiteconnect.OrderParams) (float64, error) {
+	return 0, nil
+}
+
+func TestNext(t *testing.T) {
+	s, err := squareoff.New(calendar.New(), &stubBroker{}, map[string]string{"NSE": "15:15", "NFO": "15:15", "MCX": "23:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Monday morning, equities first
+	now := time.Date(2025, 10, 20, 10, 0, 0, 0, calendar.IST)
+	next, exchanges := s.Next(now)
+	if want := time.Date(2025, 10, 20, 15, 15, 0, 0, calendar.IST); !next.Equal(want) || len(exchanges) != 2 {
+		t.Errorf("want NFO and NSE at %v, got %v %v", want, next, exchanges)
+	}
+
+	// After the equity cut-off MCX is next
+	next, exchanges = s.Next(next)
+	if want := time.Date(2025, 10, 20, 23, 0, 0, 0, calendar.IST); !next.Equal(want) || len(exchanges) != 1 || exchanges[0] != "MCX" {
+		t.Errorf("want MCX at %v, got %v %v", want, next, exchanges)
+	}
+
+	// Friday night rolls over the weekend
+	next, _ = s.Next(time.Date(2025, 10, 24, 23, 30, 0, 0, calendar.IST))
+	if next.Weekday() != time.Monday {
+		t.Errorf("want Monday cut-off after the weekend, got %v", next)
+	}
+
+	if _, err := squareoff.New(calendar.New(), &stubBroker{}, map[string]string{"NSE": "3pm"}); err == nil {
+		t.Error("want error for a bad time")
+	}
+}
+
+func TestSquareOff(t *testing.T) {
+	b := &stubBroker{}
+	b.orders = kiteconnect.Orders{
+		{OrderID: "1", Exchange: "NFO", TradingSymbol: "NIFTY25OCT25000CE", Tag: "ftorb", Status: "COMPLETE"},
+		{OrderID: "2", Exchange: "NFO", TradingSymbol: "NIFTY25OCT25000CE", Tag: "ftorb", Status: "OPEN", Variety: "regular"},
+		{OrderID: "3", Exchange: "NFO", TradingSymbol: "BANKNIFTY25OCTFUT", Tag: "manual", Status: "OPEN"},
+		{OrderID: "4", Exchange: "MCX", TradingSymbol: "CRUDEOIL25NOVFUT", Tag: "ftorb", Status: "OPEN"},
+	}
+	b.positions.Net = []kiteconnect.Position{
+		{Exchange: "NFO", Tradingsymbol: "NIFTY25OCT25000CE", Product: "MIS", Quantity: 75},
+		{Exchange: "NFO", Tradingsymbol: "BANKNIFTY25OCTFUT", Product: "MIS", Quantity: 35},
+		{Exchange: "MCX", Tradingsymbol: "CRUDEOIL25NOVFUT", Product: "MIS", Quantity: 100},
+	}
+
+	s, err := squareoff.New(calendar.New(), b, map[string]string{"NFO": "15:15"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hooked []squareoff.Report
+	s.OnSquareOff(func(r squareoff.Report) { hooked = append(hooked, r) })
+	report, err := s.SquareOff("NFO")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Manual orders and other exchanges are left alone
+	if len(b.cancelled) != 1 || b.cancelled[0] != "2" {
+		t.Errorf("want only our open NFO order cancelled, got %v", b.cancelled)
+	}
+	if len(b.placed) != 1 || b.placed[0].Tradingsymbol != "NIFTY25OCT25000CE" ||
+		b.placed[0].TransactionType != kiteconnect.TransactionTypeSell || b.placed[0].Quantity != 75 {
+		t.Errorf("want our NIFTY position sold, got %+v", b.placed)
+	}
+	if len(report.SquaredOff) != 1 || len(s.Reports()) != 1 {
+		t.Errorf("want the square-off reported, got %+v", report)
+	}
+	if len(hooked) != 1 || len(hooked[0].SquaredOff) != 1 || hooked[0].SquaredOff[0] != "NFO:NIFTY25OCT25000CE" {
+		t.Errorf("want the report passed on, got %+v", hooked)
+	}
+}
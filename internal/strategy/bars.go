@@ -0,0 +1,109 @@
+package strategy
+
+import (
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// Bars folds ticks into candles of one timeframe. A zero timeframe makes every tick its own candle.
+type Bars struct {
+	frame   time.Duration
+	current Candle
+	token   uint32 // of the current candle
+	open    bool
+	onClose func(token uint32, c Candle)
+}
+
+// ModeCandle marks a tick standing for a whole candle, as backtests replay history.
+// Bars folds in its OHLC rather than only its last price.
+const ModeCandle = "candle"
+
+// NewBars builds candles of frame from ticks
+func NewBars(frame time.Duration) *Bars {
+	return &Bars{frame: frame}
+}
+
+// Frame is the candle length, 0 for tick by tick
+func (b *Bars) Frame() time.Duration {
+	return b.frame
+}
+
+// OnClose hands fn every candle of the timeframe as it closes, with the token it was built from.
+// Tick by tick bars close no candles of their own, fn isn't called for them.
+func (b *Bars) OnClose(fn func(token uint32, c Candle)) {
+	b.onClose = fn
+}
+
+// TimeframeParam reads the "timeframe" param, in seconds, 0 or unset is tick by tick
+func TimeframeParam(p Params) time.Duration {
+	return time.Duration(p.Get("timeframe", 0) * float64(time.Second))
+}
+
+// Add folds a tick in and returns the candle it closed, if any.
+// Candles close on the first tick of the next bucket.
+func (b *Bars) Add(tick kitemodels.Tick) (Candle, bool) {
+	if b.frame <= 0 {
+		return TickCandle(tick), true
+	}
+
+	at := tick.Timestamp.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	start := bucket(at, b.frame)
+	open, high, low := tick.LastPrice, tick.LastPrice, tick.LastPrice
+	if tick.Mode == ModeCandle {
+		open, high, low = tick.OHLC.Open, tick.OHLC.High, tick.OHLC.Low
+	}
+
+	if b.open && start.Equal(b.current.Timestamp) {
+		b.current.High = max(b.current.High, high)
+		b.current.Low = min(b.current.Low, low)
+		b.current.Close = tick.LastPrice
+		b.current.LastPrice = tick.LastPrice
+		return Candle{}, false
+	}
+
+	closed, wasOpen, token := b.current, b.open, b.token
+	if wasOpen && b.onClose != nil {
+		b.onClose(token, closed)
+	}
+	b.token = tick.InstrumentToken
+	b.current = Candle{
+		Timestamp: start,
+		Open:      open,
+		High:      high,
+		Low:       low,
+		Close:     tick.LastPrice,
+		LastPrice: tick.LastPrice,
+	}
+	b.open = true
+	return closed, wasOpen
+}
+
+// 09:15 IST in UTC, buckets count from the open so hourly candles match the exchange's
+const openUTC = 3*time.Hour + 45*time.Minute
+
+func bucket(at time.Time, frame time.Duration) time.Time {
+	return at.Add(-openUTC).Truncate(frame).Add(openUTC)
+}
+
+// Resample folds candles, oldest first, into candles of frame on the same buckets as Bars
+func Resample(candles []Candle, frame time.Duration) []Candle {
+	var out []Candle
+	for _, c := range candles {
+		start := bucket(c.Timestamp, frame)
+		if n := len(out); n > 0 && out[n-1].Timestamp.Equal(start) {
+			last := &out[n-1]
+			last.High = max(last.High, c.High)
+			last.Low = min(last.Low, c.Low)
+			last.Close = c.Close
+			last.LastPrice = c.LastPrice
+			continue
+		}
+		c.Timestamp = start
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/huml-lang/go-huml"
+
+	"friction-trading/internal/risk"
+)
+
+// Where a definition's orders go
+const (
+	ModePaper = "paper"
+	ModeLive  = "live"
+)
+
+// Definition declares a strategy instance the server starts once logged in
+type Definition struct {
+	Name        string   `huml:"name" json:"name"`               // unique, tags the instance's orders
+	Strategy    string   `huml:"strategy" json:"strategy"`       // registered strategy, e.g. sma or supertrend
+	Instruments []string `huml:"instruments" json:"instruments"` // NSE:SBIN, SBIN or a spec like "NIFTY current-week ATM CE", an instance each
+	Timeframe   string   `huml:"timeframe" json:"timeframe"`     // tick (default) or a candle length like 5m
+	Params      Params   `huml:"params" json:"params"`           // strategy parameters, e.g. period, atr_period, multiplier
+	Entry       string   `huml:"entry" json:"entry,omitempty"`   // rules strategies, e.g. "close > supertrend(7,3) and rsi(14) < 70"
+	Exit        string   `huml:"exit" json:"exit,omitempty"`     // rules strategies
+	Mode        string   `huml:"mode" json:"mode"`               // paper (default) or live
+	Product     string   `huml:"product" json:"product"`         // MIS by default
+	Quantity    int      `huml:"quantity" json:"quantity"`       // units per entry without sizing, one lot by default
+	Short       bool     `huml:"short" json:"short,omitempty"`   // SELL signals open shorts, otherwise they only close longs
+
+	Sizing *DefinitionSizing `huml:"sizing" json:"sizing,omitempty"`
+
+	// Tightens the account's limits for this instance, 0 keeps the account's
+	Risk risk.Limits `huml:"risk" json:"risk"`
+}
+
+// DefinitionSizing picks a sizing policy for each signal's order
+type DefinitionSizing struct {
+	Policy string `huml:"policy" json:"policy"`
+	Params Params `huml:"params" json:"params"`
+}
+
+// definitionFile is the HUML strategy file layout
+type definitionFile struct {
+	Strategies []Definition `huml:"strategies"`
+}
+
+// LoadDefinitions reads and validates a HUML strategy file
+func LoadDefinitions(path string) ([]Definition, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error :- failed to read strategy file :- %v", err)
+	}
+
+	var file definitionFile
+	if err := huml.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("Error :- failed to load strategies :- %v", err)
+	}
+
+	seen := map[string]bool{}
+	for i := range file.Strategies {
+		def := &file.Strategies[i]
+		if err := def.Validate(); err != nil {
+			return nil, fmt.Errorf("strategy %d %q: %w", i+1, def.Name, err)
+		}
+		if seen[def.Name] {
+			return nil, fmt.Errorf("strategy %q is declared twice", def.Name)
+		}
+		seen[def.Name] = true
+	}
+	return file.Strategies, nil
+}
+
+// Validate checks a definition and fills in its defaults
+func (d *Definition) Validate() error {
+	if d.Name == "" {
+		return errors.New("name is required")
+	}
+	if d.Strategy == "" {
+		return errors.New("strategy is required")
+	}
+	if len(d.Instruments) == 0 {
+		return errors.New("at least one instrument is required")
+	}
+	if _, err := d.Frame(); err != nil {
+		return err
+	}
+	if d.Quantity < 0 {
+		return errors.New("quantity must not be negative")
+	}
+	if d.Sizing != nil && d.Sizing.Policy == "" {
+		return errors.New("sizing needs a policy")
+	}
+
+	d.Mode = strings.ToLower(d.Mode)
+	switch d.Mode {
+	case "":
+		d.Mode = ModePaper
+	case ModePaper, ModeLive:
+	default:
+		return fmt.Errorf("unknown mode %q, want paper or live", d.Mode)
+	}
+	return nil
+}
+
+// Frame is the candle length, 0 for tick by tick
+func (d Definition) Frame() (time.Duration, error) {
+	if d.Timeframe == "" || strings.EqualFold(d.Timeframe, "tick") {
+		return 0, nil
+	}
+	frame, err := time.ParseDuration(d.Timeframe)
+	if err != nil || frame < time.Second || frame%time.Second != 0 {
+		return 0, fmt.Errorf("invalid timeframe %q, want tick or whole seconds like 5m", d.Timeframe)
+	}
+	return frame, nil
+}
+
+// StartParams are the strategy's params with the timeframe folded in
+func (d Definition) StartParams() Params {
+	p := Params{}
+	for k, v := range d.Params {
+		p[k] = v
+	}
+	if frame, err := d.Frame(); err == nil && frame > 0 {
+		p["timeframe"] = frame.Seconds()
+	}
+	return p
+}
@@ -0,0 +1,89 @@
+package strategy_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"friction-trading/internal/strategy"
+)
+
+func TestLoadDefinitions(t *testing.T) {
+	defs, err := strategy.LoadDefinitions("testdata/strategies.huml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 3 {
+		t.Fatalf("want 3 definitions, got %+v", defs)
+	}
+
+	sma := defs[0]
+	if sma.Name != "sbin-sma" || sma.Strategy != "sma" || sma.Mode != strategy.ModePaper || sma.Quantity != 10 || sma.Risk.MaxOrderNotional != 100000 {
+		t.Errorf("unexpected sma definition %+v", sma)
+	}
+	if p := sma.StartParams(); p["period"] != 20 || p["timeframe"] != 300 {
+		t.Errorf("want period 20 on 5 minute candles, got %v", p)
+	}
+
+	st := defs[1]
+	if len(st.Instruments) != 2 || st.Mode != strategy.ModeLive || st.Product != "NRML" {
+		t.Errorf("unexpected supertrend definition %+v", st)
+	}
+	if st.Sizing == nil || st.Sizing.Policy != "fixed_risk" || st.Sizing.Params["risk"] != 2000 {
+		t.Errorf("want fixed risk sizing, got %+v", st.Sizing)
+	}
+	if p := st.StartParams(); p["multiplier"] != 3 || p["timeframe"] != 0 {
+		t.Errorf("want tick by tick with multiplier 3, got %v", p)
+	}
+
+	if r := defs[2]; r.Strategy != "rules" || r.Entry != "close > supertrend(7,3) and rsi(14) < 70" || r.Exit == "" {
+		t.Errorf("want entry and exit rules, got %+v", r)
+	}
+}
+
+func TestInvalidDefinitions(t *testing.T) {
+	for name, tc := range map[string]struct{ file, err string }{
+		"no instruments": {`strategies::
+  - ::
+    name: "a"
+    strategy: "sma"
+`, "instrument"},
+		"bad timeframe": {`strategies::
+  - ::
+    name: "a"
+    strategy: "sma"
+    instruments::
+      - "NSE:SBIN"
+    timeframe: "5 minutes"
+`, "timeframe"},
+		"bad mode": {`strategies::
+  - ::
+    name: "a"
+    strategy: "sma"
+    instruments::
+      - "NSE:SBIN"
+    mode: "demo"
+`, "mode"},
+		"duplicate": {`strategies::
+  - ::
+    name: "a"
+    strategy: "sma"
+    instruments::
+      - "NSE:SBIN"
+  - ::
+    name: "a"
+    strategy: "supertrend"
+    instruments::
+      - "NSE:SBIN"
+`, "twice"},
+	} {
+		path := filepath.Join(t.TempDir(), "strategies.huml")
+		if err := os.WriteFile(path, []byte(tc.file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := strategy.LoadDefinitions(path); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: want an error about %s, got %v", name, tc.err, err)
+		}
+	}
+}
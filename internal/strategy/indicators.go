@@ -0,0 +1,241 @@
+// Indicators computed over candles
+package strategy
+
+import (
+	"math"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	. "friction-trading/internal/utils"
+)
+
+// Candle represents a 5-minute OHLC candle.
+type Candle struct {
+	Timestamp time.Time
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	LastPrice float64
+}
+
+// TickCandle creates a Candle from a tick
+func TickCandle(tick kitemodels.Tick) Candle {
+	return Candle{
+		Timestamp: tick.Timestamp.Time,
+		Close:     tick.OHLC.Close,
+		Open:      tick.OHLC.Open,
+		Low:       tick.OHLC.Low,
+		High:      tick.OHLC.High,
+		LastPrice: tick.LastPrice,
+	}
+}
+
+func sumCandleData(candleData []Candle) float64 {
+	total := 0.0
+	for _, val := range candleData {
+		total += val.LastPrice
+	}
+	return float64(total)
+}
+
+// CalculateTR computes the True Range for each candle.
+func CalculateTR(candles []Candle) []float64 {
+	if len(candles) == 0 {
+		return nil
+	}
+	tr := make([]float64, len(candles))
+	tr[0] = candles[0].High - candles[0].Low // First TR is just High - Low
+	for i := 1; i < len(candles); i++ {
+		prevClose := candles[i-1].Close
+		tr[i] = max(candles[i].High-candles[i].Low,
+			max(Abs(candles[i].High-prevClose), Abs(candles[i].Low-prevClose)))
+	}
+	return tr
+}
+
+// CalculateATR computes the Average True Range (SMA of TR).
+func CalculateATR(tr []float64, period int) []float64 {
+	if period <= 0 || len(tr) == 0 {
+		return nil
+	}
+	atr := make([]float64, len(tr))
+	for i := 0; i < len(tr); i++ {
+		start := max(0, i-period+1)
+		sum := 0.0
+		for j := start; j <= i; j++ {
+			sum += tr[j]
+		}
+		count := float64(i - start + 1)
+		atr[i] = sum / count
+	}
+	return atr
+}
+
+// CalculateSupertrend computes the Supertrend indicator.
+func CalculateSupertrend(candles []Candle, atr []float64, multiplier float64) []float64 {
+	if len(candles) != len(atr) || len(candles) == 0 {
+		return nil
+	}
+	supertrend := make([]float64, len(candles))
+	var direction int = 1 // 1 for uptrend (lower band), -1 for downtrend (upper band)
+
+	for i := 0; i < len(candles); i++ {
+		basic := (candles[i].High + candles[i].Low) / 2
+		upper := basic + multiplier*atr[i]
+		lower := basic - multiplier*atr[i]
+
+		if i == 0 {
+			supertrend[i] = lower // Start with lower band assuming uptrend
+			continue
+		}
+
+		prevSuper := supertrend[i-1]
+		if direction == 1 { // Uptrend: use lower band, but adjust if crossed
+			supertrend[i] = max(lower, prevSuper)
+			if candles[i].Close < supertrend[i] {
+				direction = -1
+				supertrend[i] = upper
+			}
+		} else { // Downtrend: use upper band
+			supertrend[i] = min(upper, prevSuper)
+			if candles[i].Close > supertrend[i] {
+				direction = 1
+				supertrend[i] = lower
+			}
+		}
+	}
+	return supertrend
+}
+
+// GenerateSignals produces BUY or SELL signals based on Supertrend.
+// BUY when close > supertrend (start of uptrend), SELL when close < supertrend (start of downtrend).
+// For each candle, signal is based on crossover from previous.
+func GenerateSignals(candles []Candle, supertrend []float64) []string {
+	if len(candles) != len(supertrend) || len(candles) < 2 {
+		return nil
+	}
+	signals := make([]string, len(candles))
+	for i := 1; i < len(candles); i++ {
+		if candles[i-1].Close <= supertrend[i-1] && candles[i].Close > supertrend[i] {
+			signals[i] = "BUY"
+		} else if candles[i-1].Close >= supertrend[i-1] && candles[i].Close < supertrend[i] {
+			signals[i] = "SELL"
+		}
+	}
+	return signals
+}
+
+// Closes picks the close of each candle
+func Closes(candles []Candle) []float64 {
+	closes := make([]float64, len(candles))
+	for i, c := range candles {
+		closes[i] = c.Close
+	}
+	return closes
+}
+
+// CalculateSMA computes the simple moving average, NaN until period values are in
+func CalculateSMA(values []float64, period int) []float64 {
+	if period <= 0 {
+		return nil
+	}
+	sma := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		sma[i] = math.NaN()
+		if i >= period-1 {
+			sma[i] = sum / float64(period)
+		}
+	}
+	return sma
+}
+
+// CalculateEMA computes the exponential moving average seeded with the first SMA, NaN before it
+func CalculateEMA(values []float64, period int) []float64 {
+	if period <= 0 {
+		return nil
+	}
+	ema := make([]float64, len(values))
+	k := 2 / float64(period+1)
+	sum := 0.0
+	for i, v := range values {
+		switch {
+		case i < period-1:
+			sum += v
+			ema[i] = math.NaN()
+		case i == period-1:
+			ema[i] = (sum + v) / float64(period)
+		default:
+			ema[i] = v*k + ema[i-1]*(1-k)
+		}
+	}
+	return ema
+}
+
+// CalculateRSI computes Wilder's RSI, NaN until period changes are in
+func CalculateRSI(values []float64, period int) []float64 {
+	if period <= 0 {
+		return nil
+	}
+	rsi := make([]float64, len(values))
+	var gain, loss float64
+	for i := range values {
+		rsi[i] = math.NaN()
+		if i == 0 {
+			continue
+		}
+		change := values[i] - values[i-1]
+		up, down := max(change, 0), max(-change, 0)
+		if i <= period {
+			gain += up / float64(period)
+			loss += down / float64(period)
+			if i < period {
+				continue
+			}
+		} else {
+			gain = (gain*float64(period-1) + up) / float64(period)
+			loss = (loss*float64(period-1) + down) / float64(period)
+		}
+		if loss == 0 {
+			rsi[i] = 100
+		} else {
+			rsi[i] = 100 - 100/(1+gain/loss)
+		}
+	}
+	return rsi
+}
+
+// CalculateHighest computes the highest high of the last period candles, NaN until period candles are in
+func CalculateHighest(candles []Candle, period int) []float64 {
+	return rolling(candles, period, func(c Candle) float64 { return c.High }, math.Max)
+}
+
+// CalculateLowest computes the lowest low of the last period candles, NaN until period candles are in
+func CalculateLowest(candles []Candle, period int) []float64 {
+	return rolling(candles, period, func(c Candle) float64 { return c.Low }, math.Min)
+}
+
+func rolling(candles []Candle, period int, pick func(Candle) float64, better func(a, b float64) float64) []float64 {
+	if period <= 0 {
+		return nil
+	}
+	out := make([]float64, len(candles))
+	for i := range candles {
+		out[i] = math.NaN()
+		if i < period-1 {
+			continue
+		}
+		best := pick(candles[i])
+		for j := i - period + 1; j < i; j++ {
+			best = better(best, pick(candles[j]))
+		}
+		out[i] = best
+	}
+	return out
+}
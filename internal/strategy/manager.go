@@ -0,0 +1,472 @@
+// Strategy lifecycle :- start, stop, list and inspect running strategies
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/depth"
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown strategy")
+	ErrAlreadyRunning  = errors.New("strategy already running on these instruments")
+	ErrNotFound        = errors.New("strategy instance not found")
+	ErrNoInstruments   = errors.New("no instruments given")
+	ErrSingleSeries    = errors.New("strategy reads one instrument's candles, start an instance per instrument")
+)
+
+// Params are numeric strategy settings, e.g. {"period": 10}
+type Params map[string]float64
+
+// Get returns the param value or def when it isn't set
+func (p Params) Get(key string, def float64) float64 {
+	if v, ok := p[key]; ok {
+		return v
+	}
+	return def
+}
+
+// Strategy reacts to ticks of the instruments it runs on
+type Strategy interface {
+	OnTick(tick kitemodels.Tick)
+}
+
+// Stater is implemented by strategies which expose their state for inspection.
+// State is called on the instance goroutine after every tick.
+type Stater interface {
+	State() map[string]any
+}
+
+// DepthAware is implemented by strategies which read the order book.
+// OnDepth is called on the instance goroutine just before OnTick, for ticks with a two sided book.
+type DepthAware interface {
+	OnDepth(f depth.Features)
+}
+
+// Signal is a strategy's call to buy or sell an instrument
+type Signal struct {
+	Strategy  string             `json:"strategy"` // instance ID, filled in by the manager
+	Token     uint32             `json:"instrument_token"`
+	Timestamp time.Time          `json:"timestamp"`
+	Side      string             `json:"side"` // BUY or SELL
+	Price     float64            `json:"price"`
+	Values    map[string]float64 `json:"values,omitempty"` // indicators behind the call
+}
+
+// Signaller is implemented by strategies which emit signals.
+// Signals is called on the instance goroutine after every tick and drains what the tick raised.
+type Signaller interface {
+	Signals() []Signal
+}
+
+// Charted is implemented by strategies which fold ticks into candles. They read one
+// series, so they run on a single instrument. The manager publishes the candles their
+// Bars close, see OnCandle.
+type Charted interface {
+	Bars() *Bars
+}
+
+// CandleEvent is a candle closed by a strategy instance's Bars
+type CandleEvent struct {
+	Strategy  string  `json:"strategy"` // instance ID
+	Token     uint32  `json:"instrument_token"`
+	Timeframe float64 `json:"timeframe"` // in seconds, as the param
+	Candle
+}
+
+// Warmer is implemented by strategies which prime their indicators from history.
+// Warm is called on the instance goroutine before the first tick, once per token with
+// that token's candles. Tick by tick strategies get minute candles. Charted strategies
+// run on one token, so their buffers only ever hold one instrument's history.
+type Warmer interface {
+	Warmup() (frame time.Duration, candles int) // candle timeframe and how many closed candles to prime with
+	Warm(token uint32, candles []Candle)
+}
+
+// History fetches the last count closed candles of frame for token, oldest first
+type History func(ctx context.Context, token uint32, frame time.Duration, count int) ([]Candle, error)
+
+// Factory builds a strategy from its params
+type Factory func(params Params) (Strategy, error)
+
+// Feed streams ticks for tokens into onTick until ctx is done
+type Feed func(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error
+
+// Status of a strategy instance
+type Status string
+
+const (
+	StatusRunning Status = "running"
+	StatusStopped Status = "stopped"
+	StatusFailed  Status = "failed"
+)
+
+// Info describes a strategy instance
+type Info struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Params    Params         `json:"params"`
+	Tokens    []uint32       `json:"tokens"`
+	Status    Status         `json:"status"`
+	StartedAt time.Time      `json:"started_at"`
+	StoppedAt *time.Time     `json:"stopped_at,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Warmed    int            `json:"warmed,omitempty"`       // candles primed from history
+	WarmupErr string         `json:"warmup_error,omitempty"` // the instance runs cold when history fails
+	State     map[string]any `json:"state,omitempty"`
+}
+
+type instance struct {
+	info     Info
+	strategy Strategy
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// Manager owns all strategy instances
+type Manager struct {
+	ctx  context.Context
+	feed Feed
+
+	mu        sync.Mutex
+	factories map[string]Factory
+	instances map[string]*instance
+	onSignal  func(Signal)
+	onCandle  func(CandleEvent)
+	history   History
+}
+
+// NewManager returns a manager whose instances live until ctx is done
+func NewManager(ctx context.Context, feed Feed) *Manager {
+	return &Manager{
+		ctx:       ctx,
+		feed:      feed,
+		factories: map[string]Factory{},
+		instances: map[string]*instance{},
+	}
+}
+
+// Register makes a strategy available by name
+func (m *Manager) Register(name string, f Factory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.factories[name] = f
+}
+
+// OnSignal hands every signal raised by an instance to fn, on the instance goroutine
+func (m *Manager) OnSignal(fn func(Signal)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onSignal = fn
+}
+
+// OnCandle hands every candle an instance's Bars close to fn, on the instance goroutine
+func (m *Manager) OnCandle(fn func(CandleEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onCandle = fn
+}
+
+// SetHistory lets instances of Warmer strategies prime from h before their first tick
+func (m *Manager) SetHistory(h History) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history = h
+}
+
+// Names lists registered strategies
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.factories))
+	for name := range m.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build makes the named strategy without running it, for backtests
+func (m *Manager) Build(name string, params Params) (Strategy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if params == nil {
+		params = Params{}
+	}
+	return m.build(name, params)
+}
+
+// build runs a factory, callers hold m.mu
+func (m *Manager) build(name string, params Params) (Strategy, error) {
+	factory, ok := m.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, name)
+	}
+	strat, err := factory(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid params for %q: %w", name, err)
+	}
+	return strat, nil
+}
+
+// InstanceID is the same for a strategy started twice on the same instruments
+func InstanceID(name string, tokens []uint32) string {
+	sorted := slices.Clone(tokens)
+	slices.Sort(sorted)
+	ids := make([]string, len(sorted))
+	for i, t := range sorted {
+		ids[i] = strconv.FormatUint(uint64(t), 10)
+	}
+	return name + ":" + strings.Join(ids, ",")
+}
+
+// Start runs the named strategy on tokens
+func (m *Manager) Start(name string, params Params, tokens []uint32) (Info, error) {
+	if len(tokens) == 0 {
+		return Info{}, ErrNoInstruments
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := InstanceID(name, tokens)
+	if inst, ok := m.instances[id]; ok && inst.info.Status == StatusRunning {
+		return Info{}, fmt.Errorf("%w: %s", ErrAlreadyRunning, id)
+	}
+
+	if params == nil {
+		params = Params{}
+	}
+	strat, err := m.build(name, params)
+	if err != nil {
+		return Info{}, err
+	}
+	ch, charted := strat.(Charted)
+	if charted && len(tokens) > 1 {
+		return Info{}, fmt.Errorf("%w: %q on %d instruments", ErrSingleSeries, name, len(tokens))
+	}
+	if charted && m.onCandle != nil {
+		onCandle, bars := m.onCandle, ch.Bars()
+		bars.OnClose(func(token uint32, c Candle) {
+			onCandle(CandleEvent{Strategy: id, Token: token, Timeframe: bars.Frame().Seconds(), Candle: c})
+		})
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	inst := &instance{
+		info: Info{
+			ID:        id,
+			Name:      name,
+			Params:    params,
+			Tokens:    slices.Clone(tokens),
+			Status:    StatusRunning,
+			StartedAt: time.Now(),
+		},
+		strategy: strat,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	m.instances[id] = inst
+
+	go m.run(ctx, inst)
+
+	return inst.snapshot(), nil
+}
+
+// Ticks buffered between the feed and an instance
+const tickQueue = 1024
+
+// run feeds ticks to the instance until it stops or the feed fails.
+// Only the owner goroutine touches the strategy, others read its published state.
+func (m *Manager) run(ctx context.Context, inst *instance) {
+	defer close(inst.done)
+
+	ticks := make(chan kitemodels.Tick, tickQueue)
+	owned := make(chan struct{})
+	go func() {
+		defer close(owned)
+		m.warm(ctx, inst)
+		book := depth.NewTracker()
+		for tick := range ticks {
+			if da, ok := inst.strategy.(DepthAware); ok {
+				if f, ok := book.Update(tick); ok {
+					da.OnDepth(f)
+				}
+			}
+			inst.strategy.OnTick(tick)
+			if sg, ok := inst.strategy.(Signaller); ok {
+				m.emit(inst.info.ID, tick, sg.Signals())
+			}
+			if st, ok := inst.strategy.(Stater); ok {
+				state := st.State()
+				m.mu.Lock()
+				inst.info.State = state
+				m.mu.Unlock()
+			}
+		}
+	}()
+
+	err := m.feed(ctx, inst.info.Tokens, func(tick kitemodels.Tick) {
+		select {
+		case ticks <- tick:
+		case <-ctx.Done():
+		}
+	})
+	close(ticks)
+	<-owned
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if inst.info.Status != StatusRunning {
+		return
+	}
+	now := time.Now()
+	inst.info.StoppedAt = &now
+	inst.info.Status = StatusStopped
+	if err != nil && ctx.Err() == nil {
+		inst.info.Status = StatusFailed
+		inst.info.Error = err.Error()
+	}
+}
+
+// warm primes the strategy from history, ticks queue up meanwhile
+func (m *Manager) warm(ctx context.Context, inst *instance) {
+	w, ok := inst.strategy.(Warmer)
+	m.mu.Lock()
+	history := m.history
+	m.mu.Unlock()
+	if !ok || history == nil {
+		return
+	}
+
+	// Tick by tick strategies prime from minute candle closes
+	frame, count := w.Warmup()
+	if count <= 0 {
+		return
+	}
+	if frame <= 0 {
+		frame = time.Minute
+	}
+	warmed, errs := 0, []error{}
+	for _, token := range inst.info.Tokens {
+		candles, err := history(ctx, token, frame, count)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%d: %w", token, err))
+			continue
+		}
+		w.Warm(token, candles)
+		warmed += len(candles)
+	}
+
+	var state map[string]any
+	if st, ok := inst.strategy.(Stater); ok {
+		state = st.State()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inst.info.Warmed = warmed
+	inst.info.State = state
+	if err := errors.Join(errs...); err != nil {
+		inst.info.WarmupErr = err.Error()
+	}
+}
+
+// emit stamps signals with their instance and hands them on
+func (m *Manager) emit(id string, tick kitemodels.Tick, signals []Signal) {
+	if len(signals) == 0 {
+		return
+	}
+	m.mu.Lock()
+	onSignal := m.onSignal
+	m.mu.Unlock()
+	if onSignal == nil {
+		return
+	}
+	for _, sig := range signals {
+		sig.Strategy = id
+		if sig.Token == 0 {
+			sig.Token = tick.InstrumentToken
+		}
+		if sig.Timestamp.IsZero() {
+			sig.Timestamp = tick.Timestamp.Time
+		}
+		onSignal(sig)
+	}
+}
+
+// Stop stops a running instance
+func (m *Manager) Stop(id string) (Info, error) {
+	m.mu.Lock()
+	inst, ok := m.instances[id]
+	if !ok {
+		m.mu.Unlock()
+		return Info{}, ErrNotFound
+	}
+	if inst.info.Status == StatusRunning {
+		now := time.Now()
+		inst.info.Status = StatusStopped
+		inst.info.StoppedAt = &now
+	}
+	inst.cancel()
+	m.mu.Unlock()
+
+	// Give the feed a moment to shut down cleanly
+	select {
+	case <-inst.done:
+	case <-time.After(5 * time.Second):
+	}
+
+	return m.Get(id)
+}
+
+// StopAll stops every running instance
+func (m *Manager) StopAll() {
+	for _, info := range m.List() {
+		if info.Status == StatusRunning {
+			_, _ = m.Stop(info.ID)
+		}
+	}
+}
+
+// Get returns an instance by id
+func (m *Manager) Get(id string) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inst, ok := m.instances[id]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return inst.snapshot(), nil
+}
+
+// List returns all instances, running and stopped
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := make([]Info, 0, len(m.instances))
+	for _, inst := range m.instances {
+		infos = append(infos, inst.snapshot())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// snapshot copies the instance info, callers hold m.mu
+func (inst *instance) snapshot() Info {
+	info := inst.info
+	info.Params = maps.Clone(info.Params)
+	info.Tokens = slices.Clone(info.Tokens)
+	info.State = maps.Clone(info.State)
+	return info
+}
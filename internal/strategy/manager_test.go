@@ -0,0 +1,438 @@
+package strategy_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+
+	"friction-trading/internal/depth"
+	"friction-trading/internal/strategy"
+)
+
+// idleFeed blocks until the instance is stopped
+func idleFeed(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error {
+	<-ctx.Done()
+	return nil
+}
+
+type noopStrategy struct{}
+
+func (noopStrategy) OnTick(kitemodels.Tick) {}
+
+func newNoop(p strategy.Params) (strategy.Strategy, error) {
+	if p.Get("period", 1) < 1 {
+		return nil, errors.New("period must be at least 1")
+	}
+	return noopStrategy{}, nil
+}
+
+func TestManagerLifecycle(t *testing.T) {
+	m := strategy.NewManager(context.Background(), idleFeed)
+	m.Register("noop", newNoop)
+
+	info, err := m.Start("noop", strategy.Params{"period": 5}, []uint32{2, 1})
+	if err != nil {
+		t.Fatal("Start Error :- ", err)
+	}
+	if info.ID != "noop:1,2" || info.Status != strategy.StatusRunning {
+		t.Errorf("unexpected instance %+v", info)
+	}
+
+	// Same instruments in any order is a duplicate
+	if _, err := m.Start("noop", nil, []uint32{1, 2}); !errors.Is(err, strategy.ErrAlreadyRunning) {
+		t.Errorf("want ErrAlreadyRunning, got %v", err)
+	}
+	if _, err := m.Start("missing", nil, []uint32{1}); !errors.Is(err, strategy.ErrUnknownStrategy) {
+		t.Errorf("want ErrUnknownStrategy, got %v", err)
+	}
+	if _, err := m.Start("noop", strategy.Params{"period": 0}, []uint32{3}); err == nil {
+		t.Error("want invalid params error")
+	}
+	if _, err := m.Start("noop", nil, nil); !errors.Is(err, strategy.ErrNoInstruments) {
+		t.Errorf("want ErrNoInstruments, got %v", err)
+	}
+
+	if got := len(m.List()); got != 1 {
+		t.Errorf("want 1 instance, got %d", got)
+	}
+
+	stopped, err := m.Stop(info.ID)
+	if err != nil {
+		t.Fatal("Stop Error :- ", err)
+	}
+	if stopped.Status != strategy.StatusStopped || stopped.StoppedAt == nil {
+		t.Errorf("want stopped instance, got %+v", stopped)
+	}
+
+	// A stopped instance can be started again
+	if _, err := m.Start("noop", nil, []uint32{1, 2}); err != nil {
+		t.Errorf("restart Error :- %v", err)
+	}
+	m.StopAll()
+
+	if _, err := m.Stop("noop:9"); !errors.Is(err, strategy.ErrNotFound) {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+}
+
+func TestManagerFeedFailure(t *testing.T) {
+	done := make(chan struct{})
+	m := strategy.NewManager(context.Background(), func(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error {
+		defer close(done)
+		return errors.New("ticker gave up reconnecting")
+	})
+	m.Register("noop", newNoop)
+
+	info, err := m.Start("noop", nil, []uint32{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	// Wait for the instance to record the failure
+	for {
+		got, _ := m.Get(info.ID)
+		if got.Status != strategy.StatusRunning {
+			if got.Status != strategy.StatusFailed || got.Error == "" {
+				t.Errorf("want failed instance, got %+v", got)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// priceFeed pushes n ticks per token, priced by token, then idles
+func priceFeed(n int) strategy.Feed {
+	return func(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error {
+		for i := 0; i < n; i++ {
+			for _, token := range tokens {
+				onTick(kitemodels.Tick{InstrumentToken: token, LastPrice: float64(token)})
+			}
+		}
+		<-ctx.Done()
+		return nil
+	}
+}
+
+func TestConcurrentInstances(t *testing.T) {
+	const ticks = 200
+	m := strategy.NewManager(context.Background(), priceFeed(ticks))
+	m.Register("sma", strategy.NewSMA)
+	m.Register("supertrend", strategy.NewSupertrend)
+
+	tokens := []uint32{100, 200, 300, 400}
+	for _, token := range tokens {
+		if _, err := m.Start("sma", strategy.Params{"period": 5}, []uint32{token}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Start("supertrend", nil, []uint32{token}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Inspect while the instances are ticking
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		settled := 0
+		for _, token := range tokens {
+			info, err := m.Get(strategy.InstanceID("sma", []uint32{token}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.State["sma"] == float64(token) && info.State["candles"] == 5 {
+				settled++
+			}
+		}
+		_ = m.List()
+		if settled == len(tokens) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("instances did not settle: %+v", m.List())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	m.StopAll()
+	for _, info := range m.List() {
+		if info.Status != strategy.StatusStopped {
+			t.Errorf("want %s stopped, got %s", info.ID, info.Status)
+		}
+	}
+}
+
+// bookStrategy records the order book imbalance it was shown
+type bookStrategy struct {
+	imbalance float64
+	books     int
+}
+
+func (st *bookStrategy) OnDepth(f depth.Features) {
+	st.imbalance = f.Imbalance
+	st.books++
+}
+
+func (st *bookStrategy) OnTick(kitemodels.Tick) {}
+
+func (st *bookStrategy) State() map[string]any {
+	return map[string]any{"imbalance": st.imbalance, "books": st.books}
+}
+
+func TestDepthAware(t *testing.T) {
+	m := strategy.NewManager(context.Background(), func(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error {
+		onTick(kitemodels.Tick{InstrumentToken: tokens[0], LastPrice: 100})
+		tick := kitemodels.Tick{InstrumentToken: tokens[0], LastPrice: 100}
+		tick.Depth.Buy[0] = kitemodels.DepthItem{Price: 99.95, Quantity: 300}
+		tick.Depth.Sell[0] = kitemodels.DepthItem{Price: 100.05, Quantity: 100}
+		onTick(tick)
+		<-ctx.Done()
+		return nil
+	})
+	m.Register("book", func(strategy.Params) (strategy.Strategy, error) { return &bookStrategy{}, nil })
+
+	info, err := m.Start("book", nil, []uint32{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAll()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := m.Get(info.ID)
+		if got.State["books"] == 1 {
+			if got.State["imbalance"] != 0.5 {
+				t.Errorf("want imbalance 0.5, got %+v", got.State)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("strategy never saw the book, got %+v", got.State)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSMASignals(t *testing.T) {
+	m := strategy.NewManager(context.Background(), func(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error {
+		for _, price := range []float64{100, 100, 110, 90} {
+			onTick(kitemodels.Tick{InstrumentToken: tokens[0], LastPrice: price})
+		}
+		<-ctx.Done()
+		return nil
+	})
+	m.Register("sma", strategy.NewSMA)
+
+	signals := make(chan strategy.Signal, 4)
+	m.OnSignal(func(sig strategy.Signal) { signals <- sig })
+
+	if _, err := m.Start("sma", nil, []uint32{7, 8}); !errors.Is(err, strategy.ErrSingleSeries) {
+		t.Errorf("want one series per SMA instance, got %v", err)
+	}
+	info, err := m.Start("sma", strategy.Params{"period": 2}, []uint32{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAll()
+
+	// Starts bearish at 100, crosses up at 110 and back down at 90
+	want := []struct {
+		side       string
+		price, sma float64
+	}{{"BUY", 110, 105}, {"SELL", 90, 100}}
+	for _, w := range want {
+		select {
+		case sig := <-signals:
+			if sig.Strategy != info.ID || sig.Token != 7 || sig.Side != w.side || sig.Price != w.price || sig.Values["sma"] != w.sma {
+				t.Errorf("want %s at %.2f with SMA %.2f, got %+v", w.side, w.price, w.sma, sig)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s signal", w.side)
+		}
+	}
+}
+
+func TestBars(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	bars := strategy.NewBars(5 * time.Minute)
+	tick := func(hhmm string, price float64) (strategy.Candle, bool) {
+		at, _ := time.ParseInLocation("15:04:05", hhmm, ist)
+		tk := kitemodels.Tick{LastPrice: price}
+		tk.Timestamp.Time = time.Date(2025, 10, 20, at.Hour(), at.Minute(), at.Second(), 0, ist)
+		return bars.Add(tk)
+	}
+
+	for _, tc := range []struct {
+		at    string
+		price float64
+	}{{"09:15:01", 100}, {"09:17:30", 104}, {"09:19:59", 98}, {"09:19:59", 101}} {
+		if _, closed := tick(tc.at, tc.price); closed {
+			t.Fatalf("candle closed early at %s", tc.at)
+		}
+	}
+	candle, closed := tick("09:20:00", 102)
+	if !closed {
+		t.Fatal("want the 09:15 candle closed by the 09:20 tick")
+	}
+	if candle.Timestamp.In(ist).Format("15:04") != "09:15" || candle.Open != 100 || candle.High != 104 || candle.Low != 98 || candle.Close != 101 {
+		t.Errorf("unexpected candle %+v", candle)
+	}
+
+	// Replayed candles fold in their whole range
+	bars = strategy.NewBars(10 * time.Minute)
+	for i, c := range []strategy.Candle{{Open: 100, High: 108, Low: 99, Close: 103}, {Open: 103, High: 105, Low: 95, Close: 97}, {Open: 97, High: 98, Low: 96, Close: 98}} {
+		c.Timestamp = time.Date(2025, 10, 20, 9, 15+5*i, 0, 0, ist)
+		tk := kitemodels.Tick{Mode: strategy.ModeCandle, LastPrice: c.Close, OHLC: kitemodels.OHLC{Open: c.Open, High: c.High, Low: c.Low, Close: c.Close}}
+		tk.Timestamp.Time = c.Timestamp
+		candle, closed = bars.Add(tk)
+	}
+	if !closed || candle.Open != 100 || candle.High != 108 || candle.Low != 95 || candle.Close != 97 {
+		t.Errorf("want the two 5m candles folded into one, got %+v", candle)
+	}
+}
+
+func TestCandleEvents(t *testing.T) {
+	start := time.Date(2025, 10, 20, 3, 45, 0, 0, time.UTC)
+	m := strategy.NewManager(context.Background(), func(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error {
+		for i, price := range []float64{100, 104, 98, 101, 102} {
+			tick := kitemodels.Tick{InstrumentToken: tokens[0], LastPrice: price}
+			tick.Timestamp.Time = start.Add(time.Duration(i) * 15 * time.Second)
+			onTick(tick)
+		}
+		<-ctx.Done()
+		return nil
+	})
+	m.Register("sma", strategy.NewSMA)
+
+	candles := make(chan strategy.CandleEvent, 2)
+	m.OnCandle(func(c strategy.CandleEvent) { candles <- c })
+
+	info, err := m.Start("sma", strategy.Params{"timeframe": 60}, []uint32{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAll()
+
+	// The fifth tick opens the next minute and closes the first
+	select {
+	case c := <-candles:
+		if c.Strategy != info.ID || c.Token != 7 || c.Timeframe != 60 || !c.Timestamp.Equal(start) ||
+			c.Open != 100 || c.High != 104 || c.Low != 98 || c.Close != 101 {
+			t.Errorf("unexpected candle %+v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no candle published")
+	}
+	select {
+	case c := <-candles:
+		t.Errorf("want the forming candle held back, got %+v", c)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	at := func(hh, mm, ss int) time.Time { return time.Date(2025, 10, 20, hh, mm, ss, 0, ist) }
+	m := strategy.NewManager(context.Background(), func(ctx context.Context, tokens []uint32, onTick func(kitemodels.Tick)) error {
+		for _, ts := range []time.Time{at(9, 18, 10), at(9, 19, 0)} {
+			tick := kitemodels.Tick{InstrumentToken: tokens[0], LastPrice: 110}
+			tick.Timestamp.Time = ts
+			onTick(tick)
+		}
+		<-ctx.Done()
+		return nil
+	})
+	m.Register("sma", strategy.NewSMA)
+
+	m.SetHistory(func(ctx context.Context, token uint32, frame time.Duration, count int) ([]strategy.Candle, error) {
+		if token == 9 {
+			return nil, errors.New("no history")
+		}
+		if frame != time.Minute || count != 3 {
+			t.Errorf("want 3 one minute candles, asked for %d of %s", count, frame)
+		}
+		candles := make([]strategy.Candle, count)
+		for i := range candles {
+			candles[i] = strategy.Candle{Timestamp: at(9, 15+i, 0), Open: 100, High: 100, Low: 100, Close: 100, LastPrice: 100}
+		}
+		return candles, nil
+	})
+
+	signals := make(chan strategy.Signal, 4)
+	m.OnSignal(func(sig strategy.Signal) { signals <- sig })
+
+	info, err := m.Start("sma", strategy.Params{"period": 3, "timeframe": 60}, []uint32{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAll()
+
+	// Primed flat at 100, the first live candle crosses up
+	select {
+	case sig := <-signals:
+		if sig.Strategy != info.ID || sig.Side != "BUY" || sig.Price != 110 {
+			t.Errorf("want BUY at 110 on the first live candle, got %+v", sig)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no signal on the first live candle")
+	}
+	if info, _ = m.Get(info.ID); info.Warmed != 3 || info.WarmupErr != "" {
+		t.Errorf("want 3 candles warmed, got %+v", info)
+	}
+
+	// History failing leaves the instance running cold
+	info, err = m.Start("sma", strategy.Params{"period": 3, "timeframe": 60}, []uint32{9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for info.WarmupErr == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		info, _ = m.Get(info.ID)
+	}
+	if info.Status != strategy.StatusRunning || info.Warmed != 0 || info.WarmupErr == "" {
+		t.Errorf("want a running cold instance with a warmup error, got %+v", info)
+	}
+
+	// Tick by tick strategies prime from minute closes
+	info, err = m.Start("sma", strategy.Params{"period": 3}, []uint32{11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for info.Warmed == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		info, _ = m.Get(info.ID)
+	}
+	if info.Warmed != 3 || info.State["candles"] != 3 {
+		t.Errorf("want a tick by tick instance primed from 3 minute candles, got %+v", info)
+	}
+}
+
+func TestResample(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	var minutes []strategy.Candle
+	for i, price := range []float64{100, 103, 99, 101, 102, 104} {
+		minutes = append(minutes, strategy.Candle{
+			Timestamp: time.Date(2025, 10, 20, 9, 15+i, 0, 0, ist),
+			Open:      price, High: price + 1, Low: price - 1, Close: price, LastPrice: price,
+		})
+	}
+	got := strategy.Resample(minutes, 3*time.Minute)
+	want := []strategy.Candle{
+		{Open: 100, High: 104, Low: 98, Close: 99},
+		{Open: 101, High: 105, Low: 100, Close: 104},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d candles, got %+v", len(want), got)
+	}
+	for i, w := range want {
+		c := got[i]
+		if c.Timestamp.In(ist).Format("15:04") != []string{"09:15", "09:18"}[i] || c.Open != w.Open || c.High != w.High || c.Low != w.Low || c.Close != w.Close {
+			t.Errorf("candle %d: want %+v, got %+v", i, w, c)
+		}
+	}
+}
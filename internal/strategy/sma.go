@@ -0,0 +1,120 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// Default SMA lookback in candles
+const defaultSMAPeriod = 10
+
+// SMA Strategy :- close crossing the SMA of the last `period` candles
+type SMA struct {
+	period int
+	bars   *Bars
+
+	// Owned by the instance goroutine
+	candles  []Candle
+	position string
+	sma      float64
+	ltp      float64
+	signals  []Signal
+}
+
+// NewSMA builds an SMA strategy, params :- period, timeframe
+func NewSMA(p Params) (Strategy, error) {
+	period := int(p.Get("period", defaultSMAPeriod))
+	if period < 1 {
+		return nil, errors.New("period must be at least 1")
+	}
+	return &SMA{period: period, bars: NewBars(TimeframeParam(p)), position: "NONE"}, nil
+}
+
+// Triggered when tick is recevived
+func (st *SMA) OnTick(tick kitemodels.Tick) {
+	st.ltp = tick.LastPrice
+	candle, closed := st.bars.Add(tick)
+	if !closed {
+		return
+	}
+	side := st.onCandle(candle)
+	if len(st.candles) < st.period {
+		return
+	}
+
+	fmt.Printf("LTP :- %.2f, | SMA :- %.2f\n", candle.LastPrice, st.sma)
+	if side != "" {
+		fmt.Printf("%s at :- %.2f\n", side, candle.LastPrice)
+		st.signals = append(st.signals, Signal{
+			Token:  tick.InstrumentToken,
+			Side:   side,
+			Price:  candle.LastPrice,
+			Values: map[string]float64{"sma": st.sma, "period": float64(st.period)},
+		})
+	}
+}
+
+// onCandle moves the SMA and position on a closed candle and returns the side it crossed to, if any
+func (st *SMA) onCandle(candle Candle) string {
+	st.candles = append(st.candles, candle)
+	if len(st.candles) > st.period {
+		st.candles = st.candles[len(st.candles)-st.period:]
+	}
+	if len(st.candles) < st.period {
+		return ""
+	}
+
+	price := candle.LastPrice
+	st.sma = sumCandleData(st.candles) / float64(st.period)
+	switch {
+	case price > st.sma && st.position == "BEAR":
+		st.position = "BULL"
+		return "BUY"
+	case price < st.sma && st.position == "BULL":
+		st.position = "BEAR"
+		return "SELL"
+	case st.position == "NONE": // Set inital status
+		if price > st.sma {
+			st.position = "BULL"
+		} else {
+			st.position = "BEAR"
+		}
+	}
+	return ""
+}
+
+// Warmup asks for a period of candles
+func (st *SMA) Warmup() (time.Duration, int) {
+	return st.bars.Frame(), st.period
+}
+
+// Warm primes the SMA and position from history, without signalling
+func (st *SMA) Warm(token uint32, candles []Candle) {
+	for _, c := range candles {
+		st.onCandle(c)
+	}
+}
+
+// Bars the strategy folds ticks into
+func (st *SMA) Bars() *Bars {
+	return st.bars
+}
+
+// Signals raised by the last tick
+func (st *SMA) Signals() []Signal {
+	signals := st.signals
+	st.signals = nil
+	return signals
+}
+
+func (st *SMA) State() map[string]any {
+	return map[string]any{
+		"position": st.position,
+		"candles":  len(st.candles),
+		"sma":      st.sma,
+		"ltp":      st.ltp,
+	}
+}
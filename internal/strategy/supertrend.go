@@ -0,0 +1,116 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	kitemodels "github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// Supertrend candle history kept per instance
+const supertrendWindow = 200
+
+// Supertrend Strategy :- signals on Supertrend crossovers
+type Supertrend struct {
+	atrPeriod  int
+	multiplier float64
+	bars       *Bars
+
+	// Owned by the instance goroutine
+	candles    []Candle
+	supertrend float64
+	signal     string
+	signals    []Signal
+}
+
+// NewSupertrend builds a Supertrend strategy, params :- atr_period, multiplier, timeframe
+func NewSupertrend(p Params) (Strategy, error) {
+	atrPeriod := int(p.Get("atr_period", 7)) // Standard ATR period
+	multiplier := p.Get("multiplier", 3.0)   // Standard multiplier
+	if atrPeriod < 1 || multiplier <= 0 {
+		return nil, errors.New("atr_period and multiplier must be positive")
+	}
+	return &Supertrend{atrPeriod: atrPeriod, multiplier: multiplier, bars: NewBars(TimeframeParam(p))}, nil
+}
+
+func (st *Supertrend) OnTick(tick kitemodels.Tick) {
+	candle, closed := st.bars.Add(tick)
+	if !closed {
+		return
+	}
+	signal, atr := st.onCandle(candle)
+	if signal == "" {
+		return
+	}
+
+	fmt.Printf("At %s (close %.4f): %s\n", candle.Timestamp.Format("2006-01-02 15:04"), candle.Close, signal)
+	st.signals = append(st.signals, Signal{
+		Token:     tick.InstrumentToken,
+		Timestamp: candle.Timestamp,
+		Side:      signal,
+		Price:     candle.Close,
+		Values: map[string]float64{
+			"supertrend": st.supertrend,
+			"atr":        atr,
+			"atr_period": float64(st.atrPeriod),
+			"multiplier": st.multiplier,
+		},
+	})
+}
+
+// onCandle moves the Supertrend on a closed candle and returns the crossover it made, if any, with the ATR
+func (st *Supertrend) onCandle(candle Candle) (string, float64) {
+	st.candles = append(st.candles, candle)
+	if len(st.candles) > supertrendWindow {
+		st.candles = st.candles[len(st.candles)-supertrendWindow:]
+	}
+
+	tr := CalculateTR(st.candles)
+	atr := CalculateATR(tr, st.atrPeriod)
+	supertrend := CalculateSupertrend(st.candles, atr, st.multiplier)
+	signals := GenerateSignals(st.candles, supertrend)
+	if len(signals) == 0 {
+		return "", 0
+	}
+
+	// Only the latest candle can produce a new signal
+	last := len(st.candles) - 1
+	st.supertrend = supertrend[last]
+	if signals[last] != "" {
+		st.signal = signals[last]
+	}
+	return signals[last], atr[last]
+}
+
+// Warmup asks for a full window, the Supertrend depends on its whole history
+func (st *Supertrend) Warmup() (time.Duration, int) {
+	return st.bars.Frame(), supertrendWindow
+}
+
+// Warm primes the ATR and Supertrend from history, without signalling
+func (st *Supertrend) Warm(token uint32, candles []Candle) {
+	for _, c := range candles {
+		st.onCandle(c)
+	}
+}
+
+// Bars the strategy folds ticks into
+func (st *Supertrend) Bars() *Bars {
+	return st.bars
+}
+
+// Signals raised by the last tick
+func (st *Supertrend) Signals() []Signal {
+	signals := st.signals
+	st.signals = nil
+	return signals
+}
+
+func (st *Supertrend) State() map[string]any {
+	return map[string]any{
+		"candles":     len(st.candles),
+		"supertrend":  st.supertrend,
+		"last_signal": st.signal,
+	}
+}